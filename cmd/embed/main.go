@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"idea-collision-engine-api/internal/collision"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/pkg/config"
+)
+
+func main() {
+	all := flag.Bool("all", false, "re-embed every domain, ignoring cached vectors")
+	provider := flag.String("provider", "", "embedding provider (openai or local); defaults to EMBEDDING_PROVIDER")
+	flag.Usage = printHelp
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if *provider == "" {
+		*provider = cfg.EmbeddingProvider
+	}
+
+	embedder, err := collision.NewEmbedder(*provider, cfg.OpenAIAPIKey, cfg.EmbeddingModel)
+	if err != nil {
+		log.Fatalf("Failed to configure embeddings: %v", err)
+	}
+	if embedder == nil {
+		log.Fatalf("No embedding provider configured; set EMBEDDING_PROVIDER or pass --provider")
+	}
+
+	// Connect to database
+	db, err := database.NewPostgresDB(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	domains, err := db.GetCollisionDomains("premium") // Get all domains
+	if err != nil {
+		log.Fatalf("Failed to load collision domains: %v", err)
+	}
+
+	fmt.Printf("🧭 Embedding %d collision domains with %s...\n", len(domains), embedder.Model())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	index, embedded, err := collision.EmbedDomains(ctx, embedder, db, domains, *all)
+	if err != nil {
+		log.Fatalf("Embedding failed after %d domains: %v", embedded, err)
+	}
+
+	fmt.Printf("✅ Embedded %d domains (%d reused from cache)\n", embedded, index.Len()-embedded)
+}
+
+func printHelp() {
+	fmt.Fprint(os.Stderr, `Domain Embedding Utility
+
+Computes embeddings for the collision domain catalog and caches them in the
+embeddings table. By default only domains without a cached vector for the
+current model, or whose text changed since they were embedded, are embedded.
+
+Usage:
+  ./embed                  Embed new and changed domains
+  ./embed --all            Re-embed the whole catalog
+  ./embed --provider local Use the local hashing embedder
+  ./embed --help           Show this help message
+
+Environment Variables:
+  DATABASE_URL            PostgreSQL connection string (required)
+  EMBEDDING_PROVIDER      openai or local
+  EMBEDDING_MODEL         Model for the openai provider (default text-embedding-3-small)
+  OPENAI_API_KEY          Required for the openai provider
+`)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 
 	_ "github.com/lib/pq"
 
@@ -12,6 +14,9 @@ import (
 	"idea-collision-engine-api/pkg/config"
 )
 
+// initialMigration is the schema applied before migrations were tracked
+const initialMigration = "001_initial_schema.sql"
+
 func main() {
 	if len(os.Args) > 1 && os.Args[1] == "--help" {
 		printHelp()
@@ -74,27 +79,108 @@ Examples:
 }
 
 func runMigrations(db *sql.DB) error {
-	// Read migration file
-	migrationPath := "migrations/001_initial_schema.sql"
-	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
+	// Locate migrations directory
+	migrationsDir := "migrations"
+	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
 		// Try relative path from cmd/migrate
-		migrationPath = "../../migrations/001_initial_schema.sql"
+		migrationsDir = "../../migrations"
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	if err != nil {
+		return fmt.Errorf("failed to list migrations in %s: %w", migrationsDir, err)
+	}
+	sort.Strings(files)
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version VARCHAR(255) PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
 	}
 
-	content, err := os.ReadFile(migrationPath)
+	applied, err := appliedMigrations(db)
 	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %w", migrationPath, err)
+		return err
 	}
 
-	// Execute migration
-	if _, err := db.Exec(string(content)); err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+	for _, file := range files {
+		version := filepath.Base(file)
+		if applied[version] {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration file %s: %w", file, err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start transaction for %s: %w", version, err)
+		}
+
+		if _, err := tx.Exec(string(content)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute migration %s: %w", version, err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", version, err)
+		}
+
+		fmt.Printf("📋 Applied migration %s\n", version)
 	}
 
-	fmt.Println("📋 Applied initial schema migration")
 	return nil
 }
 
+// appliedMigrations returns the set of migration files already applied.
+// Databases created before migrations were tracked already contain the
+// initial schema, so it is recorded as applied.
+func appliedMigrations(db *sql.DB) (map[string]bool, error) {
+	applied := make(map[string]bool)
+
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 {
+		var usersExists bool
+		if err := db.QueryRow("SELECT to_regclass('public.users') IS NOT NULL").Scan(&usersExists); err != nil {
+			return nil, fmt.Errorf("failed to inspect existing schema: %w", err)
+		}
+		if usersExists {
+			if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", initialMigration); err != nil {
+				return nil, fmt.Errorf("failed to record initial migration: %w", err)
+			}
+			applied[initialMigration] = true
+		}
+	}
+
+	return applied, nil
+}
+
 func seedCollisionDomains(db *database.PostgresDB) error {
 	// Check if domains already exist
 	domains, err := db.GetCollisionDomains("basic")
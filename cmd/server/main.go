@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -17,8 +18,10 @@ import (
 	"idea-collision-engine-api/internal/collision"
 	"idea-collision-engine-api/internal/database"
 	"idea-collision-engine-api/internal/handlers"
+	"idea-collision-engine-api/internal/mail"
 	"idea-collision-engine-api/internal/middleware"
 	"idea-collision-engine-api/internal/models"
+	"idea-collision-engine-api/internal/realtime"
 	"idea-collision-engine-api/pkg/config"
 )
 
@@ -44,21 +47,100 @@ func main() {
 
 	// Initialize services
 	jwtService := auth.NewJWTService(cfg.JWTSecret)
-	aiService := collision.NewAIService(cfg.OpenAIAPIKey)
+	jwtService.SetAccessTokenTTL(time.Duration(cfg.AccessTokenTTLMinutes) * time.Minute)
+	if cfg.JWTAlgorithm != auth.AlgorithmHS256 {
+		if err := jwtService.UseSigningKeys(db, cfg.JWTAlgorithm, time.Duration(cfg.JWTKeyRotationDays)*24*time.Hour); err != nil {
+			log.Fatalf("Failed to load token signing keys: %v", err)
+		}
+		go rotateSigningKeys(jwtService)
+	}
+	prompts, err := loadPromptRegistry(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	resilience := collision.DefaultResilienceConfig()
+	resilience.MaxRetries = cfg.AIMaxRetries
+	resilience.FailureThreshold = cfg.AIBreakerThreshold
+	resilience.Cooldown = time.Duration(cfg.AIBreakerCooldown) * time.Second
+	blocklist := cfg.AIBlocklist
+	if cfg.AIBlocklistFile != "" {
+		terms, err := collision.LoadBlocklist(cfg.AIBlocklistFile)
+		if err != nil {
+			log.Fatalf("Failed to load AI blocklist: %v", err)
+		}
+		blocklist = append(blocklist, terms...)
+	}
+	mailer, err := mail.New(mail.Config{
+		Driver:       cfg.MailDriver,
+		From:         cfg.MailFrom,
+		Dir:          cfg.MailDir,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure mail: %v", err)
+	}
+	aiService := collision.NewAIService(cfg.OpenAIAPIKey, prompts, db, resilience, collision.NewQualityGate(blocklist))
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, redis, jwtService)
-	collisionHandler := handlers.NewCollisionHandler(db, redis, aiService)
-	subscriptionHandler := handlers.NewSubscriptionHandler(db, redis, cfg.StripeSecretKey)
+	authHandler := handlers.NewAuthHandler(db, redis, jwtService, mailer, cfg.AppURL, time.Duration(cfg.RefreshTokenTTLDays)*24*time.Hour)
+	collisionHandler := handlers.NewCollisionHandler(db, redis, aiService, cfg.AIMonthlyBudgets, cfg.AIDomainSuggestions)
+	subscriptionHandler := handlers.NewSubscriptionHandler(db, redis, cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+	adminHandler := handlers.NewAdminHandler(db, redis, aiService)
+	collectionHandler := handlers.NewCollectionHandler(db)
+	exportHandler := handlers.NewExportHandler(db)
+	shareHandler := handlers.NewShareHandler(db, redis, cfg.PublicURL)
+	accountHandler := handlers.NewAccountHandler(db, redis, mailer, cfg.AppURL, jwtService.AccessTokenTTL())
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+	teamHandler := handlers.NewTeamHandler(db, mailer, cfg.AppURL)
+	boardHandler := handlers.NewBoardHandler(db, redis)
+	boardEvents := realtime.NewHub()
+	go boardEvents.Run(redis.SubscribeBoardEvents(context.Background()))
+	liveHandler := handlers.NewLiveHandler(db, redis, boardEvents, cfg.CORSOrigins)
+	
+	if cfg.OIDCDiscoveryURL != "" {
+		provider, err := auth.NewOIDCProvider(auth.OIDCConfig{
+			Name:         cfg.OIDCProviderName,
+			DiscoveryURL: cfg.OIDCDiscoveryURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}, nil)
+		if err != nil {
+			log.Fatalf("Failed to configure OIDC sign-in: %v", err)
+		}
+		authHandler.UseOIDC(provider)
+	}
+
+	if err := grantAdminRoles(db, cfg.AdminEmails); err != nil {
+		log.Printf("Warning: Failed to grant admin roles: %v", err)
+	}
 
 	// Initialize collision engine with domains
 	if err := seedCollisionDomains(db); err != nil {
 		log.Printf("Warning: Failed to seed collision domains: %v", err)
 	}
 
+	embedder, err := collision.NewEmbedder(cfg.EmbeddingProvider, cfg.OpenAIAPIKey, cfg.EmbeddingModel)
+	if err != nil {
+		log.Fatalf("Failed to configure embeddings: %v", err)
+	}
+	if embedder != nil {
+		collisionHandler.UseEmbeddings(embedder, cfg.EmbeddingMinSimilarity)
+	}
+
 	if err := collisionHandler.Initialize(); err != nil {
 		log.Fatalf("Failed to initialize collision handler: %v", err)
 	}
+	adminHandler.OnDomainsChanged(collisionHandler.Initialize)
+
+	trashRetention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+	collisionHandler.SetTrashRetention(trashRetention)
+	go purgeTrash(db, trashRetention)
+	go purgeExpiredTokens(db)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -78,7 +160,7 @@ func main() {
 	// CORS middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSOrigins[0],
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
 		AllowCredentials: true,
 	}))
@@ -90,9 +172,16 @@ func main() {
 			"service":   "idea-collision-engine-api",
 			"version":   "1.0.0",
 			"timestamp": time.Now(),
+			"ai_circuit_breaker": aiService.BreakerState(),
 		})
 	})
 
+	// Metrics endpoint
+	app.Get("/metrics", collisionHandler.Metrics)
+
+	// Public keys that validate our tokens, for other services
+	app.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API routes
 	api := app.Group("/api")
 
@@ -100,10 +189,35 @@ func main() {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
-	auth.Get("/profile", middleware.AuthMiddleware(jwtService), authHandler.GetProfile)
-	auth.Put("/profile", middleware.AuthMiddleware(jwtService), authHandler.UpdateProfile)
+	auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/logout", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession(), authHandler.Logout)
+	auth.Post("/logout-all", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession(), authHandler.LogoutAll)
+	auth.Post("/password/forgot", authHandler.ForgotPassword)
+	auth.Post("/password/reset", authHandler.ResetPassword)
+	auth.Post("/email/verify", authHandler.VerifyEmail)
+	auth.Post("/email/verify/resend", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession(), authHandler.ResendVerification)
+	if cfg.OIDCDiscoveryURL != "" {
+		auth.Get("/oidc/login", authHandler.OIDCLogin)
+		auth.Get("/oidc/callback", authHandler.OIDCCallback)
+	}
+	auth.Get("/profile", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession(), authHandler.GetProfile)
+	auth.Put("/profile", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession(), authHandler.UpdateProfile)
+
+	// Account management routes. Confirming an email change only needs the mailed
+	// token, so it works without signing in on the device the link is opened on.
+	// The rest manage credentials, so they refuse API keys.
+	api.Post("/account/email/confirm", accountHandler.ConfirmEmailChange)
+	account := api.Group("/account", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession())
+	account.Post("/email", accountHandler.RequestEmailChange)
+	account.Put("/password", accountHandler.ChangePassword)
+	account.Delete("/", accountHandler.DeleteAccount)
+	account.Get("/api-keys", apiKeyHandler.ListAPIKeys)
+	account.Post("/api-keys", apiKeyHandler.CreateAPIKey)
+	account.Delete("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
 
-	// Collision routes
+	// Collision routes. Generating, following up and sharing need a verified
+	// email address; reading history doesn't, so new accounts aren't locked out.
+	// API keys also need the scope named on each route.
 	collisions := api.Group("/collisions")
 	
 	// Rate limiting for collision generation
@@ -114,34 +228,238 @@ func main() {
 	}
 	
 	collisions.Post("/generate", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeCollisionsWrite),
+		middleware.RequireVerifiedEmail(),
 		middleware.UsageLimitMiddleware(db, redis),
 		middleware.RateLimitMiddleware(redis, rateLimitConfig),
 		collisionHandler.GenerateCollision,
 	)
 	
 	collisions.Get("/history", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
 		collisionHandler.GetCollisionHistory,
 	)
 	
 	collisions.Put("/:id/rate", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
 		collisionHandler.RateCollision,
 	)
 	
 	collisions.Get("/usage", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
 		collisionHandler.GetUsageStatus,
 	)
 	
+	collisions.Get("/usage/ai", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		collisionHandler.GetAIUsage,
+	)
+	
 	collisions.Get("/health", collisionHandler.HealthCheck)
+	
+	collisions.Get("/export", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		exportHandler.ExportHistory,
+	)
+	
+	// Single-session routes come after the static paths above so /:id doesn't shadow them
+	collisions.Get("/trash", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		collisionHandler.GetTrash,
+	)
+	
+	collisions.Get("/:id", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		collisionHandler.GetCollisionSession,
+	)
+	
+	collisions.Patch("/:id", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collisionHandler.UpdateCollisionSession,
+	)
+	
+	collisions.Delete("/:id", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collisionHandler.DeleteCollisionSession,
+	)
+	
+	collisions.Post("/:id/restore", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collisionHandler.RestoreCollisionSession,
+	)
+	
+	collisions.Get("/:id/export", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		exportHandler.ExportCollision,
+	)
+	
+	collisions.Post("/:id/share", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		middleware.RequireVerifiedEmail(),
+		shareHandler.CreateShareLink,
+	)
+	
+	collisions.Get("/:id/share", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		shareHandler.ListShareLinks,
+	)
+	
+	collisions.Delete("/:id/share/:linkId", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		shareHandler.RevokeShareLink,
+	)
+	
+	// Follow-ups create child sessions and count toward usage like new collisions
+	collisions.Post("/:id/deepen", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeCollisionsWrite),
+		middleware.RequireVerifiedEmail(),
+		middleware.UsageLimitMiddleware(db, redis),
+		middleware.RateLimitMiddleware(redis, rateLimitConfig),
+		collisionHandler.DeepenCollision,
+	)
+	
+	collisions.Post("/:id/pivot", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeCollisionsWrite),
+		middleware.RequireVerifiedEmail(),
+		middleware.UsageLimitMiddleware(db, redis),
+		middleware.RateLimitMiddleware(redis, rateLimitConfig),
+		collisionHandler.PivotCollision,
+	)
+	
+	collisions.Post("/:id/intensify", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeCollisionsWrite),
+		middleware.RequireVerifiedEmail(),
+		middleware.UsageLimitMiddleware(db, redis),
+		middleware.RateLimitMiddleware(redis, rateLimitConfig),
+		collisionHandler.IntensifyCollision,
+	)
+	
+	collisions.Get("/:id/tree", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		collisionHandler.GetCollisionTree,
+	)
+	
+	collisions.Put("/:id/favorite", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collectionHandler.FavoriteCollision,
+	)
+	
+	collisions.Delete("/:id/favorite", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collectionHandler.UnfavoriteCollision,
+	)
+	
+	collisions.Put("/:id/tags", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryWrite),
+		collectionHandler.SetCollisionTags,
+	)
+
+	// Public share routes: JSON for the frontend and a page with OpenGraph tags for link previews
+	api.Get("/share/:token", shareHandler.GetSharedCollision)
+	app.Get("/share/:token", shareHandler.SharePage)
 
-	// Domain routes
+	// Tag and collection routes
+	api.Get("/tags", 
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeHistoryRead),
+		collectionHandler.ListTags,
+	)
+	
+	collections := api.Group("/collections", middleware.AuthMiddleware(jwtService, db, redis))
+	collections.Get("/", middleware.RequireScope(models.ScopeCollectionsRead), collectionHandler.ListCollections)
+	collections.Post("/", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.CreateCollection)
+	collections.Get("/:id", middleware.RequireScope(models.ScopeCollectionsRead), collectionHandler.GetCollection)
+	collections.Patch("/:id", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.UpdateCollection)
+	collections.Delete("/:id", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.DeleteCollection)
+	collections.Post("/:id/items", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.AddCollectionItem)
+	collections.Put("/:id/items", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.ReorderCollection)
+	collections.Delete("/:id/items/:sessionId", middleware.RequireScope(models.ScopeCollectionsWrite), collectionHandler.RemoveCollectionItem)
+
+	// Team routes. Routes under /:teamId check membership and the role named on
+	// each route. API keys can read and share to team history; managing teams
+	// needs a session.
+	teams := api.Group("/teams", middleware.WebSocketToken(), middleware.AuthMiddleware(jwtService, db, redis))
+	teams.Get("/", middleware.RequireSession(), teamHandler.ListTeams)
+	teams.Post("/", middleware.RequireSession(), middleware.RequireVerifiedEmail(), teamHandler.CreateTeam)
+	teams.Post("/invitations/accept", middleware.RequireSession(), middleware.RequireVerifiedEmail(), teamHandler.AcceptInvitation)
+	teams.Get("/:teamId", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleMember), teamHandler.GetTeam)
+	teams.Patch("/:teamId", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleAdmin), teamHandler.UpdateTeam)
+	teams.Delete("/:teamId", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleOwner), teamHandler.DeleteTeam)
+	teams.Put("/:teamId/members/:userId", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleOwner), teamHandler.UpdateMemberRole)
+	teams.Delete("/:teamId/members/:userId", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleMember), teamHandler.RemoveMember)
+	teams.Get("/:teamId/invitations", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleAdmin), teamHandler.ListInvitations)
+	teams.Post("/:teamId/invitations", middleware.RequireSession(), middleware.RequireVerifiedEmail(), middleware.RequireTeamRole(db, models.TeamRoleAdmin), teamHandler.InviteMember)
+	teams.Delete("/:teamId/invitations/:id", middleware.RequireSession(), middleware.RequireTeamRole(db, models.TeamRoleAdmin), teamHandler.RevokeInvitation)
+	teams.Get("/:teamId/history", middleware.RequireScope(models.ScopeHistoryRead), middleware.RequireTeamRole(db, models.TeamRoleMember), teamHandler.GetTeamHistory)
+	teams.Get("/:teamId/ai-usage", middleware.RequireScope(models.ScopeHistoryRead), middleware.RequireTeamRole(db, models.TeamRoleOwner), collisionHandler.GetTeamAIUsage)
+	teams.Put("/:teamId/sessions/:sessionId", middleware.RequireScope(models.ScopeHistoryWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), teamHandler.ShareSession)
+	teams.Delete("/:teamId/sessions/:sessionId", middleware.RequireScope(models.ScopeHistoryWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), teamHandler.UnshareSession)
+
+	// Team boards. Any member can contribute; the handler checks who may change
+	// or remove someone else's work.
+	teams.Get("/:teamId/boards", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.ListBoards)
+	teams.Post("/:teamId/boards", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.CreateBoard)
+	teams.Get("/:teamId/boards/:boardId", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.GetBoard)
+	teams.Patch("/:teamId/boards/:boardId", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.UpdateBoard)
+	teams.Delete("/:teamId/boards/:boardId", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.DeleteBoard)
+	teams.Get("/:teamId/boards/:boardId/shortlist", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.GetShortlist)
+	teams.Post("/:teamId/boards/:boardId/items", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.AddItem)
+	teams.Delete("/:teamId/boards/:boardId/items/:itemId", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.RemoveItem)
+	teams.Put("/:teamId/boards/:boardId/items/:itemId/vote", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.Vote)
+	teams.Delete("/:teamId/boards/:boardId/items/:itemId/vote", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.Unvote)
+	teams.Put("/:teamId/boards/:boardId/items/:itemId/reactions/:reaction", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.React)
+	teams.Delete("/:teamId/boards/:boardId/items/:itemId/reactions/:reaction", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.Unreact)
+	teams.Get("/:teamId/boards/:boardId/items/:itemId/comments", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.ListComments)
+	teams.Post("/:teamId/boards/:boardId/items/:itemId/comments", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.AddComment)
+	teams.Patch("/:teamId/boards/:boardId/items/:itemId/comments/:commentId", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.UpdateComment)
+	teams.Delete("/:teamId/boards/:boardId/items/:itemId/comments/:commentId", middleware.RequireScope(models.ScopeCollectionsWrite), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.DeleteComment)
+	teams.Get("/:teamId/activity", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), boardHandler.GetActivity)
+
+	// Live board updates over a WebSocket. Browsers pass the access token as
+	// ?access_token=, as they can't set headers on the handshake.
+	teams.Get("/:teamId/live", middleware.RequireScope(models.ScopeCollectionsRead), middleware.RequireTeamRole(db, models.TeamRoleMember), liveHandler.Connect)
+
+	// Group brainstorming sessions. Hosting takes a premium plan; anyone signed
+	// in can join with the code.
+	groups := api.Group("/groups", middleware.AuthMiddleware(jwtService, db, redis), middleware.RequireSession())
+	groups.Get("/", collisionHandler.ListGroupSessions)
+	groups.Post("/", middleware.RequireVerifiedEmail(), middleware.RequirePremium(), collisionHandler.CreateGroupSession)
+	groups.Post("/join", collisionHandler.JoinGroupSession)
+	groups.Get("/:id", collisionHandler.GetGroupSession)
+	groups.Post("/:id/generate", collisionHandler.GenerateGroupCollisions)
+	groups.Post("/:id/close", collisionHandler.CloseGroupSession)
+	groups.Put("/:id/collisions/:collisionId/vote", collisionHandler.VoteGroupCollision)
+	groups.Delete("/:id/collisions/:collisionId/vote", collisionHandler.UnvoteGroupCollision)
+
+	// Domain routes. Premium domains feed collision generation, so API keys need
+	// the same scope.
 	domains := api.Group("/domains")
 	domains.Get("/basic", collisionHandler.GetBasicDomains)
 	domains.Get("/premium", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireScope(models.ScopeCollisionsWrite),
 		middleware.RequirePremium(),
 		collisionHandler.GetPremiumDomains,
 	)
@@ -150,19 +468,48 @@ func main() {
 	subscriptions := api.Group("/subscriptions")
 	subscriptions.Get("/plans", subscriptionHandler.GetPricingPlans)
 	subscriptions.Post("/checkout", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireSession(),
+		middleware.RequireVerifiedEmail(),
 		subscriptionHandler.CreateCheckoutSession,
 	)
 	subscriptions.Get("/status", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireSession(),
 		subscriptionHandler.GetSubscriptionStatus,
 	)
 	subscriptions.Post("/cancel", 
-		middleware.AuthMiddleware(jwtService),
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireSession(),
 		subscriptionHandler.CancelSubscription,
 	)
 	subscriptions.Post("/webhook", subscriptionHandler.WebhookHandler)
 
+	// Admin routes. Support staff can look users up and reset their usage;
+	// everything else takes an admin.
+	staff := middleware.RequireRole(models.RoleSupport, models.RoleAdmin)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	admin := api.Group("/admin",
+		middleware.AuthMiddleware(jwtService, db, redis),
+		middleware.RequireSession(),
+	)
+	admin.Get("/users", staff, adminHandler.SearchUsers)
+	admin.Get("/users/:id", staff, adminHandler.GetUser)
+	admin.Put("/users/:id/tier", adminOnly, adminHandler.SetUserTier)
+	admin.Put("/users/:id/role", adminOnly, adminHandler.SetUserRole)
+	admin.Post("/users/:id/usage/reset", staff, adminHandler.ResetUsage)
+	admin.Get("/audit", adminOnly, adminHandler.ListAuditLog)
+	admin.Get("/domains", adminOnly, adminHandler.ListDomains)
+	admin.Post("/domains", adminOnly, adminHandler.CreateDomain)
+	admin.Put("/domains/:id", adminOnly, adminHandler.UpdateDomain)
+	admin.Delete("/domains/:id", adminOnly, adminHandler.DeleteDomain)
+	admin.Get("/prompts", adminOnly, adminHandler.ListPrompts)
+	admin.Post("/prompts/preview", adminOnly, adminHandler.PreviewPrompt)
+	admin.Get("/ai-usage", adminOnly, adminHandler.GetAIUsage)
+	admin.Get("/domain-suggestions", adminOnly, adminHandler.ListDomainSuggestions)
+	admin.Post("/domain-suggestions/:id/approve", adminOnly, adminHandler.ApproveDomainSuggestion)
+	admin.Post("/domain-suggestions/:id/reject", adminOnly, adminHandler.RejectDomainSuggestion)
+
 	// Documentation routes
 	docsHandler := handlers.NewDocsHandler()
 	docs := app.Group("/docs")
@@ -195,6 +542,67 @@ func main() {
 	fmt.Println("✅ Server stopped")
 }
 
+// purgeTrash permanently deletes collision sessions that have been in the trash
+// longer than the retention period, at startup and then once a day
+func purgeTrash(db *database.PostgresDB, retention time.Duration) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	
+	for {
+		purged, err := db.PurgeDeletedCollisionSessions(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Warning: Failed to purge deleted collision sessions: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted collision sessions", purged)
+		}
+		<-ticker.C
+	}
+}
+
+// purgeExpiredTokens deletes expired refresh tokens and records of used action
+// tokens at startup and then once a day
+func purgeExpiredTokens(db *database.PostgresDB) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	
+	for {
+		purged, err := db.PurgeExpiredRefreshTokens(time.Now())
+		if err != nil {
+			log.Printf("Warning: Failed to purge expired refresh tokens: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d expired refresh tokens", purged)
+		}
+		
+		purged, err = db.PurgeUsedActionTokens(time.Now())
+		if err != nil {
+			log.Printf("Warning: Failed to purge used action tokens: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d used action tokens", purged)
+		}
+		
+		purged, err = db.PurgeExpiredSigningKeys(time.Now())
+		if err != nil {
+			log.Printf("Warning: Failed to purge expired signing keys: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d expired signing keys", purged)
+		}
+		<-ticker.C
+	}
+}
+
+// rotateSigningKeys picks up keys created by other instances and creates the
+// next signing key ahead of the current one retiring
+func rotateSigningKeys(jwtService *auth.JWTService) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	
+	for range ticker.C {
+		if err := jwtService.RotateKeys(); err != nil {
+			log.Printf("Warning: Failed to rotate token signing keys: %v", err)
+		}
+	}
+}
+
 // errorHandler handles application errors
 func errorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
@@ -210,6 +618,34 @@ func errorHandler(c *fiber.Ctx, err error) error {
 	})
 }
 
+// grantAdminRoles makes the verified accounts listed in ADMIN_EMAILS admins,
+// so a new deployment has someone to hand out roles. It never takes a role
+// away; do that through the admin API.
+func grantAdminRoles(db *database.PostgresDB, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	
+	granted, err := db.GrantRoleByEmail(emails, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	
+	for _, user := range granted {
+		log.Printf("Granted the admin role to %s from ADMIN_EMAILS", user.Email)
+		userID := user.ID
+		entry := &models.AuditEntry{
+			UserID:  &userID,
+			Action:  models.AuditRoleChanged,
+			Details: map[string]interface{}{"to": models.RoleAdmin, "reason": "listed in ADMIN_EMAILS"},
+		}
+		if err := db.CreateAuditEntry(entry); err != nil {
+			log.Printf("Failed to write admin role grant to the audit log: %v", err)
+		}
+	}
+	return nil
+}
+
 // seedCollisionDomains populates the database with collision domains if empty
 func seedCollisionDomains(db *database.PostgresDB) error {
 	// Check if domains already exist
@@ -234,4 +670,34 @@ func seedCollisionDomains(db *database.PostgresDB) error {
 
 	fmt.Printf("✅ Seeded %d collision domains\n", len(seedDomains))
 	return nil
-}
\ No newline at end of file
+}
+
+// loadPromptRegistry builds the prompt registry from embedded defaults,
+// then applies overrides from PROMPT_TEMPLATES_DIR and the prompt_templates table
+func loadPromptRegistry(cfg *config.Config, db *database.PostgresDB) (*collision.PromptRegistry, error) {
+	prompts, err := collision.NewPromptRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.PromptTemplatesDir != "" {
+		if err := prompts.LoadDir(cfg.PromptTemplatesDir); err != nil {
+			return nil, err
+		}
+	}
+
+	templates, err := db.GetPromptTemplates()
+	if err != nil {
+		// Embedded prompts still work without the table
+		log.Printf("Warning: Failed to load prompt templates from database: %v", err)
+		return prompts, nil
+	}
+
+	for _, t := range templates {
+		if err := prompts.Register(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return prompts, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyMarker starts every API key, so keys are recognizable in headers,
+// config files and secret scanners
+const APIKeyMarker = "ice_"
+
+// apiKeyPrefixBytes is the randomness in a key's public prefix, which
+// identifies the key without revealing its secret
+const apiKeyPrefixBytes = 6
+
+// NewAPIKey returns a new API key and its prefix. Keys look like
+// ice_<prefix>_<secret>; only the prefix and a hash of the whole key are stored.
+func NewAPIKey() (key, prefix string, err error) {
+	buf := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = APIKeyMarker + hex.EncodeToString(buf)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyMarker)
+}
+
+// APIKeyPrefix returns the public prefix of an API key, or false if the key is
+// malformed
+func APIKeyPrefix(key string) (string, bool) {
+	prefixLength := len(APIKeyMarker) + 2*apiKeyPrefixBytes
+	if !IsAPIKey(key) || len(key) <= prefixLength+1 || key[prefixLength] != '_' {
+		return "", false
+	}
+	return key[:prefixLength], true
+}
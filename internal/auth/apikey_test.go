@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(key))
+	assert.Len(t, prefix, len(APIKeyMarker)+12)
+
+	parsed, ok := APIKeyPrefix(key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, parsed)
+
+	other, _, err := NewAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAPIKeyPrefixRejectsMalformedKeys(t *testing.T) {
+	for _, key := range []string{"", "ice_", "ice_0123456789ab", "ice_0123456789ab_", "ice_0123456789abc_secret", "eyJhbGciOiJIUzI1NiJ9.e30.sig"} {
+		_, ok := APIKeyPrefix(key)
+		assert.False(t, ok, key)
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,56 +12,110 @@ import (
 	"idea-collision-engine-api/internal/models"
 )
 
+// DefaultAccessTokenTTL is how long access tokens live. They're short lived
+// because clients renew them with a refresh token.
+const DefaultAccessTokenTTL = 15 * time.Minute
+
 type JWTService struct {
 	secretKey []byte
+	accessTTL time.Duration
+	keys      *keyring // rotating key pairs; tokens are signed with secretKey (HS256) without them
 }
 
 type Claims struct {
 	UserID           uuid.UUID `json:"user_id"`
 	Email            string    `json:"email"`
 	SubscriptionTier string    `json:"subscription_tier"`
+	EmailVerified    bool      `json:"email_verified"`
 	jwt.RegisteredClaims
 }
 
 func NewJWTService(secretKey string) *JWTService {
 	return &JWTService{
 		secretKey: []byte(secretKey),
+		accessTTL: DefaultAccessTokenTTL,
 	}
 }
 
+// SetAccessTokenTTL changes how long newly generated access tokens live
+func (j *JWTService) SetAccessTokenTTL(ttl time.Duration) {
+	j.accessTTL = ttl
+}
+
+// AccessTokenTTL returns how long newly generated access tokens live
+func (j *JWTService) AccessTokenTTL() time.Duration {
+	return j.accessTTL
+}
+
 // Generate JWT token for user
 func (j *JWTService) GenerateToken(user *models.User) (string, error) {
 	claims := &Claims{
 		UserID:           user.ID,
 		Email:            user.Email,
 		SubscriptionTier: user.SubscriptionTier,
+		EmailVerified:    user.EmailVerifiedAt != nil,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 hours
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "idea-collision-engine",
 			Subject:   user.ID.String(),
+			ID:        uuid.NewString(), // lets a single token be revoked
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.sign(claims)
 }
 
-// Validate and parse JWT token
-func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+// sign signs claims with the current signing key, naming it in the kid
+// header, or with the secret in HS256 mode
+func (j *JWTService) sign(claims jwt.Claims) (string, error) {
+	if j.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(j.secretKey)
+	}
+
+	key, err := j.keys.current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.algorithm), claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// keyFunc returns the key to check a token's signature with. Tokens must use
+// the algorithm of the key they name, so an HS256 token can't pass off a
+// public key as its secret.
+func (j *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	if j.keys == nil {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return j.secretKey, nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := j.keys.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
+
+// Validate and parse JWT token
+func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keyFunc)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	// Action tokens have an audience; access tokens never do
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
 		return claims, nil
 	}
 
@@ -88,7 +143,24 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// dummyPasswordHash is hashed at the default cost, like real passwords
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// CheckPasswordAgainstDummy takes as long as checking a password against a real
+// hash, and always fails. Use it when there's no account to check, so response
+// times don't reveal which accounts exist.
+func CheckPasswordAgainstDummy(password string) bool {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+	return false
+}
+
 // Generate refresh token (longer lived)
+//
+// Deprecated: API refresh tokens are opaque, rotated and stored hashed; see
+// NewOpaqueToken and the refresh_tokens table.
 func (j *JWTService) GenerateRefreshToken(userID uuid.UUID) (string, error) {
 	claims := &jwt.RegisteredClaims{
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
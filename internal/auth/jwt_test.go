@@ -192,8 +192,8 @@ func (suite *JWTServiceTestSuite) TestClaimsExpiration() {
 	claims, err := suite.jwtService.ValidateToken(token)
 	assert.NoError(suite.T(), err)
 	
-	// Token should expire in approximately 24 hours
-	expectedExpiration := time.Now().Add(24 * time.Hour)
+	// Token should expire after the default access token lifetime
+	expectedExpiration := time.Now().Add(DefaultAccessTokenTTL)
 	actualExpiration := claims.ExpiresAt.Time
 	
 	// Allow 1 minute tolerance for test execution time
@@ -202,6 +202,73 @@ func (suite *JWTServiceTestSuite) TestClaimsExpiration() {
 	assert.Greater(suite.T(), timeDiff, -time.Minute)
 }
 
+func (suite *JWTServiceTestSuite) TestSetAccessTokenTTL() {
+	suite.jwtService.SetAccessTokenTTL(time.Hour)
+	assert.Equal(suite.T(), time.Hour, suite.jwtService.AccessTokenTTL())
+
+	token, err := suite.jwtService.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	claims, err := suite.jwtService.ValidateToken(token)
+	assert.NoError(suite.T(), err)
+	assert.WithinDuration(suite.T(), time.Now().Add(time.Hour), claims.ExpiresAt.Time, time.Minute)
+}
+
+func (suite *JWTServiceTestSuite) TestTokensHaveUniqueIDs() {
+	first, err := suite.jwtService.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	second, err := suite.jwtService.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	firstClaims, err := suite.jwtService.ValidateToken(first)
+	assert.NoError(suite.T(), err)
+	secondClaims, err := suite.jwtService.ValidateToken(second)
+	assert.NoError(suite.T(), err)
+
+	assert.NotEmpty(suite.T(), firstClaims.ID)
+	assert.NotEqual(suite.T(), firstClaims.ID, secondClaims.ID)
+}
+
+func (suite *JWTServiceTestSuite) TestActionToken() {
+	binding := TokenBinding("$2a$10$hash")
+	token, err := suite.jwtService.GenerateActionToken(suite.testUser.ID, PurposePasswordReset, binding, time.Hour)
+	assert.NoError(suite.T(), err)
+	
+	claims, err := suite.jwtService.ValidateActionToken(token, PurposePasswordReset)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), binding, claims.Binding)
+	assert.NotEmpty(suite.T(), claims.ID)
+	
+	userID, err := claims.UserID()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.testUser.ID, userID)
+}
+
+func (suite *JWTServiceTestSuite) TestActionTokenPurposeIsChecked() {
+	token, err := suite.jwtService.GenerateActionToken(suite.testUser.ID, PurposeEmailVerification, "binding", time.Hour)
+	assert.NoError(suite.T(), err)
+	
+	_, err = suite.jwtService.ValidateActionToken(token, PurposePasswordReset)
+	assert.Error(suite.T(), err)
+	
+	// Action tokens aren't access tokens, and access tokens aren't action tokens
+	_, err = suite.jwtService.ValidateToken(token)
+	assert.Error(suite.T(), err)
+	
+	accessToken, err := suite.jwtService.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	_, err = suite.jwtService.ValidateActionToken(accessToken, PurposeEmailVerification)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *JWTServiceTestSuite) TestExpiredActionToken() {
+	token, err := suite.jwtService.GenerateActionToken(suite.testUser.ID, PurposePasswordReset, "binding", -time.Minute)
+	assert.NoError(suite.T(), err)
+	
+	_, err = suite.jwtService.ValidateActionToken(token, PurposePasswordReset)
+	assert.Error(suite.T(), err)
+}
+
 // Password hashing tests
 func (suite *JWTServiceTestSuite) TestHashPassword() {
 	password := "test-password-123"
@@ -249,6 +316,30 @@ func (suite *JWTServiceTestSuite) TestHashPasswordConsistency() {
 	assert.True(suite.T(), CheckPasswordHash(password, hash2))
 }
 
+func (suite *JWTServiceTestSuite) TestNewOpaqueToken() {
+	token, err := NewOpaqueToken()
+	assert.NoError(suite.T(), err)
+	assert.NotContains(suite.T(), token, "=")
+	assert.NotContains(suite.T(), token, "/")
+	assert.NotContains(suite.T(), token, "+")
+
+	other, err := NewOpaqueToken()
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), token, other)
+}
+
+func (suite *JWTServiceTestSuite) TestHashToken() {
+	hash := HashToken("token")
+	assert.Len(suite.T(), hash, 64)
+	assert.Equal(suite.T(), hash, HashToken("token"))
+	assert.NotEqual(suite.T(), hash, HashToken("token2"))
+}
+
+func (suite *JWTServiceTestSuite) TestCheckPasswordAgainstDummy() {
+	assert.False(suite.T(), CheckPasswordAgainstDummy("not a real password"))
+	assert.False(suite.T(), CheckPasswordAgainstDummy(""))
+}
+
 // Benchmark tests
 func BenchmarkGenerateToken(b *testing.B) {
 	jwtService := NewJWTService("benchmark-secret")
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// Algorithms tokens can be signed with. HS256 uses the shared secret; the
+// others use rotating key pairs whose public halves are published as a JWKS.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// RetiredKeyGrace is how long a signing key keeps validating tokens after it
+// stops signing new ones. It outlasts every token we issue; email verification
+// links live longest, at 48 hours.
+const RetiredKeyGrace = 72 * time.Hour
+
+const (
+	// keyPublishLead is how long before it signs anything a new key is
+	// published, so every instance and JWKS consumer has it by then
+	keyPublishLead = time.Hour
+	// unknownKeyReloadInterval limits how often tokens with an unknown key ID
+	// make us reload keys from the store
+	unknownKeyReloadInterval = 10 * time.Second
+	// rsaKeyBits is the size of generated RSA keys
+	rsaKeyBits = 2048
+)
+
+// KeyStore keeps signing keys where every API instance can load them
+type KeyStore interface {
+	// GetSigningKeys returns the keys that still validate tokens at the given time
+	GetSigningKeys(at time.Time) ([]models.SigningKey, error)
+	CreateSigningKey(key *models.SigningKey) error
+}
+
+type signingKey struct {
+	id          string
+	algorithm   string
+	private     crypto.Signer
+	activatesAt time.Time
+	retiresAt   time.Time
+	expiresAt   time.Time
+}
+
+// keyring holds the signing keys loaded from a KeyStore. Private keys are
+// encrypted at rest with a key derived from the JWT secret.
+type keyring struct {
+	store     KeyStore
+	algorithm string
+	rotation  time.Duration
+	aead      cipher.AEAD
+
+	mu       sync.RWMutex
+	keys     map[string]*signingKey
+	loadedAt time.Time
+}
+
+// UseSigningKeys switches the service from the shared secret to key pairs from
+// store, signing with algorithm and starting a new key every rotation. Older
+// keys keep validating until the tokens they signed have expired. The secret
+// is still needed: it encrypts the private keys in the store.
+func (j *JWTService) UseSigningKeys(store KeyStore, algorithm string, rotation time.Duration) error {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if rotation <= keyPublishLead {
+		return fmt.Errorf("key rotation period must be longer than %s", keyPublishLead)
+	}
+
+	// Domain-separated, so the encryption key differs from the HS256 key
+	sum := sha256.Sum256(append([]byte("idea-collision-engine signing keys\x00"), j.secretKey...))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	j.keys = &keyring{
+		store:     store,
+		algorithm: algorithm,
+		rotation:  rotation,
+		aead:      aead,
+		keys:      map[string]*signingKey{},
+	}
+	return j.RotateKeys()
+}
+
+// RotateKeys reloads signing keys from the store and, when the current key is
+// within an hour of retiring, creates its successor. Run it periodically; it's
+// safe to run on every instance.
+func (j *JWTService) RotateKeys() error {
+	if j.keys == nil {
+		return nil
+	}
+	return j.keys.rotate()
+}
+
+func (r *keyring) rotate() error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.mu.RLock()
+	var latestRetirement time.Time
+	for _, key := range r.keys {
+		if key.algorithm == r.algorithm && key.retiresAt.After(latestRetirement) {
+			latestRetirement = key.retiresAt
+		}
+	}
+	r.mu.RUnlock()
+
+	if latestRetirement.After(now.Add(keyPublishLead)) {
+		return nil
+	}
+
+	// The successor starts signing when the current key retires. With no
+	// usable key it has to start right away.
+	activatesAt := latestRetirement
+	if activatesAt.Before(now) {
+		activatesAt = now
+	}
+	if err := r.create(activatesAt); err != nil {
+		return err
+	}
+	return r.reload()
+}
+
+// create generates a key that signs from activatesAt for one rotation period
+func (r *keyring) create(activatesAt time.Time) error {
+	var private crypto.Signer
+	var err error
+	switch r.algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	kid := hex.EncodeToString(id)
+
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	retiresAt := activatesAt.Add(r.rotation)
+	return r.store.CreateSigningKey(&models.SigningKey{
+		ID:          kid,
+		Algorithm:   r.algorithm,
+		PrivateKey:  r.aead.Seal(nonce, nonce, der, []byte(kid)),
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(RetiredKeyGrace),
+	})
+}
+
+// reload replaces the loaded keys with the store's
+func (r *keyring) reload() error {
+	stored, err := r.store.GetSigningKeys(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make(map[string]*signingKey, len(stored))
+	for _, s := range stored {
+		key, err := r.decode(s)
+		if err != nil {
+			// A key sealed under another JWT secret can't be used; its
+			// successor takes over
+			log.Printf("Skipping signing key %s: %v", s.ID, err)
+			continue
+		}
+		keys[key.id] = key
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *keyring) decode(stored models.SigningKey) (*signingKey, error) {
+	nonceSize := r.aead.NonceSize()
+	if len(stored.PrivateKey) < nonceSize {
+		return nil, fmt.Errorf("private key is truncated")
+	}
+	der, err := r.aead.Open(nil, stored.PrivateKey[:nonceSize], stored.PrivateKey[nonceSize:], []byte(stored.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key, was JWT_SECRET changed? %w", err)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	var private crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if stored.Algorithm == AlgorithmRS256 {
+			private = key
+		}
+	case ed25519.PrivateKey:
+		if stored.Algorithm == AlgorithmEdDSA {
+			private = key
+		}
+	}
+	if private == nil {
+		return nil, fmt.Errorf("private key doesn't match algorithm %s", stored.Algorithm)
+	}
+
+	return &signingKey{
+		id:          stored.ID,
+		algorithm:   stored.Algorithm,
+		private:     private,
+		activatesAt: stored.ActivatesAt,
+		retiresAt:   stored.RetiresAt,
+		expiresAt:   stored.ExpiresAt,
+	}, nil
+}
+
+// current returns the key to sign new tokens with: the one activated most
+// recently. Keys published ahead of time aren't used until they activate.
+func (r *keyring) current() (*signingKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	var current *signingKey
+	for _, key := range r.keys {
+		if key.activatesAt.After(now) || !key.expiresAt.After(now) {
+			continue
+		}
+		if current == nil || key.activatesAt.After(current.activatesAt) ||
+			(key.activatesAt.Equal(current.activatesAt) && key.id > current.id) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no signing key is active")
+	}
+	return current, nil
+}
+
+// lookup returns the key with the given ID for checking a signature. Another
+// instance may have created a key we haven't loaded yet, so an unknown ID
+// triggers a reload, at most every few seconds.
+func (r *keyring) lookup(kid string) (*signingKey, error) {
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	loadedAt := r.loadedAt
+	r.mu.RUnlock()
+
+	if !ok && time.Since(loadedAt) >= unknownKeyReloadInterval {
+		if err := r.reload(); err != nil {
+			return nil, err
+		}
+		r.mu.RLock()
+		key, ok = r.keys[kid]
+		r.mu.RUnlock()
+	}
+
+	if !ok || !key.expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517)
+type JWKSet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys that validate our tokens, including keys
+// published ahead of their use. It's empty when tokens are signed with the
+// shared secret.
+func (j *JWTService) JWKS() JWKSet {
+	set := JWKSet{Keys: []JSONWebKey{}}
+	if j.keys == nil {
+		return set
+	}
+
+	j.keys.mu.RLock()
+	defer j.keys.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range j.keys.keys {
+		if !key.expiresAt.After(now) {
+			continue
+		}
+
+		jwk := JSONWebKey{Kid: key.id, Use: "sig", Alg: key.algorithm}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// memoryKeyStore is a KeyStore shared by the services in a test, like the
+// database is shared by API instances
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []models.SigningKey
+}
+
+func (s *memoryKeyStore) GetSigningKeys(at time.Time) ([]models.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []models.SigningKey
+	for _, key := range s.keys {
+		if key.ExpiresAt.After(at) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryKeyStore) CreateSigningKey(key *models.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.CreatedAt = time.Now()
+	s.keys = append(s.keys, *key)
+	return nil
+}
+
+// shift moves every key's lifetime back by d, as if d had passed
+func (s *memoryKeyStore) shift(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		s.keys[i].ActivatesAt = s.keys[i].ActivatesAt.Add(-d)
+		s.keys[i].RetiresAt = s.keys[i].RetiresAt.Add(-d)
+		s.keys[i].ExpiresAt = s.keys[i].ExpiresAt.Add(-d)
+	}
+}
+
+type SigningKeysTestSuite struct {
+	suite.Suite
+	store    *memoryKeyStore
+	service  *JWTService
+	testUser *models.User
+}
+
+func (suite *SigningKeysTestSuite) SetupTest() {
+	suite.store = &memoryKeyStore{}
+	suite.service = NewJWTService("test-secret-key-for-testing")
+	assert.NoError(suite.T(), suite.service.UseSigningKeys(suite.store, AlgorithmRS256, 30*24*time.Hour))
+
+	suite.testUser = &models.User{
+		ID:               uuid.New(),
+		Email:            "test@example.com",
+		SubscriptionTier: models.TierFree,
+	}
+}
+
+func (suite *SigningKeysTestSuite) TestSignsWithKeyID() {
+	token, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "RS256", parsed.Header["alg"])
+	assert.Equal(suite.T(), suite.store.keys[0].ID, parsed.Header["kid"])
+
+	claims, err := suite.service.ValidateToken(token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.testUser.ID, claims.UserID)
+}
+
+func (suite *SigningKeysTestSuite) TestEdDSA() {
+	service := NewJWTService("test-secret-key-for-testing")
+	assert.NoError(suite.T(), service.UseSigningKeys(&memoryKeyStore{}, AlgorithmEdDSA, 24*time.Hour))
+
+	token, err := service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	_, err = service.ValidateToken(token)
+	assert.NoError(suite.T(), err)
+
+	jwks := service.JWKS()
+	assert.Len(suite.T(), jwks.Keys, 1)
+	assert.Equal(suite.T(), "OKP", jwks.Keys[0].Kty)
+	assert.Equal(suite.T(), "Ed25519", jwks.Keys[0].Crv)
+
+	public, err := jwks.Keys[0].publicKey()
+	assert.NoError(suite.T(), err)
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public.(ed25519.PublicKey), nil })
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SigningKeysTestSuite) TestJWKSVerifiesTokens() {
+	token, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	jwks := suite.service.JWKS()
+	assert.Len(suite.T(), jwks.Keys, 1)
+	assert.Equal(suite.T(), "RSA", jwks.Keys[0].Kty)
+	assert.Equal(suite.T(), "RS256", jwks.Keys[0].Alg)
+	assert.Equal(suite.T(), "sig", jwks.Keys[0].Use)
+
+	// Anyone with the JWKS can check our tokens
+	public, err := jwks.Keys[0].publicKey()
+	assert.NoError(suite.T(), err)
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return public.(*rsa.PublicKey), nil })
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SigningKeysTestSuite) TestPrivateKeysAreEncrypted() {
+	// A different secret can't decrypt the stored keys, so it creates its own
+	other := NewJWTService("another-secret")
+	assert.NoError(suite.T(), other.UseSigningKeys(suite.store, AlgorithmRS256, 30*24*time.Hour))
+	assert.Len(suite.T(), suite.store.keys, 2)
+
+	token, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	_, err = other.ValidateToken(token)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SigningKeysTestSuite) TestRotation() {
+	oldToken, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	// Nothing to do while the key has a while to go
+	assert.NoError(suite.T(), suite.service.RotateKeys())
+	assert.Len(suite.T(), suite.store.keys, 1)
+
+	// Close to retiring, its successor is published but doesn't sign yet
+	suite.store.shift(30*24*time.Hour - 30*time.Minute)
+	assert.NoError(suite.T(), suite.service.RotateKeys())
+	assert.Len(suite.T(), suite.store.keys, 2)
+	assert.Len(suite.T(), suite.service.JWKS().Keys, 2)
+
+	token, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.store.keys[0].ID, keyID(token))
+
+	// Once the old key retires, the new one signs and the old one still validates
+	suite.store.shift(time.Hour)
+	assert.NoError(suite.T(), suite.service.RotateKeys())
+
+	token, err = suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.store.keys[1].ID, keyID(token))
+
+	_, err = suite.service.ValidateToken(oldToken)
+	assert.NoError(suite.T(), err)
+
+	// After the grace period the old key is gone
+	suite.store.shift(RetiredKeyGrace)
+	assert.NoError(suite.T(), suite.service.RotateKeys())
+	assert.NotContains(suite.T(), jwksKeyIDs(suite.service.JWKS()), suite.store.keys[0].ID)
+}
+
+func (suite *SigningKeysTestSuite) TestLoadsKeysFromOtherInstances() {
+	other := NewJWTService("test-secret-key-for-testing")
+	assert.NoError(suite.T(), other.UseSigningKeys(suite.store, AlgorithmRS256, 30*24*time.Hour))
+
+	token, err := suite.service.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+	_, err = other.ValidateToken(token)
+	assert.NoError(suite.T(), err)
+
+	// A key created elsewhere is picked up when a token names it
+	suite.store.keys = nil
+	fresh := NewJWTService("test-secret-key-for-testing")
+	assert.NoError(suite.T(), fresh.UseSigningKeys(suite.store, AlgorithmRS256, 30*24*time.Hour))
+	token, err = fresh.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	other.keys.loadedAt = time.Now().Add(-unknownKeyReloadInterval)
+	_, err = other.ValidateToken(token)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *SigningKeysTestSuite) TestRejectsSecretSignedTokens() {
+	legacy := NewJWTService("test-secret-key-for-testing")
+	token, err := legacy.GenerateToken(suite.testUser)
+	assert.NoError(suite.T(), err)
+
+	_, err = suite.service.ValidateToken(token)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SigningKeysTestSuite) TestActionTokens() {
+	token, err := suite.service.GenerateActionToken(suite.testUser.ID, PurposePasswordReset, "binding", time.Hour)
+	assert.NoError(suite.T(), err)
+
+	claims, err := suite.service.ValidateActionToken(token, PurposePasswordReset)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "binding", claims.Binding)
+}
+
+func (suite *SigningKeysTestSuite) TestHS256HasNoJWKS() {
+	assert.Empty(suite.T(), NewJWTService("secret").JWKS().Keys)
+}
+
+func (suite *SigningKeysTestSuite) TestUnsupportedAlgorithm() {
+	err := NewJWTService("secret").UseSigningKeys(&memoryKeyStore{}, "HS512", 24*time.Hour)
+	assert.Error(suite.T(), err)
+}
+
+func keyID(token string) string {
+	parsed, _, _ := jwt.NewParser().ParseUnverified(token, &Claims{})
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func jwksKeyIDs(set JWKSet) []string {
+	var ids []string
+	for _, key := range set.Keys {
+		ids = append(ids, key.Kid)
+	}
+	return ids
+}
+
+func TestSigningKeysTestSuite(t *testing.T) {
+	suite.Run(t, new(SigningKeysTestSuite))
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCConfig configures sign-in with an OpenID Connect provider
+type OIDCConfig struct {
+	Name         string // identifies the provider in linked identities, e.g. "google"
+	DiscoveryURL string // issuer URL or its /.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCIdentity is who the provider says signed in
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider signs users in with the authorization code flow and PKCE. The
+// provider's endpoints come from its discovery document, which is fetched on
+// first use, so the API starts even if the provider is unreachable.
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// oidcKeyRefreshInterval limits how often an unknown key ID triggers a JWKS fetch
+const oidcKeyRefreshInterval = time.Minute
+
+// idTokenAlgorithms are the signing algorithms accepted for ID tokens
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func NewOIDCProvider(config OIDCConfig, client *http.Client) (*OIDCProvider, error) {
+	if config.DiscoveryURL == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC discovery URL, client ID and redirect URL are required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &OIDCProvider{
+		config: config,
+		client: client,
+	}, nil
+}
+
+// Name identifies the provider in linked identities
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier
+func NewPKCEVerifier() (string, error) {
+	return NewOpaqueToken()
+}
+
+// PKCEChallenge returns the S256 code challenge for a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL to send the user to. state and nonce
+// tie the callback and ID token to this login; the verifier stays with us.
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the ID token that comes
+// back: its signature, issuer, audience, expiry and nonce.
+func (p *OIDCProvider) Exchange(code, verifier, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	// client_secret_basic is the default when the provider doesn't say
+	useBasic := p.config.ClientSecret != "" &&
+		(len(discovery.TokenAuthMethods) == 0 || slices.Contains(discovery.TokenAuthMethods, "client_secret_basic"))
+	if !useBasic {
+		form.Set("client_id", p.config.ClientID)
+		if p.config.ClientSecret != "" {
+			form.Set("client_secret", p.config.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach OIDC token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OIDC token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OIDC token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("OIDC token response has no ID token")
+	}
+
+	return p.verifyIDToken(tokens.IDToken, nonce)
+}
+
+type idTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // some providers send "true"
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (p *OIDCProvider) verifyIDToken(idToken, nonce string) (*OIDCIdentity, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	},
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid ID token: no subject")
+	}
+
+	verified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discoveryURL := p.config.DiscoveryURL
+	if !strings.Contains(discoveryURL, "/.well-known/") {
+		discoveryURL = strings.TrimRight(discoveryURL, "/") + "/.well-known/openid-configuration"
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+	}
+	if discovery.Issuer == "" || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document is missing required endpoints")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// key returns the provider's public key with the given ID, fetching the key
+// set again if it's unknown, since providers rotate keys
+func (p *OIDCProvider) key(kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := p.getJSON(p.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip key types we don't support rather than failing every login
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key. Tokens without a key ID are accepted if the
+// provider has exactly one key.
+func (p *OIDCProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// JSONWebKey is a public key in a JWKS document (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k JSONWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
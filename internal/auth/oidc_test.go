@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// mockOIDCServer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that hands out the ID token set by the test
+type mockOIDCServer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	claims    jwt.MapClaims
+	challenge string // code challenge the next code was issued for
+	form      url.Values
+	jwksHits  int
+}
+
+func newMockOIDCServer() *mockOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	m := &mockOIDCServer{key: key, kid: "key-1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.jwksHits++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": m.kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.form = r.PostForm
+		if PKCEChallenge(r.PostForm.Get("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+		token.Header["kid"] = m.kid
+		idToken, err := token.SignedString(m.key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "provider-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+type OIDCTestSuite struct {
+	suite.Suite
+	server   *mockOIDCServer
+	provider *OIDCProvider
+	verifier string
+}
+
+func (suite *OIDCTestSuite) SetupTest() {
+	suite.server = newMockOIDCServer()
+
+	provider, err := NewOIDCProvider(OIDCConfig{
+		Name:         "sso",
+		DiscoveryURL: suite.server.URL,
+		ClientID:     "collision-engine",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/callback",
+	}, suite.server.Client())
+	assert.NoError(suite.T(), err)
+	suite.provider = provider
+
+	suite.verifier, err = NewPKCEVerifier()
+	assert.NoError(suite.T(), err)
+	suite.server.challenge = PKCEChallenge(suite.verifier)
+	suite.server.claims = jwt.MapClaims{
+		"iss":            suite.server.URL,
+		"aud":            "collision-engine",
+		"sub":            "user-123",
+		"email":          "sso@example.com",
+		"email_verified": true,
+		"name":           "Single Sign",
+		"nonce":          "nonce-1",
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+	}
+}
+
+func (suite *OIDCTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *OIDCTestSuite) TestAuthCodeURL() {
+	authURL, err := suite.provider.AuthCodeURL("state-1", "nonce-1", suite.verifier)
+	assert.NoError(suite.T(), err)
+
+	parsed, err := url.Parse(authURL)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+
+	query := parsed.Query()
+	assert.Equal(suite.T(), "code", query.Get("response_type"))
+	assert.Equal(suite.T(), "collision-engine", query.Get("client_id"))
+	assert.Equal(suite.T(), "openid email profile", query.Get("scope"))
+	assert.Equal(suite.T(), "state-1", query.Get("state"))
+	assert.Equal(suite.T(), "nonce-1", query.Get("nonce"))
+	assert.Equal(suite.T(), "S256", query.Get("code_challenge_method"))
+	assert.Equal(suite.T(), PKCEChallenge(suite.verifier), query.Get("code_challenge"))
+	assert.NotContains(suite.T(), authURL, suite.verifier)
+}
+
+func (suite *OIDCTestSuite) TestExchange() {
+	identity, err := suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "user-123", identity.Subject)
+	assert.Equal(suite.T(), "sso@example.com", identity.Email)
+	assert.True(suite.T(), identity.EmailVerified)
+	assert.Equal(suite.T(), "Single Sign", identity.Name)
+
+	// The client authenticates with HTTP basic auth, not in the form
+	assert.Equal(suite.T(), "code-1", suite.server.form.Get("code"))
+	assert.Empty(suite.T(), suite.server.form.Get("client_secret"))
+}
+
+func (suite *OIDCTestSuite) TestExchangeWrongVerifier() {
+	_, err := suite.provider.Exchange("code-1", "not-the-verifier", "nonce-1")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *OIDCTestSuite) TestExchangeRejectsBadIDTokens() {
+	_, err := suite.provider.Exchange("code-1", suite.verifier, "other-nonce")
+	assert.Error(suite.T(), err)
+
+	suite.server.claims["aud"] = "someone-else"
+	_, err = suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.Error(suite.T(), err)
+
+	suite.server.claims["aud"] = "collision-engine"
+	suite.server.claims["iss"] = "https://evil.example.com"
+	_, err = suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.Error(suite.T(), err)
+
+	suite.server.claims["iss"] = suite.server.URL
+	suite.server.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *OIDCTestSuite) TestExchangeRejectsForeignSignatures() {
+	// Sign with a key the provider never published under the same key ID
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(suite.T(), err)
+	suite.server.key, other = other, suite.server.key
+
+	_, err = suite.provider.AuthCodeURL("state-1", "nonce-1", suite.verifier)
+	assert.NoError(suite.T(), err)
+	suite.provider.keys = map[string]interface{}{"key-1": &other.PublicKey}
+	suite.provider.keysAt = time.Now()
+
+	_, err = suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *OIDCTestSuite) TestKeyRotation() {
+	_, err := suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.server.jwksHits)
+
+	// A token signed with a new key ID makes us fetch the key set again
+	suite.provider.keysAt = time.Now().Add(-oidcKeyRefreshInterval)
+	suite.server.kid = "key-2"
+	_, err = suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, suite.server.jwksHits)
+}
+
+func (suite *OIDCTestSuite) TestEmailVerifiedAsString() {
+	suite.server.claims["email_verified"] = "false"
+	identity, err := suite.provider.Exchange("code-1", suite.verifier, "nonce-1")
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), identity.EmailVerified)
+}
+
+func (suite *OIDCTestSuite) TestNewOIDCProviderRequiresConfig() {
+	_, err := NewOIDCProvider(OIDCConfig{DiscoveryURL: suite.server.URL}, nil)
+	assert.Error(suite.T(), err)
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
+
+func TestOIDCTestSuite(t *testing.T) {
+	suite.Run(t, new(OIDCTestSuite))
+}
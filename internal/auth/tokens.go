@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// OpaqueTokenBytes is the amount of randomness in tokens from NewOpaqueToken
+const OpaqueTokenBytes = 32
+
+// NewOpaqueToken returns a random, URL-safe token for links and one-time codes
+func NewOpaqueToken() (string, error) {
+	buf := make([]byte, OpaqueTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the SHA-256 hex digest of a token. Tokens that grant access
+// are stored hashed so a database leak doesn't hand them out.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+// Package billing turns Stripe subscription events into plan changes
+package billing
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v76"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// PlanChange is what a subscription event means for the user who subscribed
+type PlanChange struct {
+	UserID uuid.UUID
+	Tier   string
+	// Seats of the team the user owns. Only set for the Team tier.
+	Seats int
+}
+
+// paidStatuses are the subscription states that keep the paid tier. Past-due
+// subscriptions keep it while Stripe retries the payment.
+var paidStatuses = map[stripe.SubscriptionStatus]bool{
+	stripe.SubscriptionStatusActive:   true,
+	stripe.SubscriptionStatusTrialing: true,
+	stripe.SubscriptionStatusPastDue:  true,
+}
+
+// PlanChangeFor works out the tier and team seats a subscription gives its
+// user. prices maps Stripe price IDs to tiers; the user is named in the
+// subscription's user_id metadata, which checkout sets. Subscriptions that
+// ended or never got paid put the user back on the free tier. Team seats
+// follow the subscription's quantity, with at least the seats the plan
+// includes.
+func PlanChangeFor(sub *stripe.Subscription, prices map[string]string) (*PlanChange, error) {
+	userID, err := uuid.Parse(sub.Metadata["user_id"])
+	if err != nil {
+		return nil, fmt.Errorf("subscription %s has no valid user_id metadata", sub.ID)
+	}
+
+	change := &PlanChange{UserID: userID, Tier: models.TierFree}
+	if !paidStatuses[sub.Status] {
+		return change, nil
+	}
+
+	if sub.Items != nil {
+		for _, item := range sub.Items.Data {
+			if item.Price == nil {
+				continue
+			}
+			tier, ok := prices[item.Price.ID]
+			if !ok {
+				continue
+			}
+
+			change.Tier = tier
+			if tier == models.TierTeam {
+				change.Seats = int(item.Quantity)
+				if change.Seats < models.TeamIncludedSeats {
+					change.Seats = models.TeamIncludedSeats
+				}
+			}
+			return change, nil
+		}
+	}
+
+	return nil, fmt.Errorf("subscription %s has no known price", sub.ID)
+}
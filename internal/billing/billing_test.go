@@ -0,0 +1,94 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v76"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type BillingTestSuite struct {
+	suite.Suite
+	userID uuid.UUID
+	prices map[string]string
+}
+
+func (suite *BillingTestSuite) SetupTest() {
+	suite.userID = uuid.New()
+	suite.prices = map[string]string{
+		"price_pro":  models.TierPro,
+		"price_team": models.TierTeam,
+	}
+}
+
+func (suite *BillingTestSuite) subscription(status stripe.SubscriptionStatus, priceID string, quantity int64) *stripe.Subscription {
+	return &stripe.Subscription{
+		ID:       "sub_123",
+		Status:   status,
+		Metadata: map[string]string{"user_id": suite.userID.String()},
+		Items: &stripe.SubscriptionItemList{
+			Data: []*stripe.SubscriptionItem{
+				{Price: &stripe.Price{ID: priceID}, Quantity: quantity},
+			},
+		},
+	}
+}
+
+func (suite *BillingTestSuite) TestProSubscription() {
+	change, err := PlanChangeFor(suite.subscription(stripe.SubscriptionStatusActive, "price_pro", 1), suite.prices)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.userID, change.UserID)
+	assert.Equal(suite.T(), models.TierPro, change.Tier)
+	assert.Zero(suite.T(), change.Seats)
+}
+
+func (suite *BillingTestSuite) TestTeamSeatsFollowQuantity() {
+	change, err := PlanChangeFor(suite.subscription(stripe.SubscriptionStatusActive, "price_team", 12), suite.prices)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TierTeam, change.Tier)
+	assert.Equal(suite.T(), 12, change.Seats)
+}
+
+func (suite *BillingTestSuite) TestTeamKeepsIncludedSeats() {
+	change, err := PlanChangeFor(suite.subscription(stripe.SubscriptionStatusTrialing, "price_team", 1), suite.prices)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TeamIncludedSeats, change.Seats)
+}
+
+func (suite *BillingTestSuite) TestEndedSubscriptionIsFree() {
+	for _, status := range []stripe.SubscriptionStatus{
+		stripe.SubscriptionStatusCanceled,
+		stripe.SubscriptionStatusUnpaid,
+		stripe.SubscriptionStatusIncompleteExpired,
+	} {
+		change, err := PlanChangeFor(suite.subscription(status, "price_team", 8), suite.prices)
+
+		assert.NoError(suite.T(), err, status)
+		assert.Equal(suite.T(), models.TierFree, change.Tier, status)
+		assert.Zero(suite.T(), change.Seats, status)
+	}
+}
+
+func (suite *BillingTestSuite) TestMissingUser() {
+	sub := suite.subscription(stripe.SubscriptionStatusActive, "price_pro", 1)
+	sub.Metadata = nil
+
+	_, err := PlanChangeFor(sub, suite.prices)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *BillingTestSuite) TestUnknownPrice() {
+	_, err := PlanChangeFor(suite.subscription(stripe.SubscriptionStatusActive, "price_other", 1), suite.prices)
+	assert.Error(suite.T(), err)
+}
+
+func TestBillingTestSuite(t *testing.T) {
+	suite.Run(t, new(BillingTestSuite))
+}
@@ -2,298 +2,455 @@ package collision
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
 
 	"idea-collision-engine-api/internal/models"
 )
 
+// chatClient is the subset of the OpenAI client used by the service
+type chatClient interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
 type AIService struct {
-	client *openai.Client
+	client     chatClient
+	prompts    *PromptRegistry
+	usage      UsageRecorder
+	resilience ResilienceConfig
+	breaker    *CircuitBreaker
+	gate       *QualityGate
+	stats      aiCounters
 }
 
-func NewAIService(apiKey string) *AIService {
+// AIStats reports AI provider call counters and circuit breaker state
+type AIStats struct {
+	Requests          int64  `json:"requests"`
+	Failures          int64  `json:"failures"`
+	Retries           int64  `json:"retries"`
+	Rejected          int64  `json:"rejected"`           // calls skipped because the breaker was open
+	QualityRejections int64  `json:"quality_rejections"` // outputs rejected by the quality gate
+	BreakerState      string `json:"breaker_state"`
+}
+
+type aiCounters struct {
+	requests          atomic.Int64
+	failures          atomic.Int64
+	retries           atomic.Int64
+	rejected          atomic.Int64
+	qualityRejections atomic.Int64
+}
+
+func NewAIService(apiKey string, prompts *PromptRegistry, usage UsageRecorder, resilience ResilienceConfig, gate *QualityGate) *AIService {
 	client := openai.NewClient(apiKey)
-	return &AIService{client: client}
+	return &AIService{
+		client:     client,
+		prompts:    prompts,
+		usage:      usage,
+		resilience: resilience,
+		breaker:    NewCircuitBreaker(resilience.FailureThreshold, resilience.Cooldown),
+		gate:       gate,
+	}
 }
 
-// EnhanceCollisionResult uses AI to improve the collision with deeper insights
-func (ai *AIService) EnhanceCollisionResult(result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) error {
+// EnhanceCollisionResult uses AI to improve the collision with deeper insights.
+// Token usage for each model call is attributed to userID. Parts that fail or are
+// rejected by the quality gate keep their template output and record a reason
+// code in FallbackReasons; the returned error joins every failure.
+func (ai *AIService) EnhanceCollisionResult(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) error {
+	// Don't queue four calls that the breaker will reject anyway
+	if ai.breaker.State() == CircuitOpen {
+		ai.stats.rejected.Add(1)
+		result.FallbackReason = models.FallbackAIUnavailable
+		return ErrCircuitOpen
+	}
+
+	var errs []error
+	providerFailed := false
+	fallBack := func(part string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", part, err))
+
+		var qualityErr *QualityError
+		if errors.As(err, &qualityErr) {
+			recordFallbackReason(result, part, qualityErr.Reason)
+		} else {
+			providerFailed = true
+			recordFallbackReason(result, part, models.FallbackAIUnavailable)
+		}
+	}
+
 	// Enhance the connection explanation
-	enhancedConnection, err := ai.generateEnhancedConnection(result, input, domain)
+	enhancedConnection, version, err := ai.generateEnhancedConnection(userID, result, input, domain)
 	if err == nil && enhancedConnection != "" {
 		result.Connection = enhancedConnection
+		recordPromptVersion(result, PromptConnection, version)
+	} else if err != nil {
+		fallBack(PromptConnection, err)
 	}
-	
+
 	// Generate more sophisticated spark questions
-	enhancedQuestions, err := ai.generateAdvancedSparkQuestions(input, domain)
+	enhancedQuestions, version, err := ai.generateAdvancedSparkQuestions(userID, input, domain)
 	if err == nil && len(enhancedQuestions) > 0 {
 		result.SparkQuestions = enhancedQuestions
+		recordPromptVersion(result, PromptSparkQuestions, version)
+	} else if err != nil {
+		fallBack(PromptSparkQuestions, err)
 	}
-	
+
 	// Create more contextual examples
-	enhancedExamples, err := ai.generateContextualExamples(input, domain)
+	enhancedExamples, version, err := ai.generateContextualExamples(userID, input, domain)
 	if err == nil && len(enhancedExamples) > 0 {
 		result.Examples = enhancedExamples
+		recordPromptVersion(result, PromptExamples, version)
+	} else if err != nil {
+		fallBack(PromptExamples, err)
 	}
-	
+
 	// Generate actionable next steps
-	enhancedSteps, err := ai.generateAdvancedNextSteps(input, domain)
+	enhancedSteps, version, err := ai.generateAdvancedNextSteps(userID, input, domain)
 	if err == nil && len(enhancedSteps) > 0 {
 		result.NextSteps = enhancedSteps
+		recordPromptVersion(result, PromptNextSteps, version)
+	} else if err != nil {
+		fallBack(PromptNextSteps, err)
+	}
+
+	if len(errs) == 4 {
+		if providerFailed {
+			result.FallbackReason = models.FallbackAIUnavailable
+		} else {
+			result.FallbackReason = models.FallbackAIQualityRejected
+		}
 	}
-	
-	return nil
+
+	return errors.Join(errs...)
 }
 
-// generateEnhancedConnection creates a deeper explanation of the collision
-func (ai *AIService) generateEnhancedConnection(result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) (string, error) {
-	prompt := ai.buildConnectionPrompt(input, domain)
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	
-	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: 200,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an expert at finding meaningful connections between disparate fields. Create insightful, practical connections that spark innovation.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		Temperature: 0.7,
+// DeepenCollision replaces the template-generated additions of a deepened result
+// with AI-generated spark questions and next steps that build on the previous
+// ones. Parts that fail keep their template output and record a reason code in
+// FallbackReasons; the returned error joins every failure.
+func (ai *AIService) DeepenCollision(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain, previous models.CollisionResult) error {
+	if ai.breaker.State() == CircuitOpen {
+		ai.stats.rejected.Add(1)
+		result.FallbackReason = models.FallbackAIUnavailable
+		return ErrCircuitOpen
 	}
-	
-	resp, err := ai.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", err
+
+	var errs []error
+	fallBack := func(part string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", part, err))
+
+		var qualityErr *QualityError
+		if errors.As(err, &qualityErr) {
+			recordFallbackReason(result, part, qualityErr.Reason)
+		} else {
+			recordFallbackReason(result, part, models.FallbackAIUnavailable)
+		}
 	}
-	
-	if len(resp.Choices) > 0 {
-		return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+
+	questions, version, err := ai.generate(userID, generationSpec{
+		prompt:      PromptDeeperQuestions,
+		maxTokens:   300,
+		temperature: 0.8,
+		timeout:     8 * time.Second,
+		list:        true,
+		parse:       ai.parseQuestionsList,
+	}, PromptData{Input: input, Domain: domain, Previous: previous.SparkQuestions})
+	if err == nil && len(questions) > 0 {
+		result.SparkQuestions = AppendNew(previous.SparkQuestions, questions, followUpItems)
+		recordPromptVersion(result, PromptDeeperQuestions, version)
+	} else if err != nil {
+		fallBack(PromptDeeperQuestions, err)
 	}
-	
-	return "", fmt.Errorf("no response generated")
+
+	steps, version, err := ai.generate(userID, generationSpec{
+		prompt:      PromptDeeperSteps,
+		maxTokens:   300,
+		temperature: 0.6,
+		timeout:     8 * time.Second,
+		list:        true,
+		parse:       ai.parseStepsList,
+	}, PromptData{Input: input, Domain: domain, Previous: previous.NextSteps})
+	if err == nil && len(steps) > 0 {
+		result.NextSteps = AppendNew(previous.NextSteps, steps, followUpItems)
+		recordPromptVersion(result, PromptDeeperSteps, version)
+	} else if err != nil {
+		fallBack(PromptDeeperSteps, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// generationSpec describes how one part of the result is requested and parsed
+type generationSpec struct {
+	prompt      string
+	maxTokens   int
+	temperature float32
+	timeout     time.Duration
+	list        bool
+	parse       func(content string) []string
+}
+
+// generateEnhancedConnection creates a deeper explanation of the collision
+func (ai *AIService) generateEnhancedConnection(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) (string, string, error) {
+	items, version, err := ai.generate(userID, generationSpec{
+		prompt:      PromptConnection,
+		maxTokens:   200,
+		temperature: 0.7,
+		timeout:     10 * time.Second,
+		parse:       parseProse,
+	}, PromptData{Input: input, Domain: domain})
+	if err != nil {
+		return "", "", err
+	}
+
+	return items[0], version, nil
 }
 
 // generateAdvancedSparkQuestions creates thought-provoking questions
-func (ai *AIService) generateAdvancedSparkQuestions(input models.CollisionInput, domain models.CollisionDomain) ([]string, error) {
-	prompt := ai.buildSparkQuestionsPrompt(input, domain)
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-	defer cancel()
-	
-	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: 250,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "Generate thought-provoking questions that help people explore unexpected connections. Focus on actionable insights and creative breakthroughs.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		Temperature: 0.8,
+func (ai *AIService) generateAdvancedSparkQuestions(userID uuid.UUID, input models.CollisionInput, domain models.CollisionDomain) ([]string, string, error) {
+	return ai.generate(userID, generationSpec{
+		prompt:      PromptSparkQuestions,
+		maxTokens:   250,
+		temperature: 0.8,
+		timeout:     8 * time.Second,
+		list:        true,
+		parse:       ai.parseQuestionsList,
+	}, PromptData{Input: input, Domain: domain})
+}
+
+// generateContextualExamples creates relevant examples for the specific context
+func (ai *AIService) generateContextualExamples(userID uuid.UUID, input models.CollisionInput, domain models.CollisionDomain) ([]string, string, error) {
+	return ai.generate(userID, generationSpec{
+		prompt:      PromptExamples,
+		maxTokens:   300,
+		temperature: 0.7,
+		timeout:     8 * time.Second,
+		list:        true,
+		parse:       ai.parseExamplesList,
+	}, PromptData{Input: input, Domain: domain})
+}
+
+// generateAdvancedNextSteps creates actionable implementation steps
+func (ai *AIService) generateAdvancedNextSteps(userID uuid.UUID, input models.CollisionInput, domain models.CollisionDomain) ([]string, string, error) {
+	return ai.generate(userID, generationSpec{
+		prompt:      PromptNextSteps,
+		maxTokens:   250,
+		temperature: 0.6,
+		timeout:     8 * time.Second,
+		list:        true,
+		parse:       ai.parseStepsList,
+	}, PromptData{Input: input, Domain: domain})
+}
+
+// generate renders the prompt, calls the model and runs the output through the
+// quality gate. Rejected output is retried once with the prompt's stricter
+// instructions at a lower temperature before giving up with a QualityError.
+func (ai *AIService) generate(userID uuid.UUID, spec generationSpec, data PromptData) ([]string, string, error) {
+	input, domain := data.Input, data.Domain
+	prompt, err := ai.prompts.Render(spec.prompt, data)
+	if err != nil {
+		return nil, "", err
 	}
-	
-	resp, err := ai.client.CreateChatCompletion(ctx, req)
+
+	content, finishReason, err := ai.complete(userID, prompt, prompt.User, spec.maxTokens, spec.temperature, spec.timeout)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	
-	if len(resp.Choices) > 0 {
-		content := resp.Choices[0].Message.Content
-		questions := ai.parseQuestionsList(content)
-		return questions, nil
+
+	items := spec.parse(content)
+	reason := ai.gate.Check(items, finishReason, spec.list, input, domain)
+	if reason == "" {
+		return items, prompt.Version, nil
 	}
-	
-	return nil, fmt.Errorf("no questions generated")
-}
 
-// generateContextualExamples creates relevant examples for the specific context
-func (ai *AIService) generateContextualExamples(input models.CollisionInput, domain models.CollisionDomain) ([]string, error) {
-	prompt := ai.buildExamplesPrompt(input, domain)
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-	defer cancel()
-	
-	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: 300,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "Create specific, actionable examples showing how principles from one domain can be applied to another. Focus on concrete applications.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		Temperature: 0.7,
+	// Retry once with stricter instructions, using the same prompt version
+	ai.stats.qualityRejections.Add(1)
+	data.Rejection = reason
+	prompt, err = ai.prompts.RenderVersion(spec.prompt, prompt.Version, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	strictTemperature := spec.temperature - 0.3
+	if strictTemperature < 0 {
+		strictTemperature = 0
 	}
-	
-	resp, err := ai.client.CreateChatCompletion(ctx, req)
+
+	content, finishReason, err = ai.complete(userID, prompt, prompt.User+"\n\n"+prompt.Strict, spec.maxTokens, strictTemperature, spec.timeout)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	
-	if len(resp.Choices) > 0 {
-		content := resp.Choices[0].Message.Content
-		examples := ai.parseExamplesList(content)
-		return examples, nil
+
+	items = spec.parse(content)
+	if reason := ai.gate.Check(items, finishReason, spec.list, input, domain); reason != "" {
+		ai.stats.qualityRejections.Add(1)
+		return nil, "", &QualityError{Reason: reason}
 	}
-	
-	return nil, fmt.Errorf("no examples generated")
+
+	return items, prompt.Version, nil
 }
 
-// generateAdvancedNextSteps creates actionable implementation steps
-func (ai *AIService) generateAdvancedNextSteps(input models.CollisionInput, domain models.CollisionDomain) ([]string, error) {
-	prompt := ai.buildNextStepsPrompt(input, domain)
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+// complete sends a rendered prompt to the model, returning the response text and finish reason
+func (ai *AIService) complete(userID uuid.UUID, prompt *RenderedPrompt, userMessage string, maxTokens int, temperature float32, timeout time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	
+
 	req := openai.ChatCompletionRequest{
 		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: 250,
+		MaxTokens: maxTokens,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: "Generate specific, actionable next steps that someone can take to explore and implement cross-domain insights. Be practical and concrete.",
+				Content: prompt.System,
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
+				Content: userMessage,
 			},
 		},
-		Temperature: 0.6,
+		Temperature: temperature,
 	}
-	
-	resp, err := ai.client.CreateChatCompletion(ctx, req)
+
+	resp, err := ai.createWithRetry(ctx, req)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
-	
-	if len(resp.Choices) > 0 {
-		content := resp.Choices[0].Message.Content
-		steps := ai.parseStepsList(content)
-		return steps, nil
+
+	ai.recordUsage(userID, prompt, resp)
+
+	if len(resp.Choices) == 0 {
+		return "", "", fmt.Errorf("no response generated for %s prompt", prompt.Name)
 	}
-	
-	return nil, fmt.Errorf("no steps generated")
+
+	return resp.Choices[0].Message.Content, string(resp.Choices[0].FinishReason), nil
 }
 
-// buildConnectionPrompt constructs the prompt for connection generation
-func (ai *AIService) buildConnectionPrompt(input models.CollisionInput, domain models.CollisionDomain) string {
-	return fmt.Sprintf(`Create a meaningful connection between %s and "%s" (a %s project).
-
-Domain: %s
-Category: %s  
-Description: %s
-Key concepts: %s
-
-User interests: %s
-Collision intensity: %s
-
-Generate a 2-3 sentence explanation of how %s principles can enhance or transform the "%s" project. Focus on specific, actionable insights rather than vague connections.`,
-		domain.Name,
-		input.CurrentProject,
-		input.ProjectType,
-		domain.Name,
-		domain.Category,
-		domain.Description,
-		strings.Join(domain.Keywords[:min(5, len(domain.Keywords))], ", "),
-		strings.Join(input.UserInterests, ", "),
-		input.CollisionIntensity,
-		domain.Name,
-		input.CurrentProject,
-	)
+// parseProse treats the whole response as a single item
+func parseProse(content string) []string {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+	return []string{content}
 }
 
-// buildSparkQuestionsPrompt constructs the prompt for question generation
-func (ai *AIService) buildSparkQuestionsPrompt(input models.CollisionInput, domain models.CollisionDomain) string {
-	return fmt.Sprintf(`Generate 4 thought-provoking questions that help someone explore connections between %s and their "%s" project.
-
-Domain: %s
-Description: %s
-Project type: %s
-User interests: %s
-
-Each question should:
-- Encourage deep thinking about cross-domain applications
-- Be specific and actionable
-- Help identify concrete opportunities
-- Spark creative breakthroughs
-
-Format as a numbered list (1., 2., 3., 4.).`,
-		domain.Name,
-		input.CurrentProject,
-		domain.Name,
-		domain.Description,
-		input.ProjectType,
-		strings.Join(input.UserInterests, ", "),
-	)
+// createWithRetry calls the provider through the circuit breaker, retrying
+// rate-limit and server errors with exponential backoff until ctx expires
+func (ai *AIService) createWithRetry(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if err := ai.breaker.Allow(); err != nil {
+		ai.stats.rejected.Add(1)
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	// There is always a first attempt, whatever the retries are set to
+	retries := ai.resilience.MaxRetries
+	if retries < 0 {
+		retries = 0
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			ai.stats.retries.Add(1)
+			if err := sleepContext(ctx, backoffDelay(ai.resilience, attempt-1)); err != nil {
+				break
+			}
+		}
+
+		ai.stats.requests.Add(1)
+		resp, err := ai.client.CreateChatCompletion(ctx, req)
+		if err == nil {
+			ai.breaker.RecordSuccess()
+			return resp, nil
+		}
+
+		ai.stats.failures.Add(1)
+		lastErr = err
+		if !isRetryable(err) {
+			ai.breaker.RecordIgnored()
+			return openai.ChatCompletionResponse{}, err
+		}
+	}
+
+	ai.breaker.RecordFailure()
+	return openai.ChatCompletionResponse{}, lastErr
+}
+
+// BreakerState returns the circuit breaker state for health reporting
+func (ai *AIService) BreakerState() string {
+	return ai.breaker.State()
+}
+
+// Stats returns call counters and breaker state for metrics
+func (ai *AIService) Stats() AIStats {
+	return AIStats{
+		Requests:          ai.stats.requests.Load(),
+		Failures:          ai.stats.failures.Load(),
+		Retries:           ai.stats.retries.Load(),
+		Rejected:          ai.stats.rejected.Load(),
+		QualityRejections: ai.stats.qualityRejections.Load(),
+		BreakerState:      ai.breaker.State(),
+	}
+}
+
+// recordUsage stores the token usage and estimated cost of a completion
+func (ai *AIService) recordUsage(userID uuid.UUID, prompt *RenderedPrompt, resp openai.ChatCompletionResponse) {
+	if ai.usage == nil {
+		return
+	}
+
+	usage := &models.AIUsage{
+		ID:               uuid.New(),
+		UserID:           userID,
+		Prompt:           prompt.Name,
+		PromptVersion:    prompt.Version,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		CostUSD:          EstimateCost(resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+		CreatedAt:        time.Now(),
+	}
+
+	if err := ai.usage.RecordAIUsage(usage); err != nil {
+		// Log error but don't fail the completion
+		fmt.Printf("Failed to record AI usage: %v\n", err)
+	}
 }
 
-// buildExamplesPrompt constructs the prompt for example generation
-func (ai *AIService) buildExamplesPrompt(input models.CollisionInput, domain models.CollisionDomain) string {
-	return fmt.Sprintf(`Generate 3 specific examples showing how %s principles can be applied to a %s project like "%s".
-
-Domain: %s
-Description: %s
-Key concepts: %s
-
-Each example should:
-- Show a specific principle or technique from %s
-- Demonstrate concrete application to the %s project
-- Be realistic and implementable
-- Provide clear value
-
-Format as a numbered list (1., 2., 3.).`,
-		domain.Name,
-		input.ProjectType,
-		input.CurrentProject,
-		domain.Name,
-		domain.Description,
-		strings.Join(domain.Keywords[:min(3, len(domain.Keywords))], ", "),
-		domain.Name,
-		input.CurrentProject,
-	)
+// PreviewPrompt renders a prompt for the given input and domain without calling the model.
+// An empty version renders the active one.
+func (ai *AIService) PreviewPrompt(name, version string, input models.CollisionInput, domain models.CollisionDomain) (*RenderedPrompt, error) {
+	return ai.prompts.RenderVersion(name, version, PromptData{Input: input, Domain: domain})
 }
 
-// buildNextStepsPrompt constructs the prompt for next steps generation
-func (ai *AIService) buildNextStepsPrompt(input models.CollisionInput, domain models.CollisionDomain) string {
-	return fmt.Sprintf(`Generate 4 actionable next steps for someone wanting to apply %s insights to their "%s" project.
-
-Domain: %s
-Project type: %s
-User interests: %s
-
-Each step should:
-- Be specific and actionable
-- Build toward implementing the cross-domain connection
-- Be achievable within 1-2 weeks
-- Progress from research to implementation
-
-Format as a numbered list (1., 2., 3., 4.).`,
-		domain.Name,
-		input.CurrentProject,
-		domain.Name,
-		input.ProjectType,
-		strings.Join(input.UserInterests, ", "),
-	)
+// Prompts returns the registry backing this service
+func (ai *AIService) Prompts() *PromptRegistry {
+	return ai.prompts
+}
+
+// recordFallbackReason notes why a part of the result kept its template output
+func recordFallbackReason(result *models.CollisionResult, part, reason string) {
+	if result.FallbackReasons == nil {
+		result.FallbackReasons = make(map[string]string)
+	}
+	result.FallbackReasons[part] = reason
+}
+
+// recordPromptVersion notes which prompt version produced a part of the result
+func recordPromptVersion(result *models.CollisionResult, name, version string) {
+	if result.PromptVersions == nil {
+		result.PromptVersions = make(map[string]string)
+	}
+	result.PromptVersions[name] = version
 }
 
 // parseQuestionsList extracts questions from AI response
@@ -315,13 +472,13 @@ func (ai *AIService) parseStepsList(content string) []string {
 func (ai *AIService) parseNumberedList(content string, expectedCount int) []string {
 	lines := strings.Split(content, "\n")
 	var items []string
-	
+
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
-		
+
 		// Match numbered items (1., 2., etc.)
 		for i := 1; i <= expectedCount; i++ {
 			prefix := fmt.Sprintf("%d.", i)
@@ -334,34 +491,6 @@ func (ai *AIService) parseNumberedList(content string, expectedCount int) []stri
 			}
 		}
 	}
-	
-	return items
-}
 
-// min helper function
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+	return items
 }
-
-// CheckConnection validates OpenAI API connectivity
-func (ai *AIService) CheckConnection() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: 10,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: "Test connection. Respond with 'OK'.",
-			},
-		},
-	}
-	
-	_, err := ai.client.CreateChatCompletion(ctx, req)
-	return err
-}
\ No newline at end of file
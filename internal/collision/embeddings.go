@@ -0,0 +1,513 @@
+package collision
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// Embedding kinds cached in the embeddings table
+const (
+	EmbeddingKindDomain   = "domain"
+	EmbeddingKindInterest = "interest"
+)
+
+// Embedding providers selectable through configuration
+const (
+	EmbeddingProviderNone   = "none"
+	EmbeddingProviderOpenAI = "openai"
+	EmbeddingProviderLocal  = "local"
+)
+
+// Embedder turns texts into vectors. Implementations must return one vector per
+// input text, in order, and always use the same model for a given instance.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+// EmbeddingStore caches vectors so each text is only embedded once per model
+type EmbeddingStore interface {
+	GetEmbeddings(kind, model string) ([]models.Embedding, error)
+	GetEmbedding(kind, key, model string) (*models.Embedding, error) // nil, nil when not cached
+	SaveEmbedding(embedding *models.Embedding) error
+}
+
+// NewEmbedder returns the embedder for a configured provider, or nil for "none"
+func NewEmbedder(provider, apiKey, model string) (Embedder, error) {
+	switch provider {
+	case "", EmbeddingProviderNone:
+		return nil, nil
+	case EmbeddingProviderOpenAI:
+		return NewOpenAIEmbedder(apiKey, model), nil
+	case EmbeddingProviderLocal:
+		return NewHashingEmbedder(1024), nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", provider)
+}
+
+// OpenAIEmbedder computes embeddings with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client *openai.Client
+	model  openai.EmbeddingModel
+}
+
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	if model == "" {
+		model = string(openai.SmallEmbedding3)
+	}
+	return &OpenAIEmbedder{
+		client: openai.NewClient(apiKey),
+		model:  openai.EmbeddingModel(model),
+	}
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: e.model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+func (e *OpenAIEmbedder) Model() string {
+	return string(e.model)
+}
+
+// HashingEmbedder is a local embedder that hashes words and character trigrams
+// into a fixed number of dimensions. It needs no network access and captures
+// lexical overlap, including partial matches like "garden" and "gardening".
+type HashingEmbedder struct {
+	dims int
+}
+
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims < 16 {
+		dims = 16
+	}
+	return &HashingEmbedder{dims: dims}
+}
+
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, e.dims)
+		for _, word := range tokenize(text) {
+			e.add(vector, word, 1.0)
+			padded := "^" + word + "$"
+			for j := 0; j+3 <= len(padded); j++ {
+				e.add(vector, padded[j:j+3], 0.5)
+			}
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
+
+func (e *HashingEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-%d", e.dims)
+}
+
+func (e *HashingEmbedder) add(vector []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	// The top bit picks the sign so unrelated features tend to cancel out
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint64(e.dims)] += weight
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Neighbor is a search result from a VectorIndex
+type Neighbor struct {
+	ID    string
+	Score float64 // cosine similarity
+}
+
+// VectorIndex is an in-process nearest-neighbour index over normalized vectors.
+// Search is an exact scan, which is fast enough for catalogs of a few thousand domains.
+type VectorIndex struct {
+	mu      sync.RWMutex
+	vectors map[string][]float32
+}
+
+func NewVectorIndex() *VectorIndex {
+	return &VectorIndex{
+		vectors: make(map[string][]float32),
+	}
+}
+
+// Add stores a copy of vector under id, replacing any previous vector
+func (idx *VectorIndex) Add(id string, vector []float32) {
+	normalized := normalize(append([]float32(nil), vector...))
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.vectors[id] = normalized
+}
+
+// Len returns the number of indexed vectors
+func (idx *VectorIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.vectors)
+}
+
+// Similarity returns the cosine similarity between the query and the vector stored
+// under id, and false if id isn't indexed or the dimensions differ
+func (idx *VectorIndex) Similarity(id string, query []float32) (float64, bool) {
+	idx.mu.RLock()
+	vector, ok := idx.vectors[id]
+	idx.mu.RUnlock()
+
+	if !ok || len(vector) != len(query) {
+		return 0, false
+	}
+	return dot(vector, normalize(append([]float32(nil), query...))), true
+}
+
+// Search returns the k most similar vectors to the query, best first
+func (idx *VectorIndex) Search(query []float32, k int) []Neighbor {
+	query = normalize(append([]float32(nil), query...))
+
+	idx.mu.RLock()
+	neighbors := make([]Neighbor, 0, len(idx.vectors))
+	for id, vector := range idx.vectors {
+		if len(vector) != len(query) {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: id, Score: dot(vector, query)})
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Score != neighbors[j].Score {
+			return neighbors[i].Score > neighbors[j].Score
+		}
+		return neighbors[i].ID < neighbors[j].ID
+	})
+
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+// Neighbors returns the k vectors most similar to the one stored under id, best
+// first, excluding id itself. It returns nil if id isn't indexed.
+func (idx *VectorIndex) Neighbors(id string, k int) []Neighbor {
+	idx.mu.RLock()
+	vector, ok := idx.vectors[id]
+	idx.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	neighbors := idx.Search(vector, k+1)
+	for i, neighbor := range neighbors {
+		if neighbor.ID == id {
+			neighbors = append(neighbors[:i], neighbors[i+1:]...)
+			break
+		}
+	}
+	if k > 0 && len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// DomainText is the text embedded for a domain
+func DomainText(domain models.CollisionDomain) string {
+	return strings.Join([]string{
+		domain.Name,
+		domain.Category,
+		domain.Description,
+		strings.Join(domain.Keywords, ", "),
+		strings.Join(domain.Examples, "; "),
+	}, "\n")
+}
+
+// contentHash identifies the text a cached vector was computed from, so edited
+// domains are re-embedded
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("%x", sum)[:16]
+}
+
+// EmbedDomains builds a vector index for the catalog. Cached vectors are reused
+// unless force is set or the domain's text has changed since it was embedded;
+// everything else is embedded in batches and saved. Returns the index and the
+// number of domains that were (re)embedded.
+func EmbedDomains(ctx context.Context, embedder Embedder, store EmbeddingStore, domains []models.CollisionDomain, force bool) (*VectorIndex, int, error) {
+	index := NewVectorIndex()
+
+	cached := make(map[string]models.Embedding)
+	if store != nil && !force {
+		embeddings, err := store.GetEmbeddings(EmbeddingKindDomain, embedder.Model())
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load cached domain embeddings: %w", err)
+		}
+		for _, embedding := range embeddings {
+			cached[embedding.Key] = embedding
+		}
+	}
+
+	var pending []models.CollisionDomain
+	for _, domain := range domains {
+		embedding, ok := cached[domain.ID]
+		if ok && embedding.ContentHash == contentHash(DomainText(domain)) {
+			index.Add(domain.ID, embedding.Vector)
+			continue
+		}
+		pending = append(pending, domain)
+	}
+
+	const batchSize = 64
+	for start := 0; start < len(pending); start += batchSize {
+		end := start + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		texts := make([]string, len(batch))
+		for i, domain := range batch {
+			texts[i] = DomainText(domain)
+		}
+
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, start, fmt.Errorf("failed to embed domains: %w", err)
+		}
+
+		for i, domain := range batch {
+			index.Add(domain.ID, vectors[i])
+			if store == nil {
+				continue
+			}
+			err := store.SaveEmbedding(&models.Embedding{
+				Kind:        EmbeddingKindDomain,
+				Key:         domain.ID,
+				Model:       embedder.Model(),
+				ContentHash: contentHash(texts[i]),
+				Vector:      vectors[i],
+				UpdatedAt:   time.Now(),
+			})
+			if err != nil {
+				return nil, start + i, fmt.Errorf("failed to save embedding for %s: %w", domain.Name, err)
+			}
+		}
+	}
+
+	return index, len(pending), nil
+}
+
+// SemanticMatcher compares user interests with catalog domains by cosine
+// similarity. Interest vectors are cached in memory and in the store.
+type SemanticMatcher struct {
+	embedder      Embedder
+	store         EmbeddingStore
+	index         *VectorIndex
+	minSimilarity float64
+	timeout       time.Duration
+
+	mu        sync.Mutex
+	interests map[string][]float32
+}
+
+// maxCachedInterests bounds the in-memory interest cache; the store keeps the rest
+const maxCachedInterests = 10000
+
+// NewSemanticMatcher creates a matcher over an index built by EmbedDomains.
+// Domains scoring below minSimilarity are not considered a match for an interest.
+func NewSemanticMatcher(embedder Embedder, store EmbeddingStore, index *VectorIndex, minSimilarity float64) *SemanticMatcher {
+	return &SemanticMatcher{
+		embedder:      embedder,
+		store:         store,
+		index:         index,
+		minSimilarity: minSimilarity,
+		timeout:       5 * time.Second,
+		interests:     make(map[string][]float32),
+	}
+}
+
+// InterestVector returns the normalized mean of the vectors for each interest
+func (m *SemanticMatcher) InterestVector(interests []string) ([]float32, error) {
+	var sum []float32
+	for _, interest := range interests {
+		vector, err := m.interestVector(interest)
+		if err != nil {
+			return nil, err
+		}
+		if vector == nil {
+			continue
+		}
+		if sum == nil {
+			sum = make([]float32, len(vector))
+		}
+		if len(vector) != len(sum) {
+			return nil, fmt.Errorf("embedding dimensions changed for interest %q", interest)
+		}
+		for i, v := range normalize(append([]float32(nil), vector...)) {
+			sum[i] += v
+		}
+	}
+
+	if sum == nil {
+		return nil, fmt.Errorf("no interests to embed")
+	}
+	return normalize(sum), nil
+}
+
+// Nearest returns the best matching domain ID for the interests, or false if
+// none reaches the minimum similarity
+func (m *SemanticMatcher) Nearest(interests []string) (Neighbor, bool, error) {
+	vector, err := m.InterestVector(interests)
+	if err != nil {
+		return Neighbor{}, false, err
+	}
+
+	neighbors := m.index.Search(vector, 1)
+	if len(neighbors) == 0 || neighbors[0].Score < m.minSimilarity {
+		return Neighbor{}, false, nil
+	}
+	return neighbors[0], true, nil
+}
+
+// Similarity returns the cosine similarity between the interests and a domain,
+// and false if the domain isn't indexed
+func (m *SemanticMatcher) Similarity(interests []string, domainID string) (float64, bool, error) {
+	vector, err := m.InterestVector(interests)
+	if err != nil {
+		return 0, false, err
+	}
+
+	score, ok := m.index.Similarity(domainID, vector)
+	return score, ok, nil
+}
+
+// RelatedDomains returns the k domains closest to the given domain, best first
+func (m *SemanticMatcher) RelatedDomains(domainID string, k int) []Neighbor {
+	return m.index.Neighbors(domainID, k)
+}
+
+// interestVector looks up a single interest in memory, then the store, and
+// embeds it on a miss
+func (m *SemanticMatcher) interestVector(interest string) ([]float32, error) {
+	key := strings.Join(tokenize(interest), " ")
+	if key == "" {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	vector, ok := m.interests[key]
+	m.mu.Unlock()
+	if ok {
+		return vector, nil
+	}
+
+	model := m.embedder.Model()
+	if m.store != nil {
+		cached, err := m.store.GetEmbedding(EmbeddingKindInterest, key, model)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil {
+			m.remember(key, cached.Vector)
+			return cached.Vector, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	vectors, err := m.embedder.Embed(ctx, []string{interest})
+	if err != nil {
+		return nil, err
+	}
+	vector = vectors[0]
+
+	if m.store != nil {
+		err := m.store.SaveEmbedding(&models.Embedding{
+			Kind:        EmbeddingKindInterest,
+			Key:         key,
+			Model:       model,
+			ContentHash: contentHash(key),
+			Vector:      vector,
+			UpdatedAt:   time.Now(),
+		})
+		if err != nil {
+			// Log error but keep the vector for this request
+			fmt.Printf("Failed to cache interest embedding: %v\n", err)
+		}
+	}
+
+	m.remember(key, vector)
+	return vector, nil
+}
+
+func (m *SemanticMatcher) remember(key string, vector []float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.interests) >= maxCachedInterests {
+		m.interests = make(map[string][]float32)
+	}
+	m.interests[key] = vector
+}
@@ -0,0 +1,204 @@
+package collision
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// memoryEmbeddingStore is an in-memory EmbeddingStore
+type memoryEmbeddingStore struct {
+	embeddings map[string]models.Embedding
+	saves      int
+}
+
+func newMemoryEmbeddingStore() *memoryEmbeddingStore {
+	return &memoryEmbeddingStore{embeddings: make(map[string]models.Embedding)}
+}
+
+func (s *memoryEmbeddingStore) GetEmbeddings(kind, model string) ([]models.Embedding, error) {
+	var embeddings []models.Embedding
+	for _, embedding := range s.embeddings {
+		if embedding.Kind == kind && embedding.Model == model {
+			embeddings = append(embeddings, embedding)
+		}
+	}
+	return embeddings, nil
+}
+
+func (s *memoryEmbeddingStore) GetEmbedding(kind, key, model string) (*models.Embedding, error) {
+	embedding, ok := s.embeddings[kind+"|"+model+"|"+key]
+	if !ok {
+		return nil, nil
+	}
+	return &embedding, nil
+}
+
+func (s *memoryEmbeddingStore) SaveEmbedding(embedding *models.Embedding) error {
+	s.saves++
+	s.embeddings[embedding.Kind+"|"+embedding.Model+"|"+embedding.Key] = *embedding
+	return nil
+}
+
+// countingEmbedder wraps an embedder and counts the texts it embeds
+type countingEmbedder struct {
+	Embedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.Embedder.Embed(ctx, texts)
+}
+
+type EmbeddingsTestSuite struct {
+	suite.Suite
+	domains  []models.CollisionDomain
+	store    *memoryEmbeddingStore
+	embedder *countingEmbedder
+}
+
+func (suite *EmbeddingsTestSuite) SetupTest() {
+	suite.domains = []models.CollisionDomain{
+		{
+			ID:          uuid.New().String(),
+			Name:        "Urban Gardening",
+			Category:    "Nature",
+			Description: "Growing plants and vegetables in city spaces",
+			Keywords:    []string{"gardening", "plants", "soil", "compost"},
+			Intensity:   []string{"gentle", "moderate"},
+		},
+		{
+			ID:          uuid.New().String(),
+			Name:        "Jazz Improvisation",
+			Category:    "Music",
+			Description: "Spontaneous creation and structured freedom",
+			Keywords:    []string{"improvisation", "rhythm", "melody"},
+			Intensity:   []string{"moderate", "radical"},
+		},
+		{
+			ID:          uuid.New().String(),
+			Name:        "Quantum Physics",
+			Category:    "Science",
+			Description: "Counterintuitive principles of reality",
+			Keywords:    []string{"uncertainty", "entanglement", "superposition"},
+			Intensity:   []string{"radical"},
+		},
+	}
+	suite.store = newMemoryEmbeddingStore()
+	suite.embedder = &countingEmbedder{Embedder: NewHashingEmbedder(1024)}
+}
+
+func (suite *EmbeddingsTestSuite) TestVectorIndexSearch() {
+	index := NewVectorIndex()
+	index.Add("a", []float32{1, 0, 0})
+	index.Add("b", []float32{0.7, 0.7, 0})
+	index.Add("c", []float32{0, 0, 5})
+	index.Add("short", []float32{1, 0})
+
+	neighbors := index.Search([]float32{2, 0, 0}, 2)
+
+	assert.Len(suite.T(), neighbors, 2)
+	assert.Equal(suite.T(), "a", neighbors[0].ID)
+	assert.InDelta(suite.T(), 1.0, neighbors[0].Score, 1e-6)
+	assert.Equal(suite.T(), "b", neighbors[1].ID)
+	assert.InDelta(suite.T(), 0.7071, neighbors[1].Score, 1e-3)
+
+	score, ok := index.Similarity("c", []float32{1, 0, 0})
+	assert.True(suite.T(), ok)
+	assert.InDelta(suite.T(), 0.0, score, 1e-6)
+
+	_, ok = index.Similarity("short", []float32{1, 0, 0})
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), 4, index.Len())
+}
+
+func (suite *EmbeddingsTestSuite) TestHashingEmbedderCapturesOverlap() {
+	vectors, err := suite.embedder.Embed(context.Background(), []string{"garden", "Urban gardening and plants", "quantum entanglement"})
+	assert.NoError(suite.T(), err)
+
+	index := NewVectorIndex()
+	index.Add("gardening", vectors[1])
+	index.Add("quantum", vectors[2])
+
+	neighbors := index.Search(vectors[0], 0)
+	assert.Equal(suite.T(), "gardening", neighbors[0].ID)
+	assert.Greater(suite.T(), neighbors[0].Score, neighbors[1].Score)
+}
+
+func (suite *EmbeddingsTestSuite) TestEmbedDomainsUsesCache() {
+	index, embedded, err := EmbedDomains(context.Background(), suite.embedder, suite.store, suite.domains, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, embedded)
+	assert.Equal(suite.T(), 3, index.Len())
+	assert.Equal(suite.T(), 3, suite.store.saves)
+
+	// Unchanged domains are reused; an edited one is re-embedded
+	suite.domains[1].Description = "Call and response between musicians"
+	index, embedded, err = EmbedDomains(context.Background(), suite.embedder, suite.store, suite.domains, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, embedded)
+	assert.Equal(suite.T(), 3, index.Len())
+	assert.Equal(suite.T(), 4, suite.embedder.texts)
+
+	_, embedded, err = EmbedDomains(context.Background(), suite.embedder, suite.store, suite.domains, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, embedded)
+}
+
+func (suite *EmbeddingsTestSuite) TestSemanticMatcherCachesInterests() {
+	index, _, err := EmbedDomains(context.Background(), suite.embedder, suite.store, suite.domains, false)
+	assert.NoError(suite.T(), err)
+	embeddedDomains := suite.embedder.texts
+
+	matcher := NewSemanticMatcher(suite.embedder, suite.store, index, 0.1)
+
+	neighbor, ok, err := matcher.Nearest([]string{"Gardening", "compost"})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), suite.domains[0].ID, neighbor.ID)
+
+	_, _, err = matcher.Similarity([]string{"gardening"}, suite.domains[2].ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), embeddedDomains+2, suite.embedder.texts)
+
+	cached, err := suite.store.GetEmbedding(EmbeddingKindInterest, "gardening", suite.embedder.Model())
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), cached)
+
+	// A fresh matcher reads interests back from the store
+	matcher = NewSemanticMatcher(suite.embedder, suite.store, index, 0.1)
+	_, _, err = matcher.Nearest([]string{"gardening"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), embeddedDomains+2, suite.embedder.texts)
+
+	_, ok, err = matcher.Nearest([]string{"xylophone"})
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), ok)
+}
+
+func (suite *EmbeddingsTestSuite) TestEngineUsesSemanticMatcher() {
+	index, _, err := EmbedDomains(context.Background(), suite.embedder, suite.store, suite.domains, false)
+	assert.NoError(suite.T(), err)
+
+	engine := NewCollisionEngine(suite.domains)
+	engine.UseSemanticMatcher(NewSemanticMatcher(suite.embedder, suite.store, index, 0.1))
+
+	// "gardener" has no keyword match but shares trigrams with the gardening domain
+	assert.False(suite.T(), NewCollisionEngine(suite.domains).MatchesInterests([]string{"gardener"}))
+	assert.True(suite.T(), engine.MatchesInterests([]string{"gardener"}))
+	assert.Equal(suite.T(), "Urban Gardening", engine.selectPrimaryDomain([]string{"gardener"}))
+
+	near := engine.calculateNoveltyScore([]string{"gardener"}, suite.domains[0])
+	far := engine.calculateNoveltyScore([]string{"gardener"}, suite.domains[1])
+	assert.Less(suite.T(), near, far)
+}
+
+func TestEmbeddingsTestSuite(t *testing.T) {
+	suite.Run(t, new(EmbeddingsTestSuite))
+}
@@ -15,7 +15,8 @@ import (
 )
 
 type CollisionEngine struct {
-	Domains []models.CollisionDomain
+	Domains  []models.CollisionDomain
+	semantic *SemanticMatcher // optional; keyword matching is used when nil
 }
 
 type DomainMatch struct {
@@ -32,6 +33,12 @@ func NewCollisionEngine(domains []models.CollisionDomain) *CollisionEngine {
 	}
 }
 
+// UseSemanticMatcher switches interest matching and novelty scoring to cosine
+// similarity between embeddings, falling back to keywords on errors
+func (e *CollisionEngine) UseSemanticMatcher(matcher *SemanticMatcher) {
+	e.semantic = matcher
+}
+
 // GenerateCollision creates a collision between user interests and an unexpected domain
 func (e *CollisionEngine) GenerateCollision(input models.CollisionInput) (*models.CollisionResult, error) {
 	// 1. Find primary domain from user interests
@@ -40,6 +47,46 @@ func (e *CollisionEngine) GenerateCollision(input models.CollisionInput) (*model
 	// 2. Apply anti-echo chamber algorithm to find collision domain
 	collisionDomain, reasoning := e.selectCollisionDomain(input, primaryDomain)
 	
+	return e.buildCollision(input, primaryDomain, collisionDomain, reasoning), nil
+}
+
+// GenerateCollisionWithDomain creates a collision against a domain chosen outside
+// the catalog, such as one proposed by the AI service
+func (e *CollisionEngine) GenerateCollisionWithDomain(input models.CollisionInput, domain models.CollisionDomain) (*models.CollisionResult, error) {
+	primaryDomain := e.selectPrimaryDomain(input.UserInterests)
+	
+	relevance := e.calculateDomainRelevance(input, domain)
+	novelty := e.calculateNoveltyScore(input.UserInterests, domain)
+	reasoning := e.generateReasoningSnippet(input.CurrentProject, domain, relevance, novelty)
+	
+	return e.buildCollision(input, primaryDomain, domain, reasoning), nil
+}
+
+// MatchesInterests reports whether any catalog domain matches the user's interests
+func (e *CollisionEngine) MatchesInterests(interests []string) bool {
+	if _, ok := e.nearestDomain(interests); ok {
+		return true
+	}
+	
+	for _, domain := range e.Domains {
+		if e.calculateInterestRelevance(interests, domain) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainNames returns the names of every catalog domain
+func (e *CollisionEngine) DomainNames() []string {
+	names := make([]string, 0, len(e.Domains))
+	for _, domain := range e.Domains {
+		names = append(names, domain.Name)
+	}
+	return names
+}
+
+// buildCollision assembles the result for a chosen collision domain
+func (e *CollisionEngine) buildCollision(input models.CollisionInput, primaryDomain string, collisionDomain models.CollisionDomain, reasoning string) *models.CollisionResult {
 	// 3. Generate connection hash for caching (for future use)
 	_ = e.generateConnectionHash(input, collisionDomain.Name)
 	
@@ -56,7 +103,7 @@ func (e *CollisionEngine) GenerateCollision(input models.CollisionInput) (*model
 	// 5. Generate spark questions, examples, and next steps
 	e.enrichCollisionResult(result, input, collisionDomain)
 	
-	return result, nil
+	return result
 }
 
 // selectPrimaryDomain chooses the most relevant domain from user interests
@@ -65,6 +112,11 @@ func (e *CollisionEngine) selectPrimaryDomain(interests []string) string {
 		return "General Innovation"
 	}
 	
+	// Prefer the nearest domain by embedding similarity when available
+	if domain, ok := e.nearestDomain(interests); ok {
+		return domain.Name
+	}
+	
 	// Find the domain that best matches user interests
 	bestMatch := ""
 	highestScore := 0.0
@@ -87,8 +139,15 @@ func (e *CollisionEngine) selectPrimaryDomain(interests []string) string {
 
 // selectCollisionDomain implements anti-echo chamber algorithm
 func (e *CollisionEngine) selectCollisionDomain(input models.CollisionInput, primaryDomain string) (models.CollisionDomain, string) {
-	candidates := e.filterCandidateDomains(input, primaryDomain)
+	matches := e.rankCandidates(input, e.filterCandidateDomains(input, primaryDomain))
 	
+	// Select from top candidates with weighted randomness
+	selected := e.selectWithRandomness(matches, input.CollisionIntensity)
+	return selected.Domain, selected.Reasoning
+}
+
+// rankCandidates scores candidate domains for the input, best first
+func (e *CollisionEngine) rankCandidates(input models.CollisionInput, candidates []models.CollisionDomain) []DomainMatch {
 	// Score each candidate domain
 	var matches []DomainMatch
 	for _, domain := range candidates {
@@ -109,14 +168,12 @@ func (e *CollisionEngine) selectCollisionDomain(input models.CollisionInput, pri
 		})
 	}
 	
-	// Sort by overall score and add randomness
+	// Sort by overall score
 	sort.Slice(matches, func(i, j int) bool {
 		return matches[i].OverallScore > matches[j].OverallScore
 	})
 	
-	// Select from top candidates with weighted randomness
-	selected := e.selectWithRandomness(matches, input.CollisionIntensity)
-	return selected.Domain, selected.Reasoning
+	return matches
 }
 
 // filterCandidateDomains removes unsuitable domains
@@ -183,6 +240,49 @@ func (e *CollisionEngine) calculateInterestRelevance(interests []string, domain
 	return score / totalPossible
 }
 
+// nearestDomain returns the catalog domain most similar to the interests by
+// embedding, and false if there is no semantic matcher or no close enough domain
+func (e *CollisionEngine) nearestDomain(interests []string) (models.CollisionDomain, bool) {
+	if e.semantic == nil || len(interests) == 0 {
+		return models.CollisionDomain{}, false
+	}
+	
+	neighbor, ok, err := e.semantic.Nearest(interests)
+	if err != nil {
+		fmt.Printf("Semantic domain matching failed: %v\n", err)
+		return models.CollisionDomain{}, false
+	}
+	if !ok {
+		return models.CollisionDomain{}, false
+	}
+	
+	for _, domain := range e.Domains {
+		if domain.ID == neighbor.ID {
+			return domain, true
+		}
+	}
+	return models.CollisionDomain{}, false
+}
+
+// semanticRelevance returns the cosine similarity between the interests and the
+// domain, clamped to 0-1, and false if it can't be computed
+func (e *CollisionEngine) semanticRelevance(interests []string, domain models.CollisionDomain) (float64, bool) {
+	if e.semantic == nil || len(interests) == 0 {
+		return 0, false
+	}
+	
+	similarity, ok, err := e.semantic.Similarity(interests, domain.ID)
+	if err != nil {
+		fmt.Printf("Semantic relevance failed: %v\n", err)
+		return 0, false
+	}
+	if !ok {
+		return 0, false
+	}
+	
+	return math.Max(0, math.Min(1, similarity)), true
+}
+
 // calculateDomainRelevance scores domain relevance to project context
 func (e *CollisionEngine) calculateDomainRelevance(input models.CollisionInput, domain models.CollisionDomain) float64 {
 	score := 0.0
@@ -234,7 +334,10 @@ func (e *CollisionEngine) calculateDomainRelevance(input models.CollisionInput,
 // calculateNoveltyScore measures how unexpected the domain is
 func (e *CollisionEngine) calculateNoveltyScore(interests []string, domain models.CollisionDomain) float64 {
 	// Higher novelty = lower relevance to existing interests
-	relevance := e.calculateInterestRelevance(interests, domain)
+	relevance, ok := e.semanticRelevance(interests, domain)
+	if !ok {
+		relevance = e.calculateInterestRelevance(interests, domain)
+	}
 	
 	// Invert relevance for novelty, but keep some floor
 	novelty := math.Max(0.2, 1.0-relevance)
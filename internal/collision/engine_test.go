@@ -83,6 +83,39 @@ func (suite *CollisionEngineTestSuite) TestGenerateCollision() {
 	assert.WithinDuration(suite.T(), time.Now(), result.Timestamp, 5*time.Second)
 }
 
+func (suite *CollisionEngineTestSuite) TestGenerateCollisionWithDomain() {
+	input := models.CollisionInput{
+		UserInterests:      []string{"knitting"},
+		CurrentProject:     "Mobile app for habit tracking",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+	}
+	domain := models.CollisionDomain{
+		Name:        "Beekeeping",
+		Category:    "Natural Systems",
+		Description: "Managing colonies of honey bees",
+		Keywords:    []string{"hive", "swarm", "pollination"},
+		Examples:    []string{"Waggle dance communication", "Seasonal hive inspections"},
+		Intensity:   []string{"gentle", "moderate", "radical"},
+		Tier:        "custom",
+	}
+	
+	result, err := suite.engine.GenerateCollisionWithDomain(input, domain)
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Beekeeping", result.CollisionDomain)
+	assert.Equal(suite.T(), "Knitting", result.PrimaryDomain)
+	assert.Len(suite.T(), result.Examples, 2)
+	assert.NotEmpty(suite.T(), result.Connection)
+}
+
+func (suite *CollisionEngineTestSuite) TestMatchesInterests() {
+	assert.True(suite.T(), suite.engine.MatchesInterests([]string{"nature"}))
+	assert.True(suite.T(), suite.engine.MatchesInterests([]string{"knitting", "improvisation"}))
+	assert.False(suite.T(), suite.engine.MatchesInterests([]string{"knitting"}))
+	assert.Equal(suite.T(), []string{"Biomimicry", "Jazz Improvisation", "Quantum Physics"}, suite.engine.DomainNames())
+}
+
 func (suite *CollisionEngineTestSuite) TestSelectPrimaryDomain() {
 	// Test with matching interests
 	interests := []string{"nature", "biology"}
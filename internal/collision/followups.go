@@ -0,0 +1,256 @@
+package collision
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// ErrNoNearbyDomain is returned by Pivot when no unexplored domain is close enough to pivot to
+var ErrNoNearbyDomain = errors.New("no nearby collision domain to pivot to")
+
+// ErrMaxIntensity is returned by NextIntensity for sessions already at the highest intensity
+var ErrMaxIntensity = errors.New("collision is already at the highest intensity")
+
+// intensityLevels are the collision intensities from mildest to wildest
+var intensityLevels = []string{"gentle", "moderate", "radical"}
+
+// followUpItems is how many new spark questions and next steps a deepen adds
+const followUpItems = 4
+
+// NextIntensity returns the intensity one level above current
+func NextIntensity(current string) (string, error) {
+	for i, level := range intensityLevels {
+		if level == current {
+			if i == len(intensityLevels)-1 {
+				return "", ErrMaxIntensity
+			}
+			return intensityLevels[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("unknown intensity %q", current)
+}
+
+// Deepen builds a follow-up on the same domain pair as a previous result, keeping
+// its connection and examples and adding spark questions and next steps it
+// doesn't already have
+func (e *CollisionEngine) Deepen(input models.CollisionInput, domain models.CollisionDomain, previous models.CollisionResult) *models.CollisionResult {
+	result := &models.CollisionResult{
+		ID:              uuid.New().String(),
+		PrimaryDomain:   previous.PrimaryDomain,
+		CollisionDomain: domain.Name,
+		Connection:      previous.Connection,
+		QualityScore:    previous.QualityScore,
+		Examples:        previous.Examples,
+		Timestamp:       time.Now(),
+	}
+
+	result.SparkQuestions = AppendNew(previous.SparkQuestions, e.generateDeeperQuestions(input, domain), followUpItems)
+	result.NextSteps = AppendNew(previous.NextSteps, e.generateDeeperSteps(input, domain), followUpItems)
+
+	return result
+}
+
+// Pivot builds a collision for the same project against a domain near the one it
+// came from. Domains named in explored, such as those earlier in the exploration
+// tree, are skipped.
+func (e *CollisionEngine) Pivot(input models.CollisionInput, from models.CollisionDomain, explored []string) (*models.CollisionResult, models.CollisionDomain, error) {
+	skip := map[string]bool{strings.ToLower(from.Name): true}
+	for _, name := range explored {
+		skip[strings.ToLower(name)] = true
+	}
+
+	var candidates, compatible []models.CollisionDomain
+	for _, domain := range e.NearbyDomains(from, 10) {
+		if skip[strings.ToLower(domain.Name)] {
+			continue
+		}
+		candidates = append(candidates, domain)
+		if e.isIntensityCompatible(domain, input.CollisionIntensity) {
+			compatible = append(compatible, domain)
+		}
+	}
+	if len(compatible) > 0 {
+		candidates = compatible
+	}
+	if len(candidates) == 0 {
+		return nil, models.CollisionDomain{}, ErrNoNearbyDomain
+	}
+
+	// Pick among the closest few so repeated pivots don't always land on the same domain
+	if len(candidates) > 3 {
+		candidates = candidates[:3]
+	}
+	domain := candidates[rand.Intn(len(candidates))]
+
+	relevance := e.calculateDomainRelevance(input, domain)
+	novelty := e.calculateNoveltyScore(input.UserInterests, domain)
+	reasoning := fmt.Sprintf("%s It sits close to %s, so what you found there carries over.",
+		e.generateReasoningSnippet(input.CurrentProject, domain, relevance, novelty), from.Name)
+
+	return e.buildCollision(input, e.selectPrimaryDomain(input.UserInterests), domain, reasoning), domain, nil
+}
+
+// NearbyDomains returns up to k catalog domains closest to the given one, best
+// first. Embedding similarity is used when a semantic matcher is configured;
+// otherwise domains are related by shared category and keywords.
+func (e *CollisionEngine) NearbyDomains(domain models.CollisionDomain, k int) []models.CollisionDomain {
+	byID := make(map[string]models.CollisionDomain, len(e.Domains))
+	for _, candidate := range e.Domains {
+		byID[candidate.ID] = candidate
+	}
+
+	if e.semantic != nil {
+		if neighbors := e.semantic.RelatedDomains(domain.ID, k); len(neighbors) > 0 {
+			nearby := make([]models.CollisionDomain, 0, len(neighbors))
+			for _, neighbor := range neighbors {
+				if candidate, ok := byID[neighbor.ID]; ok {
+					nearby = append(nearby, candidate)
+				}
+			}
+			return nearby
+		}
+	}
+
+	type scored struct {
+		domain models.CollisionDomain
+		score  float64
+	}
+	var matches []scored
+	for _, candidate := range e.Domains {
+		if candidate.ID == domain.ID || strings.EqualFold(candidate.Name, domain.Name) {
+			continue
+		}
+		score := keywordOverlap(domain.Keywords, candidate.Keywords)
+		if strings.EqualFold(candidate.Category, domain.Category) {
+			score += 0.5
+		}
+		if score > 0 {
+			matches = append(matches, scored{candidate, score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].domain.Name < matches[j].domain.Name
+	})
+
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	nearby := make([]models.CollisionDomain, 0, len(matches))
+	for _, match := range matches {
+		nearby = append(nearby, match.domain)
+	}
+	return nearby
+}
+
+// keywordOverlap is the Jaccard similarity of two keyword lists, ignoring case
+func keywordOverlap(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, keyword := range a {
+		set[strings.ToLower(keyword)] = true
+	}
+
+	union := len(set)
+	shared := 0
+	seen := make(map[string]bool, len(b))
+	for _, keyword := range b {
+		keyword = strings.ToLower(keyword)
+		if seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		if set[keyword] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// AppendNew appends up to limit items to existing, skipping any already present (ignoring case)
+func AppendNew(existing, items []string, limit int) []string {
+	seen := make(map[string]bool, len(existing)+len(items))
+	merged := append([]string(nil), existing...)
+	for _, item := range existing {
+		seen[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+
+	added := 0
+	for _, item := range items {
+		key := strings.ToLower(strings.TrimSpace(item))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, item)
+		added++
+		if added == limit {
+			break
+		}
+	}
+	return merged
+}
+
+// generateDeeperQuestions creates follow-up questions that dig into specific
+// keywords and examples of the domain
+func (e *CollisionEngine) generateDeeperQuestions(input models.CollisionInput, domain models.CollisionDomain) []string {
+	questions := []string{
+		fmt.Sprintf("If %s were the only lens you could use on %s, what would you change first?",
+			domain.Name, input.CurrentProject),
+		fmt.Sprintf("Which problem in %s have %s practitioners already solved in a different form?",
+			input.CurrentProject, strings.ToLower(domain.Category)),
+	}
+
+	for _, keyword := range domain.Keywords {
+		questions = append(questions,
+			fmt.Sprintf("Where in %s does '%s' already happen without anyone noticing?", input.CurrentProject, keyword))
+	}
+	for _, example := range domain.Examples {
+		questions = append(questions,
+			fmt.Sprintf("What would a %s version of \"%s\" look like?", input.ProjectType, example))
+	}
+
+	questions = append(questions,
+		fmt.Sprintf("What would have to be true for %s to fail because it ignored %s?",
+			input.CurrentProject, domain.Name))
+
+	return questions
+}
+
+// generateDeeperSteps creates follow-up steps that move from research to experiments
+func (e *CollisionEngine) generateDeeperSteps(input models.CollisionInput, domain models.CollisionDomain) []string {
+	steps := []string{
+		fmt.Sprintf("List the three biggest constraints of %s and find how %s handles the same constraints",
+			input.CurrentProject, domain.Name),
+	}
+
+	for _, keyword := range domain.Keywords {
+		steps = append(steps,
+			fmt.Sprintf("Run a one-week experiment applying '%s' to one part of %s", keyword, input.CurrentProject))
+	}
+
+	steps = append(steps,
+		fmt.Sprintf("Interview someone who works in %s about how they would approach %s",
+			domain.Name, input.CurrentProject),
+		fmt.Sprintf("Write a one-page brief explaining %s to your team through the lens of %s",
+			input.CurrentProject, domain.Name),
+	)
+
+	return steps
+}
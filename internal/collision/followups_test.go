@@ -0,0 +1,183 @@
+package collision
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type FollowUpTestSuite struct {
+	suite.Suite
+	engine  *CollisionEngine
+	domains []models.CollisionDomain
+	input   models.CollisionInput
+}
+
+func (suite *FollowUpTestSuite) SetupTest() {
+	suite.domains = []models.CollisionDomain{
+		{
+			ID:        uuid.New().String(),
+			Name:      "Biomimicry",
+			Category:  "Nature",
+			Keywords:  []string{"evolution", "adaptation", "efficiency"},
+			Examples:  []string{"Velcro from burrs"},
+			Intensity: []string{"gentle", "moderate"},
+		},
+		{
+			ID:        uuid.New().String(),
+			Name:      "Ecology",
+			Category:  "Nature",
+			Keywords:  []string{"ecosystems", "adaptation"},
+			Intensity: []string{"gentle", "moderate"},
+		},
+		{
+			ID:        uuid.New().String(),
+			Name:      "Mycology",
+			Category:  "Nature",
+			Keywords:  []string{"networks", "decomposition"},
+			Intensity: []string{"moderate", "radical"},
+		},
+		{
+			ID:        uuid.New().String(),
+			Name:      "Jazz Improvisation",
+			Category:  "Music",
+			Keywords:  []string{"improvisation", "spontaneity"},
+			Intensity: []string{"moderate", "radical"},
+		},
+	}
+	suite.engine = NewCollisionEngine(suite.domains)
+	suite.input = models.CollisionInput{
+		UserInterests:      []string{"design"},
+		CurrentProject:     "habit tracking app",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+	}
+}
+
+func (suite *FollowUpTestSuite) TestNextIntensity() {
+	next, err := NextIntensity("gentle")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "moderate", next)
+
+	next, err = NextIntensity("moderate")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "radical", next)
+
+	_, err = NextIntensity("radical")
+	assert.Equal(suite.T(), ErrMaxIntensity, err)
+
+	_, err = NextIntensity("extreme")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *FollowUpTestSuite) TestDeepenAddsNewItems() {
+	domain := suite.domains[0]
+	previous, err := suite.engine.GenerateCollisionWithDomain(suite.input, domain)
+	assert.NoError(suite.T(), err)
+
+	result := suite.engine.Deepen(suite.input, domain, *previous)
+
+	assert.NotEqual(suite.T(), previous.ID, result.ID)
+	assert.Equal(suite.T(), previous.Connection, result.Connection)
+	assert.Equal(suite.T(), domain.Name, result.CollisionDomain)
+	assert.Equal(suite.T(), previous.SparkQuestions, result.SparkQuestions[:len(previous.SparkQuestions)])
+	assert.Len(suite.T(), result.SparkQuestions, len(previous.SparkQuestions)+followUpItems)
+	assert.Len(suite.T(), result.NextSteps, len(previous.NextSteps)+followUpItems)
+	assert.False(suite.T(), hasDuplicates(result.SparkQuestions))
+	assert.False(suite.T(), hasDuplicates(result.NextSteps))
+
+	// Deepening again keeps adding rather than repeating
+	again := suite.engine.Deepen(suite.input, domain, *result)
+	assert.Greater(suite.T(), len(again.SparkQuestions), len(result.SparkQuestions))
+	assert.False(suite.T(), hasDuplicates(again.SparkQuestions))
+}
+
+func (suite *FollowUpTestSuite) TestNearbyDomainsByKeywordsAndCategory() {
+	nearby := suite.engine.NearbyDomains(suite.domains[0], 5)
+
+	names := make([]string, 0, len(nearby))
+	for _, domain := range nearby {
+		names = append(names, domain.Name)
+	}
+	// Ecology shares the category and a keyword, Mycology only the category
+	assert.Equal(suite.T(), []string{"Ecology", "Mycology"}, names)
+}
+
+func (suite *FollowUpTestSuite) TestNearbyDomainsBySimilarity() {
+	index, _, err := EmbedDomains(context.Background(), NewHashingEmbedder(1024), newMemoryEmbeddingStore(), suite.domains, false)
+	assert.NoError(suite.T(), err)
+	suite.engine.UseSemanticMatcher(NewSemanticMatcher(NewHashingEmbedder(1024), newMemoryEmbeddingStore(), index, 0.1))
+
+	nearby := suite.engine.NearbyDomains(suite.domains[0], 2)
+	assert.Len(suite.T(), nearby, 2)
+	for _, domain := range nearby {
+		assert.NotEqual(suite.T(), "Biomimicry", domain.Name)
+	}
+}
+
+func (suite *FollowUpTestSuite) TestPivotSkipsExploredDomains() {
+	for i := 0; i < 10; i++ {
+		result, domain, err := suite.engine.Pivot(suite.input, suite.domains[0], []string{"ecology"})
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "Mycology", domain.Name)
+		assert.Equal(suite.T(), "Mycology", result.CollisionDomain)
+		assert.Contains(suite.T(), result.Connection, "Biomimicry")
+	}
+
+	_, _, err := suite.engine.Pivot(suite.input, suite.domains[0], []string{"Ecology", "Mycology"})
+	assert.Equal(suite.T(), ErrNoNearbyDomain, err)
+}
+
+func (suite *FollowUpTestSuite) TestAppendNew() {
+	merged := AppendNew([]string{"One", "Two"}, []string{"two", " ", "Three", "Four", "Five"}, 2)
+	assert.Equal(suite.T(), []string{"One", "Two", "Three", "Four"}, merged)
+}
+
+func (suite *FollowUpTestSuite) TestDeepenCollisionWithAI() {
+	prompts, err := NewPromptRegistry()
+	assert.NoError(suite.T(), err)
+
+	client := &fakeChatClient{contents: []string{
+		"1. Where does adaptation already happen in your habit tracking app?\n2. Which streaks would Biomimicry prune?",
+		"1. Run a Biomimicry experiment on reminders\n2. Measure adaptation after a week",
+	}}
+	service := &AIService{
+		client:     client,
+		prompts:    prompts,
+		resilience: ResilienceConfig{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		breaker:    NewCircuitBreaker(5, time.Minute),
+		gate:       NewQualityGate(nil),
+	}
+
+	domain := suite.domains[0]
+	previous := models.CollisionResult{
+		SparkQuestions: []string{"Which streaks would Biomimicry prune?"},
+		NextSteps:      []string{"Read about adaptation"},
+	}
+	result := suite.engine.Deepen(suite.input, domain, previous)
+
+	err = service.DeepenCollision(uuid.New(), result, suite.input, domain, previous)
+	assert.NoError(suite.T(), err)
+
+	// Questions the user already had are not repeated
+	assert.Equal(suite.T(), []string{
+		"Which streaks would Biomimicry prune?",
+		"Where does adaptation already happen in your habit tracking app?",
+	}, result.SparkQuestions)
+	assert.Len(suite.T(), result.NextSteps, 3)
+	assert.Equal(suite.T(), "v1", result.PromptVersions[PromptDeeperQuestions])
+
+	// The previous items are sent so the model can build on them
+	assert.True(suite.T(), strings.Contains(client.requests[0].Messages[1].Content, "Which streaks would Biomimicry prune?"))
+}
+
+func TestFollowUpTestSuite(t *testing.T) {
+	suite.Run(t, new(FollowUpTestSuite))
+}
@@ -0,0 +1,169 @@
+package collision
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	// GroupInterestLimit is how many interests a group's merged input keeps.
+	// Relevance is averaged over interests, so more would blur every match.
+	GroupInterestLimit = 6
+
+	// groupOverlapShare is the share of the merged interests that goes to
+	// interests several participants listed. The rest makes sure interests
+	// only one participant brings are represented too.
+	groupOverlapShare = 0.6
+)
+
+// groupInterest is an interest across a group's participants
+type groupInterest struct {
+	name         string // as the first participant to list it wrote it
+	participants int    // how many participants listed it
+	position     int    // earliest position in a participant's list
+}
+
+// MergeGroupInterests combines the interests of a group's participants into
+// the interests for one collision input, at most limit of them. Interests
+// several participants share come first, most widely shared first, and fill
+// most of the list. The remaining places go to interests only one participant
+// listed, taking turns between participants so each one is represented.
+// Participants are expected to list their interests most important first.
+func MergeGroupInterests(participants [][]string, limit int) []string {
+	interests := map[string]*groupInterest{}
+	var order []string
+	keys := make([][]string, len(participants)) // each participant's interests, cleaned up
+
+	for p, list := range participants {
+		for i, raw := range list {
+			name := strings.Join(strings.Fields(raw), " ")
+			key := strings.ToLower(name)
+			if key == "" || slices.Contains(keys[p], key) {
+				continue
+			}
+			keys[p] = append(keys[p], key)
+
+			interest, ok := interests[key]
+			if !ok {
+				interest = &groupInterest{name: name, position: i}
+				interests[key] = interest
+				order = append(order, key)
+			}
+			interest.participants++
+			interest.position = min(interest.position, i)
+		}
+	}
+
+	var shared []*groupInterest
+	for _, key := range order {
+		if interests[key].participants > 1 {
+			shared = append(shared, interests[key])
+		}
+	}
+	sort.SliceStable(shared, func(i, j int) bool {
+		if shared[i].participants != shared[j].participants {
+			return shared[i].participants > shared[j].participants
+		}
+		return shared[i].position < shared[j].position
+	})
+
+	unique := make([][]string, len(participants))
+	for p := range keys {
+		for _, key := range keys[p] {
+			if interests[key].participants == 1 {
+				unique[p] = append(unique[p], interests[key].name)
+			}
+		}
+	}
+
+	merged := make([]string, 0, limit)
+
+	// Shared interests first, leaving room for everyone's own
+	sharedSlots := int(math.Ceil(float64(limit) * groupOverlapShare))
+	taken := 0
+	for ; taken < len(shared) && taken < sharedSlots; taken++ {
+		merged = append(merged, shared[taken].name)
+	}
+
+	// Then one unique interest from each participant in turn
+	for round := 0; len(merged) < limit; round++ {
+		added := false
+		for _, own := range unique {
+			if round < len(own) && len(merged) < limit {
+				merged = append(merged, own[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	// Any places left go to the rest of the shared interests
+	for ; taken < len(shared) && len(merged) < limit; taken++ {
+		merged = append(merged, shared[taken].name)
+	}
+
+	return merged
+}
+
+// GenerateGroupCollisions creates up to count collisions for a group's merged
+// input, each against a different domain, best first. Domains that any
+// participant already lists as an interest are skipped, unless that leaves
+// nothing to collide with.
+func (e *CollisionEngine) GenerateGroupCollisions(input models.CollisionInput, participants [][]string, count int) ([]*models.CollisionResult, error) {
+	primaryDomain := e.selectPrimaryDomain(input.UserInterests)
+	candidates := e.filterCandidateDomains(input, primaryDomain)
+
+	var unlisted []models.CollisionDomain
+	for _, domain := range candidates {
+		if !listedByAny(domain, participants) {
+			unlisted = append(unlisted, domain)
+		}
+	}
+	if len(unlisted) > 0 {
+		candidates = unlisted
+	}
+
+	matches := e.rankCandidates(input, candidates)
+	results := make([]*models.CollisionResult, 0, count)
+	for len(results) < count && len(matches) > 0 {
+		selected := e.selectWithRandomness(matches, input.CollisionIntensity)
+		for i := range matches {
+			if matches[i].Domain.Name == selected.Domain.Name {
+				matches = append(matches[:i], matches[i+1:]...)
+				break
+			}
+		}
+
+		results = append(results, e.buildCollision(input, primaryDomain, selected.Domain, selected.Reasoning))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].QualityScore > results[j].QualityScore
+	})
+	return results, nil
+}
+
+// listedByAny reports whether any participant lists the domain, or one of its
+// keywords, as an interest
+func listedByAny(domain models.CollisionDomain, participants [][]string) bool {
+	for _, interests := range participants {
+		for _, interest := range interests {
+			interest = strings.TrimSpace(interest)
+			if strings.EqualFold(interest, domain.Name) {
+				return true
+			}
+			for _, keyword := range domain.Keywords {
+				if strings.EqualFold(interest, keyword) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
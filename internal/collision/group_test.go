@@ -0,0 +1,130 @@
+package collision
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type GroupTestSuite struct {
+	suite.Suite
+	engine *CollisionEngine
+}
+
+func (suite *GroupTestSuite) SetupTest() {
+	domain := func(name string, keywords ...string) models.CollisionDomain {
+		return models.CollisionDomain{
+			ID:          uuid.New().String(),
+			Name:        name,
+			Category:    "Test",
+			Description: name + " principles",
+			Keywords:    keywords,
+			Intensity:   []string{"gentle", "moderate", "radical"},
+			Tier:        "basic",
+		}
+	}
+
+	suite.engine = NewCollisionEngine([]models.CollisionDomain{
+		domain("Biomimicry", "evolution", "adaptation"),
+		domain("Jazz Improvisation", "improvisation", "music"),
+		domain("Urban Planning", "cities", "zoning"),
+		domain("Game Design", "games", "play"),
+		domain("Mycology", "fungi", "networks"),
+	})
+}
+
+func (suite *GroupTestSuite) TestMergeWeightsOverlap() {
+	merged := MergeGroupInterests([][]string{
+		{"design", "coffee", "ux"},
+		{"UX", "running", "design"},
+		{"design", "chess"},
+	}, 6)
+
+	// Shared interests lead, most widely shared first, then each participant's own
+	assert.Equal(suite.T(), []string{"design", "ux", "coffee", "running", "chess"}, merged)
+}
+
+func (suite *GroupTestSuite) TestMergeRepresentsEveryone() {
+	merged := MergeGroupInterests([][]string{
+		{"design", "ux", "research", "typography"},
+		{"design", "ux", "research", "sailing"},
+		{"design", "ux", "research", "baking"},
+		{"pottery"},
+	}, 5)
+
+	// Shared interests take at most 60% of the places, so unique ones still fit
+	assert.Equal(suite.T(), []string{"design", "ux", "research", "typography", "sailing"}, merged)
+
+	merged = MergeGroupInterests([][]string{{"a", "b"}, {"c"}, {"d"}}, 3)
+	assert.Equal(suite.T(), []string{"a", "c", "d"}, merged)
+}
+
+func (suite *GroupTestSuite) TestMergeCleansInterests() {
+	merged := MergeGroupInterests([][]string{
+		{"  machine   learning ", "Machine Learning", "", "sailing", "Sailing"},
+		{"machine learning"},
+	}, 6)
+	assert.Equal(suite.T(), []string{"machine learning", "sailing"}, merged)
+
+	assert.Empty(suite.T(), MergeGroupInterests(nil, 6))
+}
+
+func (suite *GroupTestSuite) TestGenerateAvoidsListedDomains() {
+	participants := [][]string{{"biomimicry", "design"}, {"games"}}
+	input := models.CollisionInput{
+		UserInterests:      MergeGroupInterests(participants, GroupInterestLimit),
+		CurrentProject:     "A workshop on accessible city apps",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+	}
+
+	results, err := suite.engine.GenerateGroupCollisions(input, participants, 10)
+	assert.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), results)
+
+	seen := map[string]bool{}
+	for i, result := range results {
+		assert.NotEqual(suite.T(), "Biomimicry", result.CollisionDomain)
+		assert.NotEqual(suite.T(), "Game Design", result.CollisionDomain)
+		assert.False(suite.T(), seen[result.CollisionDomain], "domains repeat")
+		seen[result.CollisionDomain] = true
+		if i > 0 {
+			assert.GreaterOrEqual(suite.T(), results[i-1].QualityScore, result.QualityScore)
+		}
+	}
+}
+
+func (suite *GroupTestSuite) TestGenerateLimitsCount() {
+	input := models.CollisionInput{
+		UserInterests:      []string{"writing"},
+		CurrentProject:     "A newsletter",
+		ProjectType:        "content",
+		CollisionIntensity: "gentle",
+	}
+
+	results, err := suite.engine.GenerateGroupCollisions(input, [][]string{{"writing"}}, 2)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 2)
+}
+
+func (suite *GroupTestSuite) TestGenerateFallsBackWhenEverythingIsListed() {
+	participants := [][]string{{"Biomimicry", "Jazz Improvisation", "Urban Planning", "Game Design", "Mycology"}}
+	input := models.CollisionInput{
+		UserInterests:      []string{"Biomimicry"},
+		CurrentProject:     "An exhibition",
+		ProjectType:        "content",
+		CollisionIntensity: "radical",
+	}
+
+	results, err := suite.engine.GenerateGroupCollisions(input, participants, 3)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), results, 3)
+}
+
+func TestGroupTestSuite(t *testing.T) {
+	suite.Run(t, new(GroupTestSuite))
+}
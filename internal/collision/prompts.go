@@ -0,0 +1,344 @@
+package collision
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+// Prompt names used by the AI service
+const (
+	PromptConnection       = "connection"
+	PromptSparkQuestions   = "spark_questions"
+	PromptExamples         = "examples"
+	PromptNextSteps        = "next_steps"
+	PromptDomainSuggestion = "domain_suggestion"
+	PromptDeeperQuestions  = "deeper_questions"
+	PromptDeeperSteps      = "deeper_steps"
+)
+
+// Prompt template sources
+const (
+	PromptSourceEmbedded = "embedded"
+	PromptSourceFile     = "file"
+	PromptSourceDatabase = "database"
+)
+
+// PromptData is the data passed to prompt templates when rendering
+type PromptData struct {
+	Input     models.CollisionInput
+	Domain    models.CollisionDomain
+	Rejection string   // quality gate reason code when rendering the strict retry
+	Catalog   []string // existing domain names, used when asking for a new domain
+	Previous  []string // items the user already has, used when deepening a collision
+}
+
+// RenderedPrompt is a prompt ready to be sent to the model
+type RenderedPrompt struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	System  string `json:"system"`
+	User    string `json:"user"`
+	Strict  string `json:"strict"` // appended to the user message when retrying rejected output
+}
+
+// defaultStrictTemplate is used by prompts that don't define their own "strict" block
+const defaultStrictTemplate = `Your previous answer was rejected by our quality check{{if .Rejection}} ({{.Rejection}}){{end}}. ` +
+	`Answer again: refer explicitly to {{.Domain.Name}} and the "{{.Input.CurrentProject}}" project, ` +
+	`keep every point distinct and complete, follow the requested format exactly, and do not include links or contact details.`
+
+// PromptRegistry stores versioned prompt templates and renders them with text/template.
+// Embedded defaults are loaded first; files and database rows can add new versions
+// or replace existing ones. Templates define "system" and "user" blocks and may
+// define a "strict" block used when retrying output rejected by the quality gate.
+type PromptRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*promptEntry // name -> version -> entry
+	pinned    map[string]string                  // name -> explicitly active version
+}
+
+type promptEntry struct {
+	meta models.PromptTemplate
+	tmpl *template.Template
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	"first": func(n int, items []string) []string {
+		if n < len(items) {
+			return items[:n]
+		}
+		return items
+	},
+}
+
+// NewPromptRegistry creates a registry populated with the embedded default prompts
+func NewPromptRegistry() (*PromptRegistry, error) {
+	r := &PromptRegistry{
+		templates: make(map[string]map[string]*promptEntry),
+		pinned:    make(map[string]string),
+	}
+
+	files, err := defaultPromptFiles.ReadDir("prompts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded prompts: %w", err)
+	}
+
+	for _, file := range files {
+		content, err := defaultPromptFiles.ReadFile("prompts/" + file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded prompt %s: %w", file.Name(), err)
+		}
+		if err := r.registerFile(file.Name(), string(content), PromptSourceEmbedded); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// LoadDir registers every <name>.<version>.tmpl file in dir, overriding
+// embedded templates that share the same name and version
+func (r *PromptRegistry) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return fmt.Errorf("failed to list prompt templates in %s: %w", dir, err)
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+		if err := r.registerFile(filepath.Base(path), string(content), PromptSourceFile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Register adds a template defined by separate system and user message templates,
+// as stored in the prompt_templates table. Active templates are pinned as the
+// version used for rendering.
+func (r *PromptRegistry) Register(t models.PromptTemplate) error {
+	if t.Name == "" || t.Version == "" {
+		return fmt.Errorf("prompt template requires a name and version")
+	}
+
+	body := `{{define "system"}}` + t.System + `{{end}}{{define "user"}}` + t.User + `{{end}}`
+	if t.Source == "" {
+		t.Source = PromptSourceDatabase
+	}
+
+	return r.register(t, body)
+}
+
+// registerFile parses a template file whose name encodes the prompt name and version
+func (r *PromptRegistry) registerFile(filename, content, source string) error {
+	name, version, err := parsePromptFilename(filename)
+	if err != nil {
+		return err
+	}
+
+	return r.register(models.PromptTemplate{
+		Name:    name,
+		Version: version,
+		Source:  source,
+	}, content)
+}
+
+func (r *PromptRegistry) register(meta models.PromptTemplate, body string) error {
+	tmpl, err := template.New(meta.Name).Funcs(promptFuncs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse prompt %s@%s: %w", meta.Name, meta.Version, err)
+	}
+
+	for _, part := range []string{"system", "user"} {
+		if tmpl.Lookup(part) == nil {
+			return fmt.Errorf("prompt %s@%s is missing a %q block", meta.Name, meta.Version, part)
+		}
+	}
+
+	if tmpl.Lookup("strict") == nil {
+		if _, err := tmpl.New("strict").Parse(defaultStrictTemplate); err != nil {
+			return fmt.Errorf("failed to add strict block to prompt %s@%s: %w", meta.Name, meta.Version, err)
+		}
+	}
+
+	// Keep the raw text around so List can show what each version contains
+	if meta.System == "" && meta.User == "" {
+		meta.System = templateText(tmpl, "system")
+		meta.User = templateText(tmpl, "user")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.templates[meta.Name] == nil {
+		r.templates[meta.Name] = make(map[string]*promptEntry)
+	}
+	r.templates[meta.Name][meta.Version] = &promptEntry{meta: meta, tmpl: tmpl}
+
+	if meta.Active {
+		r.pinned[meta.Name] = meta.Version
+	}
+
+	return nil
+}
+
+// ActiveVersion returns the version used when rendering a prompt: the pinned
+// version if one is set, otherwise the highest registered version
+func (r *PromptRegistry) ActiveVersion(name string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.activeVersionLocked(name)
+}
+
+func (r *PromptRegistry) activeVersionLocked(name string) (string, error) {
+	versions, ok := r.templates[name]
+	if !ok || len(versions) == 0 {
+		return "", fmt.Errorf("unknown prompt %q", name)
+	}
+
+	if pinned, ok := r.pinned[name]; ok {
+		if _, exists := versions[pinned]; exists {
+			return pinned, nil
+		}
+	}
+
+	latest := ""
+	for version := range versions {
+		if latest == "" || compareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// Render renders the active version of a prompt
+func (r *PromptRegistry) Render(name string, data PromptData) (*RenderedPrompt, error) {
+	return r.RenderVersion(name, "", data)
+}
+
+// RenderVersion renders a specific version of a prompt; an empty version selects the active one
+func (r *PromptRegistry) RenderVersion(name, version string, data PromptData) (*RenderedPrompt, error) {
+	r.mu.RLock()
+	if version == "" {
+		active, err := r.activeVersionLocked(name)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		version = active
+	}
+	entry, ok := r.templates[name][version]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt version %s@%s", name, version)
+	}
+
+	system, err := executeTemplate(entry.tmpl, "system", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s@%s system prompt: %w", name, version, err)
+	}
+
+	user, err := executeTemplate(entry.tmpl, "user", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s@%s user prompt: %w", name, version, err)
+	}
+
+	strict, err := executeTemplate(entry.tmpl, "strict", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s@%s strict prompt: %w", name, version, err)
+	}
+
+	return &RenderedPrompt{
+		Name:    name,
+		Version: version,
+		System:  system,
+		User:    user,
+		Strict:  strict,
+	}, nil
+}
+
+// List returns every registered template, with Active set on the version used for rendering
+func (r *PromptRegistry) List() []models.PromptTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var templates []models.PromptTemplate
+	for name, versions := range r.templates {
+		active, _ := r.activeVersionLocked(name)
+		for version, entry := range versions {
+			meta := entry.meta
+			meta.Active = version == active
+			templates = append(templates, meta)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name != templates[j].Name {
+			return templates[i].Name < templates[j].Name
+		}
+		return compareVersions(templates[i].Version, templates[j].Version) < 0
+	})
+
+	return templates
+}
+
+func executeTemplate(tmpl *template.Template, part string, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, part, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func templateText(tmpl *template.Template, part string) string {
+	if t := tmpl.Lookup(part); t != nil && t.Tree != nil {
+		return t.Tree.Root.String()
+	}
+	return ""
+}
+
+// parsePromptFilename splits "connection.v2.tmpl" into ("connection", "v2")
+func parsePromptFilename(filename string) (string, string, error) {
+	base := strings.TrimSuffix(filename, ".tmpl")
+	idx := strings.LastIndex(base, ".")
+	if idx <= 0 || idx == len(base)-1 {
+		return "", "", fmt.Errorf("prompt template %q must be named <name>.<version>.tmpl", filename)
+	}
+	return base[:idx], base[idx+1:], nil
+}
+
+// compareVersions orders versions like v1 < v2 < v10, falling back to string comparison
+func compareVersions(a, b string) int {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,131 @@
+package collision
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type PromptRegistryTestSuite struct {
+	suite.Suite
+	registry *PromptRegistry
+	data     PromptData
+}
+
+func (suite *PromptRegistryTestSuite) SetupTest() {
+	var err error
+	suite.registry, err = NewPromptRegistry()
+	assert.NoError(suite.T(), err)
+
+	suite.data = PromptData{
+		Input: models.CollisionInput{
+			UserInterests:      []string{"machine learning", "design"},
+			CurrentProject:     "AI recommendation system",
+			ProjectType:        "product",
+			CollisionIntensity: "moderate",
+		},
+		Domain: models.CollisionDomain{
+			Name:        "Biomimicry",
+			Category:    "Nature",
+			Description: "How nature solves problems",
+			Keywords:    []string{"evolution", "adaptation", "efficiency", "sustainability", "natural selection", "symbiosis"},
+		},
+	}
+}
+
+func (suite *PromptRegistryTestSuite) TestEmbeddedDefaults() {
+	for _, name := range []string{PromptConnection, PromptSparkQuestions, PromptExamples, PromptNextSteps} {
+		prompt, err := suite.registry.Render(name, suite.data)
+
+		assert.NoError(suite.T(), err, name)
+		assert.Equal(suite.T(), "v1", prompt.Version)
+		assert.NotEmpty(suite.T(), prompt.System)
+		assert.Contains(suite.T(), prompt.User, "Biomimicry")
+		assert.Contains(suite.T(), prompt.User, "AI recommendation system")
+	}
+}
+
+func (suite *PromptRegistryTestSuite) TestRenderConnectionLimitsKeywords() {
+	prompt, err := suite.registry.Render(PromptConnection, suite.data)
+
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), prompt.User, "Key concepts: evolution, adaptation, efficiency, sustainability, natural selection\n")
+	assert.NotContains(suite.T(), prompt.User, "symbiosis")
+	assert.Contains(suite.T(), prompt.User, "User interests: machine learning, design")
+}
+
+func (suite *PromptRegistryTestSuite) TestRegisterNewerVersionBecomesActive() {
+	err := suite.registry.Register(models.PromptTemplate{
+		Name:    PromptConnection,
+		Version: "v2",
+		System:  "Be brief.",
+		User:    "Link {{.Domain.Name}} to {{.Input.CurrentProject}}.",
+	})
+	assert.NoError(suite.T(), err)
+
+	prompt, err := suite.registry.Render(PromptConnection, suite.data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "v2", prompt.Version)
+	assert.Equal(suite.T(), "Link Biomimicry to AI recommendation system.", prompt.User)
+
+	// Older versions can still be rendered explicitly
+	prompt, err = suite.registry.RenderVersion(PromptConnection, "v1", suite.data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "v1", prompt.Version)
+}
+
+func (suite *PromptRegistryTestSuite) TestPinnedVersionWins() {
+	assert.NoError(suite.T(), suite.registry.Register(models.PromptTemplate{
+		Name: PromptConnection, Version: "v2", System: "a", User: "b", Active: true,
+	}))
+	assert.NoError(suite.T(), suite.registry.Register(models.PromptTemplate{
+		Name: PromptConnection, Version: "v10", System: "c", User: "d",
+	}))
+
+	version, err := suite.registry.ActiveVersion(PromptConnection)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "v2", version)
+}
+
+func (suite *PromptRegistryTestSuite) TestLoadDirOverridesEmbedded() {
+	dir := suite.T().TempDir()
+	content := `{{define "system"}}Custom system{{end}}{{define "user"}}Custom {{.Domain.Name}}{{end}}`
+	assert.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "examples.v1.tmpl"), []byte(content), 0o644))
+
+	assert.NoError(suite.T(), suite.registry.LoadDir(dir))
+
+	prompt, err := suite.registry.Render(PromptExamples, suite.data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Custom system", prompt.System)
+	assert.Equal(suite.T(), "Custom Biomimicry", prompt.User)
+}
+
+func (suite *PromptRegistryTestSuite) TestInvalidTemplates() {
+	err := suite.registry.Register(models.PromptTemplate{
+		Name: PromptConnection, Version: "v3", System: "ok", User: "{{.Domain.Name",
+	})
+	assert.Error(suite.T(), err)
+
+	dir := suite.T().TempDir()
+	assert.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "nosystem.v1.tmpl"), []byte(`{{define "user"}}x{{end}}`), 0o644))
+	assert.Error(suite.T(), suite.registry.LoadDir(dir))
+
+	_, err = suite.registry.Render("unknown", suite.data)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *PromptRegistryTestSuite) TestRecordPromptVersion() {
+	result := &models.CollisionResult{}
+	recordPromptVersion(result, PromptConnection, "v3")
+
+	assert.Equal(suite.T(), map[string]string{PromptConnection: "v3"}, result.PromptVersions)
+}
+
+func TestPromptRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(PromptRegistryTestSuite))
+}
@@ -0,0 +1,201 @@
+package collision
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// Reason codes recorded when AI output is rejected by the quality gate
+const (
+	RejectEmpty     = "empty"
+	RejectTruncated = "truncated"
+	RejectOffTopic  = "off_topic"
+	RejectDuplicate = "duplicate"
+	RejectUnsafe    = "unsafe"
+	RejectRefusal   = "refusal"
+	RejectInvalid   = "invalid" // structured output that doesn't parse or fails schema validation
+)
+
+// QualityError is returned when model output still fails the quality gate after the strict retry
+type QualityError struct {
+	Reason string
+}
+
+func (e *QualityError) Error() string {
+	return fmt.Sprintf("ai output rejected: %s", e.Reason)
+}
+
+// Local heuristics applied regardless of the configured blocklist
+var (
+	unsafePattern  = regexp.MustCompile(`(?i)(https?://|www\.|[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}|ignore (all )?previous instructions|system prompt)`)
+	refusalPattern = regexp.MustCompile(`(?i)(as an ai (language )?model|i'm sorry, but|i am sorry, but|i cannot (help|assist|provide)|i can't (help|assist|provide))`)
+)
+
+// QualityGate checks model output with local heuristics before it replaces template output
+type QualityGate struct {
+	blocklist *regexp.Regexp
+}
+
+// NewQualityGate creates a gate that also rejects any of the given terms (whole words, case-insensitive)
+func NewQualityGate(blocklist []string) *QualityGate {
+	var terms []string
+	for _, term := range blocklist {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+
+	gate := &QualityGate{}
+	if len(terms) > 0 {
+		gate.blocklist = regexp.MustCompile(`(?i)\b(` + strings.Join(terms, "|") + `)\b`)
+	}
+	return gate
+}
+
+// LoadBlocklist reads one term per line, skipping blank lines and # comments
+func LoadBlocklist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var terms []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms = append(terms, line)
+	}
+
+	return terms, scanner.Err()
+}
+
+// Check returns a reason code if the output should be rejected, or "" if it passes.
+// list is true for numbered-list outputs and false for prose.
+func (g *QualityGate) Check(items []string, finishReason string, list bool, input models.CollisionInput, domain models.CollisionDomain) string {
+	var nonEmpty []string
+	for _, item := range items {
+		if strings.TrimSpace(item) != "" {
+			nonEmpty = append(nonEmpty, item)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return RejectEmpty
+	}
+
+	if finishReason == string(openai.FinishReasonLength) {
+		return RejectTruncated
+	}
+	if !list && !endsSentence(nonEmpty[0]) {
+		return RejectTruncated
+	}
+
+	text := strings.Join(nonEmpty, "\n")
+	if reason := g.CheckSafety(text); reason != "" {
+		return reason
+	}
+
+	units := nonEmpty
+	if !list {
+		units = splitSentences(nonEmpty[0])
+	}
+	if hasDuplicates(units) {
+		return RejectDuplicate
+	}
+
+	if !mentionsTopic(strings.ToLower(text), input, domain) {
+		return RejectOffTopic
+	}
+
+	return ""
+}
+
+// CheckSafety returns RejectUnsafe or RejectRefusal if free text trips the
+// local heuristics or the blocklist, or "" if it passes
+func (g *QualityGate) CheckSafety(text string) string {
+	if unsafePattern.MatchString(text) || (g.blocklist != nil && g.blocklist.MatchString(text)) {
+		return RejectUnsafe
+	}
+	if refusalPattern.MatchString(text) {
+		return RejectRefusal
+	}
+	return ""
+}
+
+// endsSentence reports whether prose ends with terminal punctuation rather than mid-word
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"')*`)
+	if text == "" {
+		return false
+	}
+	switch text[len(text)-1] {
+	case '.', '!', '?':
+		return true
+	}
+	return false
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?'
+	})
+}
+
+// hasDuplicates compares items ignoring case, punctuation and spacing
+func hasDuplicates(items []string) bool {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		key := normalizeForComparison(item)
+		if key == "" {
+			continue
+		}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+	}
+	return false
+}
+
+func normalizeForComparison(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// mentionsTopic reports whether the text refers to the collision domain or the user's project
+func mentionsTopic(text string, input models.CollisionInput, domain models.CollisionDomain) bool {
+	var terms []string
+	terms = append(terms, domain.Name)
+	terms = append(terms, strings.Fields(domain.Name)...)
+	terms = append(terms, domain.Keywords...)
+	terms = append(terms, input.CurrentProject)
+	terms = append(terms, strings.Fields(input.CurrentProject)...)
+
+	for _, term := range terms {
+		term = strings.ToLower(strings.Trim(term, `"'.,:;!?()`))
+		// Skip short words like "the" or "app" that match almost anything
+		if len(term) <= 3 {
+			continue
+		}
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package collision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type QualityGateTestSuite struct {
+	suite.Suite
+	gate   *QualityGate
+	input  models.CollisionInput
+	domain models.CollisionDomain
+}
+
+func (suite *QualityGateTestSuite) SetupTest() {
+	suite.gate = NewQualityGate([]string{"gambling", "crypto scheme"})
+	suite.input = models.CollisionInput{
+		UserInterests:      []string{"design"},
+		CurrentProject:     "habit tracking app",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+	}
+	suite.domain = models.CollisionDomain{
+		Name:     "Jazz Improvisation",
+		Keywords: []string{"spontaneity", "call and response"},
+	}
+}
+
+func (suite *QualityGateTestSuite) check(items []string, finishReason string, list bool) string {
+	return suite.gate.Check(items, finishReason, list, suite.input, suite.domain)
+}
+
+func (suite *QualityGateTestSuite) TestAcceptsGoodOutput() {
+	assert.Equal(suite.T(), "", suite.check([]string{"Jazz improvisation shows how habit tracking can reward spontaneity."}, "stop", false))
+	assert.Equal(suite.T(), "", suite.check([]string{"Use call and response for reminders", "Let streaks swing"}, "stop", true))
+}
+
+func (suite *QualityGateTestSuite) TestRejections() {
+	assert.Equal(suite.T(), RejectEmpty, suite.check(nil, "stop", true))
+	assert.Equal(suite.T(), RejectEmpty, suite.check([]string{"  "}, "stop", false))
+	assert.Equal(suite.T(), RejectTruncated, suite.check([]string{"Jazz improvisation is great."}, "length", false))
+	assert.Equal(suite.T(), RejectTruncated, suite.check([]string{"Jazz improvisation teaches habit tracking to"}, "stop", false))
+	assert.Equal(suite.T(), RejectOffTopic, suite.check([]string{"Drink more water.", "Sleep early."}, "stop", true))
+	assert.Equal(suite.T(), RejectDuplicate, suite.check([]string{"Use spontaneity!", "use spontaneity"}, "stop", true))
+	assert.Equal(suite.T(), RejectDuplicate, suite.check([]string{"Jazz helps habits. Jazz helps habits."}, "stop", false))
+	assert.Equal(suite.T(), RejectUnsafe, suite.check([]string{"Read about jazz improvisation at https://example.com"}, "stop", true))
+	assert.Equal(suite.T(), RejectUnsafe, suite.check([]string{"Add Gambling mechanics to the habit tracking app"}, "stop", true))
+	assert.Equal(suite.T(), RejectRefusal, suite.check([]string{"As an AI language model I cannot discuss jazz improvisation."}, "stop", false))
+}
+
+func (suite *QualityGateTestSuite) TestBlocklistMatchesWholeWords() {
+	gate := NewQualityGate([]string{"ass"})
+	assert.Equal(suite.T(), "", gate.Check([]string{"Classic jazz improvisation."}, "stop", false, suite.input, suite.domain))
+}
+
+func (suite *QualityGateTestSuite) TestRetriesOnceWithStrictPrompt() {
+	prompts, err := NewPromptRegistry()
+	assert.NoError(suite.T(), err)
+
+	client := &fakeChatClient{contents: []string{
+		"Drink more water.",
+		"Jazz improvisation turns the habit tracking app into a space for spontaneity.",
+	}}
+	service := &AIService{
+		client:     client,
+		prompts:    prompts,
+		resilience: ResilienceConfig{FailureThreshold: 5, Cooldown: time.Minute},
+		breaker:    NewCircuitBreaker(5, time.Minute),
+		gate:       suite.gate,
+	}
+
+	connection, version, err := service.generateEnhancedConnection(uuid.New(), nil, suite.input, suite.domain)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "v1", version)
+	assert.Contains(suite.T(), connection, "Jazz improvisation")
+	assert.Equal(suite.T(), 2, client.calls)
+	assert.Contains(suite.T(), client.requests[1].Messages[1].Content, "rejected by our quality check (off_topic)")
+	assert.Less(suite.T(), client.requests[1].Temperature, client.requests[0].Temperature)
+}
+
+func (suite *QualityGateTestSuite) TestRecordsReasonWhenRetryFails() {
+	prompts, err := NewPromptRegistry()
+	assert.NoError(suite.T(), err)
+
+	// Every call returns the same off-topic list
+	client := &fakeChatClient{}
+	service := &AIService{
+		client:     client,
+		prompts:    prompts,
+		resilience: ResilienceConfig{FailureThreshold: 5, Cooldown: time.Minute},
+		breaker:    NewCircuitBreaker(5, time.Minute),
+		gate:       suite.gate,
+	}
+
+	result := &models.CollisionResult{Connection: "template", SparkQuestions: []string{"template?"}}
+	err = service.EnhanceCollisionResult(uuid.New(), result, suite.input, suite.domain)
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 8, client.calls)
+	assert.Equal(suite.T(), "template", result.Connection)
+	assert.Equal(suite.T(), []string{"template?"}, result.SparkQuestions)
+	assert.Equal(suite.T(), RejectOffTopic, result.FallbackReasons[PromptSparkQuestions])
+	assert.Equal(suite.T(), RejectTruncated, result.FallbackReasons[PromptConnection])
+	assert.Equal(suite.T(), models.FallbackAIQualityRejected, result.FallbackReason)
+	assert.Equal(suite.T(), int64(8), service.Stats().QualityRejections)
+}
+
+func TestQualityGateTestSuite(t *testing.T) {
+	suite.Run(t, new(QualityGateTestSuite))
+}
@@ -0,0 +1,186 @@
+package collision
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker is rejecting calls to the AI provider
+var ErrCircuitOpen = errors.New("ai provider circuit breaker is open")
+
+// ResilienceConfig controls retries and the circuit breaker around AI provider calls
+type ResilienceConfig struct {
+	MaxRetries       int           // retries after the first attempt for retryable errors
+	BaseDelay        time.Duration // first backoff delay, doubled on every retry
+	MaxDelay         time.Duration // cap for a single backoff delay
+	FailureThreshold int           // consecutive failures that open the breaker
+	Cooldown         time.Duration // how long the breaker stays open before a probe is allowed
+}
+
+// DefaultResilienceConfig returns the settings used when none are configured
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxRetries:       2,
+		BaseDelay:        200 * time.Millisecond,
+		MaxDelay:         2 * time.Second,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops calling the provider after repeated failures. Once the
+// cooldown has elapsed a single probe call is let through: success closes the
+// breaker, failure re-opens it for another cooldown.
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	state         string
+	failures      int
+	threshold     int
+	cooldown      time.Duration
+	openedAt      time.Time
+	probeInFlight bool
+	now           func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{
+		state:     CircuitClosed,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed, returning ErrCircuitOpen if not
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probeInFlight = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probeInFlight {
+			return ErrCircuitOpen
+		}
+		b.probeInFlight = true
+		return nil
+	}
+
+	return nil
+}
+
+// RecordSuccess closes the breaker and resets the failure count
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probeInFlight = false
+}
+
+// RecordFailure counts a failed call and opens the breaker when the threshold is reached
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probeInFlight = false
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// RecordIgnored releases a probe slot for a call whose outcome says nothing about provider health
+func (b *CircuitBreaker) RecordIgnored() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probeInFlight = false
+	}
+}
+
+// State returns the current breaker state. An open breaker whose cooldown has
+// elapsed is reported as half open, since the next call will be a probe.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// isRetryable reports whether an AI provider error is worth retrying:
+// rate limiting, server errors and network timeouts
+func isRetryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == 429 || code >= 500
+}
+
+// backoffDelay returns the delay before retry number attempt (starting at 0),
+// doubling each time with up to 50% jitter
+func backoffDelay(cfg ResilienceConfig, attempt int) time.Duration {
+	delay := cfg.BaseDelay << attempt
+	if delay <= 0 || delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
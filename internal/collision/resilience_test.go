@@ -0,0 +1,182 @@
+package collision
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// fakeChatClient returns queued errors before succeeding, then queued
+// contents (or a fixed list when none are queued)
+type fakeChatClient struct {
+	errs     []error
+	contents []string
+	requests []openai.ChatCompletionRequest
+	calls    int
+}
+
+func (f *fakeChatClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	f.calls++
+	f.requests = append(f.requests, req)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+	}
+	content := "1. First\n2. Second"
+	if len(f.contents) > 0 {
+		content = f.contents[0]
+		f.contents = f.contents[1:]
+	}
+	return openai.ChatCompletionResponse{
+		Model: openai.GPT3Dot5Turbo,
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Content: content}, FinishReason: openai.FinishReasonStop},
+		},
+	}, nil
+}
+
+type ResilienceTestSuite struct {
+	suite.Suite
+	client  *fakeChatClient
+	service *AIService
+	clock   time.Time
+}
+
+func (suite *ResilienceTestSuite) SetupTest() {
+	prompts, err := NewPromptRegistry()
+	assert.NoError(suite.T(), err)
+
+	cfg := ResilienceConfig{
+		MaxRetries:       2,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         2 * time.Millisecond,
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	}
+
+	suite.clock = time.Now()
+	suite.client = &fakeChatClient{}
+	suite.service = &AIService{
+		client:     suite.client,
+		prompts:    prompts,
+		resilience: cfg,
+		breaker:    NewCircuitBreaker(cfg.FailureThreshold, cfg.Cooldown),
+		gate:       NewQualityGate(nil),
+	}
+	suite.service.breaker.now = func() time.Time { return suite.clock }
+}
+
+func rateLimited() error {
+	return &openai.APIError{HTTPStatusCode: 429, Message: "rate limited"}
+}
+
+func (suite *ResilienceTestSuite) TestRetriesRetryableErrors() {
+	suite.client.errs = []error{rateLimited(), &openai.RequestError{HTTPStatusCode: 503}}
+
+	_, err := suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, suite.client.calls)
+	assert.Equal(suite.T(), int64(2), suite.service.Stats().Retries)
+	assert.Equal(suite.T(), CircuitClosed, suite.service.BreakerState())
+}
+
+func (suite *ResilienceTestSuite) TestDoesNotRetryClientErrors() {
+	suite.client.errs = []error{&openai.APIError{HTTPStatusCode: 400, Message: "bad request"}}
+
+	_, err := suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.client.calls)
+	assert.Equal(suite.T(), CircuitClosed, suite.service.BreakerState())
+}
+
+func (suite *ResilienceTestSuite) TestNegativeRetriesStillCallOnce() {
+	suite.service.resilience.MaxRetries = -1
+	suite.client.errs = []error{rateLimited()}
+
+	_, err := suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 1, suite.client.calls)
+	assert.Equal(suite.T(), int64(0), suite.service.Stats().Retries)
+}
+
+func (suite *ResilienceTestSuite) TestBreakerOpensAndProbes() {
+	// Two exhausted calls (3 attempts each) reach the failure threshold
+	for i := 0; i < 6; i++ {
+		suite.client.errs = append(suite.client.errs, rateLimited())
+	}
+	for i := 0; i < 2; i++ {
+		_, err := suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+		assert.Error(suite.T(), err)
+	}
+	assert.Equal(suite.T(), CircuitOpen, suite.service.BreakerState())
+
+	// Open breaker rejects without calling the provider
+	calls := suite.client.calls
+	_, err := suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+	assert.ErrorIs(suite.T(), err, ErrCircuitOpen)
+	assert.Equal(suite.T(), calls, suite.client.calls)
+	assert.Equal(suite.T(), int64(1), suite.service.Stats().Rejected)
+
+	// After the cooldown a successful probe closes it again
+	suite.clock = suite.clock.Add(time.Minute)
+	assert.Equal(suite.T(), CircuitHalfOpen, suite.service.BreakerState())
+
+	_, err = suite.service.createWithRetry(context.Background(), openai.ChatCompletionRequest{})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CircuitClosed, suite.service.BreakerState())
+}
+
+func (suite *ResilienceTestSuite) TestFailedProbeReopens() {
+	breaker := NewCircuitBreaker(1, time.Minute)
+	breaker.now = func() time.Time { return suite.clock }
+
+	breaker.RecordFailure()
+	assert.Equal(suite.T(), CircuitOpen, breaker.State())
+
+	suite.clock = suite.clock.Add(time.Minute)
+	assert.NoError(suite.T(), breaker.Allow())
+	// Only one probe at a time
+	assert.ErrorIs(suite.T(), breaker.Allow(), ErrCircuitOpen)
+
+	breaker.RecordFailure()
+	assert.Equal(suite.T(), CircuitOpen, breaker.State())
+	assert.ErrorIs(suite.T(), breaker.Allow(), ErrCircuitOpen)
+}
+
+func (suite *ResilienceTestSuite) TestEnhanceSkipsProviderWhenOpen() {
+	suite.service.breaker.RecordFailure()
+	suite.service.breaker.RecordFailure()
+
+	result := &models.CollisionResult{Connection: "template"}
+	err := suite.service.EnhanceCollisionResult(uuid.New(), result, models.CollisionInput{}, models.CollisionDomain{Name: "Biomimicry"})
+
+	assert.ErrorIs(suite.T(), err, ErrCircuitOpen)
+	assert.Equal(suite.T(), 0, suite.client.calls)
+	assert.Equal(suite.T(), "template", result.Connection)
+	assert.Equal(suite.T(), models.FallbackAIUnavailable, result.FallbackReason)
+}
+
+func (suite *ResilienceTestSuite) TestIsRetryable() {
+	assert.True(suite.T(), isRetryable(rateLimited()))
+	assert.True(suite.T(), isRetryable(&openai.RequestError{HTTPStatusCode: 502}))
+	assert.False(suite.T(), isRetryable(&openai.APIError{HTTPStatusCode: 401}))
+	assert.False(suite.T(), isRetryable(errors.New("boom")))
+}
+
+func TestResilienceTestSuite(t *testing.T) {
+	suite.Run(t, new(ResilienceTestSuite))
+}
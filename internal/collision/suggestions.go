@@ -0,0 +1,144 @@
+package collision
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// suggestedDomain is the JSON shape the model is asked to return, with the
+// limits enforced by the collision_domains schema
+type suggestedDomain struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Category    string   `json:"category" validate:"required,max=50"`
+	Description string   `json:"description" validate:"required,min=20,max=500"`
+	Keywords    []string `json:"keywords" validate:"min=3,max=10,dive,required,max=50"`
+	Examples    []string `json:"examples" validate:"min=2,max=5,dive,required,max=200"`
+}
+
+var suggestionValidator = validator.New()
+
+// SuggestDomain asks the model for a new collision domain when the user's interests
+// match nothing in the catalog. The proposal is validated against the domain schema
+// and the quality gate, and retried once with stricter instructions if rejected.
+// catalog lists existing domain names, which the proposal must not duplicate.
+func (ai *AIService) SuggestDomain(userID uuid.UUID, input models.CollisionInput, catalog []string) (*models.CollisionDomain, string, error) {
+	data := PromptData{Input: input, Catalog: catalog}
+	prompt, err := ai.prompts.Render(PromptDomainSuggestion, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	content, finishReason, err := ai.complete(userID, prompt, prompt.User, 400, 0.9, 10*time.Second)
+	if err != nil {
+		return nil, "", err
+	}
+
+	domain, reason := ai.checkSuggestedDomain(content, finishReason, catalog)
+	if reason == "" {
+		return domain, prompt.Version, nil
+	}
+
+	// Retry once with stricter instructions, using the same prompt version
+	ai.stats.qualityRejections.Add(1)
+	data.Rejection = reason
+	prompt, err = ai.prompts.RenderVersion(PromptDomainSuggestion, prompt.Version, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	content, finishReason, err = ai.complete(userID, prompt, prompt.User+"\n\n"+prompt.Strict, 400, 0.6, 10*time.Second)
+	if err != nil {
+		return nil, "", err
+	}
+
+	domain, reason = ai.checkSuggestedDomain(content, finishReason, catalog)
+	if reason != "" {
+		ai.stats.qualityRejections.Add(1)
+		return nil, "", &QualityError{Reason: reason}
+	}
+
+	return domain, prompt.Version, nil
+}
+
+// checkSuggestedDomain parses and validates a proposed domain, returning a
+// quality gate reason code if it can't be used
+func (ai *AIService) checkSuggestedDomain(content, finishReason string, catalog []string) (*models.CollisionDomain, string) {
+	if strings.TrimSpace(content) == "" {
+		return nil, RejectEmpty
+	}
+	if finishReason == string(openai.FinishReasonLength) {
+		return nil, RejectTruncated
+	}
+
+	var proposal suggestedDomain
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &proposal); err != nil {
+		return nil, RejectInvalid
+	}
+
+	proposal.Name = strings.TrimSpace(proposal.Name)
+	proposal.Category = strings.TrimSpace(proposal.Category)
+	proposal.Description = strings.TrimSpace(proposal.Description)
+	proposal.Keywords = trimItems(proposal.Keywords)
+	proposal.Examples = trimItems(proposal.Examples)
+
+	if err := suggestionValidator.Struct(&proposal); err != nil {
+		return nil, RejectInvalid
+	}
+
+	text := strings.Join(append(append([]string{proposal.Name, proposal.Category, proposal.Description}, proposal.Keywords...), proposal.Examples...), "\n")
+	if reason := ai.gate.CheckSafety(text); reason != "" {
+		return nil, reason
+	}
+
+	for _, name := range catalog {
+		if strings.EqualFold(name, proposal.Name) {
+			return nil, RejectDuplicate
+		}
+	}
+	if hasDuplicates(proposal.Keywords) || hasDuplicates(proposal.Examples) {
+		return nil, RejectDuplicate
+	}
+
+	now := time.Now()
+	return &models.CollisionDomain{
+		ID:          uuid.New().String(),
+		Name:        proposal.Name,
+		Category:    proposal.Category,
+		Description: proposal.Description,
+		Examples:    proposal.Examples,
+		Keywords:    proposal.Keywords,
+		Intensity:   []string{"gentle", "moderate", "radical"},
+		Tier:        "custom",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, ""
+}
+
+// stripCodeFence removes a surrounding ```json fence that models often add despite instructions
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimPrefix(content, "json")
+	content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	return strings.TrimSpace(content)
+}
+
+func trimItems(items []string) []string {
+	trimmed := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,109 @@
+package collision
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+const validSuggestion = `{
+	"name": "Beekeeping",
+	"category": "Natural Systems",
+	"description": "The craft of managing honey bee colonies and their hives.",
+	"keywords": ["hive", "swarm", "pollination", "waggle dance"],
+	"examples": ["Waggle dance communication", "Seasonal hive inspections", "Queen rearing"]
+}`
+
+type DomainSuggestionTestSuite struct {
+	suite.Suite
+	client  *fakeChatClient
+	service *AIService
+	input   models.CollisionInput
+	catalog []string
+}
+
+func (suite *DomainSuggestionTestSuite) SetupTest() {
+	prompts, err := NewPromptRegistry()
+	suite.Require().NoError(err)
+
+	suite.client = &fakeChatClient{}
+	suite.service = &AIService{
+		client:     suite.client,
+		prompts:    prompts,
+		resilience: ResilienceConfig{FailureThreshold: 5, Cooldown: time.Minute},
+		breaker:    NewCircuitBreaker(5, time.Minute),
+		gate:       NewQualityGate(nil),
+	}
+	suite.input = models.CollisionInput{
+		UserInterests:      []string{"knitting"},
+		CurrentProject:     "habit tracking app",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+	}
+	suite.catalog = []string{"Biomimicry", "Jazz Improvisation"}
+}
+
+func (suite *DomainSuggestionTestSuite) TestReturnsValidatedDomain() {
+	suite.client.contents = []string{"```json\n" + validSuggestion + "\n```"}
+
+	domain, version, err := suite.service.SuggestDomain(uuid.New(), suite.input, suite.catalog)
+
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "v1", version)
+	assert.Equal(suite.T(), "Beekeeping", domain.Name)
+	assert.Equal(suite.T(), "custom", domain.Tier)
+	assert.Equal(suite.T(), []string{"gentle", "moderate", "radical"}, domain.Intensity)
+	assert.Len(suite.T(), domain.Examples, 3)
+	assert.NotEmpty(suite.T(), domain.ID)
+	assert.Contains(suite.T(), suite.client.requests[0].Messages[1].Content, "Biomimicry, Jazz Improvisation")
+}
+
+func (suite *DomainSuggestionTestSuite) TestRetriesInvalidJSON() {
+	suite.client.contents = []string{"Here is a domain: Beekeeping", validSuggestion}
+
+	domain, _, err := suite.service.SuggestDomain(uuid.New(), suite.input, suite.catalog)
+
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Beekeeping", domain.Name)
+	assert.Equal(suite.T(), 2, suite.client.calls)
+	assert.Contains(suite.T(), suite.client.requests[1].Messages[1].Content, "rejected (invalid)")
+}
+
+func (suite *DomainSuggestionTestSuite) TestRejectsCatalogDuplicate() {
+	duplicate := `{"name": "jazz improvisation", "category": "Music", "description": "Spontaneous musical creation within a shared structure.", "keywords": ["solo", "groove", "call and response"], "examples": ["Trading fours", "Modal jams"]}`
+	suite.client.contents = []string{duplicate, duplicate}
+
+	_, _, err := suite.service.SuggestDomain(uuid.New(), suite.input, suite.catalog)
+
+	var qualityErr *QualityError
+	suite.Require().True(errors.As(err, &qualityErr))
+	assert.Equal(suite.T(), RejectDuplicate, qualityErr.Reason)
+}
+
+func (suite *DomainSuggestionTestSuite) TestRejectsSchemaViolations() {
+	cases := map[string]string{
+		"missing name":   `{"category": "Craft", "description": "A description that is long enough.", "keywords": ["a", "b", "c"], "examples": ["x", "y"]}`,
+		"few keywords":   `{"name": "Beekeeping", "category": "Craft", "description": "A description that is long enough.", "keywords": ["hive"], "examples": ["x", "y"]}`,
+		"long category":  `{"name": "Beekeeping", "category": "` + strings.Repeat("x", 60) + `", "description": "A description that is long enough.", "keywords": ["a", "b", "c"], "examples": ["x", "y"]}`,
+		"unsafe content": `{"name": "Beekeeping", "category": "Craft", "description": "Learn more at https://bees.example.com today.", "keywords": ["a", "b", "c"], "examples": ["x", "y"]}`,
+	}
+
+	for name, content := range cases {
+		_, reason := suite.service.checkSuggestedDomain(content, "stop", suite.catalog)
+		assert.NotEmpty(suite.T(), reason, name)
+	}
+
+	_, reason := suite.service.checkSuggestedDomain(validSuggestion, "length", suite.catalog)
+	assert.Equal(suite.T(), RejectTruncated, reason)
+}
+
+func TestDomainSuggestionTestSuite(t *testing.T) {
+	suite.Run(t, new(DomainSuggestionTestSuite))
+}
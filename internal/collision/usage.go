@@ -0,0 +1,43 @@
+package collision
+
+import (
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// UsageRecorder persists token usage for every model call
+type UsageRecorder interface {
+	RecordAIUsage(usage *models.AIUsage) error
+}
+
+// ModelPrice is the USD price per 1K tokens for a model
+type ModelPrice struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+}
+
+// ModelPricing lists known model prices, keyed by model name prefix
+var ModelPricing = map[string]ModelPrice{
+	openai.GPT3Dot5Turbo: {PromptPer1K: 0.0005, CompletionPer1K: 0.0015},
+	openai.GPT4oMini:     {PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+	openai.GPT4o:         {PromptPer1K: 0.0025, CompletionPer1K: 0.01},
+}
+
+// EstimateCost returns the estimated USD cost of a call. Responses report dated
+// model names (e.g. gpt-3.5-turbo-0125), so the longest matching prefix is used.
+func EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	var price ModelPrice
+	matched := ""
+	for name, p := range ModelPricing {
+		if strings.HasPrefix(model, name) && len(name) > len(matched) {
+			matched = name
+			price = p
+		}
+	}
+
+	return float64(promptTokens)/1000*price.PromptPer1K +
+		float64(completionTokens)/1000*price.CompletionPer1K
+}
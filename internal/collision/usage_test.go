@@ -0,0 +1,20 @@
+package collision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateCost(t *testing.T) {
+	// Dated model names match their base pricing
+	cost := EstimateCost("gpt-3.5-turbo-0125", 1000, 2000)
+	assert.InDelta(t, 0.0005+0.003, cost, 1e-9)
+
+	// gpt-4o-mini must not be priced as gpt-4o
+	cost = EstimateCost("gpt-4o-mini-2024-07-18", 1000, 1000)
+	assert.InDelta(t, 0.00015+0.0006, cost, 1e-9)
+
+	// Unknown models are recorded at zero cost
+	assert.Equal(t, 0.0, EstimateCost("unknown-model", 1000, 1000))
+}
@@ -3,11 +3,13 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"idea-collision-engine-api/internal/models"
 )
@@ -47,6 +49,9 @@ func (p *PostgresDB) CreateUser(user *models.User) error {
 	
 	interestsJSON, _ := json.Marshal(user.Interests)
 	
+	// New accounts get the column's default role
+	user.Role = models.RoleUser
+	
 	_, err := p.db.Exec(query,
 		user.ID,
 		user.Email,
@@ -57,127 +62,3470 @@ func (p *PostgresDB) CreateUser(user *models.User) error {
 		user.UpdatedAt,
 	)
 	
-	return err
+	return err
+}
+
+// userColumns selects a user in the order scanUser reads them
+const userColumns = `id, email, password_hash, subscription_tier, interests, created_at, updated_at, COALESCE(display_name, ''), email_verified_at, role`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	var interestsJSON []byte
+	
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.SubscriptionTier,
+		&interestsJSON,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DisplayName,
+		&user.EmailVerifiedAt,
+		&user.Role,
+	)
+	
+	if err != nil {
+		return nil, err
+	}
+	
+	if len(interestsJSON) > 0 {
+		json.Unmarshal(interestsJSON, &user.Interests)
+	}
+	
+	return user, nil
+}
+
+func (p *PostgresDB) GetUserByEmail(email string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users WHERE LOWER(email) = LOWER($1)
+	`
+	
+	return scanUser(p.db.QueryRow(query, email))
+}
+
+func (p *PostgresDB) GetUserByID(id uuid.UUID) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users WHERE id = $1
+	`
+	
+	return scanUser(p.db.QueryRow(query, id))
+}
+
+// UpdateUser saves a user's profile fields (display name and interests) and
+// sets UpdatedAt. Returns sql.ErrNoRows if the user doesn't exist.
+func (p *PostgresDB) UpdateUser(user *models.User) error {
+	query := `
+		UPDATE users
+		SET display_name = NULLIF($2, ''), interests = $3
+		WHERE id = $1
+		RETURNING updated_at
+	`
+	
+	interests := user.Interests
+	if interests == nil {
+		interests = []string{}
+	}
+	interestsJSON, _ := json.Marshal(interests)
+	
+	return p.db.QueryRow(query, user.ID, user.DisplayName, interestsJSON).Scan(&user.UpdatedAt)
+}
+
+// UpdateUserPassword replaces a user's password hash and ends all of their
+// logins by revoking their refresh tokens. Returns sql.ErrNoRows if the user
+// doesn't exist.
+func (p *PostgresDB) UpdateUserPassword(userID uuid.UUID, passwordHash string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1`, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// UpdateUserSubscriptionTier changes a user's subscription tier
+func (p *PostgresDB) UpdateUserSubscriptionTier(userID uuid.UUID, tier string) error {
+	result, err := p.db.Exec(`UPDATE users SET subscription_tier = $2 WHERE id = $1`, userID, tier)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// UpdateUserRole changes a user's role. Returns sql.ErrNoRows if the user
+// doesn't exist.
+func (p *PostgresDB) UpdateUserRole(userID uuid.UUID, role string) error {
+	result, err := p.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, userID, role)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// GrantRoleByEmail gives a role to the verified accounts with the given email
+// addresses, compared case-insensitively, and returns the users who didn't
+// have it yet
+func (p *PostgresDB) GrantRoleByEmail(emails []string, role string) ([]models.User, error) {
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(strings.TrimSpace(email))
+	}
+	
+	rows, err := p.db.Query(`
+		UPDATE users SET role = $2
+		WHERE LOWER(email) = ANY($1) AND email_verified_at IS NOT NULL AND role <> $2
+		RETURNING `+userColumns, pq.Array(lowered), role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	
+	return users, rows.Err()
+}
+
+// SearchUsers returns up to limit users whose ID is query, or whose email or
+// display name contains it, ordered by email
+func (p *PostgresDB) SearchUsers(query string, limit int) ([]models.User, error) {
+	pattern := "%" + likeEscaper.Replace(strings.TrimSpace(query)) + "%"
+	
+	rows, err := p.db.Query(`
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id::text = $1 OR email ILIKE $2 OR display_name ILIKE $2
+		ORDER BY email
+		LIMIT $3
+	`, strings.TrimSpace(query), pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	
+	return users, rows.Err()
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so user input matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetUserByIdentity returns the user linked to a provider's subject. Returns
+// sql.ErrNoRows if no account is linked to it.
+func (p *PostgresDB) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)
+	`
+	
+	return scanUser(p.db.QueryRow(query, provider, subject))
+}
+
+// LinkUserIdentity links a provider's subject to a user, or records another
+// sign-in if it's linked already
+func (p *PostgresDB) LinkUserIdentity(identity *models.UserIdentity) error {
+	if identity.ID == uuid.Nil {
+		identity.ID = uuid.New()
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO user_identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (provider, subject) DO UPDATE SET
+			email = EXCLUDED.email,
+			last_login_at = NOW()
+		RETURNING id, user_id, created_at, last_login_at
+	`, identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+}
+
+// MarkEmailVerified marks a user's email address as verified, as when an
+// identity provider vouches for it. Returns sql.ErrNoRows if the user doesn't
+// exist or no longer has that address.
+func (p *PostgresDB) MarkEmailVerified(userID uuid.UUID, email string) error {
+	result, err := p.db.Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2
+	`, userID, email)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// ErrEmailTaken is returned when an email address already belongs to another account
+var ErrEmailTaken = errors.New("email address already in use")
+
+// CreateEmailChange stores a pending email change, replacing any earlier one for the same user
+func (p *PostgresDB) CreateEmailChange(change *models.EmailChange) error {
+	query := `
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			new_email = EXCLUDED.new_email,
+			token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at,
+			created_at = NOW()
+	`
+	
+	_, err := p.db.Exec(query, change.UserID, change.NewEmail, change.TokenHash, change.ExpiresAt)
+	return err
+}
+
+// ConfirmEmailChange applies the pending email change with the given token hash
+// and returns the user with their new address. Returns sql.ErrNoRows if no
+// unexpired change has that token, and ErrEmailTaken if another account took
+// the address in the meantime.
+func (p *PostgresDB) ConfirmEmailChange(tokenHash string) (*models.User, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	var change models.EmailChange
+	err = tx.QueryRow(`
+		DELETE FROM email_changes
+		WHERE token_hash = $1
+		RETURNING user_id, new_email, expires_at
+	`, tokenHash).Scan(&change.UserID, &change.NewEmail, &change.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !change.ExpiresAt.After(time.Now()) {
+		// Keep the expired change deleted; the user has to request a new one
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	
+	user, err := scanUser(tx.QueryRow(`
+		UPDATE users SET email = $2, email_verified_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns, change.UserID, change.NewEmail))
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	return user, tx.Commit()
+}
+
+// DeleteUser deletes a user's account. Their collision sessions, tags,
+// collections, share links, usage, refresh tokens, linked identities, API
+// keys, pending email changes, team memberships and the team they own are
+// removed with it by the foreign keys' ON DELETE CASCADE. Returns
+// sql.ErrNoRows if the user doesn't exist.
+func (p *PostgresDB) DeleteUser(userID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// ErrActionTokenUsed is returned when a single-use action token was already used
+var ErrActionTokenUsed = errors.New("action token already used")
+
+// ResetUserPassword sets a new password with a password reset token and ends
+// all of the user's logins by revoking their refresh tokens. Returns
+// ErrActionTokenUsed if the token was used before and sql.ErrNoRows if the user
+// doesn't exist.
+func (p *PostgresDB) ResetUserPassword(token *models.UsedActionToken, passwordHash string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	if err := useActionToken(tx, token); err != nil {
+		return err
+	}
+	
+	result, err := tx.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1`, token.UserID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, token.UserID); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// ReclaimUserAccount hands an account over to whoever proved they own its email
+// address: the password is replaced and every refresh token and API key is
+// revoked, so whoever registered the address loses access. Returns
+// sql.ErrNoRows if the user doesn't exist.
+func (p *PostgresDB) ReclaimUserAccount(userID uuid.UUID, passwordHash string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1`, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return err
+	}
+	
+	if _, err := tx.Exec(`
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// VerifyUserEmail marks a user's email address as verified with an email
+// verification token. Returns ErrActionTokenUsed if the token was used before
+// and sql.ErrNoRows if the user doesn't exist or no longer has that address.
+func (p *PostgresDB) VerifyUserEmail(token *models.UsedActionToken, email string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	if err := useActionToken(tx, token); err != nil {
+		return err
+	}
+	
+	result, err := tx.Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2
+	`, token.UserID, email)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// useActionToken records an action token as used, or returns
+// ErrActionTokenUsed if it already was
+func useActionToken(tx *sql.Tx, token *models.UsedActionToken) error {
+	_, err := tx.Exec(`
+		INSERT INTO used_action_tokens (token_id, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, token.TokenID, token.UserID, token.Purpose, token.ExpiresAt)
+	if isUniqueViolation(err) {
+		return ErrActionTokenUsed
+	}
+	return err
+}
+
+// PurgeUsedActionTokens deletes records of used action tokens that expired
+// before the given time. Expired tokens are rejected anyway.
+func (p *PostgresDB) PurgeUsedActionTokens(expiredBefore time.Time) (int64, error) {
+	result, err := p.db.Exec(`DELETE FROM used_action_tokens WHERE expires_at <= $1`, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// exchanged is presented again. The token's whole family is revoked, since
+// either the client or an attacker holds a stolen copy.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// CreateRefreshToken stores the first refresh token of a new login
+func (p *PostgresDB) CreateRefreshToken(token *models.RefreshToken) error {
+	return p.db.QueryRow(`
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&token.CreatedAt)
+}
+
+// RotateRefreshToken exchanges the refresh token with the given hash for next,
+// which joins the same family and user. It returns the exchanged token.
+// Returns sql.ErrNoRows if the token is unknown, expired or revoked, and
+// ErrRefreshTokenReused, along with the token, if it was already exchanged.
+func (p *PostgresDB) RotateRefreshToken(tokenHash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	var current models.RefreshToken
+	err = tx.QueryRow(`
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, tokenHash).Scan(
+		&current.ID, &current.UserID, &current.FamilyID, &current.TokenHash,
+		&current.ExpiresAt, &current.RevokedAt, &current.ReplacedBy, &current.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	if current.ReplacedBy != nil {
+		if _, err := tx.Exec(`
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, current.FamilyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &current, ErrRefreshTokenReused
+	}
+	if current.RevokedAt != nil || !current.ExpiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2
+		WHERE id = $1
+	`, current.ID, next.ID); err != nil {
+		return nil, err
+	}
+	
+	err = tx.QueryRow(`
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, next.ID, next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt).Scan(&next.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &current, tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes the family of the user's refresh token with
+// the given hash, ending that login. Unknown or already revoked tokens are ignored.
+func (p *PostgresDB) RevokeRefreshTokenFamily(tokenHash string, userID uuid.UUID) error {
+	_, err := p.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+		) AND revoked_at IS NULL
+	`, tokenHash, userID)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user, ending all of
+// their logins. Returns how many tokens were still active.
+func (p *PostgresDB) RevokeUserRefreshTokens(userID uuid.UUID) (int64, error) {
+	result, err := p.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// PurgeExpiredRefreshTokens deletes refresh tokens that expired before the
+// given time. Expired tokens are rejected anyway, so reuse detection doesn't
+// need them.
+func (p *PostgresDB) PurgeExpiredRefreshTokens(expiredBefore time.Time) (int64, error) {
+	result, err := p.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at <= $1`, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// Signing key operations
+
+// GetSigningKeys returns the token signing keys that still validate tokens at
+// the given time
+func (p *PostgresDB) GetSigningKeys(at time.Time) ([]models.SigningKey, error) {
+	rows, err := p.db.Query(`
+		SELECT kid, algorithm, private_key, activates_at, retires_at, expires_at, created_at
+		FROM signing_keys
+		WHERE expires_at > $1
+		ORDER BY activates_at
+	`, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var keys []models.SigningKey
+	for rows.Next() {
+		var key models.SigningKey
+		err := rows.Scan(
+			&key.ID,
+			&key.Algorithm,
+			&key.PrivateKey,
+			&key.ActivatesAt,
+			&key.RetiresAt,
+			&key.ExpiresAt,
+			&key.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	
+	return keys, rows.Err()
+}
+
+// CreateSigningKey stores a new token signing key and sets its CreatedAt
+func (p *PostgresDB) CreateSigningKey(key *models.SigningKey) error {
+	return p.db.QueryRow(`
+		INSERT INTO signing_keys (kid, algorithm, private_key, activates_at, retires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`, key.ID, key.Algorithm, key.PrivateKey, key.ActivatesAt, key.RetiresAt, key.ExpiresAt).Scan(&key.CreatedAt)
+}
+
+// PurgeExpiredSigningKeys deletes signing keys that stopped validating tokens
+// before the given time
+func (p *PostgresDB) PurgeExpiredSigningKeys(expiredBefore time.Time) (int64, error) {
+	result, err := p.db.Exec(`DELETE FROM signing_keys WHERE expires_at <= $1`, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// API key operations
+
+// apiKeyColumns selects an API key in the order scanAPIKey reads them
+const apiKeyColumns = `id, user_id, name, prefix, secret_hash, scopes, expires_at, last_used_at, created_at`
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.SecretHash,
+		pq.Array(&key.Scopes),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	return key, nil
+}
+
+// CreateAPIKey stores a new API key and sets its CreatedAt
+func (p *PostgresDB) CreateAPIKey(key *models.APIKey) error {
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO api_keys (id, user_id, name, prefix, secret_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`, key.ID, key.UserID, key.Name, key.Prefix, key.SecretHash, pq.Array(key.Scopes), key.ExpiresAt).Scan(&key.CreatedAt)
+}
+
+// GetAPIKeyByPrefix returns the usable API key with the given prefix. Returns
+// sql.ErrNoRows if there is none, or it was revoked or has expired.
+func (p *PostgresDB) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE prefix = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	
+	return scanAPIKey(p.db.QueryRow(query, prefix))
+}
+
+// ListAPIKeys returns the user's API keys that haven't been revoked, newest first
+func (p *PostgresDB) ListAPIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := p.db.Query(`
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes one of the user's API keys. Returns sql.ErrNoRows if the
+// key doesn't exist, belongs to someone else or is already revoked.
+func (p *PostgresDB) RevokeAPIKey(keyID, userID uuid.UUID) error {
+	result, err := p.db.Exec(`
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, keyID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// TouchAPIKey records that an API key was used. It writes at most once a
+// minute per key, so busy scripts don't turn every request into a write.
+func (p *PostgresDB) TouchAPIKey(keyID uuid.UUID) error {
+	_, err := p.db.Exec(`
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, keyID)
+	
+	return err
+}
+
+// Collision Domain operations
+func (p *PostgresDB) GetCollisionDomains(tier string) ([]models.CollisionDomain, error) {
+	query := `
+		SELECT id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at
+		FROM collision_domains
+		WHERE tier = $1 OR tier = 'basic'
+		ORDER BY name
+	`
+	
+	rows, err := p.db.Query(query, tier)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var domains []models.CollisionDomain
+	for rows.Next() {
+		domain := models.CollisionDomain{}
+		var examplesJSON, keywordsJSON, intensityJSON []byte
+		
+		err := rows.Scan(
+			&domain.ID,
+			&domain.Name,
+			&domain.Category,
+			&domain.Description,
+			&examplesJSON,
+			&keywordsJSON,
+			&intensityJSON,
+			&domain.Tier,
+			&domain.CreatedAt,
+			&domain.UpdatedAt,
+		)
+		
+		if err != nil {
+			return nil, err
+		}
+		
+		json.Unmarshal(examplesJSON, &domain.Examples)
+		json.Unmarshal(keywordsJSON, &domain.Keywords)
+		json.Unmarshal(intensityJSON, &domain.Intensity)
+		
+		domains = append(domains, domain)
+	}
+	
+	return domains, nil
+}
+
+func (p *PostgresDB) CreateCollisionDomain(domain *models.CollisionDomain) error {
+	query := `
+		INSERT INTO collision_domains (id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	
+	examplesJSON, _ := json.Marshal(domain.Examples)
+	keywordsJSON, _ := json.Marshal(domain.Keywords)
+	intensityJSON, _ := json.Marshal(domain.Intensity)
+	
+	_, err := p.db.Exec(query,
+		domain.ID,
+		domain.Name,
+		domain.Category,
+		domain.Description,
+		examplesJSON,
+		keywordsJSON,
+		intensityJSON,
+		domain.Tier,
+		domain.CreatedAt,
+		domain.UpdatedAt,
+	)
+	
+	return err
+}
+
+// GetCollisionDomain returns a catalog domain. Returns sql.ErrNoRows if it
+// doesn't exist.
+func (p *PostgresDB) GetCollisionDomain(id string) (*models.CollisionDomain, error) {
+	domain := &models.CollisionDomain{}
+	var examplesJSON, keywordsJSON, intensityJSON []byte
+	
+	err := p.db.QueryRow(`
+		SELECT id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at
+		FROM collision_domains
+		WHERE id = $1
+	`, id).Scan(
+		&domain.ID,
+		&domain.Name,
+		&domain.Category,
+		&domain.Description,
+		&examplesJSON,
+		&keywordsJSON,
+		&intensityJSON,
+		&domain.Tier,
+		&domain.CreatedAt,
+		&domain.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	json.Unmarshal(examplesJSON, &domain.Examples)
+	json.Unmarshal(keywordsJSON, &domain.Keywords)
+	json.Unmarshal(intensityJSON, &domain.Intensity)
+	
+	return domain, nil
+}
+
+// UpdateCollisionDomain replaces every field of a catalog domain but its ID
+// and CreatedAt, and sets UpdatedAt. Returns sql.ErrNoRows if it doesn't exist.
+func (p *PostgresDB) UpdateCollisionDomain(domain *models.CollisionDomain) error {
+	examplesJSON, _ := json.Marshal(domain.Examples)
+	keywordsJSON, _ := json.Marshal(domain.Keywords)
+	intensityJSON, _ := json.Marshal(domain.Intensity)
+	
+	return p.db.QueryRow(`
+		UPDATE collision_domains
+		SET name = $2, category = $3, description = $4, examples = $5, keywords = $6, intensity = $7, tier = $8, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`,
+		domain.ID,
+		domain.Name,
+		domain.Category,
+		domain.Description,
+		examplesJSON,
+		keywordsJSON,
+		intensityJSON,
+		domain.Tier,
+	).Scan(&domain.CreatedAt, &domain.UpdatedAt)
+}
+
+// DeleteCollisionDomain removes a domain from the catalog. Past collisions
+// keep its name. Returns sql.ErrNoRows if it doesn't exist.
+func (p *PostgresDB) DeleteCollisionDomain(id string) error {
+	result, err := p.db.Exec(`DELETE FROM collision_domains WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// Collision Session operations
+func (p *PostgresDB) CreateCollisionSession(session *models.CollisionSession) error {
+	query := `
+		INSERT INTO collision_sessions (id, user_id, input_data, collision_result, created_at, parent_id, follow_up)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+	`
+	
+	inputJSON, _ := json.Marshal(session.InputData)
+	resultJSON, _ := json.Marshal(session.CollisionResult)
+	
+	_, err := p.db.Exec(query,
+		session.ID,
+		session.UserID,
+		inputJSON,
+		resultJSON,
+		session.CreatedAt,
+		session.ParentID,
+		session.FollowUp,
+	)
+	
+	return err
+}
+
+func (p *PostgresDB) GetUserCollisionHistory(userID uuid.UUID, limit int) ([]models.CollisionSession, error) {
+	query := `
+		SELECT id, user_id, input_data, collision_result, user_rating, exploration_notes, created_at
+		FROM collision_sessions
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	
+	rows, err := p.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var sessions []models.CollisionSession
+	for rows.Next() {
+		session := models.CollisionSession{}
+		var inputJSON, resultJSON []byte
+		
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&inputJSON,
+			&resultJSON,
+			&session.UserRating,
+			&session.ExplorationNotes,
+			&session.CreatedAt,
+		)
+		
+		if err != nil {
+			return nil, err
+		}
+		
+		json.Unmarshal(inputJSON, &session.InputData)
+		json.Unmarshal(resultJSON, &session.CollisionResult)
+		
+		sessions = append(sessions, session)
+	}
+	
+	return sessions, nil
+}
+
+// SearchCollisionHistory returns a page of the user's sessions matching the filter,
+// newest first, and whether more sessions follow the page
+func (p *PostgresDB) SearchCollisionHistory(userID uuid.UUID, filter models.HistoryFilter) ([]models.CollisionSession, bool, error) {
+	conditions := []string{"user_id = $1", "deleted_at IS NULL"}
+	args := []interface{}{userID}
+	
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	
+	if filter.Domain != "" {
+		addCondition("LOWER(collision_result->>'collision_domain') = LOWER($%d)", filter.Domain)
+	}
+	if filter.ProjectType != "" {
+		addCondition("input_data->>'project_type' = $%d", filter.ProjectType)
+	}
+	if filter.Intensity != "" {
+		addCondition("input_data->>'collision_intensity' = $%d", filter.Intensity)
+	}
+	if filter.MinRating != nil {
+		addCondition("user_rating >= $%d", *filter.MinRating)
+	}
+	if filter.MaxRating != nil {
+		addCondition("user_rating <= $%d", *filter.MaxRating)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+	if filter.Query != "" {
+		addCondition("search_vector @@ websearch_to_tsquery('english', $%d)", filter.Query)
+	}
+	if filter.Tag != "" {
+		addCondition("EXISTS (SELECT 1 FROM session_tags WHERE session_tags.session_id = collision_sessions.id AND session_tags.tag = $%d)", filter.Tag)
+	}
+	if filter.CollectionID != nil {
+		addCondition(`EXISTS (SELECT 1 FROM collection_items JOIN collections ON collections.id = collection_items.collection_id
+			WHERE collection_items.session_id = collision_sessions.id AND collections.user_id = $1 AND collections.id = $%d)`, *filter.CollectionID)
+	}
+	if filter.FavoritesOnly {
+		conditions = append(conditions, "is_favorite")
+	}
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	
+	// Fetch one extra row to know whether another page follows
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM collision_sessions
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, sessionColumns, strings.Join(conditions, " AND "), len(args))
+	
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	
+	var sessions []models.CollisionSession
+	for rows.Next() {
+		session, err := scanCollisionSession(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	
+	hasMore := len(sessions) > filter.Limit
+	if hasMore {
+		sessions = sessions[:filter.Limit]
+	}
+	
+	return sessions, hasMore, nil
+}
+
+// RateCollision sets the rating and notes of one of the user's sessions.
+// Returns sql.ErrNoRows if the session doesn't exist or belongs to someone else.
+func (p *PostgresDB) RateCollision(sessionID, userID uuid.UUID, rating int, notes *string) error {
+	query := `
+		UPDATE collision_sessions
+		SET user_rating = $1, exploration_notes = $2
+		WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
+	`
+	
+	result, err := p.db.Exec(query, rating, notes, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// GetCollisionSession returns one of the user's sessions.
+// Returns sql.ErrNoRows if it doesn't exist, belongs to someone else or is in the trash.
+func (p *PostgresDB) GetCollisionSession(sessionID, userID uuid.UUID) (*models.CollisionSession, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM collision_sessions
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	`
+	
+	return scanCollisionSession(p.db.QueryRow(query, sessionID, userID))
+}
+
+// UpdateCollisionSession changes the rating and/or notes of one of the user's
+// sessions, leaving nil fields untouched.
+// Returns sql.ErrNoRows if the session doesn't exist or belongs to someone else.
+func (p *PostgresDB) UpdateCollisionSession(sessionID, userID uuid.UUID, update models.SessionUpdate) error {
+	query := `
+		UPDATE collision_sessions
+		SET user_rating = COALESCE($1, user_rating), exploration_notes = COALESCE($2, exploration_notes)
+		WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
+	`
+	
+	result, err := p.db.Exec(query, update.Rating, update.Notes, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// DeleteCollisionSession moves one of the user's sessions to the trash.
+// Returns sql.ErrNoRows if the session doesn't exist, belongs to someone else or is already trashed.
+func (p *PostgresDB) DeleteCollisionSession(sessionID, userID uuid.UUID) error {
+	query := `
+		UPDATE collision_sessions
+		SET deleted_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	`
+	
+	result, err := p.db.Exec(query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// RestoreCollisionSession takes one of the user's sessions out of the trash if it
+// was deleted after deletedAfter. Returns sql.ErrNoRows otherwise.
+func (p *PostgresDB) RestoreCollisionSession(sessionID, userID uuid.UUID, deletedAfter time.Time) error {
+	query := `
+		UPDATE collision_sessions
+		SET deleted_at = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL AND deleted_at > $3
+	`
+	
+	result, err := p.db.Exec(query, sessionID, userID, deletedAfter)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// GetDeletedCollisionSessions returns the user's trashed sessions deleted after deletedAfter, most recent first
+func (p *PostgresDB) GetDeletedCollisionSessions(userID uuid.UUID, deletedAfter time.Time) ([]models.CollisionSession, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM collision_sessions
+		WHERE user_id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		ORDER BY deleted_at DESC
+	`
+	
+	rows, err := p.db.Query(query, userID, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var sessions []models.CollisionSession
+	for rows.Next() {
+		session, err := scanCollisionSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	
+	return sessions, rows.Err()
+}
+
+// PurgeDeletedCollisionSessions permanently removes sessions trashed before deletedBefore
+func (p *PostgresDB) PurgeDeletedCollisionSessions(deletedBefore time.Time) (int64, error) {
+	result, err := p.db.Exec(`
+		DELETE FROM collision_sessions
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+	`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	
+	return result.RowsAffected()
+}
+
+// GetCollisionTree returns every session in the exploration tree containing the
+// given session: its root and all follow-ups below it, oldest first. Sessions in
+// the trash are left out. Returns sql.ErrNoRows if the session doesn't exist,
+// belongs to someone else or is in the trash.
+func (p *PostgresDB) GetCollisionTree(sessionID, userID uuid.UUID) ([]models.CollisionSession, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM collision_sessions WHERE id = $1 AND user_id = $2
+			UNION ALL
+			SELECT s.id, s.parent_id FROM collision_sessions s
+			JOIN ancestors a ON s.id = a.parent_id
+			WHERE s.user_id = $2
+		), tree AS (
+			SELECT id FROM ancestors WHERE parent_id IS NULL
+			UNION ALL
+			SELECT s.id FROM collision_sessions s
+			JOIN tree t ON s.parent_id = t.id
+			WHERE s.user_id = $2
+		)
+		SELECT ` + sessionColumns + `
+		FROM collision_sessions
+		WHERE id IN (SELECT id FROM tree) AND deleted_at IS NULL
+		ORDER BY created_at, id
+	`
+	
+	rows, err := p.db.Query(query, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var sessions []models.CollisionSession
+	found := false
+	for rows.Next() {
+		session, err := scanCollisionSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		if session.ID == sessionID {
+			found = true
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+	
+	return sessions, nil
+}
+
+// sessionColumns selects a collision session with its favorite flag and tags, in
+// the order scanCollisionSession reads them. Columns are qualified so the list
+// can be used in joins.
+const sessionColumns = `collision_sessions.id, collision_sessions.user_id, collision_sessions.input_data,
+		collision_sessions.collision_result, collision_sessions.user_rating, collision_sessions.exploration_notes,
+		collision_sessions.created_at, collision_sessions.deleted_at, collision_sessions.is_favorite,
+		ARRAY(SELECT tag FROM session_tags WHERE session_tags.session_id = collision_sessions.id ORDER BY tag),
+		collision_sessions.parent_id, COALESCE(collision_sessions.follow_up, ''),
+		(SELECT COUNT(*) FROM collision_sessions children
+			WHERE children.parent_id = collision_sessions.id AND children.deleted_at IS NULL)`
+
+// scanCollisionSession reads a session selected with sessionColumns, followed
+// by any extra columns
+func scanCollisionSession(row rowScanner, extra ...interface{}) (*models.CollisionSession, error) {
+	session := &models.CollisionSession{}
+	var inputJSON, resultJSON []byte
+	
+	dest := []interface{}{
+		&session.ID,
+		&session.UserID,
+		&inputJSON,
+		&resultJSON,
+		&session.UserRating,
+		&session.ExplorationNotes,
+		&session.CreatedAt,
+		&session.DeletedAt,
+		&session.IsFavorite,
+		pq.Array(&session.Tags),
+		&session.ParentID,
+		&session.FollowUp,
+		&session.ChildCount,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	
+	json.Unmarshal(inputJSON, &session.InputData)
+	json.Unmarshal(resultJSON, &session.CollisionResult)
+	
+	return session, nil
+}
+
+// Favorites, tags and collections
+
+// ErrCollectionNameTaken is returned when a user already has a collection with the same name
+var ErrCollectionNameTaken = errors.New("collection name already in use")
+
+// ErrCollectionOrderMismatch is returned when a new collection order doesn't list exactly the collection's sessions
+var ErrCollectionOrderMismatch = errors.New("order must list every session in the collection exactly once")
+
+// SetCollisionFavorite stars or unstars one of the user's sessions.
+// Returns sql.ErrNoRows if the session doesn't exist or belongs to someone else.
+func (p *PostgresDB) SetCollisionFavorite(sessionID, userID uuid.UUID, favorite bool) error {
+	query := `
+		UPDATE collision_sessions
+		SET is_favorite = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+	`
+	
+	result, err := p.db.Exec(query, favorite, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// SetCollisionTags replaces the tags of one of the user's sessions. Tags are
+// expected to be normalized already.
+// Returns sql.ErrNoRows if the session doesn't exist or belongs to someone else.
+func (p *PostgresDB) SetCollisionTags(sessionID, userID uuid.UUID, tags []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	// Lock the session so concurrent updates don't interleave their deletes and inserts
+	var id uuid.UUID
+	err = tx.QueryRow(`
+		SELECT id FROM collision_sessions
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		FOR UPDATE
+	`, sessionID, userID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	
+	if _, err := tx.Exec(`DELETE FROM session_tags WHERE session_id = $1`, sessionID); err != nil {
+		return err
+	}
+	
+	if len(tags) > 0 {
+		_, err = tx.Exec(`
+			INSERT INTO session_tags (session_id, user_id, tag)
+			SELECT $1, $2, UNNEST($3::text[])
+		`, sessionID, userID, pq.Array(tags))
+		if err != nil {
+			return err
+		}
+	}
+	
+	return tx.Commit()
+}
+
+// GetUserTags returns the user's tags with how many sessions carry each, most used first
+func (p *PostgresDB) GetUserTags(userID uuid.UUID) ([]models.TagCount, error) {
+	query := `
+		SELECT t.tag, COUNT(*)
+		FROM session_tags t
+		JOIN collision_sessions s ON s.id = t.session_id
+		WHERE t.user_id = $1 AND s.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag
+	`
+	
+	rows, err := p.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var tags []models.TagCount
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	
+	return tags, rows.Err()
+}
+
+// GetLibraryCounts returns how many favorites, distinct tags and collections the user has
+func (p *PostgresDB) GetLibraryCounts(userID uuid.UUID) (*models.LibraryCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM collision_sessions WHERE user_id = $1 AND is_favorite AND deleted_at IS NULL),
+			(SELECT COUNT(DISTINCT t.tag) FROM session_tags t
+				JOIN collision_sessions s ON s.id = t.session_id
+				WHERE t.user_id = $1 AND s.deleted_at IS NULL),
+			(SELECT COUNT(*) FROM collections WHERE user_id = $1)
+	`
+	
+	counts := &models.LibraryCounts{}
+	err := p.db.QueryRow(query, userID).Scan(&counts.Favorites, &counts.Tags, &counts.Collections)
+	if err != nil {
+		return nil, err
+	}
+	
+	return counts, nil
+}
+
+// CreateCollection stores a new collection.
+// Returns ErrCollectionNameTaken if the user already has one with the same name.
+func (p *PostgresDB) CreateCollection(collection *models.Collection) error {
+	query := `
+		INSERT INTO collections (id, user_id, name, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`
+	
+	err := p.db.QueryRow(query,
+		collection.ID,
+		collection.UserID,
+		collection.Name,
+		collection.Description,
+	).Scan(&collection.CreatedAt, &collection.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrCollectionNameTaken
+	}
+	
+	return err
+}
+
+// collectionColumns selects a collection with the number of sessions in it that aren't in the trash
+const collectionColumns = `c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+		(SELECT COUNT(*) FROM collection_items ci
+			JOIN collision_sessions s ON s.id = ci.session_id
+			WHERE ci.collection_id = c.id AND s.deleted_at IS NULL)`
+
+func scanCollection(row rowScanner) (*models.Collection, error) {
+	collection := &models.Collection{}
+	err := row.Scan(
+		&collection.ID,
+		&collection.UserID,
+		&collection.Name,
+		&collection.Description,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.SessionCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// GetCollections returns the user's collections ordered by name
+func (p *PostgresDB) GetCollections(userID uuid.UUID) ([]models.Collection, error) {
+	query := `
+		SELECT ` + collectionColumns + `
+		FROM collections c
+		WHERE c.user_id = $1
+		ORDER BY LOWER(c.name)
+	`
+	
+	rows, err := p.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var collections []models.Collection
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, *collection)
+	}
+	
+	return collections, rows.Err()
+}
+
+// GetCollection returns one of the user's collections with its sessions in order.
+// Sessions in the trash are left out. Returns sql.ErrNoRows if the collection
+// doesn't exist or belongs to someone else.
+func (p *PostgresDB) GetCollection(collectionID, userID uuid.UUID) (*models.Collection, error) {
+	collection, err := scanCollection(p.db.QueryRow(`
+		SELECT `+collectionColumns+`
+		FROM collections c
+		WHERE c.id = $1 AND c.user_id = $2
+	`, collectionID, userID))
+	if err != nil {
+		return nil, err
+	}
+	
+	rows, err := p.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM collection_items
+		JOIN collision_sessions ON collision_sessions.id = collection_items.session_id
+		WHERE collection_items.collection_id = $1 AND collision_sessions.deleted_at IS NULL
+		ORDER BY collection_items.position
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	collection.Sessions = []models.CollisionSession{}
+	for rows.Next() {
+		session, err := scanCollisionSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		collection.Sessions = append(collection.Sessions, *session)
+	}
+	
+	return collection, rows.Err()
+}
+
+// UpdateCollection renames one of the user's collections and/or changes its
+// description, leaving nil fields untouched. Returns sql.ErrNoRows if the
+// collection doesn't exist or belongs to someone else, and ErrCollectionNameTaken
+// if the new name is already in use.
+func (p *PostgresDB) UpdateCollection(collectionID, userID uuid.UUID, name, description *string) error {
+	query := `
+		UPDATE collections
+		SET name = COALESCE($1, name), description = COALESCE($2, description)
+		WHERE id = $3 AND user_id = $4
+	`
+	
+	result, err := p.db.Exec(query, name, description, collectionID, userID)
+	if isUniqueViolation(err) {
+		return ErrCollectionNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// DeleteCollection removes one of the user's collections. The sessions in it are kept.
+// Returns sql.ErrNoRows if the collection doesn't exist or belongs to someone else.
+func (p *PostgresDB) DeleteCollection(collectionID, userID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM collections WHERE id = $1 AND user_id = $2`, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// AddToCollection appends one of the user's sessions to the end of one of their
+// collections. Adding a session that's already there leaves its position alone.
+// Returns sql.ErrNoRows if either doesn't exist or belongs to someone else.
+func (p *PostgresDB) AddToCollection(collectionID, sessionID, userID uuid.UUID) error {
+	// The no-op update on conflict still counts as an affected row, so zero
+	// rows only happens when the collection or session isn't the user's
+	query := `
+		INSERT INTO collection_items (collection_id, session_id, position)
+		SELECT c.id, s.id, COALESCE((SELECT MAX(position) FROM collection_items WHERE collection_id = c.id), 0) + 1
+		FROM collections c, collision_sessions s
+		WHERE c.id = $1 AND c.user_id = $3 AND s.id = $2 AND s.user_id = $3 AND s.deleted_at IS NULL
+		ON CONFLICT (collection_id, session_id) DO UPDATE SET position = collection_items.position
+	`
+	
+	result, err := p.db.Exec(query, collectionID, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// RemoveFromCollection takes a session out of one of the user's collections.
+// Returns sql.ErrNoRows if the collection isn't the user's or doesn't contain the session.
+func (p *PostgresDB) RemoveFromCollection(collectionID, sessionID, userID uuid.UUID) error {
+	query := `
+		DELETE FROM collection_items
+		USING collections
+		WHERE collections.id = collection_items.collection_id
+			AND collection_items.collection_id = $1
+			AND collection_items.session_id = $2
+			AND collections.user_id = $3
+	`
+	
+	result, err := p.db.Exec(query, collectionID, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// ReorderCollection puts the sessions of one of the user's collections in the given
+// order, which must list every session in it that isn't in the trash. Trashed
+// sessions keep their relative order after the listed ones. Returns sql.ErrNoRows
+// if the collection doesn't exist or belongs to someone else.
+func (p *PostgresDB) ReorderCollection(collectionID, userID uuid.UUID, sessionIDs []uuid.UUID) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	var id uuid.UUID
+	err = tx.QueryRow(`
+		SELECT id FROM collections
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, collectionID, userID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	
+	rows, err := tx.Query(`
+		SELECT ci.session_id
+		FROM collection_items ci
+		JOIN collision_sessions s ON s.id = ci.session_id
+		WHERE ci.collection_id = $1 AND s.deleted_at IS NULL
+	`, collectionID)
+	if err != nil {
+		return err
+	}
+	current := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var sessionID uuid.UUID
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return err
+		}
+		current[sessionID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	
+	if len(sessionIDs) != len(current) {
+		return ErrCollectionOrderMismatch
+	}
+	order := make([]string, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		if !current[sessionID] {
+			return ErrCollectionOrderMismatch
+		}
+		delete(current, sessionID) // catches duplicates
+		order[i] = sessionID.String()
+	}
+	
+	_, err = tx.Exec(`
+		UPDATE collection_items
+		SET position = COALESCE(array_position($2::uuid[], session_id), $3 + position)
+		WHERE collection_id = $1
+	`, collectionID, pq.Array(order), len(order))
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// CreateShareLink creates a share link for one of the user's sessions. Returns
+// sql.ErrNoRows if the session doesn't exist, is in the trash or belongs to
+// someone else.
+func (p *PostgresDB) CreateShareLink(link *models.ShareLink) error {
+	query := `
+		INSERT INTO share_links (id, session_id, user_id, token_hash, expires_at)
+		SELECT $1, id, user_id, $4, $5
+		FROM collision_sessions
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		RETURNING created_at
+	`
+	
+	return p.db.QueryRow(query,
+		link.ID,
+		link.SessionID,
+		link.UserID,
+		link.TokenHash,
+		link.ExpiresAt,
+	).Scan(&link.CreatedAt)
+}
+
+const shareLinkColumns = `id, session_id, user_id, token_hash, expires_at, revoked_at, view_count, last_viewed_at, created_at`
+
+func scanShareLink(row rowScanner) (*models.ShareLink, error) {
+	link := &models.ShareLink{}
+	err := row.Scan(
+		&link.ID,
+		&link.SessionID,
+		&link.UserID,
+		&link.TokenHash,
+		&link.ExpiresAt,
+		&link.RevokedAt,
+		&link.ViewCount,
+		&link.LastViewedAt,
+		&link.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// GetShareLinks returns every share link of one of the user's sessions, revoked
+// and expired ones included, newest first
+func (p *PostgresDB) GetShareLinks(sessionID, userID uuid.UUID) ([]models.ShareLink, error) {
+	query := `
+		SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE session_id = $1 AND user_id = $2
+		ORDER BY created_at DESC
+	`
+	
+	rows, err := p.db.Query(query, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var links []models.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+	
+	return links, rows.Err()
+}
+
+// RevokeShareLink stops a share link from working. Returns sql.ErrNoRows if the
+// link doesn't exist, is already revoked or belongs to someone else.
+func (p *PostgresDB) RevokeShareLink(linkID, sessionID, userID uuid.UUID) error {
+	query := `
+		UPDATE share_links
+		SET revoked_at = NOW()
+		WHERE id = $1 AND session_id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+	
+	result, err := p.db.Exec(query, linkID, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// GetActiveShareLink finds a share link by the hash of its token. Returns
+// sql.ErrNoRows if there is no such link or it has been revoked or has expired.
+func (p *PostgresDB) GetActiveShareLink(tokenHash string) (*models.ShareLink, error) {
+	query := `
+		SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	
+	return scanShareLink(p.db.QueryRow(query, tokenHash))
+}
+
+// RecordShareView counts a view of a share link and returns the new view count
+func (p *PostgresDB) RecordShareView(linkID uuid.UUID) (int, error) {
+	query := `
+		UPDATE share_links
+		SET view_count = view_count + 1, last_viewed_at = NOW()
+		WHERE id = $1
+		RETURNING view_count
+	`
+	
+	var views int
+	err := p.db.QueryRow(query, linkID).Scan(&views)
+	return views, err
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// requireRowsAffected turns an update that matched nothing into sql.ErrNoRows
+func requireRowsAffected(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Team operations
+
+// ErrTeamExists is returned when a user who already owns a team creates another
+var ErrTeamExists = errors.New("user already owns a team")
+
+// ErrTeamFull is returned when every seat of a team is taken by members and pending invitations
+var ErrTeamFull = errors.New("team has no free seats")
+
+// ErrAlreadyTeamMember is returned when inviting or adding someone who is already on the team
+var ErrAlreadyTeamMember = errors.New("already a member of the team")
+
+// ErrInvitationEmailMismatch is returned when a team invitation is accepted by
+// an account with a different email address than it was sent to
+var ErrInvitationEmailMismatch = errors.New("invitation was sent to another email address")
+
+// teamColumns selects a team, its owner's tier and its used seats, in the order
+// scanTeam reads them. Queries join the owner as o.
+const teamColumns = `t.id, t.name, t.owner_id, t.seats, t.created_at, t.updated_at, o.subscription_tier,
+		(SELECT COUNT(*) FROM team_members m WHERE m.team_id = t.id) +
+		(SELECT COUNT(*) FROM team_invitations i
+			WHERE i.team_id = t.id AND i.accepted_at IS NULL AND i.expires_at > NOW())`
+
+// scanTeam reads a team selected with teamColumns, followed by any extra columns
+func scanTeam(row rowScanner, extra ...interface{}) (*models.Team, error) {
+	team := &models.Team{}
+	var ownerTier string
+	
+	dest := []interface{}{
+		&team.ID,
+		&team.Name,
+		&team.OwnerID,
+		&team.Seats,
+		&team.CreatedAt,
+		&team.UpdatedAt,
+		&ownerTier,
+		&team.SeatsUsed,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	
+	team.PlanActive = models.TeamSeatLimit(team.Seats, ownerTier) > 0
+	return team, nil
+}
+
+// CreateTeam stores a new team with its owner as the first member and sets its
+// timestamps. Unless Seats is set, the team gets the seats its owner's Team
+// subscription paid for, or TeamIncludedSeats if no subscription has been
+// seen yet. Returns ErrTeamExists if the owner already owns a team.
+func (p *PostgresDB) CreateTeam(team *models.Team) error {
+	if team.ID == uuid.Nil {
+		team.ID = uuid.New()
+	}
+	
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	err = tx.QueryRow(`
+		INSERT INTO teams (id, name, owner_id, seats)
+		SELECT $1, $2, $3, COALESCE(NULLIF($4, 0), team_seats, $5)
+		FROM users WHERE id = $3
+		RETURNING seats, created_at, updated_at
+	`, team.ID, team.Name, team.OwnerID, team.Seats, models.TeamIncludedSeats).Scan(&team.Seats, &team.CreatedAt, &team.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrTeamExists
+	}
+	if err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`
+		INSERT INTO team_members (team_id, user_id, role)
+		VALUES ($1, $2, $3)
+	`, team.ID, team.OwnerID, models.TeamRoleOwner)
+	if err != nil {
+		return err
+	}
+	
+	team.Role = models.TeamRoleOwner
+	team.SeatsUsed = 1
+	return tx.Commit()
+}
+
+// GetTeam returns a team. Returns sql.ErrNoRows if it doesn't exist.
+func (p *PostgresDB) GetTeam(teamID uuid.UUID) (*models.Team, error) {
+	query := `
+		SELECT ` + teamColumns + `
+		FROM teams t
+		JOIN users o ON o.id = t.owner_id
+		WHERE t.id = $1
+	`
+	
+	return scanTeam(p.db.QueryRow(query, teamID))
+}
+
+// GetUserTeams returns the teams a user belongs to, with their role in each, ordered by name
+func (p *PostgresDB) GetUserTeams(userID uuid.UUID) ([]models.Team, error) {
+	rows, err := p.db.Query(`
+		SELECT `+teamColumns+`, tm.role
+		FROM team_members tm
+		JOIN teams t ON t.id = tm.team_id
+		JOIN users o ON o.id = t.owner_id
+		WHERE tm.user_id = $1
+		ORDER BY t.name, t.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	teams := []models.Team{}
+	for rows.Next() {
+		var role string
+		team, err := scanTeam(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		team.Role = role
+		teams = append(teams, *team)
+	}
+	
+	return teams, rows.Err()
+}
+
+// UpdateTeamName renames a team. Returns sql.ErrNoRows if it doesn't exist.
+func (p *PostgresDB) UpdateTeamName(teamID uuid.UUID, name string) error {
+	result, err := p.db.Exec(`
+		UPDATE teams SET name = $2, updated_at = NOW()
+		WHERE id = $1
+	`, teamID, name)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// UpdateTeamSeats records the seat quantity of a user's Team subscription and
+// sets the seats of the team they own to it. A user who hasn't created their
+// team yet gets the seats when they do. Returns sql.ErrNoRows if the user
+// doesn't exist.
+func (p *PostgresDB) UpdateTeamSeats(ownerID uuid.UUID, seats int) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`UPDATE users SET team_seats = $2 WHERE id = $1`, ownerID, seats)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`
+		UPDATE teams SET seats = $2, updated_at = NOW()
+		WHERE owner_id = $1
+	`, ownerID, seats)
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// DeleteTeam deletes a team with its memberships, invitations and shares. The
+// shared sessions stay with the members who created them. Returns
+// sql.ErrNoRows if the team doesn't exist.
+func (p *PostgresDB) DeleteTeam(teamID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM teams WHERE id = $1`, teamID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// teamMemberColumns selects a membership with the member's email and display
+// name, in the order scanTeamMember reads them. Queries join the user as u.
+const teamMemberColumns = `tm.team_id, tm.user_id, u.email, COALESCE(u.display_name, ''), tm.role, tm.joined_at`
+
+func scanTeamMember(row rowScanner) (*models.TeamMember, error) {
+	member := &models.TeamMember{}
+	err := row.Scan(
+		&member.TeamID,
+		&member.UserID,
+		&member.Email,
+		&member.DisplayName,
+		&member.Role,
+		&member.JoinedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	return member, nil
+}
+
+// GetTeamMember returns a user's membership of a team. Returns sql.ErrNoRows
+// if they aren't a member or the team doesn't exist.
+func (p *PostgresDB) GetTeamMember(teamID, userID uuid.UUID) (*models.TeamMember, error) {
+	query := `
+		SELECT ` + teamMemberColumns + `
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1 AND tm.user_id = $2
+	`
+	
+	return scanTeamMember(p.db.QueryRow(query, teamID, userID))
+}
+
+// GetTeamMembers returns the members of a team in the order they joined
+func (p *PostgresDB) GetTeamMembers(teamID uuid.UUID) ([]models.TeamMember, error) {
+	rows, err := p.db.Query(`
+		SELECT `+teamMemberColumns+`
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1
+		ORDER BY tm.joined_at, tm.user_id
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	members := []models.TeamMember{}
+	for rows.Next() {
+		member, err := scanTeamMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *member)
+	}
+	
+	return members, rows.Err()
+}
+
+// UpdateTeamMemberRole changes a member's role. The owner's role can't be
+// changed. Returns sql.ErrNoRows if the user isn't a member other than the owner.
+func (p *PostgresDB) UpdateTeamMemberRole(teamID, userID uuid.UUID, role string) error {
+	result, err := p.db.Exec(`
+		UPDATE team_members SET role = $3
+		WHERE team_id = $1 AND user_id = $2 AND role <> 'owner'
+	`, teamID, userID, role)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// RemoveTeamMember takes a member off a team, along with the sessions they
+// shared with it and put on its boards. The owner can't be removed. Returns
+// sql.ErrNoRows if the user isn't a member other than the owner.
+func (p *PostgresDB) RemoveTeamMember(teamID, userID uuid.UUID) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`
+		DELETE FROM team_members
+		WHERE team_id = $1 AND user_id = $2 AND role <> 'owner'
+	`, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`DELETE FROM team_sessions WHERE team_id = $1 AND shared_by = $2`, teamID, userID)
+	if err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`
+		DELETE FROM board_items
+		WHERE added_by = $2 AND board_id IN (SELECT id FROM boards WHERE team_id = $1)
+	`, teamID, userID)
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// lockTeamSeats locks a team's row until the transaction ends, so its seats
+// are handed out one at a time, and returns how many it has. Returns
+// sql.ErrNoRows if the team doesn't exist.
+func lockTeamSeats(tx *sql.Tx, teamID uuid.UUID) (int, error) {
+	var seats int
+	var ownerTier string
+	err := tx.QueryRow(`
+		SELECT t.seats, o.subscription_tier
+		FROM teams t
+		JOIN users o ON o.id = t.owner_id
+		WHERE t.id = $1
+		FOR UPDATE OF t
+	`, teamID).Scan(&seats, &ownerTier)
+	if err != nil {
+		return 0, err
+	}
+	
+	return models.TeamSeatLimit(seats, ownerTier), nil
+}
+
+// CreateTeamInvitation stores an invitation, replacing any pending one to the
+// same address, and sets its CreatedAt. The email is expected in lower case.
+// Returns ErrAlreadyTeamMember if the address belongs to a member and
+// ErrTeamFull if no seat is free for it.
+func (p *PostgresDB) CreateTeamInvitation(invitation *models.TeamInvitation) error {
+	if invitation.ID == uuid.Nil {
+		invitation.ID = uuid.New()
+	}
+	
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	limit, err := lockTeamSeats(tx, invitation.TeamID)
+	if err != nil {
+		return err
+	}
+	
+	var isMember bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM team_members tm
+			JOIN users u ON u.id = tm.user_id
+			WHERE tm.team_id = $1 AND LOWER(u.email) = $2
+		)
+	`, invitation.TeamID, invitation.Email).Scan(&isMember)
+	if err != nil {
+		return err
+	}
+	if isMember {
+		return ErrAlreadyTeamMember
+	}
+	
+	_, err = tx.Exec(`
+		DELETE FROM team_invitations
+		WHERE team_id = $1 AND email = $2 AND accepted_at IS NULL
+	`, invitation.TeamID, invitation.Email)
+	if err != nil {
+		return err
+	}
+	
+	var used int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM team_members WHERE team_id = $1) +
+			(SELECT COUNT(*) FROM team_invitations
+				WHERE team_id = $1 AND accepted_at IS NULL AND expires_at > NOW())
+	`, invitation.TeamID).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return ErrTeamFull
+	}
+	
+	err = tx.QueryRow(`
+		INSERT INTO team_invitations (id, team_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`, invitation.ID, invitation.TeamID, invitation.Email, invitation.Role, invitation.TokenHash,
+		invitation.InvitedBy, invitation.ExpiresAt).Scan(&invitation.CreatedAt)
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// teamInvitationColumns selects an invitation in the order scanTeamInvitation reads them
+const teamInvitationColumns = `id, team_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at`
+
+func scanTeamInvitation(row rowScanner) (*models.TeamInvitation, error) {
+	invitation := &models.TeamInvitation{}
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.TeamID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.TokenHash,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	return invitation, nil
+}
+
+// GetTeamInvitations returns a team's pending invitations that haven't expired, newest first
+func (p *PostgresDB) GetTeamInvitations(teamID uuid.UUID) ([]models.TeamInvitation, error) {
+	rows, err := p.db.Query(`
+		SELECT `+teamInvitationColumns+`
+		FROM team_invitations
+		WHERE team_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	invitations := []models.TeamInvitation{}
+	for rows.Next() {
+		invitation, err := scanTeamInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *invitation)
+	}
+	
+	return invitations, rows.Err()
+}
+
+// RevokeTeamInvitation deletes a pending invitation, freeing its seat. Returns
+// sql.ErrNoRows if the team has no such pending invitation.
+func (p *PostgresDB) RevokeTeamInvitation(invitationID, teamID uuid.UUID) error {
+	result, err := p.db.Exec(`
+		DELETE FROM team_invitations
+		WHERE id = $1 AND team_id = $2 AND accepted_at IS NULL
+	`, invitationID, teamID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// AcceptTeamInvitation adds a user to a team with the invitation whose token
+// has the given hash, and returns the invitation. Returns sql.ErrNoRows if no
+// pending, unexpired invitation has that token, ErrInvitationEmailMismatch if
+// it was sent to another address, ErrAlreadyTeamMember if the user is on the
+// team already and ErrTeamFull if the team has no seat for them.
+func (p *PostgresDB) AcceptTeamInvitation(tokenHash string, user *models.User) (*models.TeamInvitation, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	invitation, err := scanTeamInvitation(tx.QueryRow(`
+		SELECT `+teamInvitationColumns+`
+		FROM team_invitations
+		WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()
+	`, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, ErrInvitationEmailMismatch
+	}
+	
+	limit, err := lockTeamSeats(tx, invitation.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Accepted or revoked in the meantime
+	result, err := tx.Exec(`
+		UPDATE team_invitations SET accepted_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL
+	`, invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return nil, err
+	}
+	
+	result, err = tx.Exec(`
+		INSERT INTO team_members (team_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, user_id) DO NOTHING
+	`, invitation.TeamID, user.ID, invitation.Role)
+	if err != nil {
+		return nil, err
+	}
+	if requireRowsAffected(result) == sql.ErrNoRows {
+		return nil, ErrAlreadyTeamMember
+	}
+	
+	// The invitation held a seat, but the plan may have shrunk since
+	var members int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM team_members WHERE team_id = $1`, invitation.TeamID).Scan(&members)
+	if err != nil {
+		return nil, err
+	}
+	if members > limit {
+		return nil, ErrTeamFull
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	invitation.AcceptedAt = &now
+	return invitation, nil
+}
+
+// ShareSessionWithTeam adds one of the user's sessions to a team's history and
+// returns when it was shared. Sharing it again changes nothing. Returns
+// sql.ErrNoRows if the session doesn't exist, is in the trash or belongs to
+// someone else.
+func (p *PostgresDB) ShareSessionWithTeam(teamID, sessionID, userID uuid.UUID) (time.Time, error) {
+	var sharedAt time.Time
+	err := p.db.QueryRow(`
+		INSERT INTO team_sessions (team_id, session_id, shared_by)
+		SELECT $1, id, user_id
+		FROM collision_sessions
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		ON CONFLICT (team_id, session_id) DO UPDATE SET shared_at = team_sessions.shared_at
+		RETURNING shared_at
+	`, teamID, sessionID, userID).Scan(&sharedAt)
+	
+	return sharedAt, err
+}
+
+// UnshareSessionFromTeam takes a session out of a team's history. With a
+// sharedBy, only a session that user shared is taken out. Returns
+// sql.ErrNoRows if there's no such shared session.
+func (p *PostgresDB) UnshareSessionFromTeam(teamID, sessionID uuid.UUID, sharedBy *uuid.UUID) error {
+	result, err := p.db.Exec(`
+		DELETE FROM team_sessions
+		WHERE team_id = $1 AND session_id = $2 AND ($3::uuid IS NULL OR shared_by = $3)
+	`, teamID, sessionID, sharedBy)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// GetTeamHistory returns the sessions shared with a team, most recently shared
+// first. Sessions their owners moved to the trash are left out.
+func (p *PostgresDB) GetTeamHistory(teamID uuid.UUID, limit int) ([]models.TeamSession, error) {
+	rows, err := p.db.Query(`
+		SELECT `+sessionColumns+`,
+			ts.shared_by, COALESCE(NULLIF(u.display_name, ''), u.email), ts.shared_at
+		FROM team_sessions ts
+		JOIN collision_sessions ON collision_sessions.id = ts.session_id
+		JOIN users u ON u.id = ts.shared_by
+		WHERE ts.team_id = $1 AND collision_sessions.deleted_at IS NULL
+		ORDER BY ts.shared_at DESC, ts.session_id
+		LIMIT $2
+	`, teamID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	history := []models.TeamSession{}
+	for rows.Next() {
+		var shared models.TeamSession
+		session, err := scanCollisionSession(rows, &shared.SharedBy, &shared.SharedByName, &shared.SharedAt)
+		if err != nil {
+			return nil, err
+		}
+		shared.CollisionSession = *session
+		history = append(history, shared)
+	}
+	
+	return history, rows.Err()
+}
+
+// Board operations
+
+// ErrBoardItemExists is returned when a session is already on the board
+var ErrBoardItemExists = errors.New("session is already on the board")
+
+// boardColumns selects a board with its item count, in the order scanBoard
+// reads them. Items whose sessions are in the trash aren't counted.
+const boardColumns = `b.id, b.team_id, b.name, b.description, b.created_by, b.created_at, b.updated_at,
+		(SELECT COUNT(*) FROM board_items bi
+			JOIN collision_sessions s ON s.id = bi.session_id
+			WHERE bi.board_id = b.id AND s.deleted_at IS NULL)`
+
+func scanBoard(row rowScanner) (*models.Board, error) {
+	board := &models.Board{}
+	err := row.Scan(
+		&board.ID,
+		&board.TeamID,
+		&board.Name,
+		&board.Description,
+		&board.CreatedBy,
+		&board.CreatedAt,
+		&board.UpdatedAt,
+		&board.ItemCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	return board, nil
+}
+
+// CreateBoard stores a new board and sets its timestamps
+func (p *PostgresDB) CreateBoard(board *models.Board) error {
+	if board.ID == uuid.Nil {
+		board.ID = uuid.New()
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO boards (id, team_id, name, description, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+	`, board.ID, board.TeamID, board.Name, board.Description, board.CreatedBy).Scan(&board.CreatedAt, &board.UpdatedAt)
+}
+
+// GetBoards returns a team's boards, newest first
+func (p *PostgresDB) GetBoards(teamID uuid.UUID) ([]models.Board, error) {
+	rows, err := p.db.Query(`
+		SELECT `+boardColumns+`
+		FROM boards b
+		WHERE b.team_id = $1
+		ORDER BY b.created_at DESC, b.id
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	boards := []models.Board{}
+	for rows.Next() {
+		board, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, *board)
+	}
+	
+	return boards, rows.Err()
+}
+
+// GetBoard returns one of a team's boards, without its items. Returns
+// sql.ErrNoRows if it doesn't exist or belongs to another team.
+func (p *PostgresDB) GetBoard(boardID, teamID uuid.UUID) (*models.Board, error) {
+	query := `
+		SELECT ` + boardColumns + `
+		FROM boards b
+		WHERE b.id = $1 AND b.team_id = $2
+	`
+	
+	return scanBoard(p.db.QueryRow(query, boardID, teamID))
+}
+
+// UpdateBoard renames one of a team's boards and/or changes its description,
+// leaving nil fields untouched. Returns sql.ErrNoRows if it doesn't exist or
+// belongs to another team.
+func (p *PostgresDB) UpdateBoard(boardID, teamID uuid.UUID, name, description *string) error {
+	result, err := p.db.Exec(`
+		UPDATE boards
+		SET name = COALESCE($3, name), description = COALESCE($4, description), updated_at = NOW()
+		WHERE id = $1 AND team_id = $2
+	`, boardID, teamID, name, description)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// DeleteBoard deletes one of a team's boards with its items, comments,
+// reactions and votes. The sessions on it are kept. Returns sql.ErrNoRows if
+// it doesn't exist or belongs to another team.
+func (p *PostgresDB) DeleteBoard(boardID, teamID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM boards WHERE id = $1 AND team_id = $2`, boardID, teamID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// AddBoardItem puts one of the adding user's sessions on a board and sets the
+// item's CreatedAt. Returns sql.ErrNoRows if the session doesn't exist, is in
+// the trash or belongs to someone else, and ErrBoardItemExists if it's on the
+// board already.
+func (p *PostgresDB) AddBoardItem(item *models.BoardItem) error {
+	if item.ID == uuid.Nil {
+		item.ID = uuid.New()
+	}
+	
+	err := p.db.QueryRow(`
+		INSERT INTO board_items (id, board_id, session_id, added_by, note)
+		SELECT $1, $2, id, user_id, $5
+		FROM collision_sessions
+		WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
+		RETURNING created_at
+	`, item.ID, item.BoardID, item.SessionID, item.AddedBy, item.Note).Scan(&item.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrBoardItemExists
+	}
+	
+	return err
+}
+
+// GetBoardItem returns an item on a board, without its session, votes or
+// reactions. Returns sql.ErrNoRows if the board has no such item.
+func (p *PostgresDB) GetBoardItem(itemID, boardID uuid.UUID) (*models.BoardItem, error) {
+	item := &models.BoardItem{}
+	err := p.db.QueryRow(`
+		SELECT id, board_id, session_id, added_by, note, created_at
+		FROM board_items
+		WHERE id = $1 AND board_id = $2
+	`, itemID, boardID).Scan(&item.ID, &item.BoardID, &item.SessionID, &item.AddedBy, &item.Note, &item.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	
+	return item, nil
+}
+
+// RemoveBoardItem takes an item off a board with its comments, reactions and
+// votes. Returns sql.ErrNoRows if the board has no such item.
+func (p *PostgresDB) RemoveBoardItem(itemID, boardID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM board_items WHERE id = $1 AND board_id = $2`, itemID, boardID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// boardItemColumns selects a board item with its session, tallies and the
+// viewer's ($2) vote and reactions, in the order scanBoardItem reads them
+const boardItemColumns = sessionColumns + `,
+		bi.id, bi.board_id, bi.added_by, COALESCE(NULLIF(u.display_name, ''), u.email), bi.note, bi.created_at,
+		(SELECT COUNT(*) FROM board_votes v WHERE v.item_id = bi.id),
+		EXISTS (SELECT 1 FROM board_votes v WHERE v.item_id = bi.id AND v.user_id = $2),
+		COALESCE((SELECT json_object_agg(reaction, n) FROM (
+			SELECT reaction, COUNT(*) AS n FROM board_reactions r WHERE r.item_id = bi.id GROUP BY reaction
+		) tallies), '{}'),
+		ARRAY(SELECT reaction FROM board_reactions r WHERE r.item_id = bi.id AND r.user_id = $2 ORDER BY reaction),
+		(SELECT COUNT(*) FROM board_comments c WHERE c.item_id = bi.id)`
+
+func scanBoardItem(row rowScanner) (*models.BoardItem, error) {
+	item := &models.BoardItem{}
+	var reactionsJSON []byte
+	
+	session, err := scanCollisionSession(row,
+		&item.ID,
+		&item.BoardID,
+		&item.AddedBy,
+		&item.AddedByName,
+		&item.Note,
+		&item.CreatedAt,
+		&item.VoteCount,
+		&item.Voted,
+		&reactionsJSON,
+		pq.Array(&item.MyReactions),
+		&item.CommentCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	item.Session = session
+	item.SessionID = session.ID
+	item.Reactions = map[string]int{}
+	json.Unmarshal(reactionsJSON, &item.Reactions)
+	if item.MyReactions == nil {
+		item.MyReactions = []string{}
+	}
+	return item, nil
+}
+
+// GetBoardItems returns the items on a board in the order they were added, as
+// seen by viewerID. Items whose sessions are in the trash are left out.
+func (p *PostgresDB) GetBoardItems(boardID, viewerID uuid.UUID) ([]models.BoardItem, error) {
+	return p.queryBoardItems(`
+		SELECT `+boardItemColumns+`
+		FROM board_items bi
+		JOIN collision_sessions ON collision_sessions.id = bi.session_id
+		JOIN users u ON u.id = bi.added_by
+		WHERE bi.board_id = $1 AND collision_sessions.deleted_at IS NULL
+		ORDER BY bi.created_at, bi.id
+	`, boardID, viewerID)
+}
+
+// GetBoardShortlist returns up to limit items on a board with at least one
+// vote, most votes first, as seen by viewerID. Ties go to the item added first.
+func (p *PostgresDB) GetBoardShortlist(boardID, viewerID uuid.UUID, limit int) ([]models.BoardItem, error) {
+	return p.queryBoardItems(`
+		SELECT `+boardItemColumns+`
+		FROM board_items bi
+		JOIN collision_sessions ON collision_sessions.id = bi.session_id
+		JOIN users u ON u.id = bi.added_by
+		WHERE bi.board_id = $1 AND collision_sessions.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM board_votes v WHERE v.item_id = bi.id)
+		ORDER BY (SELECT COUNT(*) FROM board_votes v WHERE v.item_id = bi.id) DESC, bi.created_at, bi.id
+		LIMIT $3
+	`, boardID, viewerID, limit)
+}
+
+func (p *PostgresDB) queryBoardItems(query string, args ...interface{}) ([]models.BoardItem, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	items := []models.BoardItem{}
+	for rows.Next() {
+		item, err := scanBoardItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	
+	return items, rows.Err()
+}
+
+// SetBoardVote casts or withdraws a user's vote for a board item and returns
+// the item's vote count. Each member has one vote per item; voting twice
+// changes nothing.
+func (p *PostgresDB) SetBoardVote(itemID, userID uuid.UUID, vote bool) (int, error) {
+	query := `DELETE FROM board_votes WHERE item_id = $1 AND user_id = $2`
+	if vote {
+		query = `INSERT INTO board_votes (item_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	}
+	if _, err := p.db.Exec(query, itemID, userID); err != nil {
+		return 0, err
+	}
+	
+	var count int
+	err := p.db.QueryRow(`SELECT COUNT(*) FROM board_votes WHERE item_id = $1`, itemID).Scan(&count)
+	return count, err
+}
+
+// SetBoardReaction gives or takes back a user's reaction to a board item.
+// Reactions are expected to be checked against models.BoardReactions already.
+func (p *PostgresDB) SetBoardReaction(itemID, userID uuid.UUID, reaction string, react bool) error {
+	query := `DELETE FROM board_reactions WHERE item_id = $1 AND user_id = $2 AND reaction = $3`
+	if react {
+		query = `INSERT INTO board_reactions (item_id, user_id, reaction) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	}
+	_, err := p.db.Exec(query, itemID, userID, reaction)
+	
+	return err
+}
+
+// boardCommentColumns selects a comment with its author's name, in the order
+// scanBoardComment reads them. Queries left join the author as u.
+const boardCommentColumns = `c.id, c.item_id, c.user_id, COALESCE(NULLIF(u.display_name, ''), u.email, ''), c.body, c.created_at, c.updated_at`
+
+func scanBoardComment(row rowScanner) (*models.BoardComment, error) {
+	comment := &models.BoardComment{}
+	err := row.Scan(
+		&comment.ID,
+		&comment.ItemID,
+		&comment.UserID,
+		&comment.AuthorName,
+		&comment.Body,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	return comment, nil
+}
+
+// CreateBoardComment stores a comment on a board item and sets its timestamps
+func (p *PostgresDB) CreateBoardComment(comment *models.BoardComment) error {
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO board_comments (id, item_id, user_id, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`, comment.ID, comment.ItemID, comment.UserID, comment.Body).Scan(&comment.CreatedAt, &comment.UpdatedAt)
+}
+
+// GetBoardComments returns the comments on a board item, oldest first
+func (p *PostgresDB) GetBoardComments(itemID uuid.UUID) ([]models.BoardComment, error) {
+	rows, err := p.db.Query(`
+		SELECT `+boardCommentColumns+`
+		FROM board_comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.item_id = $1
+		ORDER BY c.created_at, c.id
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	comments := []models.BoardComment{}
+	for rows.Next() {
+		comment, err := scanBoardComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *comment)
+	}
+	
+	return comments, rows.Err()
+}
+
+// GetBoardComment returns a comment on a board item. Returns sql.ErrNoRows if
+// the item has no such comment.
+func (p *PostgresDB) GetBoardComment(commentID, itemID uuid.UUID) (*models.BoardComment, error) {
+	query := `
+		SELECT ` + boardCommentColumns + `
+		FROM board_comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.item_id = $2
+	`
+	
+	return scanBoardComment(p.db.QueryRow(query, commentID, itemID))
+}
+
+// UpdateBoardComment replaces the body of a user's comment. Returns
+// sql.ErrNoRows if the item has no such comment by the user.
+func (p *PostgresDB) UpdateBoardComment(commentID, itemID, userID uuid.UUID, body string) error {
+	result, err := p.db.Exec(`
+		UPDATE board_comments SET body = $4, updated_at = NOW()
+		WHERE id = $1 AND item_id = $2 AND user_id = $3
+	`, commentID, itemID, userID, body)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// DeleteBoardComment deletes a comment on a board item. Returns sql.ErrNoRows
+// if the item has no such comment.
+func (p *PostgresDB) DeleteBoardComment(commentID, itemID uuid.UUID) error {
+	result, err := p.db.Exec(`DELETE FROM board_comments WHERE id = $1 AND item_id = $2`, commentID, itemID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// RecordBoardActivity adds an entry to a team's activity feed
+func (p *PostgresDB) RecordBoardActivity(activity *models.BoardActivity) error {
+	if activity.ID == uuid.Nil {
+		activity.ID = uuid.New()
+	}
+	if activity.Details == nil {
+		activity.Details = map[string]interface{}{}
+	}
+	
+	details, err := json.Marshal(activity.Details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity details: %w", err)
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO board_activity (id, team_id, board_id, item_id, user_id, action, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`, activity.ID, activity.TeamID, activity.BoardID, activity.ItemID, activity.UserID, activity.Action, details).Scan(&activity.CreatedAt)
+}
+
+// GetBoardActivity returns a team's activity feed, newest first. A non-nil
+// boardID narrows it to one board.
+func (p *PostgresDB) GetBoardActivity(teamID uuid.UUID, boardID *uuid.UUID, limit int) ([]models.BoardActivity, error) {
+	rows, err := p.db.Query(`
+		SELECT a.id, a.team_id, a.board_id, a.item_id, a.user_id,
+			COALESCE(NULLIF(u.display_name, ''), u.email, ''), a.action, a.details, a.created_at
+		FROM board_activity a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.team_id = $1 AND ($2::uuid IS NULL OR a.board_id = $2)
+		ORDER BY a.created_at DESC, a.id
+		LIMIT $3
+	`, teamID, boardID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	feed := []models.BoardActivity{}
+	for rows.Next() {
+		var activity models.BoardActivity
+		var details []byte
+		err := rows.Scan(
+			&activity.ID,
+			&activity.TeamID,
+			&activity.BoardID,
+			&activity.ItemID,
+			&activity.UserID,
+			&activity.ActorName,
+			&activity.Action,
+			&details,
+			&activity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activity.Details = map[string]interface{}{}
+		json.Unmarshal(details, &activity.Details)
+		feed = append(feed, activity)
+	}
+	
+	return feed, rows.Err()
+}
+
+// Group session operations
+
+// ErrJoinCodeTaken is returned when a new group session's join code is in use
+var ErrJoinCodeTaken = errors.New("join code is already in use")
+
+// ErrGroupSessionClosed is returned when joining a group session whose
+// collisions are generated or that has ended
+var ErrGroupSessionClosed = errors.New("group session is no longer open")
+
+// ErrGroupSessionFull is returned when joining a group session that has
+// models.GroupMaxParticipants participants
+var ErrGroupSessionFull = errors.New("group session is full")
+
+// groupSessionColumns selects a group session with its participant count, in
+// the order scanGroupSession reads them
+const groupSessionColumns = `g.id, g.host_id, g.title, g.current_project, g.project_type, g.collision_intensity,
+		g.join_code, g.merged_interests, g.ends_at, g.generated_at, g.created_at,
+		(SELECT COUNT(*) FROM group_participants gp WHERE gp.session_id = g.id)`
+
+func scanGroupSession(row rowScanner) (*models.GroupSession, error) {
+	session := &models.GroupSession{}
+	err := row.Scan(
+		&session.ID,
+		&session.HostID,
+		&session.Title,
+		&session.CurrentProject,
+		&session.ProjectType,
+		&session.CollisionIntensity,
+		&session.JoinCode,
+		pq.Array(&session.MergedInterests),
+		&session.EndsAt,
+		&session.GeneratedAt,
+		&session.CreatedAt,
+		&session.ParticipantCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	if session.MergedInterests == nil {
+		session.MergedInterests = []string{}
+	}
+	session.Status = models.GroupSessionStatus(session.EndsAt, session.GeneratedAt, time.Now())
+	return session, nil
+}
+
+// CreateGroupSession stores a new group session with its host as the first
+// participant and sets its CreatedAt. Returns ErrJoinCodeTaken if another
+// session has its join code.
+func (p *PostgresDB) CreateGroupSession(session *models.GroupSession, hostInterests []string) error {
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+	
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	err = tx.QueryRow(`
+		INSERT INTO group_sessions (id, host_id, title, current_project, project_type, collision_intensity, join_code, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`,
+		session.ID,
+		session.HostID,
+		session.Title,
+		session.CurrentProject,
+		session.ProjectType,
+		session.CollisionIntensity,
+		session.JoinCode,
+		session.EndsAt,
+	).Scan(&session.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrJoinCodeTaken
+	}
+	if err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`
+		INSERT INTO group_participants (session_id, user_id, interests)
+		VALUES ($1, $2, $3)
+	`, session.ID, session.HostID, pq.Array(hostInterests))
+	if err != nil {
+		return err
+	}
+	
+	session.ParticipantCount = 1
+	session.MergedInterests = []string{}
+	session.Status = models.GroupSessionStatus(session.EndsAt, nil, time.Now())
+	return tx.Commit()
+}
+
+// GetGroupSession returns a group session that userID takes part in, without
+// its participants or collisions. Returns sql.ErrNoRows if it doesn't exist or
+// the user hasn't joined it.
+func (p *PostgresDB) GetGroupSession(sessionID, userID uuid.UUID) (*models.GroupSession, error) {
+	query := `
+		SELECT ` + groupSessionColumns + `
+		FROM group_sessions g
+		WHERE g.id = $1
+			AND EXISTS (SELECT 1 FROM group_participants gp WHERE gp.session_id = g.id AND gp.user_id = $2)
+	`
+	
+	return scanGroupSession(p.db.QueryRow(query, sessionID, userID))
+}
+
+// GetGroupSessionByCode returns the group session with a join code, whatever
+// its status. Returns sql.ErrNoRows if no session has it.
+func (p *PostgresDB) GetGroupSessionByCode(code string) (*models.GroupSession, error) {
+	query := `
+		SELECT ` + groupSessionColumns + `
+		FROM group_sessions g
+		WHERE g.join_code = $1
+	`
+	
+	return scanGroupSession(p.db.QueryRow(query, code))
+}
+
+// GetUserGroupSessions returns up to limit of the group sessions the user
+// hosts or joined, newest first
+func (p *PostgresDB) GetUserGroupSessions(userID uuid.UUID, limit int) ([]models.GroupSession, error) {
+	rows, err := p.db.Query(`
+		SELECT `+groupSessionColumns+`
+		FROM group_sessions g
+		JOIN group_participants me ON me.session_id = g.id AND me.user_id = $1
+		ORDER BY g.created_at DESC, g.id
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	sessions := []models.GroupSession{}
+	for rows.Next() {
+		session, err := scanGroupSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	
+	return sessions, rows.Err()
+}
+
+// JoinGroupSession adds a user to a group session with their interests, or
+// replaces the interests of a user who joined already. Returns sql.ErrNoRows
+// if the session doesn't exist, ErrGroupSessionClosed if its collisions are
+// generated or it has ended, and ErrGroupSessionFull if it has no room for
+// another participant.
+func (p *PostgresDB) JoinGroupSession(sessionID, userID uuid.UUID, interests []string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	// Lock the session so it can't fill up or be generated while joining
+	var open bool
+	err = tx.QueryRow(`
+		SELECT generated_at IS NULL AND ends_at > NOW()
+		FROM group_sessions
+		WHERE id = $1
+		FOR UPDATE
+	`, sessionID).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return ErrGroupSessionClosed
+	}
+	
+	var participants int
+	var joined bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(BOOL_OR(user_id = $2), FALSE)
+		FROM group_participants
+		WHERE session_id = $1
+	`, sessionID, userID).Scan(&participants, &joined)
+	if err != nil {
+		return err
+	}
+	if !joined && participants >= models.GroupMaxParticipants {
+		return ErrGroupSessionFull
+	}
+	
+	_, err = tx.Exec(`
+		INSERT INTO group_participants (session_id, user_id, interests)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (session_id, user_id) DO UPDATE SET interests = EXCLUDED.interests
+	`, sessionID, userID, pq.Array(interests))
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// GetGroupParticipants returns a group session's participants in the order
+// they joined
+func (p *PostgresDB) GetGroupParticipants(sessionID uuid.UUID) ([]models.GroupParticipant, error) {
+	rows, err := p.db.Query(`
+		SELECT gp.user_id, COALESCE(NULLIF(u.display_name, ''), split_part(u.email, '@', 1), ''),
+			gp.interests, gp.user_id = g.host_id, gp.joined_at
+		FROM group_participants gp
+		JOIN group_sessions g ON g.id = gp.session_id
+		LEFT JOIN users u ON u.id = gp.user_id
+		WHERE gp.session_id = $1
+		ORDER BY gp.joined_at, gp.user_id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	participants := []models.GroupParticipant{}
+	for rows.Next() {
+		var participant models.GroupParticipant
+		err := rows.Scan(
+			&participant.UserID,
+			&participant.Name,
+			pq.Array(&participant.Interests),
+			&participant.IsHost,
+			&participant.JoinedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if participant.Interests == nil {
+			participant.Interests = []string{}
+		}
+		participants = append(participants, participant)
+	}
+	
+	return participants, rows.Err()
+}
+
+// SaveGroupCollisions stores the collisions generated for a group session,
+// ranked in the order given, and closes it to new participants. Returns
+// sql.ErrNoRows if the session's collisions were generated already or it has
+// ended.
+func (p *PostgresDB) SaveGroupCollisions(sessionID uuid.UUID, mergedInterests []string, results []*models.CollisionResult) ([]models.GroupCollision, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`
+		UPDATE group_sessions
+		SET merged_interests = $2, generated_at = NOW()
+		WHERE id = $1 AND generated_at IS NULL AND ends_at > NOW()
+	`, sessionID, pq.Array(mergedInterests))
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return nil, err
+	}
+	
+	collisions := make([]models.GroupCollision, 0, len(results))
+	for i, res := range results {
+		resultJSON, err := json.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		
+		collision := models.GroupCollision{
+			ID:              uuid.New(),
+			SessionID:       sessionID,
+			Rank:            i + 1,
+			CollisionResult: *res,
+		}
+		err = tx.QueryRow(`
+			INSERT INTO group_collisions (id, session_id, rank, collision_result)
+			VALUES ($1, $2, $3, $4)
+			RETURNING created_at
+		`, collision.ID, sessionID, collision.Rank, resultJSON).Scan(&collision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, collision)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	
+	return collisions, nil
+}
+
+// GetGroupCollisions returns a group session's collisions as seen by viewerID,
+// most votes first. Ties go to the engine's ranking.
+func (p *PostgresDB) GetGroupCollisions(sessionID, viewerID uuid.UUID) ([]models.GroupCollision, error) {
+	rows, err := p.db.Query(`
+		SELECT gc.id, gc.session_id, gc.rank, gc.collision_result, gc.created_at,
+			(SELECT COUNT(*) FROM group_votes v WHERE v.collision_id = gc.id) AS votes,
+			EXISTS (SELECT 1 FROM group_votes v WHERE v.collision_id = gc.id AND v.user_id = $2)
+		FROM group_collisions gc
+		WHERE gc.session_id = $1
+		ORDER BY votes DESC, gc.rank
+	`, sessionID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	collisions := []models.GroupCollision{}
+	for rows.Next() {
+		var collision models.GroupCollision
+		var resultJSON []byte
+		err := rows.Scan(
+			&collision.ID,
+			&collision.SessionID,
+			&collision.Rank,
+			&resultJSON,
+			&collision.CreatedAt,
+			&collision.VoteCount,
+			&collision.Voted,
+		)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(resultJSON, &collision.CollisionResult)
+		collisions = append(collisions, collision)
+	}
+	
+	return collisions, rows.Err()
+}
+
+// SetGroupVote casts or withdraws a participant's vote for one of a group
+// session's collisions and returns its vote count. Each participant has one
+// vote per collision; voting twice changes nothing. Returns sql.ErrNoRows if
+// the session has no such collision.
+func (p *PostgresDB) SetGroupVote(collisionID, sessionID, userID uuid.UUID, vote bool) (int, error) {
+	var id uuid.UUID
+	err := p.db.QueryRow(`SELECT id FROM group_collisions WHERE id = $1 AND session_id = $2`, collisionID, sessionID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	
+	query := `DELETE FROM group_votes WHERE collision_id = $1 AND user_id = $2`
+	if vote {
+		query = `INSERT INTO group_votes (collision_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	}
+	if _, err := p.db.Exec(query, collisionID, userID); err != nil {
+		return 0, err
+	}
+	
+	var count int
+	err = p.db.QueryRow(`SELECT COUNT(*) FROM group_votes WHERE collision_id = $1`, collisionID).Scan(&count)
+	return count, err
+}
+
+// CloseGroupSession ends a group session early. Returns sql.ErrNoRows if it
+// doesn't exist, is hosted by someone else or has ended already.
+func (p *PostgresDB) CloseGroupSession(sessionID, hostID uuid.UUID) error {
+	result, err := p.db.Exec(`
+		UPDATE group_sessions SET ends_at = NOW()
+		WHERE id = $1 AND host_id = $2 AND ends_at > NOW()
+	`, sessionID, hostID)
+	if err != nil {
+		return err
+	}
+	
+	return requireRowsAffected(result)
+}
+
+// Usage tracking operations
+func (p *PostgresDB) GetUserUsage(userID uuid.UUID) (*models.UserUsage, error) {
+	usage := &models.UserUsage{}
+	
+	query := `
+		SELECT id, user_id, collision_count, reset_date, created_at, updated_at
+		FROM user_usage
+		WHERE user_id = $1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'
+		ORDER BY reset_date DESC
+		LIMIT 1
+	`
+	
+	err := p.db.QueryRow(query, userID).Scan(
+		&usage.ID,
+		&usage.UserID,
+		&usage.CollisionCount,
+		&usage.ResetDate,
+		&usage.CreatedAt,
+		&usage.UpdatedAt,
+	)
+	
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	
+	if err == sql.ErrNoRows {
+		// Create new usage record
+		usage = &models.UserUsage{
+			ID:             uuid.New(),
+			UserID:         userID,
+			CollisionCount: 0,
+			ResetDate:      time.Now(),
+			CreatedAt:      time.Now(),
+			UpdatedAt:      time.Now(),
+		}
+		
+		insertQuery := `
+			INSERT INTO user_usage (id, user_id, collision_count, reset_date, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		
+		_, err = p.db.Exec(insertQuery,
+			usage.ID,
+			usage.UserID,
+			usage.CollisionCount,
+			usage.ResetDate,
+			usage.CreatedAt,
+			usage.UpdatedAt,
+		)
+		
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	return usage, nil
+}
+
+func (p *PostgresDB) IncrementUserUsage(userID uuid.UUID) error {
+	query := `
+		UPDATE user_usage
+		SET collision_count = collision_count + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'
+	`
+	
+	_, err := p.db.Exec(query, userID)
+	return err
+}
+
+// ResetUserUsage sets the user's collision count for the current week back
+// to zero. A user with no usage this week is left as is.
+func (p *PostgresDB) ResetUserUsage(userID uuid.UUID) error {
+	_, err := p.db.Exec(`
+		UPDATE user_usage
+		SET collision_count = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'
+	`, userID)
+	
+	return err
+}
+
+// Prompt template operations
+func (p *PostgresDB) GetPromptTemplates() ([]models.PromptTemplate, error) {
+	query := `
+		SELECT id, name, version, system_template, user_template, active, created_at, updated_at
+		FROM prompt_templates
+		ORDER BY name, created_at
+	`
+	
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var templates []models.PromptTemplate
+	for rows.Next() {
+		t := models.PromptTemplate{}
+		
+		err := rows.Scan(
+			&t.ID,
+			&t.Name,
+			&t.Version,
+			&t.System,
+			&t.User,
+			&t.Active,
+			&t.CreatedAt,
+			&t.UpdatedAt,
+		)
+		
+		if err != nil {
+			return nil, err
+		}
+		
+		templates = append(templates, t)
+	}
+	
+	return templates, rows.Err()
+}
+
+func (p *PostgresDB) CreatePromptTemplate(t *models.PromptTemplate) error {
+	query := `
+		INSERT INTO prompt_templates (id, name, version, system_template, user_template, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	
+	_, err := p.db.Exec(query,
+		t.ID,
+		t.Name,
+		t.Version,
+		t.System,
+		t.User,
+		t.Active,
+		t.CreatedAt,
+		t.UpdatedAt,
+	)
+	
+	return err
+}
+
+// AI usage operations
+func (p *PostgresDB) RecordAIUsage(usage *models.AIUsage) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	insertQuery := `
+		INSERT INTO ai_usage_events (id, user_id, prompt, prompt_version, model, prompt_tokens, completion_tokens, total_tokens, cost_usd, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	
+	_, err = tx.Exec(insertQuery,
+		usage.ID,
+		usage.UserID,
+		usage.Prompt,
+		usage.PromptVersion,
+		usage.Model,
+		usage.PromptTokens,
+		usage.CompletionTokens,
+		usage.TotalTokens,
+		usage.CostUSD,
+		usage.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	
+	rollupQuery := `
+		INSERT INTO ai_usage_daily (user_id, day, calls, prompt_tokens, completion_tokens, cost_usd)
+		VALUES ($1, $2::date, 1, $3, $4, $5)
+		ON CONFLICT (user_id, day) DO UPDATE SET
+			calls = ai_usage_daily.calls + 1,
+			prompt_tokens = ai_usage_daily.prompt_tokens + EXCLUDED.prompt_tokens,
+			completion_tokens = ai_usage_daily.completion_tokens + EXCLUDED.completion_tokens,
+			cost_usd = ai_usage_daily.cost_usd + EXCLUDED.cost_usd
+	`
+	
+	_, err = tx.Exec(rollupQuery,
+		usage.UserID,
+		utcDay(usage.CreatedAt),
+		usage.PromptTokens,
+		usage.CompletionTokens,
+		usage.CostUSD,
+	)
+	if err != nil {
+		return err
+	}
+	
+	return tx.Commit()
 }
 
-func (p *PostgresDB) GetUserByEmail(email string) (*models.User, error) {
-	user := &models.User{}
-	var interestsJSON []byte
+// GetMonthlyAICost returns the user's estimated AI spend since monthStart, the
+// first day of the budget month
+func (p *PostgresDB) GetMonthlyAICost(userID uuid.UUID, monthStart time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(cost_usd), 0)
+		FROM ai_usage_daily
+		WHERE user_id = $1 AND day >= $2::date
+	`
 	
+	var cost float64
+	err := p.db.QueryRow(query, userID, utcDay(monthStart)).Scan(&cost)
+	return cost, err
+}
+
+// GetAIUsageSummary returns daily AI usage between from and to (inclusive).
+// A nil userID returns usage for every user.
+func (p *PostgresDB) GetAIUsageSummary(userID *uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error) {
 	query := `
-		SELECT id, email, password_hash, subscription_tier, interests, created_at, updated_at
-		FROM users WHERE email = $1
+		SELECT user_id, day, calls, prompt_tokens, completion_tokens, cost_usd
+		FROM ai_usage_daily
+		WHERE day BETWEEN $1::date AND $2::date
+		  AND ($3::uuid IS NULL OR user_id = $3)
+		ORDER BY day DESC, cost_usd DESC
 	`
 	
-	err := p.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.SubscriptionTier,
-		&interestsJSON,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return p.queryAIUsageSummaries(query, utcDay(from), utcDay(to), userID)
+}
+
+// GetTeamAIUsageSummary returns the daily AI usage of a team's current members
+// between from and to (inclusive)
+func (p *PostgresDB) GetTeamAIUsageSummary(teamID uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error) {
+	query := `
+		SELECT d.user_id, d.day, d.calls, d.prompt_tokens, d.completion_tokens, d.cost_usd
+		FROM ai_usage_daily d
+		JOIN team_members m ON m.user_id = d.user_id
+		WHERE m.team_id = $3 AND d.day BETWEEN $1::date AND $2::date
+		ORDER BY d.day DESC, d.cost_usd DESC
+	`
 	
+	return p.queryAIUsageSummaries(query, utcDay(from), utcDay(to), teamID)
+}
+
+func (p *PostgresDB) queryAIUsageSummaries(query string, args ...interface{}) ([]models.AIUsageSummary, error) {
+	rows, err := p.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	
-	if len(interestsJSON) > 0 {
-		json.Unmarshal(interestsJSON, &user.Interests)
+	var summaries []models.AIUsageSummary
+	for rows.Next() {
+		summary := models.AIUsageSummary{}
+		
+		err := rows.Scan(
+			&summary.UserID,
+			&summary.Day,
+			&summary.Calls,
+			&summary.PromptTokens,
+			&summary.CompletionTokens,
+			&summary.CostUSD,
+		)
+		
+		if err != nil {
+			return nil, err
+		}
+		
+		summaries = append(summaries, summary)
 	}
 	
-	return user, nil
+	return summaries, rows.Err()
 }
 
-func (p *PostgresDB) GetUserByID(id uuid.UUID) (*models.User, error) {
-	user := &models.User{}
-	var interestsJSON []byte
-	
+// utcDay formats the UTC date of t. AI usage is rolled up by UTC day, whatever
+// the time zone of the server or the database session.
+func utcDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// Domain suggestion operations
+
+// CreateDomainSuggestion queues an AI-proposed domain for review. A suggestion
+// whose name is already pending is skipped; queued reports whether it was stored.
+func (p *PostgresDB) CreateDomainSuggestion(suggestion *models.DomainSuggestion) (bool, error) {
 	query := `
-		SELECT id, email, password_hash, subscription_tier, interests, created_at, updated_at
-		FROM users WHERE id = $1
+		INSERT INTO domain_suggestions (id, user_id, interests, domain, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (LOWER(domain->>'name')) WHERE status = 'pending' DO NOTHING
 	`
 	
-	err := p.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.SubscriptionTier,
-		&interestsJSON,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	interestsJSON, _ := json.Marshal(suggestion.Interests)
+	domainJSON, err := json.Marshal(suggestion.Domain)
+	if err != nil {
+		return false, err
+	}
 	
+	result, err := p.db.Exec(query,
+		suggestion.ID,
+		suggestion.UserID,
+		interestsJSON,
+		domainJSON,
+		suggestion.Status,
+		suggestion.CreatedAt,
+	)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 	
-	if len(interestsJSON) > 0 {
-		json.Unmarshal(interestsJSON, &user.Interests)
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
 	
-	return user, nil
+	return rows > 0, nil
 }
 
-// Collision Domain operations
-func (p *PostgresDB) GetCollisionDomains(tier string) ([]models.CollisionDomain, error) {
+// GetDomainSuggestions returns suggestions with the given status, newest first.
+// An empty status returns every suggestion.
+func (p *PostgresDB) GetDomainSuggestions(status string, limit int) ([]models.DomainSuggestion, error) {
 	query := `
-		SELECT id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at
-		FROM collision_domains
-		WHERE tier = $1 OR tier = 'basic'
-		ORDER BY name
+		SELECT id, user_id, interests, domain, status, review_notes, reviewed_by, domain_id, created_at, reviewed_at
+		FROM domain_suggestions
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2
 	`
 	
-	rows, err := p.db.Query(query, tier)
+	rows, err := p.db.Query(query, status, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	
-	var domains []models.CollisionDomain
+	var suggestions []models.DomainSuggestion
 	for rows.Next() {
-		domain := models.CollisionDomain{}
-		var examplesJSON, keywordsJSON, intensityJSON []byte
-		
-		err := rows.Scan(
-			&domain.ID,
-			&domain.Name,
-			&domain.Category,
-			&domain.Description,
-			&examplesJSON,
-			&keywordsJSON,
-			&intensityJSON,
-			&domain.Tier,
-			&domain.CreatedAt,
-			&domain.UpdatedAt,
-		)
-		
+		suggestion, err := scanDomainSuggestion(rows)
 		if err != nil {
 			return nil, err
 		}
-		
-		json.Unmarshal(examplesJSON, &domain.Examples)
-		json.Unmarshal(keywordsJSON, &domain.Keywords)
-		json.Unmarshal(intensityJSON, &domain.Intensity)
-		
-		domains = append(domains, domain)
+		suggestions = append(suggestions, *suggestion)
 	}
 	
-	return domains, nil
+	return suggestions, rows.Err()
 }
 
-func (p *PostgresDB) CreateCollisionDomain(domain *models.CollisionDomain) error {
+// GetDomainSuggestion returns a single suggestion, or sql.ErrNoRows if it doesn't exist
+func (p *PostgresDB) GetDomainSuggestion(id uuid.UUID) (*models.DomainSuggestion, error) {
 	query := `
-		INSERT INTO collision_domains (id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		SELECT id, user_id, interests, domain, status, review_notes, reviewed_by, domain_id, created_at, reviewed_at
+		FROM domain_suggestions
+		WHERE id = $1
 	`
 	
+	return scanDomainSuggestion(p.db.QueryRow(query, id))
+}
+
+// ApproveDomainSuggestion adds the (possibly edited) domain to the catalog and
+// marks the suggestion approved. Returns sql.ErrNoRows if the suggestion is not pending.
+func (p *PostgresDB) ApproveDomainSuggestion(id, reviewerID uuid.UUID, domain *models.CollisionDomain, notes *string) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
 	examplesJSON, _ := json.Marshal(domain.Examples)
 	keywordsJSON, _ := json.Marshal(domain.Keywords)
 	intensityJSON, _ := json.Marshal(domain.Intensity)
 	
-	_, err := p.db.Exec(query,
+	_, err = tx.Exec(`
+		INSERT INTO collision_domains (id, name, category, description, examples, keywords, intensity, tier, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`,
 		domain.ID,
 		domain.Name,
 		domain.Category,
@@ -189,150 +3537,241 @@ func (p *PostgresDB) CreateCollisionDomain(domain *models.CollisionDomain) error
 		domain.CreatedAt,
 		domain.UpdatedAt,
 	)
+	if err != nil {
+		return err
+	}
 	
-	return err
+	result, err := tx.Exec(`
+		UPDATE domain_suggestions
+		SET status = 'approved', review_notes = $3, reviewed_by = $2, domain_id = $4, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, id, reviewerID, notes, domain.ID)
+	if err != nil {
+		return err
+	}
+	
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
 }
 
-// Collision Session operations
-func (p *PostgresDB) CreateCollisionSession(session *models.CollisionSession) error {
-	query := `
-		INSERT INTO collision_sessions (id, user_id, input_data, collision_result, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`
+// RejectDomainSuggestion marks a pending suggestion rejected.
+// Returns sql.ErrNoRows if the suggestion is not pending.
+func (p *PostgresDB) RejectDomainSuggestion(id, reviewerID uuid.UUID, notes *string) error {
+	result, err := p.db.Exec(`
+		UPDATE domain_suggestions
+		SET status = 'rejected', review_notes = $3, reviewed_by = $2, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, id, reviewerID, notes)
+	if err != nil {
+		return err
+	}
 	
-	inputJSON, _ := json.Marshal(session.InputData)
-	resultJSON, _ := json.Marshal(session.CollisionResult)
+	return requireRowsAffected(result)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDomainSuggestion(row rowScanner) (*models.DomainSuggestion, error) {
+	suggestion := &models.DomainSuggestion{}
+	var interestsJSON, domainJSON []byte
 	
-	_, err := p.db.Exec(query,
-		session.ID,
-		session.UserID,
-		inputJSON,
-		resultJSON,
-		session.CreatedAt,
+	err := row.Scan(
+		&suggestion.ID,
+		&suggestion.UserID,
+		&interestsJSON,
+		&domainJSON,
+		&suggestion.Status,
+		&suggestion.ReviewNotes,
+		&suggestion.ReviewedBy,
+		&suggestion.DomainID,
+		&suggestion.CreatedAt,
+		&suggestion.ReviewedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
 	
-	return err
+	json.Unmarshal(interestsJSON, &suggestion.Interests)
+	json.Unmarshal(domainJSON, &suggestion.Domain)
+	
+	return suggestion, nil
 }
 
-func (p *PostgresDB) GetUserCollisionHistory(userID uuid.UUID, limit int) ([]models.CollisionSession, error) {
+// Embedding operations
+
+// GetEmbeddings returns every cached embedding of a kind for a model
+func (p *PostgresDB) GetEmbeddings(kind, model string) ([]models.Embedding, error) {
 	query := `
-		SELECT id, user_id, input_data, collision_result, user_rating, exploration_notes, created_at
-		FROM collision_sessions
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
+		SELECT kind, key, model, content_hash, vector, updated_at
+		FROM embeddings
+		WHERE kind = $1 AND model = $2
 	`
 	
-	rows, err := p.db.Query(query, userID, limit)
+	rows, err := p.db.Query(query, kind, model)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	
-	var sessions []models.CollisionSession
+	var embeddings []models.Embedding
 	for rows.Next() {
-		session := models.CollisionSession{}
-		var inputJSON, resultJSON []byte
+		embedding := models.Embedding{}
 		
 		err := rows.Scan(
-			&session.ID,
-			&session.UserID,
-			&inputJSON,
-			&resultJSON,
-			&session.UserRating,
-			&session.ExplorationNotes,
-			&session.CreatedAt,
+			&embedding.Kind,
+			&embedding.Key,
+			&embedding.Model,
+			&embedding.ContentHash,
+			(*pq.Float32Array)(&embedding.Vector),
+			&embedding.UpdatedAt,
 		)
 		
 		if err != nil {
 			return nil, err
 		}
 		
-		json.Unmarshal(inputJSON, &session.InputData)
-		json.Unmarshal(resultJSON, &session.CollisionResult)
-		
-		sessions = append(sessions, session)
+		embeddings = append(embeddings, embedding)
 	}
 	
-	return sessions, nil
+	return embeddings, rows.Err()
 }
 
-func (p *PostgresDB) RateCollision(sessionID, userID uuid.UUID, rating int, notes *string) error {
+// GetEmbedding returns a cached embedding, or nil if there isn't one
+func (p *PostgresDB) GetEmbedding(kind, key, model string) (*models.Embedding, error) {
 	query := `
-		UPDATE collision_sessions
-		SET user_rating = $1, exploration_notes = $2
-		WHERE id = $3 AND user_id = $4
+		SELECT kind, key, model, content_hash, vector, updated_at
+		FROM embeddings
+		WHERE kind = $1 AND key = $2 AND model = $3
 	`
 	
-	_, err := p.db.Exec(query, rating, notes, sessionID, userID)
-	return err
+	embedding := &models.Embedding{}
+	err := p.db.QueryRow(query, kind, key, model).Scan(
+		&embedding.Kind,
+		&embedding.Key,
+		&embedding.Model,
+		&embedding.ContentHash,
+		(*pq.Float32Array)(&embedding.Vector),
+		&embedding.UpdatedAt,
+	)
+	
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	return embedding, nil
 }
 
-// Usage tracking operations
-func (p *PostgresDB) GetUserUsage(userID uuid.UUID) (*models.UserUsage, error) {
-	usage := &models.UserUsage{}
-	
+// SaveEmbedding inserts or replaces a cached embedding
+func (p *PostgresDB) SaveEmbedding(embedding *models.Embedding) error {
 	query := `
-		SELECT id, user_id, collision_count, reset_date, created_at, updated_at
-		FROM user_usage
-		WHERE user_id = $1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'
-		ORDER BY reset_date DESC
-		LIMIT 1
+		INSERT INTO embeddings (kind, key, model, content_hash, vector, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (kind, model, key) DO UPDATE SET
+			content_hash = EXCLUDED.content_hash,
+			vector = EXCLUDED.vector,
+			updated_at = EXCLUDED.updated_at
 	`
 	
-	err := p.db.QueryRow(query, userID).Scan(
-		&usage.ID,
-		&usage.UserID,
-		&usage.CollisionCount,
-		&usage.ResetDate,
-		&usage.CreatedAt,
-		&usage.UpdatedAt,
+	_, err := p.db.Exec(query,
+		embedding.Kind,
+		embedding.Key,
+		embedding.Model,
+		embedding.ContentHash,
+		pq.Float32Array(embedding.Vector),
+		embedding.UpdatedAt,
 	)
 	
-	if err != nil && err != sql.ErrNoRows {
+	return err
+}
+
+// Audit log operations
+
+// CreateAuditEntry records a security or administrative event
+func (p *PostgresDB) CreateAuditEntry(entry *models.AuditEntry) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.Details == nil {
+		entry.Details = map[string]interface{}{}
+	}
+	
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+	
+	return p.db.QueryRow(`
+		INSERT INTO audit_log (id, user_id, actor_id, action, ip_address, details)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING created_at
+	`, entry.ID, entry.UserID, entry.ActorID, entry.Action, entry.IPAddress, details).Scan(&entry.CreatedAt)
+}
+
+// GetAuditEntries returns the audit log entries matching the filter, newest first
+func (p *PostgresDB) GetAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	
+	if filter.UserID != nil {
+		addCondition("user_id = $%d", *filter.UserID)
+	}
+	if filter.ActorID != nil {
+		addCondition("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	
+	rows, err := p.db.Query(fmt.Sprintf(`
+		SELECT id, user_id, actor_id, action, COALESCE(ip_address, ''), details, created_at
+		FROM audit_log
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d
+	`, where, len(args)), args...)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	
-	if err == sql.ErrNoRows {
-		// Create new usage record
-		usage = &models.UserUsage{
-			ID:             uuid.New(),
-			UserID:         userID,
-			CollisionCount: 0,
-			ResetDate:      time.Now(),
-			CreatedAt:      time.Now(),
-			UpdatedAt:      time.Now(),
-		}
-		
-		insertQuery := `
-			INSERT INTO user_usage (id, user_id, collision_count, reset_date, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
-		`
-		
-		_, err = p.db.Exec(insertQuery,
-			usage.ID,
-			usage.UserID,
-			usage.CollisionCount,
-			usage.ResetDate,
-			usage.CreatedAt,
-			usage.UpdatedAt,
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var details []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.IPAddress,
+			&details,
+			&entry.CreatedAt,
 		)
-		
 		if err != nil {
 			return nil, err
 		}
+		json.Unmarshal(details, &entry.Details)
+		entries = append(entries, entry)
 	}
 	
-	return usage, nil
+	return entries, rows.Err()
 }
-
-func (p *PostgresDB) IncrementUserUsage(userID uuid.UUID) error {
-	query := `
-		UPDATE user_usage
-		SET collision_count = collision_count + 1, updated_at = CURRENT_TIMESTAMP
-		WHERE user_id = $1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'
-	`
-	
-	_, err := p.db.Exec(query, userID)
-	return err
-}
\ No newline at end of file
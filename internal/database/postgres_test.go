@@ -7,6 +7,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
@@ -67,7 +68,7 @@ func (suite *PostgresTestSuite) TestGetUserByEmail() {
 	
 	rows := sqlmock.NewRows([]string{
 		"id", "email", "password_hash", "subscription_tier", 
-		"interests", "created_at", "updated_at",
+		"interests", "created_at", "updated_at", "display_name", "email_verified_at", "role",
 	}).AddRow(
 		userID,
 		email,
@@ -76,9 +77,12 @@ func (suite *PostgresTestSuite) TestGetUserByEmail() {
 		interests,
 		time.Now(),
 		time.Now(),
+		"",
+		nil,
+		models.RoleUser,
 	)
 	
-	suite.mock.ExpectQuery("SELECT .* FROM users WHERE email = \\$1").
+	suite.mock.ExpectQuery("SELECT .* FROM users WHERE LOWER\\(email\\) = LOWER\\(\\$1\\)").
 		WithArgs(email).
 		WillReturnRows(rows)
 	
@@ -95,7 +99,7 @@ func (suite *PostgresTestSuite) TestGetUserByEmail() {
 func (suite *PostgresTestSuite) TestGetUserByEmailNotFound() {
 	email := "nonexistent@example.com"
 	
-	suite.mock.ExpectQuery("SELECT .* FROM users WHERE email = \\$1").
+	suite.mock.ExpectQuery("SELECT .* FROM users WHERE LOWER\\(email\\) = LOWER\\(\\$1\\)").
 		WithArgs(email).
 		WillReturnError(sql.ErrNoRows)
 	
@@ -112,7 +116,7 @@ func (suite *PostgresTestSuite) TestGetUserByID() {
 	
 	rows := sqlmock.NewRows([]string{
 		"id", "email", "password_hash", "subscription_tier",
-		"interests", "created_at", "updated_at",
+		"interests", "created_at", "updated_at", "display_name", "email_verified_at", "role",
 	}).AddRow(
 		userID,
 		"test@example.com",
@@ -121,6 +125,9 @@ func (suite *PostgresTestSuite) TestGetUserByID() {
 		interests,
 		time.Now(),
 		time.Now(),
+		"",
+		nil,
+		models.RoleUser,
 	)
 	
 	suite.mock.ExpectQuery("SELECT .* FROM users WHERE id = \\$1").
@@ -136,6 +143,139 @@ func (suite *PostgresTestSuite) TestGetUserByID() {
 	assert.Equal(suite.T(), []string{"technology", "design"}, user.Interests)
 }
 
+// userColumnNames matches the columns selected by userColumns
+var userColumnNames = []string{"id", "email", "password_hash", "subscription_tier", "interests", "created_at", "updated_at", "display_name", "email_verified_at", "role"}
+
+func (suite *PostgresTestSuite) TestUpdateUser() {
+	user := &models.User{
+		ID:          uuid.New(),
+		DisplayName: "Ada",
+		Interests:   []string{"biology", "music"},
+	}
+	updatedAt := time.Now()
+	
+	suite.mock.ExpectQuery("UPDATE users SET display_name = NULLIF\\(\\$2, ''\\), interests = \\$3 WHERE id = \\$1 RETURNING updated_at").
+		WithArgs(user.ID, "Ada", []byte(`["biology","music"]`)).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updatedAt))
+	
+	err := suite.pgdb.UpdateUser(user)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), updatedAt, user.UpdatedAt)
+	
+	// Clearing interests stores an empty list rather than null
+	user.Interests = nil
+	suite.mock.ExpectQuery("UPDATE users").
+		WithArgs(user.ID, "Ada", []byte(`[]`)).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}))
+	
+	err = suite.pgdb.UpdateUser(user)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateUserPassword() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET password_hash = \\$2 WHERE id = \\$1").
+		WithArgs(userID, "$2a$10$newhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.UpdateUserPassword(userID, "$2a$10$newhash"))
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET password_hash").
+		WithArgs(userID, "$2a$10$newhash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.UpdateUserPassword(userID, "$2a$10$newhash"))
+}
+
+func (suite *PostgresTestSuite) TestCreateEmailChange() {
+	change := &models.EmailChange{
+		UserID:    uuid.New(),
+		NewEmail:  "new@example.com",
+		TokenHash: "abc123",
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	
+	suite.mock.ExpectExec("INSERT INTO email_changes (.+) ON CONFLICT \\(user_id\\) DO UPDATE").
+		WithArgs(change.UserID, change.NewEmail, change.TokenHash, change.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.CreateEmailChange(change))
+}
+
+func (suite *PostgresTestSuite) TestConfirmEmailChange() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("DELETE FROM email_changes WHERE token_hash = \\$1 RETURNING user_id, new_email, expires_at").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "new_email", "expires_at"}).
+			AddRow(userID, "new@example.com", time.Now().Add(time.Hour)))
+	suite.mock.ExpectQuery("UPDATE users SET email = \\$2, email_verified_at = NOW\\(\\) WHERE id = \\$1 RETURNING").
+		WithArgs(userID, "new@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumnNames).
+			AddRow(userID, "new@example.com", "$2a$10$hash", models.TierFree, `[]`, time.Now(), time.Now(), "Ada", time.Now(), models.RoleUser))
+	suite.mock.ExpectCommit()
+	
+	user, err := suite.pgdb.ConfirmEmailChange("abc123")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "new@example.com", user.Email)
+	assert.Equal(suite.T(), "Ada", user.DisplayName)
+}
+
+func (suite *PostgresTestSuite) TestConfirmEmailChangeExpired() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("DELETE FROM email_changes").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "new_email", "expires_at"}).
+			AddRow(uuid.New(), "new@example.com", time.Now().Add(-time.Minute)))
+	// The expired change is still removed
+	suite.mock.ExpectCommit()
+	
+	_, err := suite.pgdb.ConfirmEmailChange("abc123")
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestConfirmEmailChangeTaken() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("DELETE FROM email_changes").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "new_email", "expires_at"}).
+			AddRow(userID, "taken@example.com", time.Now().Add(time.Hour)))
+	suite.mock.ExpectQuery("UPDATE users SET email").
+		WithArgs(userID, "taken@example.com").
+		WillReturnError(&pq.Error{Code: "23505"})
+	suite.mock.ExpectRollback()
+	
+	_, err := suite.pgdb.ConfirmEmailChange("abc123")
+	assert.Equal(suite.T(), ErrEmailTaken, err)
+}
+
+func (suite *PostgresTestSuite) TestDeleteUser() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("DELETE FROM users WHERE id = \\$1").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.DeleteUser(userID))
+	
+	suite.mock.ExpectExec("DELETE FROM users").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.DeleteUser(userID))
+}
+
 func (suite *PostgresTestSuite) TestGetCollisionDomains() {
 	domainID := uuid.New().String()
 	tier := "basic"
@@ -231,6 +371,8 @@ func (suite *PostgresTestSuite) TestCreateCollisionSession() {
 			sqlmock.AnyArg(), // JSON input_data
 			sqlmock.AnyArg(), // JSON collision_result
 			session.CreatedAt,
+			session.ParentID,
+			session.FollowUp,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	
@@ -352,7 +494,7 @@ func (suite *PostgresTestSuite) TestDatabaseConnectionError() {
 	// Test error handling when database operations fail
 	email := "test@example.com"
 	
-	suite.mock.ExpectQuery("SELECT .* FROM users WHERE email = \\$1").
+	suite.mock.ExpectQuery("SELECT .* FROM users WHERE LOWER\\(email\\) = LOWER\\(\\$1\\)").
 		WithArgs(email).
 		WillReturnError(sql.ErrConnDone)
 	
@@ -391,6 +533,1889 @@ func (suite *PostgresTestSuite) TestJSONMarshaling() {
 	assert.NoError(suite.T(), err)
 }
 
+func (suite *PostgresTestSuite) TestGetPromptTemplates() {
+	templateID := uuid.New()
+	
+	rows := sqlmock.NewRows([]string{
+		"id", "name", "version", "system_template", "user_template",
+		"active", "created_at", "updated_at",
+	}).AddRow(
+		templateID,
+		"connection",
+		"v2",
+		"You are a connector.",
+		"Connect {{.Domain.Name}}",
+		true,
+		time.Now(),
+		time.Now(),
+	)
+	
+	suite.mock.ExpectQuery("SELECT .* FROM prompt_templates").
+		WillReturnRows(rows)
+	
+	templates, err := suite.pgdb.GetPromptTemplates()
+	
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), templates, 1)
+	assert.Equal(suite.T(), templateID, templates[0].ID)
+	assert.Equal(suite.T(), "connection", templates[0].Name)
+	assert.Equal(suite.T(), "v2", templates[0].Version)
+	assert.Equal(suite.T(), "Connect {{.Domain.Name}}", templates[0].User)
+	assert.True(suite.T(), templates[0].Active)
+}
+
+func (suite *PostgresTestSuite) TestRecordAIUsage() {
+	usage := &models.AIUsage{
+		ID:               uuid.New(),
+		UserID:           uuid.New(),
+		Prompt:           "connection",
+		PromptVersion:    "v1",
+		Model:            "gpt-3.5-turbo-0125",
+		PromptTokens:     120,
+		CompletionTokens: 80,
+		TotalTokens:      200,
+		CostUSD:          0.00018,
+		CreatedAt:        time.Now(),
+	}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO ai_usage_events").
+		WithArgs(usage.ID, usage.UserID, "connection", "v1", usage.Model, 120, 80, 200, 0.00018, usage.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO ai_usage_daily .* ON CONFLICT").
+		WithArgs(usage.UserID, usage.CreatedAt.UTC().Format("2006-01-02"), 120, 80, 0.00018).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.RecordAIUsage(usage)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestGetMonthlyAICost() {
+	userID := uuid.New()
+	
+	monthStart := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	
+	suite.mock.ExpectQuery("SELECT COALESCE\\(SUM\\(cost_usd\\), 0\\) FROM ai_usage_daily WHERE user_id = \\$1 AND day >= \\$2::date").
+		WithArgs(userID, "2026-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(1.25))
+	
+	cost, err := suite.pgdb.GetMonthlyAICost(userID, monthStart)
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1.25, cost)
+}
+
+func (suite *PostgresTestSuite) TestGetTeamAIUsageSummary() {
+	teamID := uuid.New()
+	userID := uuid.New()
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.March, 14, 23, 0, 0, 0, time.UTC)
+	day := time.Date(2026, time.March, 12, 0, 0, 0, 0, time.UTC)
+	
+	suite.mock.ExpectQuery("FROM ai_usage_daily d JOIN team_members m ON m.user_id = d.user_id WHERE m.team_id = \\$3 AND d.day BETWEEN \\$1::date AND \\$2::date").
+		WithArgs("2026-03-01", "2026-03-14", teamID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "day", "calls", "prompt_tokens", "completion_tokens", "cost_usd"}).
+			AddRow(userID, day, 4, 480, 320, 0.00072))
+	
+	summaries, err := suite.pgdb.GetTeamAIUsageSummary(teamID, from, to)
+	
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), summaries, 1)
+	assert.Equal(suite.T(), userID, summaries[0].UserID)
+	assert.Equal(suite.T(), 4, summaries[0].Calls)
+	assert.Equal(suite.T(), 0.00072, summaries[0].CostUSD)
+}
+
+func (suite *PostgresTestSuite) TestCreateDomainSuggestion() {
+	userID := uuid.New()
+	suggestion := &models.DomainSuggestion{
+		ID:        uuid.New(),
+		UserID:    &userID,
+		Interests: []string{"knitting"},
+		Domain:    models.CollisionDomain{Name: "Beekeeping", Category: "Natural Systems"},
+		Status:    models.SuggestionPending,
+		CreatedAt: time.Now(),
+	}
+	
+	suite.mock.ExpectExec("INSERT INTO domain_suggestions .* ON CONFLICT").
+		WithArgs(suggestion.ID, suggestion.UserID, sqlmock.AnyArg(), sqlmock.AnyArg(), "pending", suggestion.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	queued, err := suite.pgdb.CreateDomainSuggestion(suggestion)
+	
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), queued)
+}
+
+func (suite *PostgresTestSuite) TestApproveDomainSuggestion() {
+	suggestionID := uuid.New()
+	reviewerID := uuid.New()
+	domain := &models.CollisionDomain{
+		ID:        uuid.New().String(),
+		Name:      "Beekeeping",
+		Category:  "Natural Systems",
+		Tier:      "premium",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO collision_domains").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("UPDATE domain_suggestions SET status = 'approved'").
+		WithArgs(suggestionID, reviewerID, nil, domain.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.ApproveDomainSuggestion(suggestionID, reviewerID, domain, nil)
+	
+	assert.ErrorIs(suite.T(), err, sql.ErrNoRows)
+}
+
+func (suite *PostgresTestSuite) TestGetDomainSuggestion() {
+	suggestionID := uuid.New()
+	userID := uuid.New()
+	now := time.Now()
+	
+	rows := sqlmock.NewRows([]string{"id", "user_id", "interests", "domain", "status", "review_notes", "reviewed_by", "domain_id", "created_at", "reviewed_at"}).
+		AddRow(suggestionID, userID, []byte(`["knitting"]`), []byte(`{"name":"Beekeeping","keywords":["hive"]}`), "pending", nil, nil, nil, now, nil)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM domain_suggestions WHERE id = \\$1").
+		WithArgs(suggestionID).
+		WillReturnRows(rows)
+	
+	suggestion, err := suite.pgdb.GetDomainSuggestion(suggestionID)
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), userID, *suggestion.UserID)
+	assert.Equal(suite.T(), []string{"knitting"}, suggestion.Interests)
+	assert.Equal(suite.T(), "Beekeeping", suggestion.Domain.Name)
+	assert.Nil(suite.T(), suggestion.ReviewedBy)
+	assert.Nil(suite.T(), suggestion.ReviewedAt)
+}
+
+func (suite *PostgresTestSuite) TestSaveAndGetEmbedding() {
+	embedding := &models.Embedding{
+		Kind:        "interest",
+		Key:         "gardening",
+		Model:       "local-hash-1024",
+		ContentHash: "abc123",
+		Vector:      []float32{0.5, -0.25, 1},
+		UpdatedAt:   time.Now(),
+	}
+	
+	suite.mock.ExpectExec("INSERT INTO embeddings .* ON CONFLICT \\(kind, model, key\\) DO UPDATE").
+		WithArgs("interest", "gardening", "local-hash-1024", "abc123", sqlmock.AnyArg(), embedding.UpdatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.SaveEmbedding(embedding))
+	
+	rows := sqlmock.NewRows([]string{"kind", "key", "model", "content_hash", "vector", "updated_at"}).
+		AddRow("interest", "gardening", "local-hash-1024", "abc123", "{0.5,-0.25,1}", embedding.UpdatedAt)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM embeddings WHERE kind = \\$1 AND key = \\$2 AND model = \\$3").
+		WithArgs("interest", "gardening", "local-hash-1024").
+		WillReturnRows(rows)
+	
+	cached, err := suite.pgdb.GetEmbedding("interest", "gardening", "local-hash-1024")
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []float32{0.5, -0.25, 1}, cached.Vector)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM embeddings").
+		WithArgs("interest", "missing", "local-hash-1024").
+		WillReturnError(sql.ErrNoRows)
+	
+	missing, err := suite.pgdb.GetEmbedding("interest", "missing", "local-hash-1024")
+	
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), missing)
+}
+
+func (suite *PostgresTestSuite) TestSearchCollisionHistory() {
+	userID := uuid.New()
+	minRating := 4
+	cursor := &models.HistoryCursor{CreatedAt: time.Now(), ID: uuid.New()}
+	
+	rows := sqlmock.NewRows(sessionColumnNames)
+	for i := 0; i < 3; i++ {
+		rows.AddRow(uuid.New(), userID, []byte(`{"project_type":"product"}`), []byte(`{"collision_domain":"Biomimicry"}`), 5, nil, time.Now(), nil, false, "{}", nil, "", 0)
+	}
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM collision_sessions WHERE user_id = \\$1 AND deleted_at IS NULL AND LOWER\\(collision_result->>'collision_domain'\\) = LOWER\\(\\$2\\) AND user_rating >= \\$3 AND search_vector @@ websearch_to_tsquery\\('english', \\$4\\) AND \\(created_at, id\\) < \\(\\$5, \\$6\\) ORDER BY created_at DESC, id DESC LIMIT \\$7").
+		WithArgs(userID, "biomimicry", 4, "habit tracker", cursor.CreatedAt, cursor.ID, 3).
+		WillReturnRows(rows)
+	
+	sessions, hasMore, err := suite.pgdb.SearchCollisionHistory(userID, models.HistoryFilter{
+		Domain:    "biomimicry",
+		MinRating: &minRating,
+		Query:     "habit tracker",
+		Cursor:    cursor,
+		Limit:     2,
+	})
+	
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), hasMore)
+	assert.Len(suite.T(), sessions, 2)
+	assert.Equal(suite.T(), "Biomimicry", sessions[0].CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), "product", sessions[0].InputData.ProjectType)
+}
+
+func (suite *PostgresTestSuite) TestRateCollisionNotOwned() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET user_rating = \\$1, exploration_notes = \\$2 WHERE id = \\$3 AND user_id = \\$4 AND deleted_at IS NULL").
+		WithArgs(4, nil, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.RateCollision(sessionID, userID, 4, nil)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetCollisionSession() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	
+	rows := sqlmock.NewRows(sessionColumnNames).
+		AddRow(sessionID, userID, []byte(`{"user_interests":["design"]}`), []byte(`{"collision_domain":"Biomimicry"}`), 4, nil, time.Now(), nil, true, "{packaging,retail}", nil, "", 0)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM collision_sessions WHERE id = \\$1 AND user_id = \\$2 AND deleted_at IS NULL").
+		WithArgs(sessionID, userID).
+		WillReturnRows(rows)
+	
+	session, err := suite.pgdb.GetCollisionSession(sessionID, userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), sessionID, session.ID)
+	assert.Equal(suite.T(), "Biomimicry", session.CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), 4, *session.UserRating)
+	assert.True(suite.T(), session.IsFavorite)
+	assert.Equal(suite.T(), []string{"packaging", "retail"}, session.Tags)
+	
+	// Someone else's session looks exactly like a missing one
+	suite.mock.ExpectQuery("SELECT (.+) FROM collision_sessions").
+		WithArgs(sessionID, userID).
+		WillReturnError(sql.ErrNoRows)
+	
+	_, err = suite.pgdb.GetCollisionSession(sessionID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateCollisionSession() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	notes := "Try it with packaging"
+	update := models.SessionUpdate{Notes: &notes}
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET user_rating = COALESCE\\(\\$1, user_rating\\), exploration_notes = COALESCE\\(\\$2, exploration_notes\\)").
+		WithArgs(update.Rating, update.Notes, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	err := suite.pgdb.UpdateCollisionSession(sessionID, userID, update)
+	assert.NoError(suite.T(), err)
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions").
+		WithArgs(update.Rating, update.Notes, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err = suite.pgdb.UpdateCollisionSession(sessionID, userID, update)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestDeleteAndRestoreCollisionSession() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET deleted_at = NOW\\(\\) WHERE id = \\$1 AND user_id = \\$2 AND deleted_at IS NULL").
+		WithArgs(sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	err := suite.pgdb.DeleteCollisionSession(sessionID, userID)
+	assert.NoError(suite.T(), err)
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET deleted_at = NULL WHERE id = \\$1 AND user_id = \\$2 AND deleted_at IS NOT NULL AND deleted_at > \\$3").
+		WithArgs(sessionID, userID, cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	err = suite.pgdb.RestoreCollisionSession(sessionID, userID, cutoff)
+	assert.NoError(suite.T(), err)
+	
+	// Deleting twice, or restoring past the retention window, matches nothing
+	suite.mock.ExpectExec("UPDATE collision_sessions SET deleted_at = NOW\\(\\)").
+		WithArgs(sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err = suite.pgdb.DeleteCollisionSession(sessionID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET deleted_at = NULL").
+		WithArgs(sessionID, userID, cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err = suite.pgdb.RestoreCollisionSession(sessionID, userID, cutoff)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestPurgeDeletedCollisionSessions() {
+	before := time.Now().Add(-30 * 24 * time.Hour)
+	
+	suite.mock.ExpectExec("DELETE FROM collision_sessions WHERE deleted_at IS NOT NULL AND deleted_at <= \\$1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	
+	purged, err := suite.pgdb.PurgeDeletedCollisionSessions(before)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), purged)
+}
+
+// sessionColumnNames matches the columns selected by sessionColumns
+var sessionColumnNames = []string{"id", "user_id", "input_data", "collision_result", "user_rating", "exploration_notes", "created_at", "deleted_at", "is_favorite", "tags", "parent_id", "follow_up", "child_count"}
+
+func (suite *PostgresTestSuite) TestSearchCollisionHistoryByTagCollectionAndFavorite() {
+	userID := uuid.New()
+	collectionID := uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM collision_sessions WHERE user_id = \\$1 AND deleted_at IS NULL AND EXISTS \\(SELECT 1 FROM session_tags (.+) session_tags.tag = \\$2\\) AND EXISTS \\(SELECT 1 FROM collection_items (.+) collections.user_id = \\$1 AND collections.id = \\$3\\) AND is_favorite ORDER BY created_at DESC, id DESC LIMIT \\$4").
+		WithArgs(userID, "packaging", collectionID, 21).
+		WillReturnRows(sqlmock.NewRows(sessionColumnNames))
+	
+	sessions, hasMore, err := suite.pgdb.SearchCollisionHistory(userID, models.HistoryFilter{
+		Tag:           "packaging",
+		CollectionID:  &collectionID,
+		FavoritesOnly: true,
+		Limit:         20,
+	})
+	
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), hasMore)
+	assert.Empty(suite.T(), sessions)
+}
+
+func (suite *PostgresTestSuite) TestSetCollisionFavorite() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET is_favorite = \\$1 WHERE id = \\$2 AND user_id = \\$3 AND deleted_at IS NULL").
+		WithArgs(true, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.SetCollisionFavorite(sessionID, userID, true))
+	
+	suite.mock.ExpectExec("UPDATE collision_sessions SET is_favorite").
+		WithArgs(false, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.SetCollisionFavorite(sessionID, userID, false))
+}
+
+func (suite *PostgresTestSuite) TestSetCollisionTags() {
+	sessionID := uuid.New()
+	userID := uuid.New()
+	tags := []string{"packaging", "retail"}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT id FROM collision_sessions WHERE id = \\$1 AND user_id = \\$2 AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(sessionID))
+	suite.mock.ExpectExec("DELETE FROM session_tags WHERE session_id = \\$1").
+		WithArgs(sessionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO session_tags").
+		WithArgs(sessionID, userID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.SetCollisionTags(sessionID, userID, tags))
+	
+	// Someone else's session
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT id FROM collision_sessions").
+		WithArgs(sessionID, userID).
+		WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.SetCollisionTags(sessionID, userID, tags))
+	assert.NoError(suite.T(), suite.mock.ExpectationsWereMet())
+}
+
+func (suite *PostgresTestSuite) TestGetLibraryCounts() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM collision_sessions WHERE user_id = \\$1 AND is_favorite (.+) FROM collections WHERE user_id = \\$1").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"favorites", "tags", "collections"}).AddRow(3, 5, 2))
+	
+	counts, err := suite.pgdb.GetLibraryCounts(userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.LibraryCounts{Favorites: 3, Tags: 5, Collections: 2}, *counts)
+}
+
+func (suite *PostgresTestSuite) TestCreateCollectionNameTaken() {
+	collection := &models.Collection{ID: uuid.New(), UserID: uuid.New(), Name: "Packaging ideas"}
+	
+	suite.mock.ExpectQuery("INSERT INTO collections").
+		WithArgs(collection.ID, collection.UserID, collection.Name, nil).
+		WillReturnError(&pq.Error{Code: "23505"})
+	
+	err := suite.pgdb.CreateCollection(collection)
+	assert.Equal(suite.T(), ErrCollectionNameTaken, err)
+}
+
+func (suite *PostgresTestSuite) TestGetCollection() {
+	collectionID := uuid.New()
+	userID := uuid.New()
+	first, second := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM collections c WHERE c.id = \\$1 AND c.user_id = \\$2").
+		WithArgs(collectionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "description", "created_at", "updated_at", "session_count"}).
+			AddRow(collectionID, userID, "Packaging ideas", nil, time.Now(), time.Now(), 2))
+	suite.mock.ExpectQuery("SELECT (.+) FROM collection_items JOIN collision_sessions (.+) ORDER BY collection_items.position").
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows(sessionColumnNames).
+			AddRow(first, userID, []byte(`{}`), []byte(`{}`), nil, nil, time.Now(), nil, false, "{}", nil, "", 0).
+			AddRow(second, userID, []byte(`{}`), []byte(`{}`), nil, nil, time.Now(), nil, true, "{}", nil, "", 0))
+	
+	collection, err := suite.pgdb.GetCollection(collectionID, userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, collection.SessionCount)
+	assert.Len(suite.T(), collection.Sessions, 2)
+	assert.Equal(suite.T(), first, collection.Sessions[0].ID)
+	assert.Equal(suite.T(), second, collection.Sessions[1].ID)
+}
+
+func (suite *PostgresTestSuite) TestAddToCollection() {
+	collectionID := uuid.New()
+	sessionID := uuid.New()
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("INSERT INTO collection_items (.+) WHERE c.id = \\$1 AND c.user_id = \\$3 AND s.id = \\$2 AND s.user_id = \\$3").
+		WithArgs(collectionID, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.AddToCollection(collectionID, sessionID, userID))
+	
+	suite.mock.ExpectExec("INSERT INTO collection_items").
+		WithArgs(collectionID, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.AddToCollection(collectionID, sessionID, userID))
+}
+
+func (suite *PostgresTestSuite) TestReorderCollection() {
+	collectionID := uuid.New()
+	userID := uuid.New()
+	first, second := uuid.New(), uuid.New()
+	
+	expectCurrent := func() {
+		suite.mock.ExpectBegin()
+		suite.mock.ExpectQuery("SELECT id FROM collections WHERE id = \\$1 AND user_id = \\$2 FOR UPDATE").
+			WithArgs(collectionID, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(collectionID))
+		suite.mock.ExpectQuery("SELECT ci.session_id FROM collection_items ci").
+			WithArgs(collectionID).
+			WillReturnRows(sqlmock.NewRows([]string{"session_id"}).AddRow(first).AddRow(second))
+	}
+	
+	expectCurrent()
+	suite.mock.ExpectExec("UPDATE collection_items SET position = COALESCE\\(array_position\\(\\$2::uuid\\[\\], session_id\\), \\$3 \\+ position\\)").
+		WithArgs(collectionID, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.ReorderCollection(collectionID, userID, []uuid.UUID{second, first}))
+	
+	// Duplicates and missing sessions are rejected
+	expectCurrent()
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.ReorderCollection(collectionID, userID, []uuid.UUID{second, second})
+	assert.Equal(suite.T(), ErrCollectionOrderMismatch, err)
+	assert.NoError(suite.T(), suite.mock.ExpectationsWereMet())
+}
+
+func (suite *PostgresTestSuite) TestGetCollisionTree() {
+	rootID := uuid.New()
+	childID := uuid.New()
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("WITH RECURSIVE ancestors AS (.+) SELECT (.+) FROM collision_sessions WHERE id IN \\(SELECT id FROM tree\\) AND deleted_at IS NULL ORDER BY created_at, id").
+		WithArgs(childID, userID).
+		WillReturnRows(sqlmock.NewRows(sessionColumnNames).
+			AddRow(rootID, userID, []byte(`{}`), []byte(`{}`), nil, nil, time.Now(), nil, false, "{}", nil, "", 1).
+			AddRow(childID, userID, []byte(`{}`), []byte(`{}`), nil, nil, time.Now(), nil, false, "{}", rootID, "pivot", 0))
+	
+	sessions, err := suite.pgdb.GetCollisionTree(childID, userID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), sessions, 2)
+	assert.Equal(suite.T(), 1, sessions[0].ChildCount)
+	assert.Equal(suite.T(), rootID, *sessions[1].ParentID)
+	assert.Equal(suite.T(), models.FollowUpPivot, sessions[1].FollowUp)
+	
+	// The requested session itself must be in the tree
+	suite.mock.ExpectQuery("WITH RECURSIVE ancestors").
+		WithArgs(childID, userID).
+		WillReturnRows(sqlmock.NewRows(sessionColumnNames).
+			AddRow(rootID, userID, []byte(`{}`), []byte(`{}`), nil, nil, time.Now(), nil, false, "{}", nil, "", 0))
+	
+	_, err = suite.pgdb.GetCollisionTree(childID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestCreateShareLink() {
+	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+	link := &models.ShareLink{
+		ID:        uuid.New(),
+		SessionID: uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		ExpiresAt: &expiresAt,
+	}
+	createdAt := time.Now()
+	
+	suite.mock.ExpectQuery("INSERT INTO share_links (.+) SELECT (.+) FROM collision_sessions WHERE id = \\$2 AND user_id = \\$3 AND deleted_at IS NULL").
+		WithArgs(link.ID, link.SessionID, link.UserID, link.TokenHash, link.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+	
+	err := suite.pgdb.CreateShareLink(link)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), createdAt, link.CreatedAt)
+	
+	// Sessions of other users, or in the trash, can't be shared
+	suite.mock.ExpectQuery("INSERT INTO share_links").
+		WithArgs(link.ID, link.SessionID, link.UserID, link.TokenHash, link.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	
+	err = suite.pgdb.CreateShareLink(link)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetActiveShareLink() {
+	linkID := uuid.New()
+	sessionID := uuid.New()
+	userID := uuid.New()
+	tokenHash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	columns := []string{"id", "session_id", "user_id", "token_hash", "expires_at", "revoked_at", "view_count", "last_viewed_at", "created_at"}
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM share_links WHERE token_hash = \\$1 AND revoked_at IS NULL AND \\(expires_at IS NULL OR expires_at > NOW\\(\\)\\)").
+		WithArgs(tokenHash).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(linkID, sessionID, userID, tokenHash, nil, nil, 3, time.Now(), time.Now()))
+	
+	link, err := suite.pgdb.GetActiveShareLink(tokenHash)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), sessionID, link.SessionID)
+	assert.Equal(suite.T(), userID, link.UserID)
+	assert.Equal(suite.T(), 3, link.ViewCount)
+	assert.Nil(suite.T(), link.ExpiresAt)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM share_links").
+		WithArgs(tokenHash).
+		WillReturnRows(sqlmock.NewRows(columns))
+	
+	_, err = suite.pgdb.GetActiveShareLink(tokenHash)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestRevokeShareLink() {
+	linkID := uuid.New()
+	sessionID := uuid.New()
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE share_links SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND session_id = \\$2 AND user_id = \\$3 AND revoked_at IS NULL").
+		WithArgs(linkID, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.RevokeShareLink(linkID, sessionID, userID))
+	
+	// Revoking twice, or someone else's link, finds nothing
+	suite.mock.ExpectExec("UPDATE share_links SET revoked_at").
+		WithArgs(linkID, sessionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.RevokeShareLink(linkID, sessionID, userID))
+}
+
+func (suite *PostgresTestSuite) TestRecordShareView() {
+	linkID := uuid.New()
+	
+	suite.mock.ExpectQuery("UPDATE share_links SET view_count = view_count \\+ 1, last_viewed_at = NOW\\(\\) WHERE id = \\$1 RETURNING view_count").
+		WithArgs(linkID).
+		WillReturnRows(sqlmock.NewRows([]string{"view_count"}).AddRow(8))
+	
+	views, err := suite.pgdb.RecordShareView(linkID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 8, views)
+}
+
+func (suite *PostgresTestSuite) TestUpdateUserSubscriptionTier() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE users SET subscription_tier = \\$2 WHERE id = \\$1").
+		WithArgs(userID, models.TierPro).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	err := suite.pgdb.UpdateUserSubscriptionTier(userID, models.TierPro)
+	assert.NoError(suite.T(), err)
+	
+	suite.mock.ExpectExec("UPDATE users SET subscription_tier").
+		WithArgs(userID, models.TierFree).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err = suite.pgdb.UpdateUserSubscriptionTier(userID, models.TierFree)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestResetUserPassword() {
+	token := &models.UsedActionToken{TokenID: uuid.New(), UserID: uuid.New(), Purpose: "password_reset", ExpiresAt: time.Now().Add(time.Hour)}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO used_action_tokens").
+		WithArgs(token.TokenID, token.UserID, token.Purpose, token.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE users SET password_hash = \\$2 WHERE id = \\$1").
+		WithArgs(token.UserID, "$2a$10$newhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(token.UserID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.ResetUserPassword(token, "$2a$10$newhash")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestResetUserPasswordTokenUsed() {
+	token := &models.UsedActionToken{TokenID: uuid.New(), UserID: uuid.New(), Purpose: "password_reset", ExpiresAt: time.Now().Add(time.Hour)}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO used_action_tokens").
+		WithArgs(token.TokenID, token.UserID, token.Purpose, token.ExpiresAt).
+		WillReturnError(&pq.Error{Code: "23505"})
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.ResetUserPassword(token, "$2a$10$newhash")
+	assert.Equal(suite.T(), ErrActionTokenUsed, err)
+}
+
+func (suite *PostgresTestSuite) TestReclaimUserAccount() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET password_hash = \\$2 WHERE id = \\$1").
+		WithArgs(userID, "$2a$10$newhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec("UPDATE api_keys SET revoked_at = NOW\\(\\) WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.ReclaimUserAccount(userID, "$2a$10$newhash"))
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET password_hash").
+		WithArgs(userID, "$2a$10$newhash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.ReclaimUserAccount(userID, "$2a$10$newhash"))
+}
+
+func (suite *PostgresTestSuite) TestVerifyUserEmail() {
+	token := &models.UsedActionToken{TokenID: uuid.New(), UserID: uuid.New(), Purpose: "email_verification", ExpiresAt: time.Now().Add(time.Hour)}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO used_action_tokens").
+		WithArgs(token.TokenID, token.UserID, token.Purpose, token.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE users SET email_verified_at = COALESCE\\(email_verified_at, NOW\\(\\)\\) WHERE id = \\$1 AND email = \\$2").
+		WithArgs(token.UserID, "ada@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.VerifyUserEmail(token, "ada@example.com")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestVerifyUserEmailAddressChanged() {
+	token := &models.UsedActionToken{TokenID: uuid.New(), UserID: uuid.New(), Purpose: "email_verification", ExpiresAt: time.Now().Add(time.Hour)}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO used_action_tokens").
+		WithArgs(token.TokenID, token.UserID, token.Purpose, token.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE users SET email_verified_at").
+		WithArgs(token.UserID, "old@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The token isn't used up when nothing was verified
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.VerifyUserEmail(token, "old@example.com")
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetUserByIdentity() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("FROM users WHERE id = \\(SELECT user_id FROM user_identities WHERE provider = \\$1 AND subject = \\$2\\)").
+		WithArgs("sso", "user-123").
+		WillReturnRows(sqlmock.NewRows(userColumnNames).
+			AddRow(userID, "sso@example.com", "$2a$10$hash", models.TierFree, `[]`, time.Now(), time.Now(), "", time.Now(), models.RoleUser))
+	
+	user, err := suite.pgdb.GetUserByIdentity("sso", "user-123")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), userID, user.ID)
+	assert.NotNil(suite.T(), user.EmailVerifiedAt)
+}
+
+func (suite *PostgresTestSuite) TestLinkUserIdentity() {
+	identity := &models.UserIdentity{
+		UserID:   uuid.New(),
+		Provider: "sso",
+		Subject:  "user-123",
+		Email:    "sso@example.com",
+	}
+	linkedAt := time.Now().Add(-24 * time.Hour)
+	
+	suite.mock.ExpectQuery("INSERT INTO user_identities .* ON CONFLICT \\(provider, subject\\) DO UPDATE SET").
+		WithArgs(sqlmock.AnyArg(), identity.UserID, "sso", "user-123", "sso@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "created_at", "last_login_at"}).
+			AddRow(uuid.New(), identity.UserID, linkedAt, time.Now()))
+	
+	err := suite.pgdb.LinkUserIdentity(identity)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), uuid.Nil, identity.ID)
+	assert.Equal(suite.T(), linkedAt, identity.CreatedAt)
+}
+
+func (suite *PostgresTestSuite) TestMarkEmailVerified() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE users SET email_verified_at = COALESCE\\(email_verified_at, NOW\\(\\)\\) WHERE id = \\$1 AND email = \\$2").
+		WithArgs(userID, "sso@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.MarkEmailVerified(userID, "sso@example.com")
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestCreateSigningKey() {
+	now := time.Now()
+	key := &models.SigningKey{
+		ID:          "0123456789abcdef",
+		Algorithm:   "RS256",
+		PrivateKey:  []byte("sealed"),
+		ActivatesAt: now,
+		RetiresAt:   now.Add(30 * 24 * time.Hour),
+		ExpiresAt:   now.Add(33 * 24 * time.Hour),
+	}
+	
+	suite.mock.ExpectQuery("INSERT INTO signing_keys").
+		WithArgs(key.ID, key.Algorithm, key.PrivateKey, key.ActivatesAt, key.RetiresAt, key.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(now))
+	
+	err := suite.pgdb.CreateSigningKey(key)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), now, key.CreatedAt)
+}
+
+func (suite *PostgresTestSuite) TestGetSigningKeys() {
+	now := time.Now()
+	
+	suite.mock.ExpectQuery("FROM signing_keys WHERE expires_at > \\$1 ORDER BY activates_at").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"kid", "algorithm", "private_key", "activates_at", "retires_at", "expires_at", "created_at"}).
+			AddRow("old", "RS256", []byte("sealed-1"), now.Add(-40*24*time.Hour), now.Add(-10*24*time.Hour), now.Add(time.Hour), now).
+			AddRow("new", "RS256", []byte("sealed-2"), now.Add(-10*24*time.Hour), now.Add(20*24*time.Hour), now.Add(23*24*time.Hour), now))
+	
+	keys, err := suite.pgdb.GetSigningKeys(now)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), keys, 2)
+	assert.Equal(suite.T(), "new", keys[1].ID)
+	assert.Equal(suite.T(), []byte("sealed-2"), keys[1].PrivateKey)
+}
+
+var apiKeyColumnNames = []string{"id", "user_id", "name", "prefix", "secret_hash", "scopes", "expires_at", "last_used_at", "created_at"}
+
+func (suite *PostgresTestSuite) TestCreateAPIKey() {
+	expiresAt := time.Now().Add(30 * 24 * time.Hour)
+	key := &models.APIKey{
+		UserID:     uuid.New(),
+		Name:       "CI",
+		Prefix:     "ice_0123456789ab",
+		SecretHash: "abc123",
+		Scopes:     []string{models.ScopeCollisionsWrite, models.ScopeHistoryRead},
+		ExpiresAt:  &expiresAt,
+	}
+	createdAt := time.Now()
+	
+	suite.mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs(sqlmock.AnyArg(), key.UserID, "CI", "ice_0123456789ab", "abc123", "{\"collisions:write\",\"history:read\"}", key.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+	
+	err := suite.pgdb.CreateAPIKey(key)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), uuid.Nil, key.ID)
+	assert.Equal(suite.T(), createdAt, key.CreatedAt)
+}
+
+func (suite *PostgresTestSuite) TestGetAPIKeyByPrefix() {
+	keyID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("FROM api_keys WHERE prefix = \\$1 AND revoked_at IS NULL AND \\(expires_at IS NULL OR expires_at > NOW\\(\\)\\)").
+		WithArgs("ice_0123456789ab").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumnNames).
+			AddRow(keyID, userID, "CI", "ice_0123456789ab", "abc123", "{history:read}", nil, nil, time.Now()))
+	
+	key, err := suite.pgdb.GetAPIKeyByPrefix("ice_0123456789ab")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), keyID, key.ID)
+	assert.Equal(suite.T(), userID, key.UserID)
+	assert.Equal(suite.T(), []string{models.ScopeHistoryRead}, key.Scopes)
+	assert.Nil(suite.T(), key.ExpiresAt)
+}
+
+func (suite *PostgresTestSuite) TestListAPIKeys() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("FROM api_keys WHERE user_id = \\$1 AND revoked_at IS NULL ORDER BY created_at DESC").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumnNames).
+			AddRow(uuid.New(), userID, "CI", "ice_0123456789ab", "abc123", "{history:read}", nil, time.Now(), time.Now()).
+			AddRow(uuid.New(), userID, "Laptop", "ice_ba9876543210", "def456", "{collisions:write,history:write}", time.Now().Add(time.Hour), nil, time.Now()))
+	
+	keys, err := suite.pgdb.ListAPIKeys(userID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), keys, 2)
+	assert.NotNil(suite.T(), keys[0].LastUsedAt)
+	assert.Equal(suite.T(), []string{models.ScopeCollisionsWrite, models.ScopeHistoryWrite}, keys[1].Scopes)
+}
+
+func (suite *PostgresTestSuite) TestRevokeAPIKey() {
+	keyID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE api_keys SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND user_id = \\$2 AND revoked_at IS NULL").
+		WithArgs(keyID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.RevokeAPIKey(keyID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestTouchAPIKey() {
+	keyID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE api_keys SET last_used_at = NOW\\(\\) WHERE id = \\$1 AND \\(last_used_at IS NULL OR last_used_at < NOW\\(\\) - INTERVAL '1 minute'\\)").
+		WithArgs(keyID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	err := suite.pgdb.TouchAPIKey(keyID)
+	assert.NoError(suite.T(), err)
+}
+
+// teamColumnNames matches the columns selected by teamColumns
+var teamColumnNames = []string{"id", "name", "owner_id", "seats", "created_at", "updated_at", "subscription_tier", "seats_used"}
+
+func (suite *PostgresTestSuite) TestCreateTeam() {
+	team := &models.Team{Name: "Studio", OwnerID: uuid.New()}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO teams \\(id, name, owner_id, seats\\) SELECT (.+) FROM users WHERE id = \\$3").
+		WithArgs(sqlmock.AnyArg(), "Studio", team.OwnerID, 0, models.TeamIncludedSeats).
+		WillReturnRows(sqlmock.NewRows([]string{"seats", "created_at", "updated_at"}).AddRow(models.TeamIncludedSeats, time.Now(), time.Now()))
+	suite.mock.ExpectExec("INSERT INTO team_members \\(team_id, user_id, role\\)").
+		WithArgs(sqlmock.AnyArg(), team.OwnerID, models.TeamRoleOwner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.CreateTeam(team)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), uuid.Nil, team.ID)
+	assert.Equal(suite.T(), models.TeamRoleOwner, team.Role)
+	assert.Equal(suite.T(), 1, team.SeatsUsed)
+	assert.Equal(suite.T(), models.TeamIncludedSeats, team.Seats)
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamAfterSubscription() {
+	team := &models.Team{Name: "Studio", OwnerID: uuid.New()}
+	
+	// The webhook recorded 12 seats on the owner before the team existed
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO teams \\(id, name, owner_id, seats\\) SELECT \\$1, \\$2, \\$3, COALESCE\\(NULLIF\\(\\$4, 0\\), team_seats, \\$5\\)").
+		WithArgs(sqlmock.AnyArg(), "Studio", team.OwnerID, 0, models.TeamIncludedSeats).
+		WillReturnRows(sqlmock.NewRows([]string{"seats", "created_at", "updated_at"}).AddRow(12, time.Now(), time.Now()))
+	suite.mock.ExpectExec("INSERT INTO team_members").
+		WithArgs(sqlmock.AnyArg(), team.OwnerID, models.TeamRoleOwner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.CreateTeam(team)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 12, team.Seats)
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamExists() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO teams").
+		WillReturnError(&pq.Error{Code: "23505"})
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.CreateTeam(&models.Team{Name: "Studio", OwnerID: uuid.New()})
+	assert.Equal(suite.T(), ErrTeamExists, err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateTeamSeats() {
+	ownerID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET team_seats = \\$2 WHERE id = \\$1").
+		WithArgs(ownerID, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE teams SET seats = \\$2").
+		WithArgs(ownerID, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.UpdateTeamSeats(ownerID, 12)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateTeamSeatsBeforeTeam() {
+	ownerID := uuid.New()
+	
+	// No team yet: the seats are still recorded on the owner for CreateTeam
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET team_seats = \\$2 WHERE id = \\$1").
+		WithArgs(ownerID, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE teams SET seats = \\$2").
+		WithArgs(ownerID, 12).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectCommit()
+	
+	err := suite.pgdb.UpdateTeamSeats(ownerID, 12)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateTeamSeatsUnknownUser() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE users SET team_seats").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.UpdateTeamSeats(uuid.New(), 12)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetTeam() {
+	teamID := uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM teams t JOIN users o ON o.id = t.owner_id WHERE t.id = \\$1").
+		WithArgs(teamID).
+		WillReturnRows(sqlmock.NewRows(teamColumnNames).
+			AddRow(teamID, "Studio", uuid.New(), 5, time.Now(), time.Now(), models.TierTeam, 3))
+	
+	team, err := suite.pgdb.GetTeam(teamID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, team.SeatsUsed)
+	assert.True(suite.T(), team.PlanActive)
+	
+	// Once the owner leaves the Team plan nobody new can join
+	suite.mock.ExpectQuery("SELECT (.+) FROM teams t").
+		WithArgs(teamID).
+		WillReturnRows(sqlmock.NewRows(teamColumnNames).
+			AddRow(teamID, "Studio", uuid.New(), 5, time.Now(), time.Now(), models.TierPro, 3))
+	
+	team, err = suite.pgdb.GetTeam(teamID)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), team.PlanActive)
+}
+
+func (suite *PostgresTestSuite) TestGetUserTeams() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+), tm.role FROM team_members tm JOIN teams t ON t.id = tm.team_id (.+) WHERE tm.user_id = \\$1").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows(append(teamColumnNames, "role")).
+			AddRow(uuid.New(), "Studio", uuid.New(), 5, time.Now(), time.Now(), models.TierTeam, 2, models.TeamRoleAdmin))
+	
+	teams, err := suite.pgdb.GetUserTeams(userID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), teams, 1)
+	assert.Equal(suite.T(), models.TeamRoleAdmin, teams[0].Role)
+}
+
+func (suite *PostgresTestSuite) TestGetTeamMember() {
+	teamID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM team_members tm JOIN users u ON u.id = tm.user_id WHERE tm.team_id = \\$1 AND tm.user_id = \\$2").
+		WithArgs(teamID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"team_id", "user_id", "email", "display_name", "role", "joined_at"}).
+			AddRow(teamID, userID, "ada@example.com", "Ada", models.TeamRoleMember, time.Now()))
+	
+	member, err := suite.pgdb.GetTeamMember(teamID, userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.TeamRoleMember, member.Role)
+	assert.Equal(suite.T(), "Ada", member.DisplayName)
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM team_members tm").
+		WithArgs(teamID, userID).
+		WillReturnError(sql.ErrNoRows)
+	
+	_, err = suite.pgdb.GetTeamMember(teamID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateTeamMemberRoleSparesOwner() {
+	teamID, ownerID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE team_members SET role = \\$3 WHERE team_id = \\$1 AND user_id = \\$2 AND role <> 'owner'").
+		WithArgs(teamID, ownerID, models.TeamRoleMember).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.UpdateTeamMemberRole(teamID, ownerID, models.TeamRoleMember)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestRemoveTeamMember() {
+	teamID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("DELETE FROM team_members WHERE team_id = \\$1 AND user_id = \\$2 AND role <> 'owner'").
+		WithArgs(teamID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("DELETE FROM team_sessions WHERE team_id = \\$1 AND shared_by = \\$2").
+		WithArgs(teamID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	suite.mock.ExpectExec("DELETE FROM board_items WHERE added_by = \\$2 AND board_id IN \\(SELECT id FROM boards WHERE team_id = \\$1\\)").
+		WithArgs(teamID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.RemoveTeamMember(teamID, userID))
+	
+	// The owner stays
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("DELETE FROM team_members").
+		WithArgs(teamID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.RemoveTeamMember(teamID, userID))
+}
+
+func (suite *PostgresTestSuite) expectTeamSeatsLock(teamID uuid.UUID, seats int, ownerTier string) {
+	suite.mock.ExpectQuery("SELECT t.seats, o.subscription_tier FROM teams t JOIN users o ON o.id = t.owner_id WHERE t.id = \\$1 FOR UPDATE OF t").
+		WithArgs(teamID).
+		WillReturnRows(sqlmock.NewRows([]string{"seats", "subscription_tier"}).AddRow(seats, ownerTier))
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamInvitation() {
+	invitation := &models.TeamInvitation{
+		TeamID:    uuid.New(),
+		Email:     "grace@example.com",
+		Role:      models.TeamRoleMember,
+		TokenHash: "abc123",
+		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+	}
+	
+	suite.mock.ExpectBegin()
+	suite.expectTeamSeatsLock(invitation.TeamID, 5, models.TierTeam)
+	suite.mock.ExpectQuery("SELECT EXISTS \\((.+)LOWER\\(u.email\\) = \\$2").
+		WithArgs(invitation.TeamID, "grace@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	// An earlier invitation to the same address is replaced
+	suite.mock.ExpectExec("DELETE FROM team_invitations WHERE team_id = \\$1 AND email = \\$2 AND accepted_at IS NULL").
+		WithArgs(invitation.TeamID, "grace@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT \\(SELECT COUNT").
+		WithArgs(invitation.TeamID).
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(4))
+	suite.mock.ExpectQuery("INSERT INTO team_invitations").
+		WithArgs(sqlmock.AnyArg(), invitation.TeamID, "grace@example.com", models.TeamRoleMember, "abc123", nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.CreateTeamInvitation(invitation))
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamInvitationFull() {
+	teamID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.expectTeamSeatsLock(teamID, 5, models.TierTeam)
+	suite.mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	suite.mock.ExpectExec("DELETE FROM team_invitations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery("SELECT \\(SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(5))
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.CreateTeamInvitation(&models.TeamInvitation{TeamID: teamID, Email: "grace@example.com"})
+	assert.Equal(suite.T(), ErrTeamFull, err)
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamInvitationWithoutPlan() {
+	teamID := uuid.New()
+	
+	// Without the owner's Team plan the team has no seats at all
+	suite.mock.ExpectBegin()
+	suite.expectTeamSeatsLock(teamID, 5, models.TierFree)
+	suite.mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	suite.mock.ExpectExec("DELETE FROM team_invitations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery("SELECT \\(SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(1))
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.CreateTeamInvitation(&models.TeamInvitation{TeamID: teamID, Email: "grace@example.com"})
+	assert.Equal(suite.T(), ErrTeamFull, err)
+}
+
+func (suite *PostgresTestSuite) TestCreateTeamInvitationForMember() {
+	teamID := uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.expectTeamSeatsLock(teamID, 5, models.TierTeam)
+	suite.mock.ExpectQuery("SELECT EXISTS").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	suite.mock.ExpectRollback()
+	
+	err := suite.pgdb.CreateTeamInvitation(&models.TeamInvitation{TeamID: teamID, Email: "ada@example.com"})
+	assert.Equal(suite.T(), ErrAlreadyTeamMember, err)
+}
+
+// teamInvitationColumnNames matches the columns selected by teamInvitationColumns
+var teamInvitationColumnNames = []string{"id", "team_id", "email", "role", "token_hash", "invited_by", "expires_at", "accepted_at", "created_at"}
+
+func (suite *PostgresTestSuite) expectPendingInvitation(invitationID, teamID uuid.UUID, email string) {
+	suite.mock.ExpectQuery("SELECT (.+) FROM team_invitations WHERE token_hash = \\$1 AND accepted_at IS NULL AND expires_at > NOW\\(\\)").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows(teamInvitationColumnNames).
+			AddRow(invitationID, teamID, email, models.TeamRoleMember, "abc123", nil, time.Now().Add(time.Hour), nil, time.Now()))
+}
+
+func (suite *PostgresTestSuite) TestAcceptTeamInvitation() {
+	invitationID, teamID := uuid.New(), uuid.New()
+	user := &models.User{ID: uuid.New(), Email: "Grace@Example.com"}
+	
+	suite.mock.ExpectBegin()
+	suite.expectPendingInvitation(invitationID, teamID, "grace@example.com")
+	suite.expectTeamSeatsLock(teamID, 5, models.TierTeam)
+	suite.mock.ExpectExec("UPDATE team_invitations SET accepted_at = NOW\\(\\) WHERE id = \\$1 AND accepted_at IS NULL").
+		WithArgs(invitationID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO team_members (.+) ON CONFLICT \\(team_id, user_id\\) DO NOTHING").
+		WithArgs(teamID, user.ID, models.TeamRoleMember).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM team_members WHERE team_id = \\$1").
+		WithArgs(teamID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	suite.mock.ExpectCommit()
+	
+	invitation, err := suite.pgdb.AcceptTeamInvitation("abc123", user)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), teamID, invitation.TeamID)
+	assert.NotNil(suite.T(), invitation.AcceptedAt)
+}
+
+func (suite *PostgresTestSuite) TestAcceptTeamInvitationOtherEmail() {
+	suite.mock.ExpectBegin()
+	suite.expectPendingInvitation(uuid.New(), uuid.New(), "grace@example.com")
+	suite.mock.ExpectRollback()
+	
+	_, err := suite.pgdb.AcceptTeamInvitation("abc123", &models.User{ID: uuid.New(), Email: "mallory@example.com"})
+	assert.Equal(suite.T(), ErrInvitationEmailMismatch, err)
+}
+
+func (suite *PostgresTestSuite) TestAcceptTeamInvitationAlreadyMember() {
+	invitationID, teamID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.expectPendingInvitation(invitationID, teamID, "grace@example.com")
+	suite.expectTeamSeatsLock(teamID, 5, models.TierTeam)
+	suite.mock.ExpectExec("UPDATE team_invitations SET accepted_at").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO team_members").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	_, err := suite.pgdb.AcceptTeamInvitation("abc123", &models.User{ID: uuid.New(), Email: "grace@example.com"})
+	assert.Equal(suite.T(), ErrAlreadyTeamMember, err)
+}
+
+func (suite *PostgresTestSuite) TestAcceptTeamInvitationFull() {
+	invitationID, teamID := uuid.New(), uuid.New()
+	
+	// The plan shrank after the invitation was sent
+	suite.mock.ExpectBegin()
+	suite.expectPendingInvitation(invitationID, teamID, "grace@example.com")
+	suite.expectTeamSeatsLock(teamID, 3, models.TierTeam)
+	suite.mock.ExpectExec("UPDATE team_invitations SET accepted_at").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO team_members").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM team_members").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	suite.mock.ExpectRollback()
+	
+	_, err := suite.pgdb.AcceptTeamInvitation("abc123", &models.User{ID: uuid.New(), Email: "grace@example.com"})
+	assert.Equal(suite.T(), ErrTeamFull, err)
+}
+
+func (suite *PostgresTestSuite) TestAcceptTeamInvitationExpired() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT (.+) FROM team_invitations").
+		WithArgs("abc123").
+		WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectRollback()
+	
+	_, err := suite.pgdb.AcceptTeamInvitation("abc123", &models.User{ID: uuid.New(), Email: "grace@example.com"})
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestShareSessionWithTeam() {
+	teamID, sessionID, userID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("INSERT INTO team_sessions \\(team_id, session_id, shared_by\\) SELECT \\$1, id, user_id FROM collision_sessions WHERE id = \\$2 AND user_id = \\$3 AND deleted_at IS NULL ON CONFLICT").
+		WithArgs(teamID, sessionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"shared_at"}).AddRow(time.Now()))
+	
+	_, err := suite.pgdb.ShareSessionWithTeam(teamID, sessionID, userID)
+	assert.NoError(suite.T(), err)
+	
+	// Someone else's session can't be shared
+	suite.mock.ExpectQuery("INSERT INTO team_sessions").
+		WithArgs(teamID, sessionID, userID).
+		WillReturnError(sql.ErrNoRows)
+	
+	_, err = suite.pgdb.ShareSessionWithTeam(teamID, sessionID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestUnshareSessionFromTeam() {
+	teamID, sessionID, userID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("DELETE FROM team_sessions WHERE team_id = \\$1 AND session_id = \\$2 AND \\(\\$3::uuid IS NULL OR shared_by = \\$3\\)").
+		WithArgs(teamID, sessionID, &userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.UnshareSessionFromTeam(teamID, sessionID, &userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetTeamHistory() {
+	teamID, sessionID, sharerID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM team_sessions ts JOIN collision_sessions (.+) WHERE ts.team_id = \\$1 AND collision_sessions.deleted_at IS NULL ORDER BY ts.shared_at DESC").
+		WithArgs(teamID, 20).
+		WillReturnRows(sqlmock.NewRows(append(sessionColumnNames, "shared_by", "shared_by_name", "shared_at")).
+			AddRow(sessionID, sharerID, []byte(`{"user_interests":["design"]}`), []byte(`{"collision_domain":"Biomimicry"}`), nil, nil, time.Now(), nil, false, "{}", nil, "", 0,
+				sharerID, "Ada", time.Now()))
+	
+	history, err := suite.pgdb.GetTeamHistory(teamID, 20)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), history, 1)
+	assert.Equal(suite.T(), sessionID, history[0].ID)
+	assert.Equal(suite.T(), "Biomimicry", history[0].CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), "Ada", history[0].SharedByName)
+}
+
+var boardColumnNames = []string{"id", "team_id", "name", "description", "created_by", "created_at", "updated_at", "item_count"}
+
+func (suite *PostgresTestSuite) TestGetBoard() {
+	boardID, teamID, creatorID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM boards b WHERE b.id = \\$1 AND b.team_id = \\$2").
+		WithArgs(boardID, teamID).
+		WillReturnRows(sqlmock.NewRows(boardColumnNames).
+			AddRow(boardID, teamID, "Q3 ideas", nil, creatorID, time.Now(), time.Now(), 3))
+	
+	board, err := suite.pgdb.GetBoard(boardID, teamID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Q3 ideas", board.Name)
+	assert.Equal(suite.T(), creatorID, *board.CreatedBy)
+	assert.Equal(suite.T(), 3, board.ItemCount)
+	
+	// Another team's board
+	suite.mock.ExpectQuery("SELECT (.+) FROM boards b").
+		WithArgs(boardID, teamID).
+		WillReturnRows(sqlmock.NewRows(boardColumnNames))
+	
+	_, err = suite.pgdb.GetBoard(boardID, teamID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestAddBoardItem() {
+	item := &models.BoardItem{ID: uuid.New(), BoardID: uuid.New(), SessionID: uuid.New(), AddedBy: uuid.New()}
+	
+	suite.mock.ExpectQuery("INSERT INTO board_items (.+) SELECT (.+) FROM collision_sessions WHERE id = \\$3 AND user_id = \\$4 AND deleted_at IS NULL").
+		WithArgs(item.ID, item.BoardID, item.SessionID, item.AddedBy, item.Note).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	
+	assert.NoError(suite.T(), suite.pgdb.AddBoardItem(item))
+	assert.False(suite.T(), item.CreatedAt.IsZero())
+	
+	// Someone else's session
+	suite.mock.ExpectQuery("INSERT INTO board_items").
+		WithArgs(item.ID, item.BoardID, item.SessionID, item.AddedBy, item.Note).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.AddBoardItem(item))
+	
+	// Already on the board
+	suite.mock.ExpectQuery("INSERT INTO board_items").
+		WithArgs(item.ID, item.BoardID, item.SessionID, item.AddedBy, item.Note).
+		WillReturnError(&pq.Error{Code: "23505"})
+	
+	assert.Equal(suite.T(), ErrBoardItemExists, suite.pgdb.AddBoardItem(item))
+}
+
+func (suite *PostgresTestSuite) TestGetBoardItems() {
+	boardID, viewerID, itemID, sessionID, adderID := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM board_items bi JOIN collision_sessions (.+) WHERE bi.board_id = \\$1 AND collision_sessions.deleted_at IS NULL ORDER BY bi.created_at").
+		WithArgs(boardID, viewerID).
+		WillReturnRows(sqlmock.NewRows(append(sessionColumnNames,
+			"item_id", "board_id", "added_by", "added_by_name", "note", "item_created_at",
+			"vote_count", "voted", "reactions", "my_reactions", "comment_count")).
+			AddRow(sessionID, adderID, []byte(`{"user_interests":["design"]}`), []byte(`{"collision_domain":"Biomimicry"}`), nil, nil, time.Now(), nil, false, "{}", nil, "", 0,
+				itemID, boardID, adderID, "Ada", "Worth a prototype", time.Now(),
+				2, true, []byte(`{"heart":2,"bulb":1}`), "{heart}", 4))
+	
+	items, err := suite.pgdb.GetBoardItems(boardID, viewerID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), items, 1)
+	
+	item := items[0]
+	assert.Equal(suite.T(), itemID, item.ID)
+	assert.Equal(suite.T(), sessionID, item.SessionID)
+	assert.Equal(suite.T(), "Biomimicry", item.Session.CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), "Ada", item.AddedByName)
+	assert.Equal(suite.T(), 2, item.VoteCount)
+	assert.True(suite.T(), item.Voted)
+	assert.Equal(suite.T(), map[string]int{"heart": 2, "bulb": 1}, item.Reactions)
+	assert.Equal(suite.T(), []string{"heart"}, item.MyReactions)
+	assert.Equal(suite.T(), 4, item.CommentCount)
+}
+
+func (suite *PostgresTestSuite) TestGetBoardShortlist() {
+	boardID, viewerID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM board_items bi (.+) AND EXISTS \\(SELECT 1 FROM board_votes v WHERE v.item_id = bi.id\\) ORDER BY \\(SELECT COUNT\\(\\*\\) FROM board_votes v WHERE v.item_id = bi.id\\) DESC, (.+) LIMIT \\$3").
+		WithArgs(boardID, viewerID, 10).
+		WillReturnRows(sqlmock.NewRows(sessionColumnNames))
+	
+	items, err := suite.pgdb.GetBoardShortlist(boardID, viewerID, 10)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), items)
+}
+
+func (suite *PostgresTestSuite) TestSetBoardVote() {
+	itemID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("INSERT INTO board_votes \\(item_id, user_id\\) VALUES \\(\\$1, \\$2\\) ON CONFLICT DO NOTHING").
+		WithArgs(itemID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM board_votes WHERE item_id = \\$1").
+		WithArgs(itemID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	
+	count, err := suite.pgdb.SetBoardVote(itemID, userID, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, count)
+	
+	suite.mock.ExpectExec("DELETE FROM board_votes WHERE item_id = \\$1 AND user_id = \\$2").
+		WithArgs(itemID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM board_votes").
+		WithArgs(itemID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	
+	count, err = suite.pgdb.SetBoardVote(itemID, userID, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count)
+}
+
+func (suite *PostgresTestSuite) TestSetBoardReaction() {
+	itemID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("INSERT INTO board_reactions (.+) ON CONFLICT DO NOTHING").
+		WithArgs(itemID, userID, "bulb").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.SetBoardReaction(itemID, userID, "bulb", true))
+	
+	suite.mock.ExpectExec("DELETE FROM board_reactions WHERE item_id = \\$1 AND user_id = \\$2 AND reaction = \\$3").
+		WithArgs(itemID, userID, "bulb").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.SetBoardReaction(itemID, userID, "bulb", false))
+}
+
+func (suite *PostgresTestSuite) TestUpdateBoardComment() {
+	commentID, itemID, userID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE board_comments SET body = \\$4, updated_at = NOW\\(\\) WHERE id = \\$1 AND item_id = \\$2 AND user_id = \\$3").
+		WithArgs(commentID, itemID, userID, "Revised").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.UpdateBoardComment(commentID, itemID, userID, "Revised"))
+	
+	// Someone else's comment
+	suite.mock.ExpectExec("UPDATE board_comments").
+		WithArgs(commentID, itemID, userID, "Revised").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.UpdateBoardComment(commentID, itemID, userID, "Revised"))
+}
+
+func (suite *PostgresTestSuite) TestBoardActivity() {
+	teamID, boardID, userID := uuid.New(), uuid.New(), uuid.New()
+	activity := &models.BoardActivity{
+		ID:      uuid.New(),
+		TeamID:  teamID,
+		BoardID: &boardID,
+		UserID:  &userID,
+		Action:  models.ActivityBoardCreated,
+		Details: map[string]interface{}{"name": "Q3 ideas"},
+	}
+	
+	suite.mock.ExpectQuery("INSERT INTO board_activity").
+		WithArgs(activity.ID, teamID, activity.BoardID, activity.ItemID, activity.UserID, models.ActivityBoardCreated, []byte(`{"name":"Q3 ideas"}`)).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	
+	assert.NoError(suite.T(), suite.pgdb.RecordBoardActivity(activity))
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM board_activity a LEFT JOIN users u ON u.id = a.user_id WHERE a.team_id = \\$1 AND \\(\\$2::uuid IS NULL OR a.board_id = \\$2\\) ORDER BY a.created_at DESC").
+		WithArgs(teamID, &boardID, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "team_id", "board_id", "item_id", "user_id", "actor_name", "action", "details", "created_at"}).
+			AddRow(activity.ID, teamID, boardID, nil, userID, "Ada", models.ActivityBoardCreated, []byte(`{"name":"Q3 ideas"}`), time.Now()))
+	
+	feed, err := suite.pgdb.GetBoardActivity(teamID, &boardID, 50)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), feed, 1)
+	assert.Equal(suite.T(), "Ada", feed[0].ActorName)
+	assert.Nil(suite.T(), feed[0].ItemID)
+	assert.Equal(suite.T(), "Q3 ideas", feed[0].Details["name"])
+}
+
+var groupSessionColumnNames = []string{"id", "host_id", "title", "current_project", "project_type", "collision_intensity",
+	"join_code", "merged_interests", "ends_at", "generated_at", "created_at", "participant_count"}
+
+func (suite *PostgresTestSuite) TestCreateGroupSession() {
+	session := &models.GroupSession{
+		HostID:             uuid.New(),
+		Title:              "Offsite",
+		CurrentProject:     "Onboarding flow",
+		ProjectType:        "product",
+		CollisionIntensity: "moderate",
+		JoinCode:           "K7QX2M",
+		EndsAt:             time.Now().Add(30 * time.Minute),
+	}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO group_sessions").
+		WithArgs(sqlmock.AnyArg(), session.HostID, "Offsite", "Onboarding flow", "product", "moderate", "K7QX2M", session.EndsAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	suite.mock.ExpectExec("INSERT INTO group_participants").
+		WithArgs(sqlmock.AnyArg(), session.HostID, `{"design"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.CreateGroupSession(session, []string{"design"}))
+	assert.NotEqual(suite.T(), uuid.Nil, session.ID)
+	assert.Equal(suite.T(), 1, session.ParticipantCount)
+	assert.Equal(suite.T(), models.GroupStatusCollecting, session.Status)
+	
+	// Code in use
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO group_sessions").WillReturnError(&pq.Error{Code: "23505"})
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), ErrJoinCodeTaken, suite.pgdb.CreateGroupSession(&models.GroupSession{HostID: session.HostID}, nil))
+}
+
+func (suite *PostgresTestSuite) TestGetGroupSession() {
+	sessionID, userID, hostID := uuid.New(), uuid.New(), uuid.New()
+	generatedAt := time.Now()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM group_sessions g WHERE g.id = \\$1 AND EXISTS \\(SELECT 1 FROM group_participants gp WHERE gp.session_id = g.id AND gp.user_id = \\$2\\)").
+		WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(groupSessionColumnNames).
+			AddRow(sessionID, hostID, "Offsite", "Onboarding flow", "product", "moderate",
+				"K7QX2M", "{design,music}", time.Now().Add(10*time.Minute), generatedAt, time.Now(), 3))
+	
+	session, err := suite.pgdb.GetGroupSession(sessionID, userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"design", "music"}, session.MergedInterests)
+	assert.Equal(suite.T(), 3, session.ParticipantCount)
+	assert.Equal(suite.T(), models.GroupStatusVoting, session.Status)
+	
+	// Not a participant
+	suite.mock.ExpectQuery("SELECT (.+) FROM group_sessions g").
+		WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows(groupSessionColumnNames))
+	
+	_, err = suite.pgdb.GetGroupSession(sessionID, userID)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestJoinGroupSession() {
+	sessionID, userID := uuid.New(), uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT generated_at IS NULL AND ends_at > NOW\\(\\) FROM group_sessions WHERE id = \\$1 FOR UPDATE").
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"open"}).AddRow(true))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\), (.+) FROM group_participants WHERE session_id = \\$1").
+		WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "joined"}).AddRow(4, false))
+	suite.mock.ExpectExec("INSERT INTO group_participants (.+) ON CONFLICT \\(session_id, user_id\\) DO UPDATE SET interests = EXCLUDED.interests").
+		WithArgs(sessionID, userID, `{"jazz","cooking"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	assert.NoError(suite.T(), suite.pgdb.JoinGroupSession(sessionID, userID, []string{"jazz", "cooking"}))
+	
+	// Generated or ended
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT generated_at IS NULL").
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"open"}).AddRow(false))
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), ErrGroupSessionClosed, suite.pgdb.JoinGroupSession(sessionID, userID, []string{"jazz"}))
+	
+	// Full, for someone who hasn't joined yet
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT generated_at IS NULL").
+		WithArgs(sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"open"}).AddRow(true))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\)").
+		WithArgs(sessionID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "joined"}).AddRow(models.GroupMaxParticipants, false))
+	suite.mock.ExpectRollback()
+	
+	assert.Equal(suite.T(), ErrGroupSessionFull, suite.pgdb.JoinGroupSession(sessionID, userID, []string{"jazz"}))
+}
+
+func (suite *PostgresTestSuite) TestSaveGroupCollisions() {
+	sessionID := uuid.New()
+	results := []*models.CollisionResult{
+		{CollisionDomain: "Biomimicry", QualityScore: 0.9},
+		{CollisionDomain: "Jazz Improvisation", QualityScore: 0.7},
+	}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE group_sessions SET merged_interests = \\$2, generated_at = NOW\\(\\) WHERE id = \\$1 AND generated_at IS NULL AND ends_at > NOW\\(\\)").
+		WithArgs(sessionID, `{"design","music"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("INSERT INTO group_collisions").
+		WithArgs(sqlmock.AnyArg(), sessionID, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	suite.mock.ExpectQuery("INSERT INTO group_collisions").
+		WithArgs(sqlmock.AnyArg(), sessionID, 2, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	suite.mock.ExpectCommit()
+	
+	collisions, err := suite.pgdb.SaveGroupCollisions(sessionID, []string{"design", "music"}, results)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), collisions, 2)
+	assert.Equal(suite.T(), "Jazz Improvisation", collisions[1].CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), 2, collisions[1].Rank)
+	
+	// Generated already
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("UPDATE group_sessions").
+		WithArgs(sessionID, `{"design","music"}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+	
+	_, err = suite.pgdb.SaveGroupCollisions(sessionID, []string{"design", "music"}, results)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestGetGroupCollisions() {
+	sessionID, viewerID, collisionID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM group_collisions gc WHERE gc.session_id = \\$1 ORDER BY votes DESC, gc.rank").
+		WithArgs(sessionID, viewerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "rank", "collision_result", "created_at", "votes", "voted"}).
+			AddRow(collisionID, sessionID, 2, []byte(`{"collision_domain":"Biomimicry"}`), time.Now(), 5, true))
+	
+	collisions, err := suite.pgdb.GetGroupCollisions(sessionID, viewerID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), collisions, 1)
+	assert.Equal(suite.T(), "Biomimicry", collisions[0].CollisionResult.CollisionDomain)
+	assert.Equal(suite.T(), 5, collisions[0].VoteCount)
+	assert.True(suite.T(), collisions[0].Voted)
+}
+
+func (suite *PostgresTestSuite) TestSetGroupVote() {
+	collisionID, sessionID, userID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT id FROM group_collisions WHERE id = \\$1 AND session_id = \\$2").
+		WithArgs(collisionID, sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(collisionID))
+	suite.mock.ExpectExec("INSERT INTO group_votes \\(collision_id, user_id\\) VALUES \\(\\$1, \\$2\\) ON CONFLICT DO NOTHING").
+		WithArgs(collisionID, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM group_votes WHERE collision_id = \\$1").
+		WithArgs(collisionID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	
+	count, err := suite.pgdb.SetGroupVote(collisionID, sessionID, userID, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, count)
+	
+	// A collision from another session
+	suite.mock.ExpectQuery("SELECT id FROM group_collisions").
+		WithArgs(collisionID, sessionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	
+	_, err = suite.pgdb.SetGroupVote(collisionID, sessionID, userID, false)
+	assert.Equal(suite.T(), sql.ErrNoRows, err)
+}
+
+func (suite *PostgresTestSuite) TestUpdateUserRole() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE users SET role = \\$2 WHERE id = \\$1").
+		WithArgs(userID, models.RoleSupport).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	
+	assert.NoError(suite.T(), suite.pgdb.UpdateUserRole(userID, models.RoleSupport))
+	
+	suite.mock.ExpectExec("UPDATE users SET role").
+		WithArgs(userID, models.RoleSupport).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.UpdateUserRole(userID, models.RoleSupport))
+}
+
+func (suite *PostgresTestSuite) TestGrantRoleByEmail() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("UPDATE users SET role = \\$2 WHERE LOWER\\(email\\) = ANY\\(\\$1\\) AND email_verified_at IS NOT NULL AND role <> \\$2 RETURNING").
+		WithArgs(`{"ops@example.com","ada@example.com"}`, models.RoleAdmin).
+		WillReturnRows(sqlmock.NewRows(userColumnNames).
+			AddRow(userID, "ops@example.com", "$2a$10$hash", models.TierFree, `[]`, time.Now(), time.Now(), "", time.Now(), models.RoleAdmin))
+	
+	users, err := suite.pgdb.GrantRoleByEmail([]string{"Ops@Example.com", " ada@example.com"}, models.RoleAdmin)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), users, 1)
+	assert.Equal(suite.T(), models.RoleAdmin, users[0].Role)
+}
+
+func (suite *PostgresTestSuite) TestSearchUsers() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM users WHERE id::text = \\$1 OR email ILIKE \\$2 OR display_name ILIKE \\$2 ORDER BY email LIMIT \\$3").
+		WithArgs("100%_ada", `%100\%\_ada%`, 20).
+		WillReturnRows(sqlmock.NewRows(userColumnNames).
+			AddRow(userID, "100%_ada@example.com", "$2a$10$hash", models.TierPro, `[]`, time.Now(), time.Now(), "Ada", nil, models.RoleUser))
+	
+	users, err := suite.pgdb.SearchUsers(" 100%_ada ", 20)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), users, 1)
+	assert.Equal(suite.T(), userID, users[0].ID)
+}
+
+func (suite *PostgresTestSuite) TestResetUserUsage() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE user_usage SET collision_count = 0, (.+) WHERE user_id = \\$1 AND reset_date >= CURRENT_DATE - INTERVAL '7 days'").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	// No usage this week is fine
+	assert.NoError(suite.T(), suite.pgdb.ResetUserUsage(userID))
+}
+
+func (suite *PostgresTestSuite) TestUpdateCollisionDomain() {
+	domain := &models.CollisionDomain{
+		ID:          uuid.New().String(),
+		Name:        "Mycology",
+		Category:    "science",
+		Description: "How fungi network",
+		Examples:    []string{},
+		Keywords:    []string{"fungi"},
+		Intensity:   []string{"radical"},
+		Tier:        "premium",
+	}
+	
+	suite.mock.ExpectQuery("UPDATE collision_domains SET name = \\$2, (.+) WHERE id = \\$1 RETURNING created_at, updated_at").
+		WithArgs(domain.ID, "Mycology", "science", "How fungi network", []byte(`[]`), []byte(`["fungi"]`), []byte(`["radical"]`), "premium").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(time.Now(), time.Now()))
+	
+	assert.NoError(suite.T(), suite.pgdb.UpdateCollisionDomain(domain))
+	assert.False(suite.T(), domain.UpdatedAt.IsZero())
+	
+	suite.mock.ExpectExec("DELETE FROM collision_domains WHERE id = \\$1").
+		WithArgs(domain.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	assert.Equal(suite.T(), sql.ErrNoRows, suite.pgdb.DeleteCollisionDomain(domain.ID))
+}
+
+func (suite *PostgresTestSuite) TestGetAuditEntries() {
+	userID, actorID, entryID := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectQuery("SELECT (.+) FROM audit_log WHERE user_id = \\$1 AND action = \\$2 ORDER BY created_at DESC, id LIMIT \\$3").
+		WithArgs(userID, models.AuditTierChanged, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "actor_id", "action", "ip_address", "details", "created_at"}).
+			AddRow(entryID, userID, actorID, models.AuditTierChanged, "203.0.113.7", []byte(`{"from":"free","to":"pro","reason":"Comped for a workshop"}`), time.Now()))
+	
+	entries, err := suite.pgdb.GetAuditEntries(models.AuditFilter{UserID: &userID, Action: models.AuditTierChanged, Limit: 20})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 1)
+	assert.Equal(suite.T(), actorID, *entries[0].ActorID)
+	assert.Equal(suite.T(), "Comped for a workshop", entries[0].Details["reason"])
+	
+	// No filter
+	suite.mock.ExpectQuery("SELECT (.+) FROM audit_log ORDER BY created_at DESC, id LIMIT \\$1").
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "actor_id", "action", "ip_address", "details", "created_at"}))
+	
+	entries, err = suite.pgdb.GetAuditEntries(models.AuditFilter{Limit: 50})
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+var refreshTokenColumnNames = []string{"id", "user_id", "family_id", "token_hash", "expires_at", "revoked_at", "replaced_by", "created_at"}
+
+func (suite *PostgresTestSuite) TestCreateRefreshToken() {
+	token := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		FamilyID:  uuid.New(),
+		TokenHash: "abc123",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	createdAt := time.Now()
+	
+	suite.mock.ExpectQuery("INSERT INTO refresh_tokens").
+		WithArgs(token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+	
+	err := suite.pgdb.CreateRefreshToken(token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), createdAt, token.CreatedAt)
+}
+
+func (suite *PostgresTestSuite) TestRotateRefreshToken() {
+	currentID, userID, familyID := uuid.New(), uuid.New(), uuid.New()
+	next := &models.RefreshToken{ID: uuid.New(), TokenHash: "def456", ExpiresAt: time.Now().Add(time.Hour)}
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT (.+) FROM refresh_tokens WHERE token_hash = \\$1 FOR UPDATE").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumnNames).
+			AddRow(currentID, userID, familyID, "abc123", time.Now().Add(time.Hour), nil, nil, time.Now()))
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\), replaced_by = \\$2 WHERE id = \\$1").
+		WithArgs(currentID, next.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("INSERT INTO refresh_tokens").
+		WithArgs(next.ID, userID, familyID, "def456", next.ExpiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	suite.mock.ExpectCommit()
+	
+	current, err := suite.pgdb.RotateRefreshToken("abc123", next)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), userID, current.UserID)
+	assert.Equal(suite.T(), userID, next.UserID)
+	assert.Equal(suite.T(), familyID, next.FamilyID)
+}
+
+func (suite *PostgresTestSuite) TestRotateRefreshTokenReused() {
+	userID, familyID, replacedBy := uuid.New(), uuid.New(), uuid.New()
+	
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT (.+) FROM refresh_tokens").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumnNames).
+			AddRow(uuid.New(), userID, familyID, "abc123", time.Now().Add(time.Hour), time.Now(), replacedBy, time.Now()))
+	// The whole family is revoked and the revocation kept
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE family_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(familyID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	
+	current, err := suite.pgdb.RotateRefreshToken("abc123", &models.RefreshToken{ID: uuid.New()})
+	assert.Equal(suite.T(), ErrRefreshTokenReused, err)
+	assert.Equal(suite.T(), userID, current.UserID)
+}
+
+func (suite *PostgresTestSuite) TestRotateRefreshTokenExpiredOrRevoked() {
+	for _, revokedAt := range []interface{}{nil, time.Now()} {
+		expiresAt := time.Now().Add(time.Hour)
+		if revokedAt == nil {
+			expiresAt = time.Now().Add(-time.Minute)
+		}
+		
+		suite.mock.ExpectBegin()
+		suite.mock.ExpectQuery("SELECT (.+) FROM refresh_tokens").
+			WithArgs("abc123").
+			WillReturnRows(sqlmock.NewRows(refreshTokenColumnNames).
+				AddRow(uuid.New(), uuid.New(), uuid.New(), "abc123", expiresAt, revokedAt, nil, time.Now()))
+		suite.mock.ExpectRollback()
+		
+		_, err := suite.pgdb.RotateRefreshToken("abc123", &models.RefreshToken{ID: uuid.New()})
+		assert.Equal(suite.T(), sql.ErrNoRows, err)
+	}
+}
+
+func (suite *PostgresTestSuite) TestRevokeRefreshTokenFamily() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE family_id = \\( SELECT family_id FROM refresh_tokens WHERE token_hash = \\$1 AND user_id = \\$2 \\) AND revoked_at IS NULL").
+		WithArgs("abc123", userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	
+	err := suite.pgdb.RevokeRefreshTokenFamily("abc123", userID)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *PostgresTestSuite) TestRevokeUserRefreshTokens() {
+	userID := uuid.New()
+	
+	suite.mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	
+	revoked, err := suite.pgdb.RevokeUserRefreshTokens(userID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), revoked)
+}
+
+func (suite *PostgresTestSuite) TestCreateAuditEntry() {
+	userID := uuid.New()
+	entry := &models.AuditEntry{
+		UserID:    &userID,
+		Action:    models.AuditLoginLockout,
+		IPAddress: "203.0.113.7",
+		Details:   map[string]interface{}{"scope": "email", "failures": 10},
+	}
+	createdAt := time.Now()
+	
+	suite.mock.ExpectQuery("INSERT INTO audit_log \\(id, user_id, actor_id, action, ip_address, details\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, NULLIF\\(\\$5, ''\\), \\$6\\)").
+		WithArgs(sqlmock.AnyArg(), &userID, nil, models.AuditLoginLockout, "203.0.113.7", []byte(`{"failures":10,"scope":"email"}`)).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+	
+	err := suite.pgdb.CreateAuditEntry(entry)
+	assert.NoError(suite.T(), err)
+	assert.NotEqual(suite.T(), uuid.Nil, entry.ID)
+	assert.Equal(suite.T(), createdAt, entry.CreatedAt)
+}
+
 // Benchmark tests for database operations
 func BenchmarkCreateUser(b *testing.B) {
 	db, mock, _ := sqlmock.New()
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 
 	"idea-collision-engine-api/internal/models"
@@ -45,8 +48,18 @@ func (r *RedisClient) Close() error {
 const (
 	KeyCollisionDomains = "collision:domains:%s"        // collision:domains:tier
 	KeyUserUsage        = "user:usage:%s"               // user:usage:user_id
+	KeyUser             = "user:row:%s"                 // user:row:user_id
 	KeyCollisionResult  = "collision:result:%s"         // collision:result:hash
 	KeyRateLimit        = "rate:limit:%s:%d"            // rate:limit:user_id:window
+	KeyShareView        = "share:view:%s:%s"            // share:view:link_id:viewer
+	KeyDeniedToken      = "auth:denied:token:%s"        // auth:denied:token:token_id
+	KeyRevokedBefore    = "auth:revoked:user:%s"        // auth:revoked:user:user_id
+	KeyLoginFailures    = "login:failures:%s"           // login:failures:email:address or login:failures:ip:address
+	KeyLoginBlock       = "login:block:%s"              // login:block:email:address or login:block:ip:address
+	KeyOIDCState        = "oidc:state:%s"               // oidc:state:state
+	KeyBoardEvents      = "board:events:%s"             // board:events:team_id (stream)
+	KeyBoardChannel     = "board:channel:%s"            // board:channel:team_id (pub/sub)
+	KeyBoardPresence    = "board:presence:%s"           // board:presence:team_id (hash of connection -> presence)
 )
 
 // Cache collision domains by tier
@@ -113,6 +126,39 @@ func (r *RedisClient) GetCachedUserUsage(userID string) (*models.UserUsage, erro
 	return &usage, nil
 }
 
+// CacheUser caches a user's row. The password hash isn't serialized, so it's
+// never stored in Redis.
+func (r *RedisClient) CacheUser(user *models.User, expiration time.Duration) error {
+	key := fmt.Sprintf(KeyUser, user.ID.String())
+	
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user: %w", err)
+	}
+	
+	return r.client.Set(r.ctx, key, data, expiration).Err()
+}
+
+func (r *RedisClient) GetCachedUser(userID string) (*models.User, error) {
+	key := fmt.Sprintf(KeyUser, userID)
+	
+	data, err := r.client.Get(r.ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil // Cache miss
+		}
+		return nil, err
+	}
+	
+	var user models.User
+	err = json.Unmarshal([]byte(data), &user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	
+	return &user, nil
+}
+
 // Cache collision results for similar requests
 func (r *RedisClient) CacheCollisionResult(inputHash string, result *models.CollisionResult, expiration time.Duration) error {
 	key := fmt.Sprintf(KeyCollisionResult, inputHash)
@@ -238,7 +284,307 @@ func (r *RedisClient) InvalidateUserUsage(userID string) error {
 	return r.client.Del(r.ctx, key).Err()
 }
 
+// InvalidateUser drops a user's cached row. Call it whenever the users row
+// changes, so the subscription tier is never stale for longer than a request.
+func (r *RedisClient) InvalidateUser(userID string) error {
+	key := fmt.Sprintf(KeyUser, userID)
+	return r.client.Del(r.ctx, key).Err()
+}
+
+// MarkShareViewed records that a viewer opened a share link and reports whether
+// this is their first view within the window, so reloads aren't counted twice
+func (r *RedisClient) MarkShareViewed(linkID, viewer string, window time.Duration) (bool, error) {
+	key := fmt.Sprintf(KeyShareView, linkID, viewer)
+	return r.client.SetNX(r.ctx, key, 1, window).Result()
+}
+
+// DenyToken puts an access token on the deny-list until it would have expired anyway
+func (r *RedisClient) DenyToken(tokenID string, expiration time.Duration) error {
+	if expiration <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf(KeyDeniedToken, tokenID)
+	return r.client.Set(r.ctx, key, 1, expiration).Err()
+}
+
+// RevokeTokensIssuedBefore denies every access token of a user issued before
+// the given time. The entry only needs to outlive the longest-lived access token.
+func (r *RedisClient) RevokeTokensIssuedBefore(userID string, before time.Time, expiration time.Duration) error {
+	key := fmt.Sprintf(KeyRevokedBefore, userID)
+	return r.client.Set(r.ctx, key, before.Unix(), expiration).Err()
+}
+
+// IsTokenDenied reports whether an access token was revoked, either on its own
+// or because all of its user's tokens were
+func (r *RedisClient) IsTokenDenied(tokenID, userID string, issuedAt time.Time) (bool, error) {
+	values, err := r.client.MGet(r.ctx,
+		fmt.Sprintf(KeyDeniedToken, tokenID),
+		fmt.Sprintf(KeyRevokedBefore, userID),
+	).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token deny-list: %w", err)
+	}
+	
+	if values[0] != nil {
+		return true, nil
+	}
+	if revokedBefore, ok := values[1].(string); ok {
+		before, err := strconv.ParseInt(revokedBefore, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse token revocation time: %w", err)
+		}
+		return issuedAt.Unix() < before, nil
+	}
+	
+	return false, nil
+}
+
+// RecordLoginFailure counts a failed login for subject and returns the number
+// of failures since the window started with the first of them
+func (r *RedisClient) RecordLoginFailure(subject string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf(KeyLoginFailures, subject)
+	
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(r.ctx, key)
+	pipe.ExpireNX(r.ctx, key, window)
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	
+	return incr.Val(), nil
+}
+
+// ClearLoginFailures forgets the failed logins of subject
+func (r *RedisClient) ClearLoginFailures(subject string) error {
+	return r.client.Del(r.ctx, fmt.Sprintf(KeyLoginFailures, subject), fmt.Sprintf(KeyLoginBlock, subject)).Err()
+}
+
+// BlockLogin refuses logins for subject for the given duration
+func (r *RedisClient) BlockLogin(subject string, duration time.Duration) error {
+	key := fmt.Sprintf(KeyLoginBlock, subject)
+	return r.client.Set(r.ctx, key, 1, duration).Err()
+}
+
+// LoginBlockedFor returns how much longer logins for subject are refused, or 0
+func (r *RedisClient) LoginBlockedFor(subject string) (time.Duration, error) {
+	key := fmt.Sprintf(KeyLoginBlock, subject)
+	
+	ttl, err := r.client.PTTL(r.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// AppendBoardEvent adds an event to its team's stream and publishes it to every
+// API instance. The stream keeps about maxLen events for replay, and is dropped
+// after retention without new ones. Sets the event's ID.
+func (r *RedisClient) AppendBoardEvent(event *models.BoardEvent, maxLen int64, retention time.Duration) error {
+	key := fmt.Sprintf(KeyBoardEvents, event.TeamID)
+	
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal board event: %w", err)
+	}
+	
+	id, err := r.client.XAdd(r.ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to append board event: %w", err)
+	}
+	r.client.Expire(r.ctx, key, retention)
+	
+	event.ID = id
+	return r.BroadcastBoardEvent(event)
+}
+
+// BroadcastBoardEvent publishes an event to every API instance without keeping
+// it for replay
+func (r *RedisClient) BroadcastBoardEvent(event *models.BoardEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal board event: %w", err)
+	}
+	
+	return r.client.Publish(r.ctx, fmt.Sprintf(KeyBoardChannel, event.TeamID), data).Err()
+}
+
+// BoardEventsSince returns a team's events after the one with ID lastID, oldest
+// first. It also reports whether that's all of them: false means lastID was
+// trimmed from the stream, or never in it, so events may have been missed.
+func (r *RedisClient) BoardEventsSince(teamID uuid.UUID, lastID string) ([]models.BoardEvent, bool, error) {
+	key := fmt.Sprintf(KeyBoardEvents, teamID)
+	
+	// The range includes lastID itself, which shows it's still in the stream
+	entries, err := r.client.XRange(r.ctx, key, lastID, "+").Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read board events: %w", err)
+	}
+	if len(entries) == 0 || entries[0].ID != lastID {
+		return nil, false, nil
+	}
+	
+	events := make([]models.BoardEvent, 0, len(entries)-1)
+	for _, entry := range entries[1:] {
+		data, _ := entry.Values["event"].(string)
+		var event models.BoardEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal board event: %w", err)
+		}
+		event.ID = entry.ID
+		events = append(events, event)
+	}
+	
+	return events, true, nil
+}
+
+// SubscribeBoardEvents delivers the events published for every team until ctx
+// is cancelled, when the channel closes. The subscription reconnects on its own
+// if Redis goes away, but events published meanwhile are only in the streams.
+func (r *RedisClient) SubscribeBoardEvents(ctx context.Context) <-chan *models.BoardEvent {
+	pubsub := r.client.PSubscribe(ctx, fmt.Sprintf(KeyBoardChannel, "*"))
+	events := make(chan *models.BoardEvent, 256)
+	
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+		
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event models.BoardEvent
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					fmt.Printf("Dropping malformed board event: %v\n", err)
+					continue
+				}
+				select {
+				case events <- &event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	
+	return events
+}
+
+// boardPresenceEntry is how a connection's presence is stored, with the time it
+// lapses unless the connection refreshes it
+type boardPresenceEntry struct {
+	models.BoardPresence
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SetBoardPresence records that a connection is on its team's live feed for
+// the next ttl
+func (r *RedisClient) SetBoardPresence(teamID uuid.UUID, connectionID string, presence models.BoardPresence, ttl time.Duration) error {
+	key := fmt.Sprintf(KeyBoardPresence, teamID)
+	
+	data, err := json.Marshal(boardPresenceEntry{BoardPresence: presence, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal board presence: %w", err)
+	}
+	
+	pipe := r.client.TxPipeline()
+	pipe.HSet(r.ctx, key, connectionID, data)
+	pipe.Expire(r.ctx, key, ttl)
+	_, err = pipe.Exec(r.ctx)
+	return err
+}
+
+// RemoveBoardPresence forgets a connection that left its team's live feed
+func (r *RedisClient) RemoveBoardPresence(teamID uuid.UUID, connectionID string) error {
+	return r.client.HDel(r.ctx, fmt.Sprintf(KeyBoardPresence, teamID), connectionID).Err()
+}
+
+// GetBoardPresence returns who is on a team's live feed, once per member and
+// board however many connections they have. Lapsed connections, whose
+// instance went away without removing them, are cleaned up.
+func (r *RedisClient) GetBoardPresence(teamID uuid.UUID) ([]models.BoardPresence, error) {
+	key := fmt.Sprintf(KeyBoardPresence, teamID)
+	
+	entries, err := r.client.HGetAll(r.ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	presence := []models.BoardPresence{}
+	seen := map[string]bool{}
+	var lapsed []string
+	for connectionID, data := range entries {
+		var entry boardPresenceEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil || entry.ExpiresAt.Before(now) {
+			lapsed = append(lapsed, connectionID)
+			continue
+		}
+		
+		seenKey := entry.UserID.String()
+		if entry.BoardID != nil {
+			seenKey += ":" + entry.BoardID.String()
+		}
+		if !seen[seenKey] {
+			seen[seenKey] = true
+			presence = append(presence, entry.BoardPresence)
+		}
+	}
+	if len(lapsed) > 0 {
+		r.client.HDel(r.ctx, key, lapsed...)
+	}
+	
+	sort.Slice(presence, func(i, j int) bool { return presence[i].Name < presence[j].Name })
+	return presence, nil
+}
+
 // Health check
+// SaveOIDCState remembers a sign-in in progress at an identity provider until
+// the provider redirects back with the state
+func (r *RedisClient) SaveOIDCState(state string, login *models.OIDCLoginState, expiration time.Duration) error {
+	key := fmt.Sprintf(KeyOIDCState, state)
+	
+	data, err := json.Marshal(login)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OIDC login state: %w", err)
+	}
+	
+	return r.client.Set(r.ctx, key, data, expiration).Err()
+}
+
+// TakeOIDCState returns and forgets the sign-in with the given state, so each
+// callback can only be used once. Returns nil if it's unknown or expired.
+func (r *RedisClient) TakeOIDCState(state string) (*models.OIDCLoginState, error) {
+	key := fmt.Sprintf(KeyOIDCState, state)
+	
+	data, err := r.client.GetDel(r.ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	
+	var login models.OIDCLoginState
+	if err := json.Unmarshal([]byte(data), &login); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OIDC login state: %w", err)
+	}
+	
+	return &login, nil
+}
+
 func (r *RedisClient) Ping() error {
 	return r.client.Ping(r.ctx).Err()
 }
\ No newline at end of file
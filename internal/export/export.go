@@ -0,0 +1,350 @@
+package export
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatCSV      Format = "csv"
+	FormatJSON     Format = "json"
+)
+
+// BundleVersion is bumped when the layout of JSON bundles changes
+const BundleVersion = 1
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var reportTemplate = template.Must(template.New("report.html.tmpl").Funcs(template.FuncMap{
+	"stars": stars,
+}).ParseFS(templateFiles, "templates/report.html.tmpl"))
+
+// ParseFormat reads a format name, accepting "md" for Markdown
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "html":
+		return FormatHTML, nil
+	case "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("format must be one of markdown, html, csv, json")
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the file extension of the format, without the dot
+func (f Format) Extension() string {
+	switch f {
+	case FormatMarkdown:
+		return "md"
+	case FormatHTML:
+		return "html"
+	case FormatCSV:
+		return "csv"
+	default:
+		return "json"
+	}
+}
+
+// Document is a set of collision sessions to export
+type Document struct {
+	Sessions   []models.CollisionSession
+	ExportedAt time.Time
+	Locale     Locale
+	Zone       *time.Location // time zone dates are written in, UTC if nil
+}
+
+// Bundle is the layout of JSON exports. Sessions are kept in their API form so
+// bundles can be read back by tools that already understand the API.
+type Bundle struct {
+	Version    int                       `json:"version"`
+	ExportedAt time.Time                 `json:"exported_at"`
+	Locale     string                    `json:"locale"`
+	TimeZone   string                    `json:"time_zone"`
+	Count      int                       `json:"count"`
+	Sessions   []models.CollisionSession `json:"sessions"`
+}
+
+// Render writes doc to w in the given format
+func Render(w io.Writer, format Format, doc Document) error {
+	if doc.Zone == nil {
+		doc.Zone = time.UTC
+	}
+	if doc.Locale.labels == nil {
+		doc.Locale = locales[DefaultLocale]
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(w, doc)
+	case FormatHTML:
+		return renderHTML(w, doc)
+	case FormatCSV:
+		return renderCSV(w, doc)
+	case FormatJSON:
+		return renderJSON(w, doc)
+	}
+	return fmt.Errorf("unsupported export format %q", format)
+}
+
+// Title is the heading of a session in exports: its project and collision domain
+func Title(session models.CollisionSession) string {
+	project := strings.TrimSpace(session.InputData.CurrentProject)
+	if project == "" {
+		return session.CollisionResult.CollisionDomain
+	}
+	return fmt.Sprintf("%s × %s", project, session.CollisionResult.CollisionDomain)
+}
+
+func renderMarkdown(w io.Writer, doc Document) error {
+	l := doc.Locale
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", l.Label("title"))
+	fmt.Fprintf(&b, "_%s: %s · %s: %d_\n\n", l.Label("exported"), l.FormatDateTime(doc.ExportedAt, doc.Zone),
+		l.Label("sessions"), len(doc.Sessions))
+
+	if len(doc.Sessions) == 0 {
+		fmt.Fprintf(&b, "%s\n", l.Label("empty"))
+	}
+
+	for i, session := range doc.Sessions {
+		if i > 0 {
+			b.WriteString("---\n\n")
+		}
+
+		result := session.CollisionResult
+		input := session.InputData
+
+		fmt.Fprintf(&b, "## %s\n\n", Title(session))
+		fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("created"), l.FormatDateTime(session.CreatedAt, doc.Zone))
+		fmt.Fprintf(&b, "- **%s:** %s → %s\n", l.Label("collision_domain"), result.PrimaryDomain, result.CollisionDomain)
+		fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("project_type"), input.ProjectType)
+		fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("intensity"), input.CollisionIntensity)
+		fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("rating"), ratingText(session.UserRating, l))
+		fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("quality"), l.FormatDecimal(result.QualityScore))
+		if len(session.Tags) > 0 {
+			fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("tags"), strings.Join(session.Tags, ", "))
+		}
+		if session.IsFavorite {
+			fmt.Fprintf(&b, "- **%s:** %s\n", l.Label("favorite"), l.Label("yes"))
+		}
+		b.WriteString("\n")
+
+		if result.Connection != "" {
+			fmt.Fprintf(&b, "### %s\n\n%s\n\n", l.Label("connection"), result.Connection)
+		}
+		markdownList(&b, l.Label("spark_questions"), result.SparkQuestions, true)
+		markdownList(&b, l.Label("examples"), result.Examples, false)
+		markdownList(&b, l.Label("next_steps"), result.NextSteps, true)
+
+		if notes := sessionNotes(session); notes != "" {
+			fmt.Fprintf(&b, "### %s\n\n", l.Label("notes"))
+			for _, line := range strings.Split(notes, "\n") {
+				fmt.Fprintf(&b, "> %s\n", line)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownList(b *strings.Builder, heading string, items []string, numbered bool) {
+	if len(items) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", heading)
+	for i, item := range items {
+		if numbered {
+			fmt.Fprintf(b, "%d. %s\n", i+1, item)
+		} else {
+			fmt.Fprintf(b, "- %s\n", item)
+		}
+	}
+	b.WriteString("\n")
+}
+
+// htmlSession is a session with its dates and numbers already formatted for the locale
+type htmlSession struct {
+	models.CollisionSession
+	Title   string
+	Created string
+	Quality string
+	Notes   string
+}
+
+func renderHTML(w io.Writer, doc Document) error {
+	l := doc.Locale
+
+	sessions := make([]htmlSession, 0, len(doc.Sessions))
+	for _, session := range doc.Sessions {
+		sessions = append(sessions, htmlSession{
+			CollisionSession: session,
+			Title:            Title(session),
+			Created:          l.FormatDateTime(session.CreatedAt, doc.Zone),
+			Quality:          l.FormatDecimal(session.CollisionResult.QualityScore),
+			Notes:            sessionNotes(session),
+		})
+	}
+
+	return reportTemplate.Execute(w, map[string]interface{}{
+		"Lang":     l.Tag,
+		"Labels":   l.labels,
+		"Exported": l.FormatDateTime(doc.ExportedAt, doc.Zone),
+		"Sessions": sessions,
+	})
+}
+
+// csvColumns are the label keys of the CSV header, in column order
+var csvColumns = []string{
+	"id", "created", "project", "project_type", "intensity", "interests",
+	"primary_domain", "collision_domain", "connection", "spark_questions",
+	"examples", "next_steps", "quality", "rating", "notes", "tags", "favorite",
+}
+
+func renderCSV(w io.Writer, doc Document) error {
+	l := doc.Locale
+
+	// A byte order mark makes spreadsheet apps read the file as UTF-8
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = l.CSVDelimiter
+
+	header := make([]string, len(csvColumns))
+	for i, key := range csvColumns {
+		header[i] = l.Label(key)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, session := range doc.Sessions {
+		result := session.CollisionResult
+		input := session.InputData
+
+		rating := ""
+		if session.UserRating != nil {
+			rating = strconv.Itoa(*session.UserRating)
+		}
+		favorite := l.Label("no")
+		if session.IsFavorite {
+			favorite = l.Label("yes")
+		}
+
+		record := []string{
+			session.ID.String(),
+			l.FormatDateTime(session.CreatedAt, doc.Zone),
+			input.CurrentProject,
+			input.ProjectType,
+			input.CollisionIntensity,
+			strings.Join(input.UserInterests, ", "),
+			result.PrimaryDomain,
+			result.CollisionDomain,
+			result.Connection,
+			strings.Join(result.SparkQuestions, "\n"),
+			strings.Join(result.Examples, "\n"),
+			strings.Join(result.NextSteps, "\n"),
+			l.FormatDecimal(result.QualityScore),
+			rating,
+			sessionNotes(session),
+			strings.Join(session.Tags, ", "),
+			favorite,
+		}
+		for i := range record {
+			record[i] = csvSafe(record[i])
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvSafe stops spreadsheet apps from evaluating user text as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func renderJSON(w io.Writer, doc Document) error {
+	sessions := doc.Sessions
+	if sessions == nil {
+		sessions = []models.CollisionSession{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Bundle{
+		Version:    BundleVersion,
+		ExportedAt: doc.ExportedAt,
+		Locale:     doc.Locale.Tag,
+		TimeZone:   doc.Zone.String(),
+		Count:      len(sessions),
+		Sessions:   sessions,
+	})
+}
+
+// sessionNotes returns a session's exploration notes, or "" if it has none
+func sessionNotes(session models.CollisionSession) string {
+	if session.ExplorationNotes == nil {
+		return ""
+	}
+	return strings.TrimSpace(*session.ExplorationNotes)
+}
+
+func ratingText(rating *int, l Locale) string {
+	if rating == nil {
+		return l.Label("not_rated")
+	}
+	return fmt.Sprintf("%s (%d/5)", stars(rating), *rating)
+}
+
+// stars draws a 1-5 rating as filled and empty stars
+func stars(rating *int) string {
+	if rating == nil {
+		return ""
+	}
+	return strings.Repeat("★", *rating) + strings.Repeat("☆", 5-*rating)
+}
@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type ExportTestSuite struct {
+	suite.Suite
+	sessions []models.CollisionSession
+	exported time.Time
+}
+
+func (suite *ExportTestSuite) SetupTest() {
+	rating := 4
+	notes := "Try this with the onboarding team\nStart with reminders"
+	suite.exported = time.Date(2026, 3, 9, 14, 30, 0, 0, time.UTC)
+
+	suite.sessions = []models.CollisionSession{
+		{
+			ID:     uuid.MustParse("5f0c6c2e-8d1a-4a52-9a57-0d7f2d0a1b11"),
+			UserID: uuid.New(),
+			InputData: models.CollisionInput{
+				UserInterests:      []string{"design", "psychology"},
+				CurrentProject:     "Habit tracker",
+				ProjectType:        "product",
+				CollisionIntensity: "moderate",
+			},
+			CollisionResult: models.CollisionResult{
+				PrimaryDomain:   "Design",
+				CollisionDomain: "Biomimicry",
+				Connection:      "Habits grow like <root systems> & adapt.",
+				SparkQuestions:  []string{"What would a habit look like as an ecosystem?"},
+				Examples:        []string{"=HYPERLINK(\"http://example.com\")", "Velcro from burrs"},
+				NextSteps:       []string{"Sketch a habit ecosystem"},
+				QualityScore:    0.857,
+			},
+			UserRating:       &rating,
+			ExplorationNotes: &notes,
+			CreatedAt:        time.Date(2026, 3, 1, 23, 15, 0, 0, time.UTC),
+			IsFavorite:       true,
+			Tags:             []string{"onboarding", "retention"},
+		},
+		{
+			ID:     uuid.New(),
+			UserID: uuid.New(),
+			InputData: models.CollisionInput{
+				CurrentProject:     "Newsletter",
+				ProjectType:        "content",
+				CollisionIntensity: "gentle",
+			},
+			CollisionResult: models.CollisionResult{
+				PrimaryDomain:   "Writing",
+				CollisionDomain: "Jazz Improvisation",
+				QualityScore:    0.5,
+			},
+			CreatedAt: time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC),
+			Tags:      []string{},
+		},
+	}
+}
+
+func (suite *ExportTestSuite) render(format Format, locale string, zone *time.Location) string {
+	l, ok := LookupLocale(locale)
+	assert.True(suite.T(), ok)
+
+	var out bytes.Buffer
+	err := Render(&out, format, Document{
+		Sessions:   suite.sessions,
+		ExportedAt: suite.exported,
+		Locale:     l,
+		Zone:       zone,
+	})
+	assert.NoError(suite.T(), err)
+	return out.String()
+}
+
+func (suite *ExportTestSuite) TestLookupLocale() {
+	locale, ok := LookupLocale("de-at")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "de-DE", locale.Tag)
+
+	locale, ok = LookupLocale("en_GB")
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), "en-GB", locale.Tag)
+
+	locale, ok = LookupLocale("ja-JP")
+	assert.False(suite.T(), ok)
+	assert.Equal(suite.T(), DefaultLocale, locale.Tag)
+}
+
+func (suite *ExportTestSuite) TestNegotiateLocale() {
+	assert.Equal(suite.T(), "fr-FR", NegotiateLocale("ja;q=0.9, fr-CA;q=0.8, en;q=0.5").Tag)
+	assert.Equal(suite.T(), "en-GB", NegotiateLocale("en-GB,en;q=0.9,de;q=0.8").Tag)
+	assert.Equal(suite.T(), "es-ES", NegotiateLocale("de;q=0, es").Tag)
+	assert.Equal(suite.T(), DefaultLocale, NegotiateLocale("").Tag)
+	assert.Equal(suite.T(), DefaultLocale, NegotiateLocale("*").Tag)
+}
+
+func (suite *ExportTestSuite) TestMarkdown() {
+	out := suite.render(FormatMarkdown, "en-US", nil)
+
+	assert.Contains(suite.T(), out, "# Idea collisions\n")
+	assert.Contains(suite.T(), out, "_Exported: 03/09/2026 2:30 PM · Sessions: 2_")
+	assert.Contains(suite.T(), out, "## Habit tracker × Biomimicry\n")
+	assert.Contains(suite.T(), out, "- **Rating:** ★★★★☆ (4/5)\n")
+	assert.Contains(suite.T(), out, "- **Quality score:** 0.86\n")
+	assert.Contains(suite.T(), out, "- **Tags:** onboarding, retention\n")
+	assert.Contains(suite.T(), out, "### Spark questions\n\n1. What would a habit look like as an ecosystem?\n")
+	assert.Contains(suite.T(), out, "> Try this with the onboarding team\n> Start with reminders\n")
+	assert.Contains(suite.T(), out, "- **Rating:** Not rated\n")
+	assert.Equal(suite.T(), 1, strings.Count(out, "\n---\n"))
+}
+
+func (suite *ExportTestSuite) TestMarkdownHonorsLocaleAndZone() {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(suite.T(), err)
+
+	out := suite.render(FormatMarkdown, "de", berlin)
+
+	assert.Contains(suite.T(), out, "# Ideen-Kollisionen\n")
+	// 23:15 UTC on March 1st is past midnight in Berlin
+	assert.Contains(suite.T(), out, "- **Erstellt:** 02.03.2026 00:15\n")
+	assert.Contains(suite.T(), out, "- **Qualitätswert:** 0,86\n")
+	assert.Contains(suite.T(), out, "- **Bewertung:** Nicht bewertet\n")
+}
+
+func (suite *ExportTestSuite) TestHTML() {
+	out := suite.render(FormatHTML, "fr", nil)
+
+	assert.True(suite.T(), strings.HasPrefix(out, "<!DOCTYPE html>"))
+	assert.Contains(suite.T(), out, `<html lang="fr-FR">`)
+	assert.Contains(suite.T(), out, "<h1>Collisions d&#39;idées</h1>")
+	assert.Contains(suite.T(), out, "<h2>Habit tracker × Biomimicry</h2>")
+	assert.Contains(suite.T(), out, "@media print")
+	assert.Contains(suite.T(), out, `<span class="stars">★★★★☆</span> 4/5`)
+	assert.Contains(suite.T(), out, "<dd>0,86</dd>")
+	assert.Contains(suite.T(), out, "Non notée")
+
+	// User content is escaped and nothing is loaded from elsewhere
+	assert.Contains(suite.T(), out, "Habits grow like &lt;root systems&gt; &amp; adapt.")
+	assert.NotContains(suite.T(), out, "<root systems>")
+	assert.NotContains(suite.T(), out, "<link")
+	assert.NotContains(suite.T(), out, "<script")
+}
+
+func (suite *ExportTestSuite) TestHTMLEmpty() {
+	suite.sessions = nil
+	out := suite.render(FormatHTML, "en", nil)
+
+	assert.Contains(suite.T(), out, "<p>No collisions match this export.</p>")
+}
+
+func (suite *ExportTestSuite) TestCSV() {
+	out := suite.render(FormatCSV, "en-US", nil)
+	assert.True(suite.T(), strings.HasPrefix(out, "\uFEFF"))
+
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\uFEFF"))).ReadAll()
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), records, 3)
+
+	header, first := records[0], records[1]
+	assert.Len(suite.T(), header, len(csvColumns))
+	assert.Equal(suite.T(), "ID", header[0])
+	assert.Equal(suite.T(), "5f0c6c2e-8d1a-4a52-9a57-0d7f2d0a1b11", first[0])
+	assert.Equal(suite.T(), "03/01/2026 11:15 PM", first[1])
+	assert.Equal(suite.T(), "Habit tracker", first[2])
+	assert.Equal(suite.T(), "design, psychology", first[5])
+	assert.Equal(suite.T(), "0.86", first[12])
+	assert.Equal(suite.T(), "4", first[13])
+	assert.Equal(suite.T(), "Yes", first[16])
+	assert.Equal(suite.T(), "", records[2][13])
+
+	// Formulas are neutralized so spreadsheets show them as text
+	assert.Equal(suite.T(), "'=HYPERLINK(\"http://example.com\")\nVelcro from burrs", first[10])
+}
+
+func (suite *ExportTestSuite) TestCSVUsesSemicolonsWithDecimalCommas() {
+	out := suite.render(FormatCSV, "de-DE", nil)
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\uFEFF")))
+	reader.Comma = ';'
+	records, err := reader.ReadAll()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Bewertung", records[0][13])
+	assert.Equal(suite.T(), "0,86", records[1][12])
+	assert.Equal(suite.T(), "Ja", records[1][16])
+	assert.Equal(suite.T(), "Nein", records[2][16])
+}
+
+func (suite *ExportTestSuite) TestJSONBundle() {
+	out := suite.render(FormatJSON, "es", nil)
+
+	var bundle Bundle
+	assert.NoError(suite.T(), json.Unmarshal([]byte(out), &bundle))
+	assert.Equal(suite.T(), BundleVersion, bundle.Version)
+	assert.Equal(suite.T(), "es-ES", bundle.Locale)
+	assert.Equal(suite.T(), "UTC", bundle.TimeZone)
+	assert.Equal(suite.T(), 2, bundle.Count)
+	assert.True(suite.T(), suite.exported.Equal(bundle.ExportedAt))
+	assert.Equal(suite.T(), suite.sessions[0].ID, bundle.Sessions[0].ID)
+	assert.Equal(suite.T(), 4, *bundle.Sessions[0].UserRating)
+	assert.Equal(suite.T(), "Try this with the onboarding team\nStart with reminders", *bundle.Sessions[0].ExplorationNotes)
+}
+
+func (suite *ExportTestSuite) TestParseFormat() {
+	format, err := ParseFormat("MD")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), FormatMarkdown, format)
+	assert.Equal(suite.T(), "md", format.Extension())
+	assert.Equal(suite.T(), "text/markdown; charset=utf-8", format.ContentType())
+
+	_, err = ParseFormat("pdf")
+	assert.Error(suite.T(), err)
+}
+
+func TestExportTestSuite(t *testing.T) {
+	suite.Run(t, new(ExportTestSuite))
+}
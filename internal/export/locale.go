@@ -0,0 +1,234 @@
+package export
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale controls how dates, numbers and headings are written in an export
+type Locale struct {
+	Tag          string // BCP 47 tag, e.g. "en-US"
+	Date         string // time layout for dates
+	DateTime     string // time layout for dates with a time of day
+	Decimal      string // decimal separator
+	CSVDelimiter rune   // field separator spreadsheet apps expect for this locale
+	labels       map[string]string
+}
+
+// DefaultLocale is used when no supported locale is requested
+const DefaultLocale = "en-US"
+
+var locales = map[string]Locale{
+	"en-US": {Tag: "en-US", Date: "01/02/2006", DateTime: "01/02/2006 3:04 PM", Decimal: ".", CSVDelimiter: ',', labels: englishLabels},
+	"en-GB": {Tag: "en-GB", Date: "02/01/2006", DateTime: "02/01/2006 15:04", Decimal: ".", CSVDelimiter: ',', labels: englishLabels},
+	"de-DE": {Tag: "de-DE", Date: "02.01.2006", DateTime: "02.01.2006 15:04", Decimal: ",", CSVDelimiter: ';', labels: germanLabels},
+	"fr-FR": {Tag: "fr-FR", Date: "02/01/2006", DateTime: "02/01/2006 15:04", Decimal: ",", CSVDelimiter: ';', labels: frenchLabels},
+	"es-ES": {Tag: "es-ES", Date: "02/01/2006", DateTime: "02/01/2006 15:04", Decimal: ",", CSVDelimiter: ';', labels: spanishLabels},
+}
+
+// languageDefaults maps a bare language to the locale used for it
+var languageDefaults = map[string]string{
+	"en": "en-US",
+	"de": "de-DE",
+	"fr": "fr-FR",
+	"es": "es-ES",
+}
+
+// LookupLocale finds a locale by tag. Tags are matched case-insensitively and a
+// region the export doesn't know falls back to its language, so "de-AT" gets
+// German headings and formats. The second return value is false when neither
+// the tag nor its language is supported.
+func LookupLocale(tag string) (Locale, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "" {
+		return locales[DefaultLocale], false
+	}
+
+	for known, locale := range locales {
+		if strings.EqualFold(known, tag) {
+			return locale, true
+		}
+	}
+
+	language := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	if known, ok := languageDefaults[language]; ok {
+		return locales[known], true
+	}
+
+	return locales[DefaultLocale], false
+}
+
+// NegotiateLocale picks the supported locale the client prefers most from an
+// Accept-Language header, falling back to DefaultLocale
+func NegotiateLocale(header string) Locale {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, weighted{tag, quality})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if locale, ok := LookupLocale(r.tag); ok {
+			return locale
+		}
+	}
+	return locales[DefaultLocale]
+}
+
+// Label returns the heading for key in the locale's language
+func (l Locale) Label(key string) string {
+	if label, ok := l.labels[key]; ok {
+		return label
+	}
+	return englishLabels[key]
+}
+
+// FormatDate writes t as a date in the given time zone
+func (l Locale) FormatDate(t time.Time, zone *time.Location) string {
+	return t.In(zone).Format(l.Date)
+}
+
+// FormatDateTime writes t as a date and time of day in the given time zone
+func (l Locale) FormatDateTime(t time.Time, zone *time.Location) string {
+	return t.In(zone).Format(l.DateTime)
+}
+
+// FormatDecimal writes f with two decimals and the locale's decimal separator
+func (l Locale) FormatDecimal(f float64) string {
+	return strings.Replace(strconv.FormatFloat(f, 'f', 2, 64), ".", l.Decimal, 1)
+}
+
+var englishLabels = map[string]string{
+	"title":            "Idea collisions",
+	"exported":         "Exported",
+	"sessions":         "Sessions",
+	"id":               "ID",
+	"created":          "Created",
+	"project":          "Project",
+	"project_type":     "Project type",
+	"intensity":        "Intensity",
+	"interests":        "Interests",
+	"primary_domain":   "Primary domain",
+	"collision_domain": "Collision domain",
+	"connection":       "Connection",
+	"spark_questions":  "Spark questions",
+	"examples":         "Examples",
+	"next_steps":       "Next steps",
+	"quality":          "Quality score",
+	"rating":           "Rating",
+	"notes":            "Notes",
+	"tags":             "Tags",
+	"favorite":         "Favorite",
+	"yes":              "Yes",
+	"no":               "No",
+	"not_rated":        "Not rated",
+	"empty":            "No collisions match this export.",
+}
+
+var germanLabels = map[string]string{
+	"title":            "Ideen-Kollisionen",
+	"exported":         "Exportiert",
+	"sessions":         "Sitzungen",
+	"id":               "ID",
+	"created":          "Erstellt",
+	"project":          "Projekt",
+	"project_type":     "Projekttyp",
+	"intensity":        "Intensität",
+	"interests":        "Interessen",
+	"primary_domain":   "Primäre Domäne",
+	"collision_domain": "Kollisionsdomäne",
+	"connection":       "Verbindung",
+	"spark_questions":  "Impulsfragen",
+	"examples":         "Beispiele",
+	"next_steps":       "Nächste Schritte",
+	"quality":          "Qualitätswert",
+	"rating":           "Bewertung",
+	"notes":            "Notizen",
+	"tags":             "Tags",
+	"favorite":         "Favorit",
+	"yes":              "Ja",
+	"no":               "Nein",
+	"not_rated":        "Nicht bewertet",
+	"empty":            "Keine Kollisionen entsprechen diesem Export.",
+}
+
+var frenchLabels = map[string]string{
+	"title":            "Collisions d'idées",
+	"exported":         "Exporté le",
+	"sessions":         "Sessions",
+	"id":               "ID",
+	"created":          "Créée le",
+	"project":          "Projet",
+	"project_type":     "Type de projet",
+	"intensity":        "Intensité",
+	"interests":        "Centres d'intérêt",
+	"primary_domain":   "Domaine principal",
+	"collision_domain": "Domaine de collision",
+	"connection":       "Connexion",
+	"spark_questions":  "Questions déclencheuses",
+	"examples":         "Exemples",
+	"next_steps":       "Prochaines étapes",
+	"quality":          "Score de qualité",
+	"rating":           "Note",
+	"notes":            "Notes",
+	"tags":             "Étiquettes",
+	"favorite":         "Favori",
+	"yes":              "Oui",
+	"no":               "Non",
+	"not_rated":        "Non notée",
+	"empty":            "Aucune collision ne correspond à cet export.",
+}
+
+var spanishLabels = map[string]string{
+	"title":            "Colisiones de ideas",
+	"exported":         "Exportado",
+	"sessions":         "Sesiones",
+	"id":               "ID",
+	"created":          "Creada",
+	"project":          "Proyecto",
+	"project_type":     "Tipo de proyecto",
+	"intensity":        "Intensidad",
+	"interests":        "Intereses",
+	"primary_domain":   "Dominio principal",
+	"collision_domain": "Dominio de colisión",
+	"connection":       "Conexión",
+	"spark_questions":  "Preguntas chispa",
+	"examples":         "Ejemplos",
+	"next_steps":       "Próximos pasos",
+	"quality":          "Puntuación de calidad",
+	"rating":           "Valoración",
+	"notes":            "Notas",
+	"tags":             "Etiquetas",
+	"favorite":         "Favorito",
+	"yes":              "Sí",
+	"no":               "No",
+	"not_rated":        "Sin valorar",
+	"empty":            "Ninguna colisión coincide con esta exportación.",
+}
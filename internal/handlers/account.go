@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/mail"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// emailChangeTTL is how long the link to confirm a new email address works
+const emailChangeTTL = 24 * time.Hour
+
+// AccountHandler manages the signed-in user's email, password and account
+type AccountHandler struct {
+	db        *database.PostgresDB
+	redis     *database.RedisClient
+	mailer    mail.Mailer
+	validator *validator.Validate
+	appURL    string
+	accessTTL time.Duration
+	guard     loginGuard
+}
+
+func NewAccountHandler(db *database.PostgresDB, redis *database.RedisClient, mailer mail.Mailer, appURL string, accessTTL time.Duration) *AccountHandler {
+	return &AccountHandler{
+		db:        db,
+		redis:     redis,
+		mailer:    mailer,
+		validator: validator.New(),
+		appURL:    strings.TrimRight(appURL, "/"),
+		accessTTL: accessTTL,
+		guard:     loginGuard{db: db, redis: redis},
+	}
+}
+
+// RequestEmailChange mails a confirmation link to a new email address. The
+// account keeps its current address until the link is used.
+func (h *AccountHandler) RequestEmailChange(c *fiber.Ctx) error {
+	var req models.ChangeEmailRequest
+	user, err := h.authenticate(c, &req, func() string { return req.Password })
+	if err != nil || user == nil {
+		return err
+	}
+
+	newEmail := normalizeEmail(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "email_unchanged",
+			Message: "The new email address is the same as the current one",
+			Code:    400,
+		})
+	}
+
+	existing, err := h.db.GetUserByEmail(newEmail)
+	if err != nil && err != sql.ErrNoRows {
+		return accountDatabaseError(c)
+	}
+	if existing != nil {
+		return emailTaken(c)
+	}
+
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return accountDatabaseError(c)
+	}
+
+	change := &models.EmailChange{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	}
+	if err := h.db.CreateEmailChange(change); err != nil {
+		return accountDatabaseError(c)
+	}
+
+	err = h.mailer.Send(mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Text: fmt.Sprintf("Confirm %s as the email address of your Idea Collision Engine account:\n\n%s/account/confirm-email?token=%s\n\nThe link works for 24 hours. If you didn't ask for this change, ignore this email.",
+			newEmail, h.appURL, token),
+	})
+	if err != nil {
+		log.Printf("Failed to send email change confirmation to %s: %v", newEmail, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "email_send_failed",
+			Message: "Failed to send the confirmation email",
+			Code:    502,
+		})
+	}
+
+	h.notify(user.Email, "Your email address is being changed",
+		fmt.Sprintf("Someone asked to change the email address of your Idea Collision Engine account to %s. If this wasn't you, change your password now.", newEmail))
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":       "Check the new address for a confirmation link",
+		"pending_email": newEmail,
+		"expires_at":    change.ExpiresAt,
+	})
+}
+
+// ConfirmEmailChange switches the account to its new email address. It only
+// needs the mailed token, so the link works from any device.
+func (h *AccountHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req models.VerifyEmailChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return invalidAccountRequest(c)
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	user, err := h.db.ConfirmEmailChange(auth.HashToken(req.Token))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_token",
+				Message: "This confirmation link is invalid or has expired",
+				Code:    400,
+			})
+		case database.ErrEmailTaken:
+			return emailTaken(c)
+		}
+		return accountDatabaseError(c)
+	}
+	invalidateCachedUser(h.redis, user.ID)
+
+	user.PasswordHash = ""
+	return c.JSON(fiber.Map{
+		"message": "Email address updated",
+		"user":    user,
+	})
+}
+
+// ChangePassword replaces the account password after checking the current one
+// and logs the account out everywhere, so a stolen session doesn't outlive it
+func (h *AccountHandler) ChangePassword(c *fiber.Ctx) error {
+	var req models.ChangePasswordRequest
+	user, err := h.authenticate(c, &req, func() string { return req.CurrentPassword })
+	if err != nil || user == nil {
+		return err
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "password_unchanged",
+			Message: "The new password must be different from the current one",
+			Code:    400,
+		})
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "hash_failed",
+			Message: "Failed to hash password",
+			Code:    500,
+		})
+	}
+
+	if err := h.db.UpdateUserPassword(user.ID, hashedPassword); err != nil {
+		return accountDatabaseError(c)
+	}
+
+	// Refresh tokens were revoked with the password; access tokens go too
+	if h.redis != nil {
+		if err := denyAccessTokens(h.redis, h.accessTTL, user.ID); err != nil {
+			log.Printf("Failed to revoke access tokens of user %s after a password change: %v", user.ID, err)
+		}
+	}
+
+	h.notify(user.Email, "Your password was changed",
+		"The password of your Idea Collision Engine account was just changed and every device was signed out. If this wasn't you, reset your password and contact support.")
+
+	return c.JSON(fiber.Map{
+		"message": "Password updated. Please sign in again.",
+	})
+}
+
+// DeleteAccount permanently deletes the account and everything in it
+func (h *AccountHandler) DeleteAccount(c *fiber.Ctx) error {
+	var req models.DeleteAccountRequest
+	user, err := h.authenticate(c, &req, func() string { return req.Password })
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err := h.db.DeleteUser(user.ID); err != nil {
+		return accountDatabaseError(c)
+	}
+
+	invalidateCachedUser(h.redis, user.ID)
+
+	h.notify(user.Email, "Your account was deleted",
+		"Your Idea Collision Engine account and all of its collisions have been deleted.")
+
+	return c.JSON(fiber.Map{
+		"message": "Account deleted",
+	})
+}
+
+// authenticate parses and validates the request body into req, loads the
+// signed-in user and checks the password returned by password. Wrong passwords
+// count toward the same limits as failed logins. If it writes an error
+// response it returns a nil user, along with the error from writing it.
+func (h *AccountHandler) authenticate(c *fiber.Ctx, req interface{}, password func() string) (*models.User, error) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.BodyParser(req); err != nil {
+		return nil, invalidAccountRequest(c)
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	user, err := h.db.GetUserByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "user_not_found",
+				Message: "User not found",
+				Code:    404,
+			})
+		}
+		return nil, accountDatabaseError(c)
+	}
+
+	email := strings.ToLower(user.Email)
+	if blocked := h.guard.blockedFor(c, email); blocked > 0 {
+		return nil, tooManyLoginAttempts(c, blocked)
+	}
+
+	if !auth.CheckPasswordHash(password(), user.PasswordHash) {
+		h.guard.recordFailure(c, email, user)
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   "invalid_password",
+			Message: "Password is incorrect",
+			Code:    401,
+		})
+	}
+	h.guard.clearFailures(email)
+
+	return user, nil
+}
+
+// invalidateCachedUser drops the cached row and usage of a user whose row
+// changed, so the change applies from the next request. The change is made by
+// then, so failures are only logged; the cache entries expire on their own.
+func invalidateCachedUser(redis *database.RedisClient, userID uuid.UUID) {
+	if redis == nil {
+		return
+	}
+	if err := redis.InvalidateUser(userID.String()); err != nil {
+		log.Printf("Failed to clear cached row of user %s: %v", userID, err)
+	}
+	if err := redis.InvalidateUserUsage(userID.String()); err != nil {
+		log.Printf("Failed to clear cached usage of user %s: %v", userID, err)
+	}
+}
+
+// notify sends a security notice about a change to the account. Notices are
+// best effort and never fail the change itself.
+func (h *AccountHandler) notify(to, subject, text string) {
+	if err := h.mailer.Send(mail.Message{To: to, Subject: subject, Text: text}); err != nil {
+		log.Printf("Failed to send account notice to %s: %v", to, err)
+	}
+}
+
+func invalidAccountRequest(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_request",
+		Message: "Invalid request body",
+		Code:    400,
+	})
+}
+
+func emailTaken(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "email_taken",
+		Message: "This email address is already in use",
+		Code:    409,
+	})
+}
+
+func accountDatabaseError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to update account",
+		Code:    500,
+	})
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/collision"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+type AdminHandler struct {
+	db               *database.PostgresDB
+	redis            *database.RedisClient
+	aiService        *collision.AIService
+	validator        *validator.Validate
+	onDomainsChanged func() error
+}
+
+func NewAdminHandler(db *database.PostgresDB, redis *database.RedisClient, aiService *collision.AIService) *AdminHandler {
+	return &AdminHandler{
+		db:        db,
+		redis:     redis,
+		aiService: aiService,
+		validator: validator.New(),
+	}
+}
+
+// OnDomainsChanged registers a callback run after the domain catalog changes,
+// e.g. to reload the collision engine
+func (h *AdminHandler) OnDomainsChanged(fn func() error) {
+	h.onDomainsChanged = fn
+}
+
+// ListPrompts returns every registered prompt template version
+func (h *AdminHandler) ListPrompts(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"prompts": h.aiService.Prompts().List(),
+	})
+}
+
+// PreviewPrompt renders a prompt for a given input and domain without calling the model
+func (h *AdminHandler) PreviewPrompt(c *fiber.Ctx) error {
+	type PreviewRequest struct {
+		Prompt  string                `json:"prompt" validate:"required"`
+		Version string                `json:"version,omitempty"`
+		Domain  string                `json:"domain" validate:"required"`
+		Input   models.CollisionInput `json:"input"`
+	}
+
+	var req PreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	domain, err := h.findDomain(req.Domain)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve collision domains",
+			Code:    500,
+		})
+	}
+	if domain == nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "domain_not_found",
+			Message: "Collision domain not found",
+			Code:    404,
+		})
+	}
+
+	rendered, err := h.aiService.PreviewPrompt(req.Prompt, req.Version, req.Input, *domain)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "prompt_render_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	return c.JSON(rendered)
+}
+
+// GetAIUsage returns daily AI usage for all users, or one user via ?user_id=.
+// Defaults to the last 30 days; from/to accept YYYY-MM-DD.
+func (h *AdminHandler) GetAIUsage(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_date",
+				Message: "from must be formatted as YYYY-MM-DD",
+				Code:    400,
+			})
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_date",
+				Message: "to must be formatted as YYYY-MM-DD",
+				Code:    400,
+			})
+		}
+	}
+
+	var userID *uuid.UUID
+	if value := c.Query("user_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_user_id",
+				Message: "Invalid user ID",
+				Code:    400,
+			})
+		}
+		userID = &id
+	}
+
+	daily, err := h.db.GetAIUsageSummary(userID, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve AI usage",
+			Code:    500,
+		})
+	}
+
+	totalCost := 0.0
+	totalTokens := 0
+	for _, day := range daily {
+		totalCost += day.CostUSD
+		totalTokens += day.PromptTokens + day.CompletionTokens
+	}
+
+	return c.JSON(fiber.Map{
+		"from":         from.Format("2006-01-02"),
+		"to":           to.Format("2006-01-02"),
+		"cost_usd":     totalCost,
+		"total_tokens": totalTokens,
+		"daily":        daily,
+	})
+}
+
+// ListDomainSuggestions returns AI-proposed domains, pending review by default.
+// ?status= filters by pending, approved, rejected or all; ?limit= caps the results.
+func (h *AdminHandler) ListDomainSuggestions(c *fiber.Ctx) error {
+	status := c.Query("status", models.SuggestionPending)
+	switch status {
+	case models.SuggestionPending, models.SuggestionApproved, models.SuggestionRejected:
+	case "all":
+		status = ""
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_status",
+			Message: "status must be pending, approved, rejected or all",
+			Code:    400,
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	suggestions, err := h.db.GetDomainSuggestions(status, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve domain suggestions",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"suggestions": suggestions,
+	})
+}
+
+// ApproveDomainSuggestion adds a suggested domain to the catalog. Editors can
+// correct any field of the proposal and choose the tier it is published in.
+func (h *AdminHandler) ApproveDomainSuggestion(c *fiber.Ctx) error {
+	type ApproveRequest struct {
+		Tier        string   `json:"tier" validate:"omitempty,oneof=basic premium"`
+		Notes       *string  `json:"notes,omitempty"`
+		Name        string   `json:"name,omitempty" validate:"max=100"`
+		Category    string   `json:"category,omitempty" validate:"max=50"`
+		Description string   `json:"description,omitempty"`
+		Keywords    []string `json:"keywords,omitempty"`
+		Examples    []string `json:"examples,omitempty"`
+		Intensity   []string `json:"intensity,omitempty" validate:"omitempty,dive,oneof=gentle moderate radical"`
+	}
+
+	reviewerID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	suggestionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_suggestion_id",
+			Message: "Invalid suggestion ID",
+			Code:    400,
+		})
+	}
+
+	var req ApproveRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid request body",
+				Code:    400,
+			})
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	suggestion, err := h.db.GetDomainSuggestion(suggestionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "suggestion_not_found",
+			Message: "Domain suggestion not found",
+			Code:    404,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve domain suggestion",
+			Code:    500,
+		})
+	}
+
+	domain := suggestion.Domain
+	if req.Name != "" {
+		domain.Name = req.Name
+	}
+	if req.Category != "" {
+		domain.Category = req.Category
+	}
+	if req.Description != "" {
+		domain.Description = req.Description
+	}
+	if len(req.Keywords) > 0 {
+		domain.Keywords = req.Keywords
+	}
+	if len(req.Examples) > 0 {
+		domain.Examples = req.Examples
+	}
+	if len(req.Intensity) > 0 {
+		domain.Intensity = req.Intensity
+	}
+
+	domain.ID = uuid.New().String()
+	domain.Tier = "premium"
+	if req.Tier != "" {
+		domain.Tier = req.Tier
+	}
+	domain.CreatedAt = time.Now()
+	domain.UpdatedAt = domain.CreatedAt
+
+	if existing, err := h.findDomain(domain.Name); err == nil && existing != nil {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "domain_exists",
+			Message: fmt.Sprintf("A domain named %q already exists", domain.Name),
+			Code:    409,
+		})
+	}
+
+	if err := h.db.ApproveDomainSuggestion(suggestionID, reviewerID, &domain, req.Notes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error:   "suggestion_reviewed",
+				Message: "Domain suggestion has already been reviewed",
+				Code:    409,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to approve domain suggestion",
+			Code:    500,
+		})
+	}
+
+	h.domainsChanged()
+
+	return c.JSON(domain)
+}
+
+// RejectDomainSuggestion marks a suggested domain as rejected
+func (h *AdminHandler) RejectDomainSuggestion(c *fiber.Ctx) error {
+	type RejectRequest struct {
+		Notes *string `json:"notes,omitempty"`
+	}
+
+	reviewerID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	suggestionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_suggestion_id",
+			Message: "Invalid suggestion ID",
+			Code:    400,
+		})
+	}
+
+	var req RejectRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid request body",
+				Code:    400,
+			})
+		}
+	}
+
+	if err := h.db.RejectDomainSuggestion(suggestionID, reviewerID, req.Notes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "suggestion_not_found",
+				Message: "No pending domain suggestion with this ID",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to reject domain suggestion",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Domain suggestion rejected",
+	})
+}
+
+// domainsChanged clears cached domain lists and notifies the registered callback
+func (h *AdminHandler) domainsChanged() {
+	h.redis.InvalidateCollisionDomains("basic")
+	h.redis.InvalidateCollisionDomains("premium")
+
+	if h.onDomainsChanged != nil {
+		if err := h.onDomainsChanged(); err != nil {
+			fmt.Printf("Failed to reload collision domains: %v\n", err)
+		}
+	}
+}
+
+// findDomain looks up a collision domain by name across all tiers
+func (h *AdminHandler) findDomain(name string) (*models.CollisionDomain, error) {
+	domains, err := h.db.GetCollisionDomains("premium")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, domain := range domains {
+		if domain.Name == name {
+			return &domain, nil
+		}
+	}
+
+	return nil, nil
+}
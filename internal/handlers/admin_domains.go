@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+// ListDomains returns the domain catalog, basic and premium
+func (h *AdminHandler) ListDomains(c *fiber.Ctx) error {
+	domains, err := h.db.GetCollisionDomains("premium")
+	if err != nil {
+		return adminDatabaseError(c, "Failed to retrieve collision domains")
+	}
+	if domains == nil {
+		domains = []models.CollisionDomain{}
+	}
+
+	return c.JSON(fiber.Map{
+		"domains": domains,
+		"count":   len(domains),
+	})
+}
+
+// CreateDomain adds a domain to the catalog. The engine picks it up straight away.
+func (h *AdminHandler) CreateDomain(c *fiber.Ctx) error {
+	var req models.AdminDomainRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	domain := domainFromRequest(req)
+	domain.ID = uuid.New().String()
+	domain.CreatedAt = time.Now()
+	domain.UpdatedAt = domain.CreatedAt
+
+	if ok, err := h.checkDomainName(c, domain); !ok {
+		return err
+	}
+
+	if err := h.db.CreateCollisionDomain(&domain); err != nil {
+		return adminDatabaseError(c, "Failed to create collision domain")
+	}
+
+	h.domainsChanged()
+	h.audit(c, uuid.Nil, models.AuditDomainCreated, map[string]interface{}{
+		"domain_id": domain.ID,
+		"name":      domain.Name,
+		"tier":      domain.Tier,
+		"reason":    req.Reason,
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(domain)
+}
+
+// UpdateDomain replaces a catalog domain
+func (h *AdminHandler) UpdateDomain(c *fiber.Ctx) error {
+	var req models.AdminDomainRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	existing, err := h.loadDomain(c)
+	if existing == nil {
+		return err
+	}
+
+	domain := domainFromRequest(req)
+	domain.ID = existing.ID
+
+	if ok, err := h.checkDomainName(c, domain); !ok {
+		return err
+	}
+
+	if err := h.db.UpdateCollisionDomain(&domain); err != nil {
+		if err == sql.ErrNoRows {
+			return domainNotFound(c)
+		}
+		return adminDatabaseError(c, "Failed to update collision domain")
+	}
+
+	h.domainsChanged()
+	h.audit(c, uuid.Nil, models.AuditDomainUpdated, map[string]interface{}{
+		"domain_id": domain.ID,
+		"name":      domain.Name,
+		"previous":  existing,
+		"reason":    req.Reason,
+	})
+
+	return c.JSON(domain)
+}
+
+// DeleteDomain removes a domain from the catalog. Past collisions keep its
+// name. ?reason= goes into the audit log.
+func (h *AdminHandler) DeleteDomain(c *fiber.Ctx) error {
+	domain, err := h.loadDomain(c)
+	if domain == nil {
+		return err
+	}
+
+	if err := h.db.DeleteCollisionDomain(domain.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return domainNotFound(c)
+		}
+		return adminDatabaseError(c, "Failed to delete collision domain")
+	}
+
+	h.domainsChanged()
+	h.audit(c, uuid.Nil, models.AuditDomainDeleted, map[string]interface{}{
+		"domain_id": domain.ID,
+		"previous":  domain,
+		"reason":    c.Query("reason"),
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Collision domain deleted",
+		"id":      domain.ID,
+	})
+}
+
+// loadDomain looks up the catalog domain in :id. If it writes an error
+// response it returns a nil domain, along with the error from writing it.
+func (h *AdminHandler) loadDomain(c *fiber.Ctx) (*models.CollisionDomain, error) {
+	domainID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, domainNotFound(c)
+	}
+
+	domain, err := h.db.GetCollisionDomain(domainID.String())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domainNotFound(c)
+		}
+		return nil, adminDatabaseError(c, "Failed to retrieve collision domain")
+	}
+
+	return domain, nil
+}
+
+// checkDomainName writes a conflict if another domain has the domain's name
+func (h *AdminHandler) checkDomainName(c *fiber.Ctx, domain models.CollisionDomain) (bool, error) {
+	existing, err := h.findDomain(domain.Name)
+	if err != nil {
+		return false, adminDatabaseError(c, "Failed to retrieve collision domains")
+	}
+	if existing != nil && existing.ID != domain.ID {
+		return false, c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "domain_exists",
+			Message: fmt.Sprintf("A domain named %q already exists", domain.Name),
+			Code:    409,
+		})
+	}
+	return true, nil
+}
+
+func domainFromRequest(req models.AdminDomainRequest) models.CollisionDomain {
+	examples := req.Examples
+	if examples == nil {
+		examples = []string{}
+	}
+
+	return models.CollisionDomain{
+		Name:        strings.TrimSpace(req.Name),
+		Category:    strings.TrimSpace(req.Category),
+		Description: strings.TrimSpace(req.Description),
+		Examples:    examples,
+		Keywords:    req.Keywords,
+		Intensity:   req.Intensity,
+		Tier:        req.Tier,
+	}
+}
+
+func domainNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "domain_not_found",
+		Message: "Collision domain not found",
+		Code:    404,
+	})
+}
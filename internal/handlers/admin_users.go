@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// adminUserAuditLimit is how many of a user's audit log entries come with
+// their account
+const adminUserAuditLimit = 20
+
+// SearchUsers finds users by ID, or by part of their email address or display
+// name (?q=). ?limit= caps the results.
+func (h *AdminHandler) SearchUsers(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return invalidAdminRequest(c, "q is required")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	users, err := h.db.SearchUsers(query, limit)
+	if err != nil {
+		return adminDatabaseError(c, "Failed to search users")
+	}
+
+	return c.JSON(fiber.Map{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// GetUser returns a user's account with their usage this week and their
+// latest audit log entries
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.loadUser(c)
+	if user == nil {
+		return err
+	}
+
+	usage, err := h.db.GetUserUsage(user.ID)
+	if err != nil {
+		return adminDatabaseError(c, "Failed to retrieve usage")
+	}
+
+	audit, err := h.db.GetAuditEntries(models.AuditFilter{UserID: &user.ID, Limit: adminUserAuditLimit})
+	if err != nil {
+		return adminDatabaseError(c, "Failed to retrieve audit log")
+	}
+
+	return c.JSON(fiber.Map{
+		"user":  user,
+		"usage": usage,
+		"audit": audit,
+	})
+}
+
+// SetUserTier overrides a user's subscription tier, e.g. to comp an account
+// or fix one a payment webhook missed. Billing changes later on replace it.
+func (h *AdminHandler) SetUserTier(c *fiber.Ctx) error {
+	var req models.AdminTierRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	user, err := h.loadUser(c)
+	if user == nil {
+		return err
+	}
+
+	previous := user.SubscriptionTier
+	if err := h.db.UpdateUserSubscriptionTier(user.ID, req.Tier); err != nil {
+		return adminDatabaseError(c, "Failed to update subscription tier")
+	}
+	user.SubscriptionTier = req.Tier
+
+	invalidateCachedUser(h.redis, user.ID)
+	h.audit(c, user.ID, models.AuditTierChanged, map[string]interface{}{
+		"from":   previous,
+		"to":     req.Tier,
+		"reason": req.Reason,
+	})
+
+	return c.JSON(user)
+}
+
+// SetUserRole changes a user's role. Admins can't change their own, so there
+// is always someone left to change it back.
+func (h *AdminHandler) SetUserRole(c *fiber.Ctx) error {
+	adminID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.AdminRoleRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	user, err := h.loadUser(c)
+	if user == nil {
+		return err
+	}
+	if user.ID == adminID {
+		return invalidAdminRequest(c, "You can't change your own role")
+	}
+
+	previous := user.Role
+	if err := h.db.UpdateUserRole(user.ID, req.Role); err != nil {
+		return adminDatabaseError(c, "Failed to update role")
+	}
+	user.Role = req.Role
+
+	invalidateCachedUser(h.redis, user.ID)
+	h.audit(c, user.ID, models.AuditRoleChanged, map[string]interface{}{
+		"from":   previous,
+		"to":     req.Role,
+		"reason": req.Reason,
+	})
+
+	return c.JSON(user)
+}
+
+// ResetUsage sets a user's collision count for this week back to zero
+func (h *AdminHandler) ResetUsage(c *fiber.Ctx) error {
+	var req models.AdminReasonRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	user, err := h.loadUser(c)
+	if user == nil {
+		return err
+	}
+
+	if err := h.db.ResetUserUsage(user.ID); err != nil {
+		return adminDatabaseError(c, "Failed to reset usage")
+	}
+	invalidateCachedUser(h.redis, user.ID)
+
+	usage, err := h.db.GetUserUsage(user.ID)
+	if err != nil {
+		return adminDatabaseError(c, "Failed to retrieve usage")
+	}
+
+	h.audit(c, user.ID, models.AuditUsageReset, map[string]interface{}{
+		"reason": req.Reason,
+	})
+
+	return c.JSON(usage)
+}
+
+// ListAuditLog returns audit log entries, newest first. ?user_id=,
+// ?actor_id= and ?action= filter them; ?limit= caps the results.
+func (h *AdminHandler) ListAuditLog(c *fiber.Ctx) error {
+	filter := models.AuditFilter{Action: c.Query("action")}
+
+	for param, dest := range map[string]**uuid.UUID{"user_id": &filter.UserID, "actor_id": &filter.ActorID} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return invalidAdminRequest(c, param+" must be a UUID")
+		}
+		*dest = &id
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	filter.Limit = limit
+
+	entries, err := h.db.GetAuditEntries(filter)
+	if err != nil {
+		return adminDatabaseError(c, "Failed to retrieve audit log")
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// loadUser looks up the user in :id. If it writes an error response it
+// returns a nil user, along with the error from writing it.
+func (h *AdminHandler) loadUser(c *fiber.Ctx) (*models.User, error) {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, adminUserNotFound(c)
+	}
+
+	user, err := h.db.GetUserByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, adminUserNotFound(c)
+		}
+		return nil, adminDatabaseError(c, "Failed to retrieve user")
+	}
+
+	return user, nil
+}
+
+// audit records an administrative change made by the signed-in staff member.
+// The change has been made by then, so failing to record it is only logged.
+func (h *AdminHandler) audit(c *fiber.Ctx, userID uuid.UUID, action string, details map[string]interface{}) {
+	entry := &models.AuditEntry{
+		Action:    action,
+		IPAddress: c.IP(),
+		Details:   details,
+	}
+	if userID != uuid.Nil {
+		entry.UserID = &userID
+	}
+	if actorID, err := middleware.GetUserIDFromContext(c); err == nil {
+		entry.ActorID = &actorID
+	}
+
+	if err := h.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write %s to the audit log: %v", action, err)
+	}
+}
+
+func (h *AdminHandler) parse(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, invalidAdminRequest(c, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	return true, nil
+}
+
+func invalidAdminRequest(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_request",
+		Message: message,
+		Code:    400,
+	})
+}
+
+func adminUserNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "user_not_found",
+		Message: "User not found",
+		Code:    404,
+	})
+}
+
+func adminDatabaseError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: message,
+		Code:    500,
+	})
+}
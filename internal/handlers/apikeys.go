@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// maxAPIKeysPerUser caps how many unrevoked API keys a user can hold
+const maxAPIKeysPerUser = 25
+
+// APIKeyHandler manages the signed-in user's personal API keys
+type APIKeyHandler struct {
+	db        *database.PostgresDB
+	validator *validator.Validate
+}
+
+func NewAPIKeyHandler(db *database.PostgresDB) *APIKeyHandler {
+	return &APIKeyHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// ListAPIKeys lists the user's API keys. Secrets are never shown again after
+// a key is created.
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.db.ListAPIKeys(userID)
+	if err != nil {
+		return apiKeyDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// CreateAPIKey creates an API key and returns it. This is the only time the
+// key itself is returned.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	existing, err := h.db.ListAPIKeys(userID)
+	if err != nil {
+		return apiKeyDatabaseError(c)
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "too_many_api_keys",
+			Message: "Revoke an API key before creating another",
+			Code:    409,
+		})
+	}
+
+	secret, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return tokenGenerationFailed(c)
+	}
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	key := &models.APIKey{
+		UserID:     userID,
+		Name:       req.Name,
+		Prefix:     prefix,
+		SecretHash: auth.HashToken(secret),
+		Scopes:     slices.Compact(scopes),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.CreateAPIKey(key); err != nil {
+		return apiKeyDatabaseError(c)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateAPIKeyResponse{
+		APIKey: *key,
+		Key:    secret,
+	})
+}
+
+// RevokeAPIKey revokes an API key. Requests with it fail straight away.
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	keyID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_api_key_id",
+			Message: "Invalid API key ID",
+			Code:    400,
+		})
+	}
+
+	if err := h.db.RevokeAPIKey(keyID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "api_key_not_found",
+				Message: "API key not found",
+				Code:    404,
+			})
+		}
+		return apiKeyDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "API key revoked",
+		"id":      keyID,
+	})
+}
+
+func apiKeyDatabaseError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to manage API keys",
+		Code:    500,
+	})
+}
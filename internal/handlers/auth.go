@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"database/sql"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -10,22 +12,53 @@ import (
 
 	"idea-collision-engine-api/internal/auth"
 	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/mail"
+	"idea-collision-engine-api/internal/middleware"
 	"idea-collision-engine-api/internal/models"
 )
 
+// authStore is the part of the database the auth handler uses
+type authStore interface {
+	CreateUser(user *models.User) error
+	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	UpdateUser(user *models.User) error
+	GetLibraryCounts(userID uuid.UUID) (*models.LibraryCounts, error)
+	CreateRefreshToken(token *models.RefreshToken) error
+	RotateRefreshToken(tokenHash string, next *models.RefreshToken) (*models.RefreshToken, error)
+	RevokeRefreshTokenFamily(tokenHash string, userID uuid.UUID) error
+	RevokeUserRefreshTokens(userID uuid.UUID) (int64, error)
+	ResetUserPassword(token *models.UsedActionToken, passwordHash string) error
+	VerifyUserEmail(token *models.UsedActionToken, email string) error
+	MarkEmailVerified(userID uuid.UUID, email string) error
+	GetUserByIdentity(provider, subject string) (*models.User, error)
+	LinkUserIdentity(identity *models.UserIdentity) error
+	ReclaimUserAccount(userID uuid.UUID, passwordHash string) error
+	CreateAuditEntry(entry *models.AuditEntry) error
+}
+
 type AuthHandler struct {
-	db         *database.PostgresDB
+	db         authStore
 	redis      *database.RedisClient
 	jwtService *auth.JWTService
 	validator  *validator.Validate
+	mailer     mail.Mailer
+	appURL     string
+	refreshTTL time.Duration
+	oidc       *auth.OIDCProvider
+	guard      loginGuard
 }
 
-func NewAuthHandler(db *database.PostgresDB, redis *database.RedisClient, jwtService *auth.JWTService) *AuthHandler {
+func NewAuthHandler(db *database.PostgresDB, redis *database.RedisClient, jwtService *auth.JWTService, mailer mail.Mailer, appURL string, refreshTTL time.Duration) *AuthHandler {
 	return &AuthHandler{
 		db:         db,
 		redis:      redis,
 		jwtService: jwtService,
 		validator:  validator.New(),
+		mailer:     mailer,
+		appURL:     strings.TrimRight(appURL, "/"),
+		refreshTTL: refreshTTL,
+		guard:      loginGuard{db: db, redis: redis},
 	}
 }
 
@@ -41,6 +74,8 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
+	req.Email = normalizeEmail(req.Email)
+	
 	if err := h.validator.Struct(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "validation_failed",
@@ -96,22 +131,25 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Generate token
-	token, err := h.jwtService.GenerateToken(user)
+	// The account works without it; the user can ask for another link
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+	
+	// Generate tokens
+	tokens, err := h.issueTokens(user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "token_generation_failed",
-			Message: "Failed to generate token",
-			Code:    500,
-		})
+		return tokenGenerationFailed(c)
 	}
 	
 	// Remove password hash from response
 	user.PasswordHash = ""
 	
 	return c.Status(fiber.StatusCreated).JSON(models.AuthResponse{
-		Token: token,
-		User:  *user,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
 	})
 }
 
@@ -127,6 +165,8 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 	
+	req.Email = normalizeEmail(req.Email)
+	
 	if err := h.validator.Struct(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "validation_failed",
@@ -135,16 +175,13 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 	
+	if blocked := h.guard.blockedFor(c, req.Email); blocked > 0 {
+		return tooManyLoginAttempts(c, blocked)
+	}
+	
 	// Get user by email
 	user, err := h.db.GetUserByEmail(req.Email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
-				Error:   "invalid_credentials",
-				Message: "Invalid email or password",
-				Code:    401,
-			})
-		}
+	if err != nil && err != sql.ErrNoRows {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "database_error",
 			Message: "Failed to retrieve user",
@@ -152,31 +189,261 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Verify password
-	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+	// Verify password. Unknown emails take as long and count toward the same
+	// limits, so neither timing nor lockouts reveal which accounts exist.
+	var valid bool
+	if user != nil {
+		valid = auth.CheckPasswordHash(req.Password, user.PasswordHash)
+	} else {
+		valid = auth.CheckPasswordAgainstDummy(req.Password)
+	}
+	if !valid {
+		h.guard.recordFailure(c, req.Email, user)
 		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
 			Error:   "invalid_credentials",
 			Message: "Invalid email or password",
 			Code:    401,
 		})
 	}
+	h.guard.clearFailures(req.Email)
 	
-	// Generate token
-	token, err := h.jwtService.GenerateToken(user)
+	// Generate tokens
+	tokens, err := h.issueTokens(user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error:   "token_generation_failed",
-			Message: "Failed to generate token",
-			Code:    500,
-		})
+		return tokenGenerationFailed(c)
 	}
 	
 	// Remove password hash from response
 	user.PasswordHash = ""
 	
 	return c.JSON(models.AuthResponse{
-		Token: token,
-		User:  *user,
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		User:         *user,
+	})
+}
+
+// normalizeEmail is the form email addresses are stored and looked up in.
+// Addresses are case-insensitive, so Alice@example.com and alice@example.com
+// are one account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once; presenting one again ends its login.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+	
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	
+	refreshToken, next, err := h.newRefreshToken()
+	if err != nil {
+		return tokenGenerationFailed(c)
+	}
+	
+	current, err := h.db.RotateRefreshToken(auth.HashToken(req.RefreshToken), next)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return invalidRefreshToken(c)
+	case database.ErrRefreshTokenReused:
+		// Someone holds a copy of a token that was already exchanged. Its family
+		// is revoked; also cut off access tokens already handed out, so only
+		// logins with an intact refresh token can get new ones.
+		log.Printf("Refresh token reuse detected for user %s, family %s", current.UserID, current.FamilyID)
+		if err := h.revokeAccessTokens(current.UserID); err != nil {
+			log.Printf("Failed to revoke access tokens of user %s after refresh token reuse: %v", current.UserID, err)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   "refresh_token_reused",
+			Message: "This refresh token was already used, so the session has been ended. Please sign in again.",
+			Code:    401,
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to refresh session",
+			Code:    500,
+		})
+	}
+	
+	user, err := h.db.GetUserByID(current.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return invalidRefreshToken(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve user",
+			Code:    500,
+		})
+	}
+	
+	token, err := h.jwtService.GenerateToken(user)
+	if err != nil {
+		return tokenGenerationFailed(c)
+	}
+	
+	return c.JSON(models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.jwtService.AccessTokenTTL().Seconds()),
+	})
+}
+
+// Logout ends the current session: the access token used for the request is
+// revoked, and so is the refresh token in the body if one is given
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+	
+	var req models.LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid request body",
+				Code:    400,
+			})
+		}
+	}
+	
+	if req.RefreshToken != "" {
+		if err := h.db.RevokeRefreshTokenFamily(auth.HashToken(req.RefreshToken), userID); err != nil {
+			return logoutFailed(c)
+		}
+	}
+	
+	// Tokens issued before they had an ID can't be denied individually; they
+	// simply run out
+	if tokenID, expiresAt := middleware.GetTokenFromContext(c); tokenID != "" {
+		if err := h.redis.DenyToken(tokenID, time.Until(expiresAt)); err != nil {
+			log.Printf("Failed to deny access token of user %s: %v", userID, err)
+			return logoutFailed(c)
+		}
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Logged out",
+	})
+}
+
+// LogoutAll ends every session of the user, on every device
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uuid.UUID)
+	
+	revoked, err := h.db.RevokeUserRefreshTokens(userID)
+	if err != nil {
+		return logoutFailed(c)
+	}
+	
+	if err := h.revokeAccessTokens(userID); err != nil {
+		log.Printf("Failed to revoke access tokens of user %s: %v", userID, err)
+		return logoutFailed(c)
+	}
+	
+	return c.JSON(fiber.Map{
+		"message":          "Logged out everywhere",
+		"sessions_revoked": revoked,
+	})
+}
+
+// issueTokens generates an access token and starts a new refresh token family
+func (h *AuthHandler) issueTokens(user *models.User) (*models.TokenResponse, error) {
+	refreshToken, record, err := h.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	record.UserID = user.ID
+	record.FamilyID = uuid.New()
+	
+	if err := h.db.CreateRefreshToken(record); err != nil {
+		return nil, err
+	}
+	
+	token, err := h.jwtService.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.jwtService.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// newRefreshToken generates a refresh token and the record to store for it.
+// The caller fills in the record's user and family.
+func (h *AuthHandler) newRefreshToken() (string, *models.RefreshToken, error) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	
+	return token, &models.RefreshToken{
+		ID:        uuid.New(),
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(h.refreshTTL),
+	}, nil
+}
+
+// revokeAccessTokens denies every access token the user currently holds
+func (h *AuthHandler) revokeAccessTokens(userID uuid.UUID) error {
+	return denyAccessTokens(h.redis, h.jwtService.AccessTokenTTL(), userID)
+}
+
+// denyAccessTokens denies every access token the user currently holds. The
+// cutoff is the start of the next second, since token issue times only have
+// second precision. Callers log failures, since until the tokens expire they
+// still work.
+func denyAccessTokens(redis *database.RedisClient, accessTTL time.Duration, userID uuid.UUID) error {
+	cutoff := time.Now().Truncate(time.Second).Add(time.Second)
+	return redis.RevokeTokensIssuedBefore(userID.String(), cutoff, accessTTL+time.Second)
+}
+
+// JWKS publishes the public keys that validate our access tokens
+func (h *AuthHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.jwtService.JWKS())
+}
+
+func tokenGenerationFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "token_generation_failed",
+		Message: "Failed to generate token",
+		Code:    500,
+	})
+}
+
+func invalidRefreshToken(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+		Error:   "invalid_refresh_token",
+		Message: "Refresh token is invalid or has expired",
+		Code:    401,
+	})
+}
+
+func logoutFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "logout_failed",
+		Message: "Failed to log out",
+		Code:    500,
 	})
 }
 
@@ -196,18 +463,26 @@ func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 	// Remove password hash from response
 	user.PasswordHash = ""
 	
-	return c.JSON(user)
+	// Library counts are informational; leave them out rather than fail the profile
+	counts, err := h.db.GetLibraryCounts(userID)
+	if err != nil {
+		log.Printf("Failed to load library counts for %s: %v", userID, err)
+	}
+	
+	return c.JSON(profileResponse{User: user, Library: counts})
 }
 
-// UpdateProfile updates user profile information
+// profileResponse is a user with how they've organized their collisions
+type profileResponse struct {
+	*models.User
+	Library *models.LibraryCounts `json:"library,omitempty"`
+}
+
+// UpdateProfile updates the user's display name and interests
 func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uuid.UUID)
 	
-	type UpdateRequest struct {
-		Interests []string `json:"interests"`
-	}
-	
-	var req UpdateRequest
+	var req models.UpdateProfileRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Error:   "invalid_request",
@@ -216,6 +491,14 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 	
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	
 	// Get current user
 	user, err := h.db.GetUserByID(userID)
 	if err != nil {
@@ -226,14 +509,39 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Update interests
-	user.Interests = req.Interests
-	user.UpdatedAt = time.Now()
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Interests != nil {
+		user.Interests = normalizeInterests(req.Interests)
+	}
 	
-	// Note: This would typically use an UpdateUser method
-	// For now, we'll return the updated user without persisting
-	// You'd need to implement UpdateUser in database layer
+	if err := h.db.UpdateUser(user); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "profile_update_failed",
+			Message: "Failed to update profile",
+			Code:    500,
+		})
+	}
+	
+	invalidateCachedUser(h.redis, userID)
 	
 	user.PasswordHash = ""
 	return c.JSON(user)
-}
\ No newline at end of file
+}
+
+// normalizeInterests trims interests and drops blanks and case-insensitive duplicates
+func normalizeInterests(interests []string) []string {
+	seen := make(map[string]bool, len(interests))
+	normalized := make([]string, 0, len(interests))
+	for _, interest := range interests {
+		interest = strings.TrimSpace(interest)
+		key := strings.ToLower(interest)
+		if interest == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, interest)
+	}
+	return normalized
+}
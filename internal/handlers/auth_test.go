@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/mail"
+	"idea-collision-engine-api/internal/models"
+)
+
+// Mock auth store
+type MockAuthStore struct {
+	mock.Mock
+}
+
+func (m *MockAuthStore) CreateUser(user *models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) GetUserByID(id uuid.UUID) (*models.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockAuthStore) GetUserByEmail(email string) (*models.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockAuthStore) UpdateUser(user *models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) GetLibraryCounts(userID uuid.UUID) (*models.LibraryCounts, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*models.LibraryCounts), args.Error(1)
+}
+
+func (m *MockAuthStore) CreateRefreshToken(token *models.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) RotateRefreshToken(tokenHash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	args := m.Called(tokenHash, next)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RefreshToken), args.Error(1)
+}
+
+func (m *MockAuthStore) RevokeRefreshTokenFamily(tokenHash string, userID uuid.UUID) error {
+	args := m.Called(tokenHash, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) RevokeUserRefreshTokens(userID uuid.UUID) (int64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAuthStore) ResetUserPassword(token *models.UsedActionToken, passwordHash string) error {
+	args := m.Called(token, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) VerifyUserEmail(token *models.UsedActionToken, email string) error {
+	args := m.Called(token, email)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) MarkEmailVerified(userID uuid.UUID, email string) error {
+	args := m.Called(userID, email)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) GetUserByIdentity(provider, subject string) (*models.User, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockAuthStore) LinkUserIdentity(identity *models.UserIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) ReclaimUserAccount(userID uuid.UUID, passwordHash string) error {
+	args := m.Called(userID, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockAuthStore) CreateAuditEntry(entry *models.AuditEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+type AuthHandlerTestSuite struct {
+	suite.Suite
+	app     *fiber.App
+	handler *AuthHandler
+	mockDB  *MockAuthStore
+}
+
+func (suite *AuthHandlerTestSuite) SetupTest() {
+	suite.mockDB = &MockAuthStore{}
+
+	suite.handler = NewAuthHandler(nil, nil, auth.NewJWTService("test-secret"), mail.NewLogMailer(), "http://localhost:3000", 30*24*time.Hour)
+	suite.handler.db = suite.mockDB
+
+	suite.app = fiber.New()
+	suite.app.Post("/auth/register", suite.handler.Register)
+	suite.app.Post("/auth/login", suite.handler.Login)
+}
+
+func (suite *AuthHandlerTestSuite) TestRegisterMixedCaseEmailThenLogin() {
+	var registered *models.User
+	suite.mockDB.On("GetUserByEmail", "alice@example.com").Return(nil, sql.ErrNoRows).Once()
+	suite.mockDB.On("CreateUser", mock.AnythingOfType("*models.User")).Run(func(args mock.Arguments) {
+		user := *args.Get(0).(*models.User)
+		registered = &user
+	}).Return(nil)
+	suite.mockDB.On("CreateRefreshToken", mock.AnythingOfType("*models.RefreshToken")).Return(nil)
+
+	resp, err := suite.app.Test(suite.jsonRequest("/auth/register", map[string]interface{}{
+		"email":    " Alice@Example.com ",
+		"password": "correct-horse",
+	}))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	if !assert.NotNil(suite.T(), registered) {
+		return
+	}
+	assert.Equal(suite.T(), "alice@example.com", registered.Email)
+
+	suite.mockDB.On("GetUserByEmail", "alice@example.com").Return(registered, nil).Once()
+
+	resp, err = suite.app.Test(suite.jsonRequest("/auth/login", map[string]interface{}{
+		"email":    "ALICE@example.COM",
+		"password": "correct-horse",
+	}))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	var result models.AuthResponse
+	assert.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(suite.T(), registered.ID, result.User.ID)
+	assert.NotEmpty(suite.T(), result.Token)
+
+	suite.mockDB.AssertExpectations(suite.T())
+}
+
+func (suite *AuthHandlerTestSuite) TestRegisterRejectsEmailInAnotherCase() {
+	existing := &models.User{ID: uuid.New(), Email: "alice@example.com"}
+	suite.mockDB.On("GetUserByEmail", "alice@example.com").Return(existing, nil)
+
+	resp, err := suite.app.Test(suite.jsonRequest("/auth/register", map[string]interface{}{
+		"email":    "Alice@example.com",
+		"password": "correct-horse",
+	}))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, resp.StatusCode)
+
+	suite.mockDB.AssertNotCalled(suite.T(), "CreateUser", mock.Anything)
+}
+
+func (suite *AuthHandlerTestSuite) jsonRequest(path string, body interface{}) *http.Request {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestAuthHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthHandlerTestSuite))
+}
@@ -0,0 +1,685 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+	"idea-collision-engine-api/internal/realtime"
+)
+
+// BoardHandler manages a team's boards: the collisions members put on them,
+// the comments, reactions and votes on those, and the team's activity feed.
+// Every route goes through middleware.RequireTeamRole, so any member can read
+// and contribute; changing or removing someone else's work takes an admin.
+// Every change is published through Redis to members who have the boards open.
+type BoardHandler struct {
+	db        *database.PostgresDB
+	redis     *database.RedisClient
+	validator *validator.Validate
+}
+
+func NewBoardHandler(db *database.PostgresDB, redis *database.RedisClient) *BoardHandler {
+	return &BoardHandler{
+		db:        db,
+		redis:     redis,
+		validator: validator.New(),
+	}
+}
+
+// ListBoards lists the team's boards, newest first
+func (h *BoardHandler) ListBoards(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	boards, err := h.db.GetBoards(member.TeamID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"boards": boards,
+		"count":  len(boards),
+	})
+}
+
+// CreateBoard creates a board for the team
+func (h *BoardHandler) CreateBoard(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CreateBoardRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return invalidTeamRequest(c, "Board name can't be blank")
+	}
+
+	board := &models.Board{
+		TeamID:      member.TeamID,
+		Name:        name,
+		Description: req.Description,
+		CreatedBy:   &member.UserID,
+		Items:       []models.BoardItem{},
+	}
+	if err := h.db.CreateBoard(board); err != nil {
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, nil, models.ActivityBoardCreated, map[string]interface{}{"name": board.Name})
+	return c.Status(fiber.StatusCreated).JSON(board)
+}
+
+// GetBoard returns a board with its items, each with its session, tallies and
+// the caller's own vote and reactions
+func (h *BoardHandler) GetBoard(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return err
+	}
+
+	board.Items, err = h.db.GetBoardItems(board.ID, member.UserID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	return c.JSON(board)
+}
+
+// UpdateBoard renames a board or changes its description. Only whoever
+// created it and team admins can.
+func (h *BoardHandler) UpdateBoard(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return err
+	}
+	if !ownsOrAdministers(member, board.CreatedBy) {
+		return insufficientTeamRole(c, "Only whoever created the board and team admins can change it")
+	}
+
+	var req models.UpdateBoardRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return invalidTeamRequest(c, "Board name can't be blank")
+		}
+		req.Name = &name
+	}
+
+	if err := h.db.UpdateBoard(board.ID, member.TeamID, req.Name, req.Description); err != nil {
+		return boardDatabaseError(c)
+	}
+
+	board, err = h.db.GetBoard(board.ID, member.TeamID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, nil, models.ActivityBoardUpdated, map[string]interface{}{"name": board.Name})
+	return c.JSON(board)
+}
+
+// DeleteBoard deletes a board along with its comments, reactions and votes.
+// The sessions on it stay with the members who ran them. Only whoever created
+// it and team admins can.
+func (h *BoardHandler) DeleteBoard(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return err
+	}
+	if !ownsOrAdministers(member, board.CreatedBy) {
+		return insufficientTeamRole(c, "Only whoever created the board and team admins can delete it")
+	}
+
+	if err := h.db.DeleteBoard(board.ID, member.TeamID); err != nil {
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, nil, models.ActivityBoardDeleted, map[string]interface{}{"name": board.Name})
+	return c.JSON(fiber.Map{
+		"message": "Board deleted",
+		"id":      board.ID,
+	})
+}
+
+// GetShortlist returns the board's items with votes, most votes first
+// (?limit=, up to 50)
+func (h *BoardHandler) GetShortlist(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return err
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	items, err := h.db.GetBoardShortlist(board.ID, member.UserID, limit)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"board_id": board.ID,
+		"items":    items,
+		"count":    len(items),
+	})
+}
+
+// AddItem puts one of the caller's collision sessions on the board
+func (h *BoardHandler) AddItem(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return err
+	}
+
+	var req models.AddBoardItemRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	item := &models.BoardItem{
+		BoardID:     board.ID,
+		SessionID:   req.SessionID,
+		AddedBy:     member.UserID,
+		Note:        req.Note,
+		Reactions:   map[string]int{},
+		MyReactions: []string{},
+	}
+	if err := h.db.AddBoardItem(item); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return sessionNotFound(c)
+		case database.ErrBoardItemExists:
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error:   "already_on_board",
+				Message: "This session is already on the board",
+				Code:    409,
+			})
+		}
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, &item.ID, models.ActivityItemAdded, map[string]interface{}{"session_id": item.SessionID})
+	return c.Status(fiber.StatusCreated).JSON(item)
+}
+
+// RemoveItem takes an item off the board. Members can take off what they
+// added; admins can take off anything.
+func (h *BoardHandler) RemoveItem(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+	if !ownsOrAdministers(member, &item.AddedBy) {
+		return insufficientTeamRole(c, "Only whoever added the item and team admins can remove it")
+	}
+
+	if err := h.db.RemoveBoardItem(item.ID, board.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return boardItemNotFound(c)
+		}
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, &item.ID, models.ActivityItemRemoved, map[string]interface{}{"session_id": item.SessionID})
+	return c.JSON(fiber.Map{
+		"message": "Item removed from the board",
+		"id":      item.ID,
+	})
+}
+
+// Vote casts the caller's vote for an item. Each member has one vote per item.
+func (h *BoardHandler) Vote(c *fiber.Ctx) error {
+	return h.setVote(c, true)
+}
+
+// Unvote withdraws the caller's vote for an item
+func (h *BoardHandler) Unvote(c *fiber.Ctx) error {
+	return h.setVote(c, false)
+}
+
+func (h *BoardHandler) setVote(c *fiber.Ctx, vote bool) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	count, err := h.db.SetBoardVote(item.ID, member.UserID, vote)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	if vote {
+		h.record(member, &board.ID, &item.ID, models.ActivityVoteCast, map[string]interface{}{"vote_count": count})
+	} else {
+		h.publish(member, &board.ID, &item.ID, models.EventVoteWithdrawn, map[string]interface{}{"vote_count": count})
+	}
+	return c.JSON(fiber.Map{
+		"item_id":    item.ID,
+		"voted":      vote,
+		"vote_count": count,
+	})
+}
+
+// React adds one of models.BoardReactions from the caller to an item
+func (h *BoardHandler) React(c *fiber.Ctx) error {
+	return h.setReaction(c, true)
+}
+
+// Unreact takes back one of the caller's reactions to an item
+func (h *BoardHandler) Unreact(c *fiber.Ctx) error {
+	return h.setReaction(c, false)
+}
+
+func (h *BoardHandler) setReaction(c *fiber.Ctx, react bool) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	reaction := c.Params("reaction")
+	if !slices.Contains(models.BoardReactions, reaction) {
+		return invalidTeamRequest(c, "Reaction must be one of: "+strings.Join(models.BoardReactions, ", "))
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	if err := h.db.SetBoardReaction(item.ID, member.UserID, reaction, react); err != nil {
+		return boardDatabaseError(c)
+	}
+
+	if react {
+		h.record(member, &board.ID, &item.ID, models.ActivityReactionAdded, map[string]interface{}{"reaction": reaction})
+	} else {
+		h.publish(member, &board.ID, &item.ID, models.EventReactionRemoved, map[string]interface{}{"reaction": reaction})
+	}
+	return c.JSON(fiber.Map{
+		"item_id":  item.ID,
+		"reaction": reaction,
+		"reacted":  react,
+	})
+}
+
+// ListComments returns the comments on an item, oldest first
+func (h *BoardHandler) ListComments(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	_, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	comments, err := h.db.GetBoardComments(item.ID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"item_id":  item.ID,
+		"comments": comments,
+		"count":    len(comments),
+	})
+}
+
+// AddComment comments on an item
+func (h *BoardHandler) AddComment(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	body, ok, err := h.parseComment(c)
+	if !ok {
+		return err
+	}
+
+	comment := &models.BoardComment{
+		ItemID: item.ID,
+		UserID: &member.UserID,
+		Body:   body,
+	}
+	if err := h.db.CreateBoardComment(comment); err != nil {
+		return boardDatabaseError(c)
+	}
+
+	comment, err = h.db.GetBoardComment(comment.ID, item.ID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	h.record(member, &board.ID, &item.ID, models.ActivityCommentAdded, map[string]interface{}{"comment_id": comment.ID})
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+// UpdateComment edits one of the caller's comments
+func (h *BoardHandler) UpdateComment(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	commentID, err := uuid.Parse(c.Params("commentId"))
+	if err != nil {
+		return boardCommentNotFound(c)
+	}
+
+	body, ok, err := h.parseComment(c)
+	if !ok {
+		return err
+	}
+
+	if err := h.db.UpdateBoardComment(commentID, item.ID, member.UserID, body); err != nil {
+		if err == sql.ErrNoRows {
+			return boardCommentNotFound(c)
+		}
+		return boardDatabaseError(c)
+	}
+
+	comment, err := h.db.GetBoardComment(commentID, item.ID)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	h.publish(member, &board.ID, &item.ID, models.EventCommentUpdated, map[string]interface{}{"comment_id": comment.ID})
+	return c.JSON(comment)
+}
+
+// DeleteComment deletes a comment. Members can delete their own; admins can
+// delete anyone's.
+func (h *BoardHandler) DeleteComment(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	board, item, err := h.loadItem(c, member)
+	if item == nil {
+		return err
+	}
+
+	commentID, err := uuid.Parse(c.Params("commentId"))
+	if err != nil {
+		return boardCommentNotFound(c)
+	}
+
+	comment, err := h.db.GetBoardComment(commentID, item.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return boardCommentNotFound(c)
+		}
+		return boardDatabaseError(c)
+	}
+	if !ownsOrAdministers(member, comment.UserID) {
+		return insufficientTeamRole(c, "Only the author and team admins can delete a comment")
+	}
+
+	if err := h.db.DeleteBoardComment(comment.ID, item.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return boardCommentNotFound(c)
+		}
+		return boardDatabaseError(c)
+	}
+
+	h.publish(member, &board.ID, &item.ID, models.EventCommentDeleted, map[string]interface{}{"comment_id": comment.ID})
+	return c.JSON(fiber.Map{
+		"message": "Comment deleted",
+		"id":      comment.ID,
+	})
+}
+
+// GetActivity returns the team's activity feed, newest first (?board_id= to
+// narrow it to one board, ?limit= up to 100)
+func (h *BoardHandler) GetActivity(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var boardID *uuid.UUID
+	if raw := c.Query("board_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return invalidTeamRequest(c, "Invalid board ID")
+		}
+		boardID = &id
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	feed, err := h.db.GetBoardActivity(member.TeamID, boardID, limit)
+	if err != nil {
+		return boardDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"team_id":  member.TeamID,
+		"activity": feed,
+		"count":    len(feed),
+	})
+}
+
+// loadBoard looks up the board in :boardId on the member's team. If it writes
+// an error response it returns a nil board, along with the error from writing it.
+func (h *BoardHandler) loadBoard(c *fiber.Ctx, member *models.TeamMember) (*models.Board, error) {
+	boardID, err := uuid.Parse(c.Params("boardId"))
+	if err != nil {
+		return nil, boardNotFound(c)
+	}
+
+	board, err := h.db.GetBoard(boardID, member.TeamID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, boardNotFound(c)
+		}
+		return nil, boardDatabaseError(c)
+	}
+
+	return board, nil
+}
+
+// loadItem looks up the board in :boardId and its item in :itemId, like
+// loadBoard
+func (h *BoardHandler) loadItem(c *fiber.Ctx, member *models.TeamMember) (*models.Board, *models.BoardItem, error) {
+	board, err := h.loadBoard(c, member)
+	if board == nil {
+		return nil, nil, err
+	}
+
+	itemID, err := uuid.Parse(c.Params("itemId"))
+	if err != nil {
+		return nil, nil, boardItemNotFound(c)
+	}
+
+	item, err := h.db.GetBoardItem(itemID, board.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, boardItemNotFound(c)
+		}
+		return nil, nil, boardDatabaseError(c)
+	}
+
+	return board, item, nil
+}
+
+// record adds an entry to the team's activity feed and publishes it. The feed
+// is a courtesy, so failing to write it doesn't fail the request.
+func (h *BoardHandler) record(member *models.TeamMember, boardID, itemID *uuid.UUID, action string, details map[string]interface{}) {
+	activity := &models.BoardActivity{
+		TeamID:  member.TeamID,
+		BoardID: boardID,
+		ItemID:  itemID,
+		UserID:  &member.UserID,
+		Action:  action,
+		Details: details,
+	}
+	if err := h.db.RecordBoardActivity(activity); err != nil {
+		log.Printf("Failed to record board activity %s: %v", action, err)
+	}
+
+	h.publish(member, boardID, itemID, action, details)
+}
+
+// publish pushes a change to members who have the team's boards open, and
+// keeps it for those who reconnect. Like the feed, it's best effort.
+func (h *BoardHandler) publish(member *models.TeamMember, boardID, itemID *uuid.UUID, eventType string, data map[string]interface{}) {
+	if h.redis == nil {
+		return
+	}
+
+	event := &models.BoardEvent{
+		Type:      eventType,
+		TeamID:    member.TeamID,
+		BoardID:   boardID,
+		ItemID:    itemID,
+		UserID:    &member.UserID,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	if err := h.redis.AppendBoardEvent(event, realtime.ReplayLength, realtime.ReplayRetention); err != nil {
+		log.Printf("Failed to publish board event %s: %v", eventType, err)
+	}
+}
+
+func (h *BoardHandler) parse(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, invalidTeamRequest(c, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	return true, nil
+}
+
+func (h *BoardHandler) parseComment(c *fiber.Ctx) (string, bool, error) {
+	var req models.BoardCommentRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return "", false, err
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return "", false, invalidTeamRequest(c, "Comment can't be blank")
+	}
+	return body, true, nil
+}
+
+// ownsOrAdministers reports whether the member is ownerID or a team admin
+func ownsOrAdministers(member *models.TeamMember, ownerID *uuid.UUID) bool {
+	if ownerID != nil && *ownerID == member.UserID {
+		return true
+	}
+	return models.TeamRoleRanks[member.Role] >= models.TeamRoleRanks[models.TeamRoleAdmin]
+}
+
+func boardNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "board_not_found",
+		Message: "Board not found",
+		Code:    404,
+	})
+}
+
+func boardItemNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "board_item_not_found",
+		Message: "No such item on this board",
+		Code:    404,
+	})
+}
+
+func boardCommentNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "comment_not_found",
+		Message: "No such comment on this item",
+		Code:    404,
+	})
+}
+
+func boardDatabaseError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to manage board",
+		Code:    500,
+	})
+}
@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// CollectionHandler lets users organize their collision sessions with favorites,
+// tags and collections
+type CollectionHandler struct {
+	db        *database.PostgresDB
+	validator *validator.Validate
+}
+
+func NewCollectionHandler(db *database.PostgresDB) *CollectionHandler {
+	return &CollectionHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// FavoriteCollision stars a collision session
+func (h *CollectionHandler) FavoriteCollision(c *fiber.Ctx) error {
+	return h.setFavorite(c, true)
+}
+
+// UnfavoriteCollision removes the star from a collision session
+func (h *CollectionHandler) UnfavoriteCollision(c *fiber.Ctx) error {
+	return h.setFavorite(c, false)
+}
+
+func (h *CollectionHandler) setFavorite(c *fiber.Ctx, favorite bool) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	if err := h.db.SetCollisionFavorite(sessionID, userID, favorite); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "favorite_failed",
+			Message: "Failed to update favorite",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":          sessionID,
+		"is_favorite": favorite,
+	})
+}
+
+// SetCollisionTags replaces the tags of a collision session
+func (h *CollectionHandler) SetCollisionTags(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	type TagsRequest struct {
+		Tags []string `json:"tags"`
+	}
+
+	var req TagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	if err := h.db.SetCollisionTags(sessionID, userID, tags); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "tagging_failed",
+			Message: "Failed to save tags",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":   sessionID,
+		"tags": tags,
+	})
+}
+
+// ListTags returns the user's tags with usage counts
+func (h *CollectionHandler) ListTags(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	tags, err := h.db.GetUserTags(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "tags_fetch_failed",
+			Message: "Failed to fetch tags",
+			Code:    500,
+		})
+	}
+
+	if tags == nil {
+		tags = []models.TagCount{}
+	}
+
+	return c.JSON(fiber.Map{
+		"tags": tags,
+	})
+}
+
+// ListCollections returns the user's collections without their sessions
+func (h *CollectionHandler) ListCollections(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collections, err := h.db.GetCollections(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collections_fetch_failed",
+			Message: "Failed to fetch collections",
+			Code:    500,
+		})
+	}
+
+	if collections == nil {
+		collections = []models.Collection{}
+	}
+
+	return c.JSON(fiber.Map{
+		"collections": collections,
+	})
+}
+
+type collectionRequest struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string `json:"description" validate:"omitempty,max=1000"`
+}
+
+// CreateCollection creates an empty collection
+func (h *CollectionHandler) CreateCollection(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := h.parseCollectionRequest(c)
+	if req == nil {
+		return err
+	}
+
+	if req.Name == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: "name is required",
+			Code:    400,
+		})
+	}
+
+	collection := &models.Collection{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        *req.Name,
+		Description: req.Description,
+		Sessions:    []models.CollisionSession{},
+	}
+
+	if err := h.db.CreateCollection(collection); err != nil {
+		if err == database.ErrCollectionNameTaken {
+			return collectionNameTaken(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_create_failed",
+			Message: "Failed to create collection",
+			Code:    500,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collection)
+}
+
+// GetCollection returns a collection with its sessions in order
+func (h *CollectionHandler) GetCollection(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	collection, err := h.db.GetCollection(collectionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return collectionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_fetch_failed",
+			Message: "Failed to fetch collection",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(collection)
+}
+
+// UpdateCollection renames a collection and/or changes its description
+func (h *CollectionHandler) UpdateCollection(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	req, err := h.parseCollectionRequest(c)
+	if req == nil {
+		return err
+	}
+
+	if err := h.db.UpdateCollection(collectionID, userID, req.Name, req.Description); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return collectionNotFound(c)
+		case database.ErrCollectionNameTaken:
+			return collectionNameTaken(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_update_failed",
+			Message: "Failed to update collection",
+			Code:    500,
+		})
+	}
+
+	return h.GetCollection(c)
+}
+
+// DeleteCollection deletes a collection; its sessions are kept
+func (h *CollectionHandler) DeleteCollection(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	if err := h.db.DeleteCollection(collectionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return collectionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_delete_failed",
+			Message: "Failed to delete collection",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Collection deleted",
+	})
+}
+
+// AddCollectionItem appends a session to a collection
+func (h *CollectionHandler) AddCollectionItem(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	type AddRequest struct {
+		SessionID uuid.UUID `json:"session_id"`
+	}
+
+	var req AddRequest
+	if err := c.BodyParser(&req); err != nil || req.SessionID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "session_id is required",
+			Code:    400,
+		})
+	}
+
+	if err := h.db.AddToCollection(collectionID, req.SessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Collection or collision session not found",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_update_failed",
+			Message: "Failed to add session to collection",
+			Code:    500,
+		})
+	}
+
+	return h.GetCollection(c)
+}
+
+// RemoveCollectionItem takes a session out of a collection
+func (h *CollectionHandler) RemoveCollectionItem(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	sessionID, err := uuid.Parse(c.Params("sessionId"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	if err := h.db.RemoveFromCollection(collectionID, sessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Collection not found or session not in it",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_update_failed",
+			Message: "Failed to remove session from collection",
+			Code:    500,
+		})
+	}
+
+	return h.GetCollection(c)
+}
+
+// ReorderCollection sets the order of the sessions in a collection
+func (h *CollectionHandler) ReorderCollection(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	collectionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidCollectionID(c)
+	}
+
+	type ReorderRequest struct {
+		SessionIDs []uuid.UUID `json:"session_ids"`
+	}
+
+	var req ReorderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if err := h.db.ReorderCollection(collectionID, userID, req.SessionIDs); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return collectionNotFound(c)
+		case database.ErrCollectionOrderMismatch:
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "validation_failed",
+				Message: "session_ids must list every session in the collection exactly once",
+				Code:    400,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collection_update_failed",
+			Message: "Failed to reorder collection",
+			Code:    500,
+		})
+	}
+
+	return h.GetCollection(c)
+}
+
+// parseCollectionRequest reads and validates a collection body. When the body is
+// invalid it writes the error response and returns a nil request.
+func (h *CollectionHandler) parseCollectionRequest(c *fiber.Ctx) (*collectionRequest, error) {
+	var req collectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		req.Name = &name
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	return &req, nil
+}
+
+// normalizeTags lowercases and trims tags, dropping duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > models.MaxTagsPerSession {
+		return nil, fmt.Errorf("a session can have at most %d tags", models.MaxTagsPerSession)
+	}
+
+	return normalized, nil
+}
+
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" {
+		return "", fmt.Errorf("tags cannot be empty")
+	}
+	if len([]rune(tag)) > models.MaxTagLength {
+		return "", fmt.Errorf("tags can be at most %d characters", models.MaxTagLength)
+	}
+	return tag, nil
+}
+
+func invalidCollectionID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_collection_id",
+		Message: "Invalid collection ID",
+		Code:    400,
+	})
+}
+
+func collectionNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "collection_not_found",
+		Message: "Collection not found",
+		Code:    404,
+	})
+}
+
+func collectionNameTaken(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "collection_exists",
+		Message: "You already have a collection with this name",
+		Code:    409,
+	})
+}
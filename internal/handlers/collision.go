@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"log"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -15,23 +21,86 @@ import (
 	"idea-collision-engine-api/internal/models"
 )
 
+// collisionStore is the part of the database the collision handler uses
+type collisionStore interface {
+	collision.EmbeddingStore
+	GetCollisionDomains(tier string) ([]models.CollisionDomain, error)
+	CreateDomainSuggestion(suggestion *models.DomainSuggestion) (bool, error)
+	CreateCollisionSession(session *models.CollisionSession) error
+	GetCollisionSession(sessionID, userID uuid.UUID) (*models.CollisionSession, error)
+	UpdateCollisionSession(sessionID, userID uuid.UUID, update models.SessionUpdate) error
+	DeleteCollisionSession(sessionID, userID uuid.UUID) error
+	RestoreCollisionSession(sessionID, userID uuid.UUID, deletedAfter time.Time) error
+	GetDeletedCollisionSessions(userID uuid.UUID, deletedAfter time.Time) ([]models.CollisionSession, error)
+	GetCollisionTree(sessionID, userID uuid.UUID) ([]models.CollisionSession, error)
+	SearchCollisionHistory(userID uuid.UUID, filter models.HistoryFilter) ([]models.CollisionSession, bool, error)
+	RateCollision(sessionID, userID uuid.UUID, rating int, notes *string) error
+	GetLibraryCounts(userID uuid.UUID) (*models.LibraryCounts, error)
+	GetUserUsage(userID uuid.UUID) (*models.UserUsage, error)
+	IncrementUserUsage(userID uuid.UUID) error
+	GetMonthlyAICost(userID uuid.UUID, monthStart time.Time) (float64, error)
+	GetAIUsageSummary(userID *uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error)
+	GetTeamAIUsageSummary(teamID uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error)
+	CreateGroupSession(session *models.GroupSession, hostInterests []string) error
+	GetGroupSession(sessionID, userID uuid.UUID) (*models.GroupSession, error)
+	GetGroupSessionByCode(code string) (*models.GroupSession, error)
+	GetUserGroupSessions(userID uuid.UUID, limit int) ([]models.GroupSession, error)
+	GetGroupParticipants(sessionID uuid.UUID) ([]models.GroupParticipant, error)
+	JoinGroupSession(sessionID, userID uuid.UUID, interests []string) error
+	SaveGroupCollisions(sessionID uuid.UUID, mergedInterests []string, results []*models.CollisionResult) ([]models.GroupCollision, error)
+	GetGroupCollisions(sessionID, viewerID uuid.UUID) ([]models.GroupCollision, error)
+	SetGroupVote(collisionID, sessionID, userID uuid.UUID, vote bool) (int, error)
+	CloseGroupSession(sessionID, hostID uuid.UUID) error
+}
+
+// collisionCache is the part of Redis the collision handler uses
+type collisionCache interface {
+	GetCachedCollisionDomains(tier string) ([]models.CollisionDomain, error)
+	CacheCollisionDomains(tier string, domains []models.CollisionDomain, expiration time.Duration) error
+	InvalidateUserUsage(userID string) error
+	Ping() error
+}
+
+// collisionAI writes and extends collisions with the AI provider
+type collisionAI interface {
+	EnhanceCollisionResult(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) error
+	DeepenCollision(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain, previous models.CollisionResult) error
+	SuggestDomain(userID uuid.UUID, input models.CollisionInput, catalog []string) (*models.CollisionDomain, string, error)
+	BreakerState() string
+	Stats() collision.AIStats
+}
+
 type CollisionHandler struct {
-	db         *database.PostgresDB
-	redis      *database.RedisClient
+	db         collisionStore
+	redis      collisionCache
 	engine     *collision.CollisionEngine
-	aiService  *collision.AIService
+	engineMu   sync.RWMutex // guards engine, which is replaced when the domain catalog changes
+	aiService  collisionAI
+	aiBudgets  map[string]float64
+	domainSuggestions bool
+	embedder   collision.Embedder // optional; enables semantic domain matching
+	minSimilarity float64
+	trashRetention time.Duration // how long deleted sessions stay restorable
 	validator  *validator.Validate
 }
 
-func NewCollisionHandler(db *database.PostgresDB, redis *database.RedisClient, aiService *collision.AIService) *CollisionHandler {
+func NewCollisionHandler(db *database.PostgresDB, redis *database.RedisClient, aiService *collision.AIService, aiBudgets map[string]float64, domainSuggestions bool) *CollisionHandler {
 	return &CollisionHandler{
 		db:        db,
 		redis:     redis,
 		aiService: aiService,
+		aiBudgets: aiBudgets,
+		domainSuggestions: domainSuggestions,
+		trashRetention: 30 * 24 * time.Hour,
 		validator: validator.New(),
 	}
 }
 
+// SetTrashRetention sets how long deleted sessions can be restored
+func (h *CollisionHandler) SetTrashRetention(retention time.Duration) {
+	h.trashRetention = retention
+}
+
 // Initialize loads collision domains and creates the engine
 func (h *CollisionHandler) Initialize() error {
 	// Load all domains for basic tier (covers all users)
@@ -40,10 +109,43 @@ func (h *CollisionHandler) Initialize() error {
 		return fmt.Errorf("failed to load collision domains: %w", err)
 	}
 	
-	h.engine = collision.NewCollisionEngine(domains)
+	engine := collision.NewCollisionEngine(domains)
+	
+	// Build the vector index, embedding any domains missing from the cache
+	if h.embedder != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		index, embedded, err := collision.EmbedDomains(ctx, h.embedder, h.db, domains, false)
+		cancel()
+		if err != nil {
+			// Log error and keep keyword matching
+			fmt.Printf("Failed to build domain embedding index: %v\n", err)
+		} else {
+			if embedded > 0 {
+				fmt.Printf("Embedded %d collision domains with %s\n", embedded, h.embedder.Model())
+			}
+			engine.UseSemanticMatcher(collision.NewSemanticMatcher(h.embedder, h.db, index, h.minSimilarity))
+		}
+	}
+	
+	h.engineMu.Lock()
+	h.engine = engine
+	h.engineMu.Unlock()
 	return nil
 }
 
+// UseEmbeddings enables cosine-similarity domain matching on the next Initialize
+func (h *CollisionHandler) UseEmbeddings(embedder collision.Embedder, minSimilarity float64) {
+	h.embedder = embedder
+	h.minSimilarity = minSimilarity
+}
+
+// currentEngine returns the engine for the current domain catalog
+func (h *CollisionHandler) currentEngine() *collision.CollisionEngine {
+	h.engineMu.RLock()
+	defer h.engineMu.RUnlock()
+	return h.engine
+}
+
 // GenerateCollision creates a new collision for the user
 func (h *CollisionHandler) GenerateCollision(c *fiber.Ctx) error {
 	userID, err := middleware.GetUserIDFromContext(c)
@@ -70,8 +172,28 @@ func (h *CollisionHandler) GenerateCollision(c *fiber.Ctx) error {
 		})
 	}
 	
+	engine := h.currentEngine()
+	premium := tier == models.TierPro || tier == models.TierTeam
+	withinBudget := premium && h.withinAIBudget(userID, tier)
+	
+	// Ask the AI for a new domain when nothing in the catalog matches a premium user's interests
+	var suggested *models.CollisionDomain
+	var suggestionVersion string
+	if withinBudget && h.domainSuggestions && !engine.MatchesInterests(input.UserInterests) {
+		suggested, suggestionVersion = h.suggestDomain(userID, input, engine.DomainNames())
+	}
+	
 	// Generate collision
-	result, err := h.engine.GenerateCollision(input)
+	var result *models.CollisionResult
+	if suggested != nil {
+		result, err = engine.GenerateCollisionWithDomain(input, *suggested)
+		if err == nil {
+			result.SuggestedDomain = suggested
+			result.PromptVersions = map[string]string{collision.PromptDomainSuggestion: suggestionVersion}
+		}
+	} else {
+		result, err = engine.GenerateCollision(input)
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "collision_generation_failed",
@@ -81,14 +203,12 @@ func (h *CollisionHandler) GenerateCollision(c *fiber.Ctx) error {
 	}
 	
 	// Enhance with AI for premium users
-	if tier == models.TierPro || tier == models.TierTeam {
-		domain := h.findDomainByName(result.CollisionDomain)
-		if domain != nil {
-			if err := h.aiService.EnhanceCollisionResult(result, input, *domain); err != nil {
-				// Log error but don't fail the request
-				fmt.Printf("AI enhancement failed: %v\n", err)
-			}
+	if premium {
+		domain := suggested
+		if domain == nil {
+			domain = h.findDomainByName(result.CollisionDomain)
 		}
+		h.enhanceResult(userID, withinBudget, result, input, domain)
 	}
 	
 	// Save collision session
@@ -105,34 +225,59 @@ func (h *CollisionHandler) GenerateCollision(c *fiber.Ctx) error {
 		fmt.Printf("Failed to save collision session: %v\n", err)
 	}
 	
-	// Increment usage for free tier users
-	if tier == models.TierFree {
-		if err := h.db.IncrementUserUsage(userID); err != nil {
-			fmt.Printf("Failed to increment usage: %v\n", err)
-		}
-		
-		// Invalidate cache
-		h.redis.InvalidateUserUsage(userID.String())
-	}
+	h.countUsage(userID, tier)
 	
 	return c.JSON(result)
 }
 
-// GetCollisionHistory returns user's collision history
+// enhanceResult improves a premium user's result with AI, keeping template output
+// once the monthly AI budget is spent. Failures are logged, not returned.
+func (h *CollisionHandler) enhanceResult(userID uuid.UUID, withinBudget bool, result *models.CollisionResult, input models.CollisionInput, domain *models.CollisionDomain) {
+	if domain == nil {
+		return
+	}
+	
+	if !withinBudget {
+		result.FallbackReason = models.FallbackAIBudgetExceeded
+	} else if err := h.aiService.EnhanceCollisionResult(userID, result, input, *domain); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("AI enhancement failed: %v\n", err)
+	}
+}
+
+// countUsage increments the weekly collision count for free tier users
+func (h *CollisionHandler) countUsage(userID uuid.UUID, tier string) {
+	if tier != models.TierFree {
+		return
+	}
+	
+	if err := h.db.IncrementUserUsage(userID); err != nil {
+		fmt.Printf("Failed to increment usage: %v\n", err)
+	}
+	
+	// Invalidate cache
+	h.redis.InvalidateUserUsage(userID.String())
+}
+
+// GetCollisionHistory returns a page of the user's collision history. Supports
+// cursor pagination (?cursor=, ?limit=), filters (domain, project_type, intensity,
+// min_rating, max_rating, from, to) and full-text search (?q=).
 func (h *CollisionHandler) GetCollisionHistory(c *fiber.Ctx) error {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
 		return err
 	}
 	
-	// Parse limit parameter
-	limitStr := c.Query("limit", "20")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 || limit > 100 {
-		limit = 20
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_filter",
+			Message: err.Error(),
+			Code:    400,
+		})
 	}
 	
-	sessions, err := h.db.GetUserCollisionHistory(userID, limit)
+	sessions, hasMore, err := h.db.SearchCollisionHistory(userID, filter)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "database_error",
@@ -141,7 +286,125 @@ func (h *CollisionHandler) GetCollisionHistory(c *fiber.Ctx) error {
 		})
 	}
 	
-	return c.JSON(sessions)
+	page := models.HistoryPage{
+		Collisions: sessions,
+		HasMore:    hasMore,
+	}
+	if page.Collisions == nil {
+		page.Collisions = []models.CollisionSession{}
+	}
+	if hasMore {
+		last := sessions[len(sessions)-1]
+		page.NextCursor = models.HistoryCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	
+	return c.JSON(page)
+}
+
+// parseHistoryFilter reads history filters from the query string
+func parseHistoryFilter(c *fiber.Ctx) (models.HistoryFilter, error) {
+	filter := models.HistoryFilter{
+		Domain:      strings.TrimSpace(c.Query("domain")),
+		ProjectType: c.Query("project_type"),
+		Intensity:   c.Query("intensity"),
+		Query:       strings.TrimSpace(c.Query("q")),
+	}
+	
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	filter.Limit = limit
+	
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := models.DecodeHistoryCursor(cursor)
+		if err != nil {
+			return filter, fmt.Errorf("cursor is invalid")
+		}
+		filter.Cursor = decoded
+	}
+	
+	switch filter.ProjectType {
+	case "", "product", "content", "business", "research":
+	default:
+		return filter, fmt.Errorf("project_type must be one of product, content, business, research")
+	}
+	
+	switch filter.Intensity {
+	case "", "gentle", "moderate", "radical":
+	default:
+		return filter, fmt.Errorf("intensity must be one of gentle, moderate, radical")
+	}
+	
+	if filter.MinRating, err = parseRating(c.Query("min_rating"), "min_rating"); err != nil {
+		return filter, err
+	}
+	if filter.MaxRating, err = parseRating(c.Query("max_rating"), "max_rating"); err != nil {
+		return filter, err
+	}
+	if filter.MinRating != nil && filter.MaxRating != nil && *filter.MinRating > *filter.MaxRating {
+		return filter, fmt.Errorf("min_rating cannot be greater than max_rating")
+	}
+	
+	if filter.From, err = parseHistoryDate(c.Query("from"), "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseHistoryDate(c.Query("to"), "to", true); err != nil {
+		return filter, err
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, fmt.Errorf("from must be before to")
+	}
+	
+	if tag := c.Query("tag"); tag != "" {
+		if filter.Tag, err = normalizeTag(tag); err != nil {
+			return filter, err
+		}
+	}
+	if collection := c.Query("collection"); collection != "" {
+		collectionID, err := uuid.Parse(collection)
+		if err != nil {
+			return filter, fmt.Errorf("collection must be a collection ID")
+		}
+		filter.CollectionID = &collectionID
+	}
+	if favorite := c.Query("favorite"); favorite != "" {
+		if filter.FavoritesOnly, err = strconv.ParseBool(favorite); err != nil {
+			return filter, fmt.Errorf("favorite must be true or false")
+		}
+	}
+	
+	return filter, nil
+}
+
+func parseRating(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	rating, err := strconv.Atoi(value)
+	if err != nil || rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("%s must be between 1 and 5", name)
+	}
+	return &rating, nil
+}
+
+// parseHistoryDate accepts RFC 3339 timestamps or YYYY-MM-DD dates. A date used
+// as the end of a range includes the whole day.
+func parseHistoryDate(value, name string, endOfRange bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", name)
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
 }
 
 // RateCollision allows users to rate and add notes to their collisions
@@ -185,6 +448,9 @@ func (h *CollisionHandler) RateCollision(c *fiber.Ctx) error {
 	
 	// Update the rating
 	if err := h.db.RateCollision(sessionID, userID, req.Rating, req.Notes); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Error:   "rating_failed",
 			Message: "Failed to save rating",
@@ -197,6 +463,196 @@ func (h *CollisionHandler) RateCollision(c *fiber.Ctx) error {
 	})
 }
 
+// GetCollisionSession returns a single collision session owned by the user
+func (h *CollisionHandler) GetCollisionSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+	
+	session, err := h.db.GetCollisionSession(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_fetch_failed",
+			Message: "Failed to fetch collision session",
+			Code:    500,
+		})
+	}
+	
+	return c.JSON(session)
+}
+
+// UpdateCollisionSession changes the rating and/or notes of a collision session
+func (h *CollisionHandler) UpdateCollisionSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+	
+	var req models.SessionUpdate
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+	
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	
+	if req.Rating == nil && req.Notes == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: "Provide a rating or notes to update",
+			Code:    400,
+		})
+	}
+	
+	if err := h.db.UpdateCollisionSession(sessionID, userID, req); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_update_failed",
+			Message: "Failed to update collision session",
+			Code:    500,
+		})
+	}
+	
+	session, err := h.db.GetCollisionSession(sessionID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_fetch_failed",
+			Message: "Failed to fetch collision session",
+			Code:    500,
+		})
+	}
+	
+	return c.JSON(session)
+}
+
+// DeleteCollisionSession moves a collision session to the trash
+func (h *CollisionHandler) DeleteCollisionSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+	
+	if err := h.db.DeleteCollisionSession(sessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_delete_failed",
+			Message: "Failed to delete collision session",
+			Code:    500,
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message":         "Collision session moved to trash",
+		"restorable_until": time.Now().Add(h.trashRetention),
+	})
+}
+
+// RestoreCollisionSession takes a collision session out of the trash while it's still restorable
+func (h *CollisionHandler) RestoreCollisionSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+	
+	if err := h.db.RestoreCollisionSession(sessionID, userID, time.Now().Add(-h.trashRetention)); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "session_not_found",
+				Message: "No restorable collision session with this ID",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_restore_failed",
+			Message: "Failed to restore collision session",
+			Code:    500,
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Collision session restored",
+	})
+}
+
+// GetTrash lists the user's deleted collision sessions that can still be restored
+func (h *CollisionHandler) GetTrash(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	sessions, err := h.db.GetDeletedCollisionSessions(userID, time.Now().Add(-h.trashRetention))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "trash_fetch_failed",
+			Message: "Failed to fetch deleted collision sessions",
+			Code:    500,
+		})
+	}
+	
+	if sessions == nil {
+		sessions = []models.CollisionSession{}
+	}
+	
+	return c.JSON(fiber.Map{
+		"collisions":     sessions,
+		"retention_days": int(h.trashRetention.Hours() / 24),
+	})
+}
+
+func invalidSessionID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_session_id",
+		Message: "Invalid session ID",
+		Code:    400,
+	})
+}
+
+func sessionNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "session_not_found",
+		Message: "Collision session not found",
+		Code:    404,
+	})
+}
+
 // GetPremiumDomains returns premium domains for Pro/Team users
 func (h *CollisionHandler) GetPremiumDomains(c *fiber.Ctx) error {
 	tier := middleware.GetSubscriptionTierFromContext(c)
@@ -265,6 +721,11 @@ func (h *CollisionHandler) GetUsageStatus(c *fiber.Ctx) error {
 	
 	tier := middleware.GetSubscriptionTierFromContext(c)
 	
+	library, err := h.db.GetLibraryCounts(userID)
+	if err != nil {
+		log.Printf("Failed to load library counts for %s: %v", userID, err)
+	}
+	
 	// Premium users have unlimited usage
 	if tier == models.TierPro || tier == models.TierTeam {
 		return c.JSON(fiber.Map{
@@ -274,6 +735,7 @@ func (h *CollisionHandler) GetUsageStatus(c *fiber.Ctx) error {
 			"collisions_remaining": -1,
 			"reset_date":         nil,
 			"unlimited":          true,
+			"library":            library,
 		})
 	}
 	
@@ -300,16 +762,196 @@ func (h *CollisionHandler) GetUsageStatus(c *fiber.Ctx) error {
 		"collisions_remaining": remaining,
 		"reset_date":          usage.ResetDate,
 		"unlimited":           false,
+		"library":             library,
+	})
+}
+
+// GetAIUsage returns the user's month-to-date AI usage and their budget
+func (h *CollisionHandler) GetAIUsage(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	tier := middleware.GetSubscriptionTierFromContext(c)
+	
+	now := time.Now()
+	monthStart := aiMonthStart(now)
+	
+	daily, err := h.db.GetAIUsageSummary(&userID, monthStart, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve AI usage",
+			Code:    500,
+		})
+	}
+	
+	spent := 0.0
+	for _, day := range daily {
+		spent += day.CostUSD
+	}
+	budget := h.aiBudgets[tier]
+	
+	return c.JSON(fiber.Map{
+		"tier":           tier,
+		"month_start":    monthStart,
+		"cost_usd":       spent,
+		"budget_usd":     budget,
+		"budget_reached": budget > 0 && spent >= budget,
+		"daily":          daily,
 	})
 }
 
+// GetTeamAIUsage returns the month-to-date AI usage of a team's members, per
+// member and day, with totals per member. Only the owner, who pays, sees it.
+func (h *CollisionHandler) GetTeamAIUsage(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	monthStart := aiMonthStart(now)
+	
+	daily, err := h.db.GetTeamAIUsageSummary(member.TeamID, monthStart, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve AI usage",
+			Code:    500,
+		})
+	}
+	if daily == nil {
+		daily = []models.AIUsageSummary{}
+	}
+	
+	spent := 0.0
+	totals := map[uuid.UUID]*models.AIUsageTotal{}
+	members := []*models.AIUsageTotal{}
+	for _, day := range daily {
+		spent += day.CostUSD
+		
+		total, ok := totals[day.UserID]
+		if !ok {
+			total = &models.AIUsageTotal{UserID: day.UserID}
+			totals[day.UserID] = total
+			members = append(members, total)
+		}
+		total.Calls += day.Calls
+		total.PromptTokens += day.PromptTokens
+		total.CompletionTokens += day.CompletionTokens
+		total.CostUSD += day.CostUSD
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].CostUSD > members[j].CostUSD
+	})
+	
+	return c.JSON(fiber.Map{
+		"team_id":     member.TeamID,
+		"month_start": monthStart,
+		"cost_usd":    spent,
+		"members":     members,
+		"daily":       daily,
+	})
+}
+
+// suggestDomain asks the AI for a new collision domain and queues it for editorial
+// review, returning it with the prompt version used. Returns nil if no usable
+// domain was proposed.
+func (h *CollisionHandler) suggestDomain(userID uuid.UUID, input models.CollisionInput, catalog []string) (*models.CollisionDomain, string) {
+	domain, version, err := h.aiService.SuggestDomain(userID, input, catalog)
+	if err != nil {
+		// Log error and fall back to a catalog domain
+		fmt.Printf("AI domain suggestion failed: %v\n", err)
+		return nil, ""
+	}
+	
+	suggestion := &models.DomainSuggestion{
+		ID:        uuid.New(),
+		UserID:    &userID,
+		Interests: input.UserInterests,
+		Domain:    *domain,
+		Status:    models.SuggestionPending,
+		CreatedAt: time.Now(),
+	}
+	
+	if _, err := h.db.CreateDomainSuggestion(suggestion); err != nil {
+		// Log error but still use the domain for this collision
+		fmt.Printf("Failed to queue domain suggestion: %v\n", err)
+	}
+	
+	return domain, version
+}
+
+// withinAIBudget reports whether the user may still make AI calls this month.
+// A zero budget means unlimited; lookup failures allow the call.
+func (h *CollisionHandler) withinAIBudget(userID uuid.UUID, tier string) bool {
+	budget := h.aiBudgets[tier]
+	if budget <= 0 {
+		return true
+	}
+	
+	spent, err := h.db.GetMonthlyAICost(userID, aiMonthStart(time.Now()))
+	if err != nil {
+		fmt.Printf("AI budget check failed: %v\n", err)
+		return true
+	}
+	
+	return spent < budget
+}
+
+// aiMonthStart returns the start of the AI budget month containing now. Budget
+// months run in UTC, like the daily usage rollup.
+func aiMonthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Metrics exposes AI provider counters and circuit breaker state in Prometheus text format
+func (h *CollisionHandler) Metrics(c *fiber.Ctx) error {
+	stats := h.aiService.Stats()
+	
+	breakerStates := []string{collision.CircuitClosed, collision.CircuitHalfOpen, collision.CircuitOpen}
+	
+	var b strings.Builder
+	b.WriteString("# HELP ai_requests_total Calls made to the AI provider, including retries.\n")
+	b.WriteString("# TYPE ai_requests_total counter\n")
+	fmt.Fprintf(&b, "ai_requests_total %d\n", stats.Requests)
+	b.WriteString("# HELP ai_failures_total Failed calls to the AI provider.\n")
+	b.WriteString("# TYPE ai_failures_total counter\n")
+	fmt.Fprintf(&b, "ai_failures_total %d\n", stats.Failures)
+	b.WriteString("# HELP ai_retries_total Retries of failed AI provider calls.\n")
+	b.WriteString("# TYPE ai_retries_total counter\n")
+	fmt.Fprintf(&b, "ai_retries_total %d\n", stats.Retries)
+	b.WriteString("# HELP ai_rejected_total AI calls skipped because the circuit breaker was open.\n")
+	b.WriteString("# TYPE ai_rejected_total counter\n")
+	fmt.Fprintf(&b, "ai_rejected_total %d\n", stats.Rejected)
+	b.WriteString("# HELP ai_quality_rejections_total AI outputs rejected by the quality gate.\n")
+	b.WriteString("# TYPE ai_quality_rejections_total counter\n")
+	fmt.Fprintf(&b, "ai_quality_rejections_total %d\n", stats.QualityRejections)
+	b.WriteString("# HELP ai_circuit_breaker_state Current AI circuit breaker state (1 for the active state).\n")
+	b.WriteString("# TYPE ai_circuit_breaker_state gauge\n")
+	for _, state := range breakerStates {
+		value := 0
+		if state == stats.BreakerState {
+			value = 1
+		}
+		fmt.Fprintf(&b, "ai_circuit_breaker_state{state=%q} %d\n", state, value)
+	}
+	
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+	return c.SendString(b.String())
+}
+
 // findDomainByName helper function to find a domain by name
 func (h *CollisionHandler) findDomainByName(name string) *models.CollisionDomain {
-	if h.engine == nil {
+	engine := h.currentEngine()
+	if engine == nil {
 		return nil
 	}
 	
-	for _, domain := range h.engine.Domains {
+	for _, domain := range engine.Domains {
 		if domain.Name == name {
 			return &domain
 		}
@@ -342,16 +984,21 @@ func (h *CollisionHandler) HealthCheck(c *fiber.Ctx) error {
 		status["cache"] = "connected"
 	}
 	
-	// Check AI service
-	if err := h.aiService.CheckConnection(); err != nil {
+	// Report AI availability from the circuit breaker rather than spending a completion
+	switch h.aiService.BreakerState() {
+	case collision.CircuitOpen:
 		status["ai_service"] = "unavailable"
 		status["status"] = "degraded"
-	} else {
+	case collision.CircuitHalfOpen:
+		status["ai_service"] = "recovering"
+		status["status"] = "degraded"
+	default:
 		status["ai_service"] = "connected"
 	}
+	status["ai_circuit_breaker"] = h.aiService.BreakerState()
 	
 	// Check collision engine
-	if h.engine == nil {
+	if h.currentEngine() == nil {
 		status["collision_engine"] = "uninitialized"
 		status["status"] = "unhealthy"
 	} else {
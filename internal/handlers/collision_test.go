@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"testing"
 	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -32,9 +34,9 @@ func (m *MockPostgresDB) CreateCollisionSession(session *models.CollisionSession
 	return args.Error(0)
 }
 
-func (m *MockPostgresDB) GetUserCollisionHistory(userID uuid.UUID, limit int) ([]models.CollisionSession, error) {
-	args := m.Called(userID, limit)
-	return args.Get(0).([]models.CollisionSession), args.Error(1)
+func (m *MockPostgresDB) SearchCollisionHistory(userID uuid.UUID, filter models.HistoryFilter) ([]models.CollisionSession, bool, error) {
+	args := m.Called(userID, filter)
+	return args.Get(0).([]models.CollisionSession), args.Bool(1), args.Error(2)
 }
 
 func (m *MockPostgresDB) RateCollision(sessionID, userID uuid.UUID, rating int, notes *string) error {
@@ -42,6 +44,29 @@ func (m *MockPostgresDB) RateCollision(sessionID, userID uuid.UUID, rating int,
 	return args.Error(0)
 }
 
+func (m *MockPostgresDB) GetCollisionSession(sessionID, userID uuid.UUID) (*models.CollisionSession, error) {
+	args := m.Called(sessionID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CollisionSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) DeleteCollisionSession(sessionID, userID uuid.UUID) error {
+	args := m.Called(sessionID, userID)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) GetCollisionTree(sessionID, userID uuid.UUID) ([]models.CollisionSession, error) {
+	args := m.Called(sessionID, userID)
+	return args.Get(0).([]models.CollisionSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetLibraryCounts(userID uuid.UUID) (*models.LibraryCounts, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*models.LibraryCounts), args.Error(1)
+}
+
 func (m *MockPostgresDB) GetUserUsage(userID uuid.UUID) (*models.UserUsage, error) {
 	args := m.Called(userID)
 	return args.Get(0).(*models.UserUsage), args.Error(1)
@@ -52,6 +77,115 @@ func (m *MockPostgresDB) IncrementUserUsage(userID uuid.UUID) error {
 	return args.Error(0)
 }
 
+func (m *MockPostgresDB) GetEmbeddings(kind, model string) ([]models.Embedding, error) {
+	args := m.Called(kind, model)
+	return args.Get(0).([]models.Embedding), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetEmbedding(kind, key, model string) (*models.Embedding, error) {
+	args := m.Called(kind, key, model)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Embedding), args.Error(1)
+}
+
+func (m *MockPostgresDB) SaveEmbedding(embedding *models.Embedding) error {
+	args := m.Called(embedding)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) CreateDomainSuggestion(suggestion *models.DomainSuggestion) (bool, error) {
+	args := m.Called(suggestion)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostgresDB) UpdateCollisionSession(sessionID, userID uuid.UUID, update models.SessionUpdate) error {
+	args := m.Called(sessionID, userID, update)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) RestoreCollisionSession(sessionID, userID uuid.UUID, deletedAfter time.Time) error {
+	args := m.Called(sessionID, userID, deletedAfter)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) GetDeletedCollisionSessions(userID uuid.UUID, deletedAfter time.Time) ([]models.CollisionSession, error) {
+	args := m.Called(userID, deletedAfter)
+	return args.Get(0).([]models.CollisionSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetMonthlyAICost(userID uuid.UUID, monthStart time.Time) (float64, error) {
+	args := m.Called(userID, monthStart)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetAIUsageSummary(userID *uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error) {
+	args := m.Called(userID, from, to)
+	return args.Get(0).([]models.AIUsageSummary), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetTeamAIUsageSummary(teamID uuid.UUID, from, to time.Time) ([]models.AIUsageSummary, error) {
+	args := m.Called(teamID, from, to)
+	return args.Get(0).([]models.AIUsageSummary), args.Error(1)
+}
+
+func (m *MockPostgresDB) CreateGroupSession(session *models.GroupSession, hostInterests []string) error {
+	args := m.Called(session, hostInterests)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) GetGroupSession(sessionID, userID uuid.UUID) (*models.GroupSession, error) {
+	args := m.Called(sessionID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.GroupSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetGroupSessionByCode(code string) (*models.GroupSession, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.GroupSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetUserGroupSessions(userID uuid.UUID, limit int) ([]models.GroupSession, error) {
+	args := m.Called(userID, limit)
+	return args.Get(0).([]models.GroupSession), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetGroupParticipants(sessionID uuid.UUID) ([]models.GroupParticipant, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]models.GroupParticipant), args.Error(1)
+}
+
+func (m *MockPostgresDB) JoinGroupSession(sessionID, userID uuid.UUID, interests []string) error {
+	args := m.Called(sessionID, userID, interests)
+	return args.Error(0)
+}
+
+func (m *MockPostgresDB) SaveGroupCollisions(sessionID uuid.UUID, mergedInterests []string, results []*models.CollisionResult) ([]models.GroupCollision, error) {
+	args := m.Called(sessionID, mergedInterests, results)
+	return args.Get(0).([]models.GroupCollision), args.Error(1)
+}
+
+func (m *MockPostgresDB) GetGroupCollisions(sessionID, viewerID uuid.UUID) ([]models.GroupCollision, error) {
+	args := m.Called(sessionID, viewerID)
+	return args.Get(0).([]models.GroupCollision), args.Error(1)
+}
+
+func (m *MockPostgresDB) SetGroupVote(collisionID, sessionID, userID uuid.UUID, vote bool) (int, error) {
+	args := m.Called(collisionID, sessionID, userID, vote)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostgresDB) CloseGroupSession(sessionID, hostID uuid.UUID) error {
+	args := m.Called(sessionID, hostID)
+	return args.Error(0)
+}
+
 // Mock Redis
 type MockRedisClient struct {
 	mock.Mock
@@ -85,16 +219,34 @@ type MockAIService struct {
 	mock.Mock
 }
 
-func (m *MockAIService) EnhanceCollisionResult(result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) error {
-	args := m.Called(result, input, domain)
+func (m *MockAIService) EnhanceCollisionResult(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain) error {
+	args := m.Called(userID, result, input, domain)
 	return args.Error(0)
 }
 
-func (m *MockAIService) CheckConnection() error {
-	args := m.Called()
+func (m *MockAIService) DeepenCollision(userID uuid.UUID, result *models.CollisionResult, input models.CollisionInput, domain models.CollisionDomain, previous models.CollisionResult) error {
+	args := m.Called(userID, result, input, domain, previous)
 	return args.Error(0)
 }
 
+func (m *MockAIService) SuggestDomain(userID uuid.UUID, input models.CollisionInput, catalog []string) (*models.CollisionDomain, string, error) {
+	args := m.Called(userID, input, catalog)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).(*models.CollisionDomain), args.String(1), args.Error(2)
+}
+
+func (m *MockAIService) BreakerState() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockAIService) Stats() collision.AIStats {
+	args := m.Called()
+	return args.Get(0).(collision.AIStats)
+}
+
 type CollisionHandlerTestSuite struct {
 	suite.Suite
 	app     *fiber.App
@@ -113,6 +265,7 @@ func (suite *CollisionHandlerTestSuite) SetupTest() {
 		db:        suite.mockDB,
 		redis:     suite.mockRedis,
 		aiService: suite.mockAI,
+		validator: validator.New(),
 	}
 	
 	// Create test domains
@@ -158,6 +311,8 @@ func (suite *CollisionHandlerTestSuite) setupRoutes() {
 	suite.app.Put("/collisions/:id/rate", suite.handler.RateCollision)
 	suite.app.Get("/collisions/usage", suite.handler.GetUsageStatus)
 	suite.app.Get("/collisions/health", suite.handler.HealthCheck)
+	suite.app.Get("/collisions/:id", suite.handler.GetCollisionSession)
+	suite.app.Delete("/collisions/:id", suite.handler.DeleteCollisionSession)
 	suite.app.Get("/domains/basic", suite.handler.GetBasicDomains)
 }
 
@@ -239,18 +394,23 @@ func (suite *CollisionHandlerTestSuite) TestGetCollisionHistory() {
 		},
 	}
 	
-	suite.mockDB.On("GetUserCollisionHistory", mock.AnythingOfType("uuid.UUID"), 20).Return(sessions, nil)
+	suite.mockDB.On("SearchCollisionHistory", mock.AnythingOfType("uuid.UUID"), models.HistoryFilter{Intensity: "moderate", Limit: 1}).Return(sessions, true, nil)
 	
-	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/history", nil))
+	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/history?intensity=moderate&limit=1", nil))
 	
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
 	
-	var result []models.CollisionSession
+	var result models.HistoryPage
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	assert.NoError(suite.T(), err)
-	assert.Len(suite.T(), result, 1)
-	assert.Equal(suite.T(), "Jazz Improvisation", result[0].CollisionResult.CollisionDomain)
+	assert.Len(suite.T(), result.Collisions, 1)
+	assert.Equal(suite.T(), "Jazz Improvisation", result.Collisions[0].CollisionResult.CollisionDomain)
+	assert.True(suite.T(), result.HasMore)
+	
+	cursor, err := models.DecodeHistoryCursor(result.NextCursor)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), sessions[0].ID, cursor.ID)
 	
 	suite.mockDB.AssertExpectations(suite.T())
 }
@@ -281,6 +441,59 @@ func (suite *CollisionHandlerTestSuite) TestRateCollision() {
 	suite.mockDB.AssertExpectations(suite.T())
 }
 
+func (suite *CollisionHandlerTestSuite) TestRateCollisionNotOwned() {
+	sessionID := uuid.New()
+	
+	suite.mockDB.On("RateCollision", sessionID, mock.AnythingOfType("uuid.UUID"), 4, mock.AnythingOfType("*string")).Return(sql.ErrNoRows)
+	
+	jsonData, _ := json.Marshal(map[string]interface{}{"rating": 4})
+	resp, err := suite.app.Test(suite.createRequest("PUT", "/collisions/"+sessionID.String()+"/rate", bytes.NewReader(jsonData)))
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	
+	suite.mockDB.AssertExpectations(suite.T())
+}
+
+func (suite *CollisionHandlerTestSuite) TestGetCollisionSessionNotFound() {
+	sessionID := uuid.New()
+	
+	suite.mockDB.On("GetCollisionSession", sessionID, mock.AnythingOfType("uuid.UUID")).Return(nil, sql.ErrNoRows)
+	
+	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/"+sessionID.String(), nil))
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	
+	suite.mockDB.AssertExpectations(suite.T())
+}
+
+func (suite *CollisionHandlerTestSuite) TestDeleteCollisionSession() {
+	sessionID := uuid.New()
+	
+	suite.mockDB.On("DeleteCollisionSession", sessionID, mock.AnythingOfType("uuid.UUID")).Return(nil)
+	
+	resp, err := suite.app.Test(suite.createRequest("DELETE", "/collisions/"+sessionID.String(), nil))
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	
+	suite.mockDB.AssertExpectations(suite.T())
+}
+
+func (suite *CollisionHandlerTestSuite) TestDeleteCollisionSessionNotOwned() {
+	sessionID := uuid.New()
+	
+	suite.mockDB.On("DeleteCollisionSession", sessionID, mock.AnythingOfType("uuid.UUID")).Return(sql.ErrNoRows)
+	
+	resp, err := suite.app.Test(suite.createRequest("DELETE", "/collisions/"+sessionID.String(), nil))
+	
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	
+	suite.mockDB.AssertExpectations(suite.T())
+}
+
 func (suite *CollisionHandlerTestSuite) TestGetUsageStatus() {
 	userID := uuid.New()
 	usage := &models.UserUsage{
@@ -291,6 +504,7 @@ func (suite *CollisionHandlerTestSuite) TestGetUsageStatus() {
 	}
 	
 	suite.mockDB.On("GetUserUsage", mock.AnythingOfType("uuid.UUID")).Return(usage, nil)
+	suite.mockDB.On("GetLibraryCounts", mock.AnythingOfType("uuid.UUID")).Return(&models.LibraryCounts{Favorites: 2}, nil)
 	
 	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/usage", nil))
 	
@@ -343,7 +557,7 @@ func (suite *CollisionHandlerTestSuite) TestGetBasicDomains() {
 func (suite *CollisionHandlerTestSuite) TestHealthCheck() {
 	// Setup mocks for healthy services
 	suite.mockRedis.On("Ping").Return(nil)
-	suite.mockAI.On("CheckConnection").Return(nil)
+	suite.mockAI.On("BreakerState").Return(collision.CircuitClosed)
 	
 	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/health", nil))
 	
@@ -368,7 +582,7 @@ func (suite *CollisionHandlerTestSuite) TestHealthCheck() {
 func (suite *CollisionHandlerTestSuite) TestHealthCheckDegraded() {
 	// Setup mocks for degraded services
 	suite.mockRedis.On("Ping").Return(assert.AnError)
-	suite.mockAI.On("CheckConnection").Return(assert.AnError)
+	suite.mockAI.On("BreakerState").Return(collision.CircuitOpen)
 	
 	resp, err := suite.app.Test(suite.createRequest("GET", "/collisions/health", nil))
 	
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/export"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// MaxExportSessions caps how many sessions a history export includes
+const MaxExportSessions = 1000
+
+// ExportHandler renders collision sessions as downloadable files
+type ExportHandler struct {
+	db *database.PostgresDB
+}
+
+func NewExportHandler(db *database.PostgresDB) *ExportHandler {
+	return &ExportHandler{
+		db: db,
+	}
+}
+
+// ExportCollision exports a single collision session
+func (h *ExportHandler) ExportCollision(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	format, doc, err := parseExportOptions(c)
+	if err != nil {
+		return invalidExport(c, err)
+	}
+
+	session, err := h.db.GetCollisionSession(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_fetch_failed",
+			Message: "Failed to fetch collision session",
+			Code:    500,
+		})
+	}
+
+	doc.Sessions = []models.CollisionSession{*session}
+	return sendExport(c, format, doc, "collision-"+session.ID.String()[:8])
+}
+
+// ExportHistory exports the user's collision history. It accepts the same
+// filters as the history endpoint and includes up to MaxExportSessions of the
+// newest matching sessions.
+func (h *ExportHandler) ExportHistory(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		return invalidExport(c, err)
+	}
+
+	format, doc, err := parseExportOptions(c)
+	if err != nil {
+		return invalidExport(c, err)
+	}
+
+	// Page through the history rather than loading it in one query, so large
+	// exports use the same indexed queries as the history endpoint
+	filter.Limit = 100
+	truncated := false
+	for {
+		sessions, hasMore, err := h.db.SearchCollisionHistory(userID, filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to retrieve collision history",
+				Code:    500,
+			})
+		}
+
+		doc.Sessions = append(doc.Sessions, sessions...)
+		if len(doc.Sessions) >= MaxExportSessions {
+			truncated = hasMore || len(doc.Sessions) > MaxExportSessions
+			doc.Sessions = doc.Sessions[:MaxExportSessions]
+			break
+		}
+		if !hasMore {
+			break
+		}
+
+		last := sessions[len(sessions)-1]
+		filter.Cursor = &models.HistoryCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	if truncated {
+		c.Set("X-Export-Truncated", "true")
+	}
+	return sendExport(c, format, doc, "collisions-"+doc.ExportedAt.In(doc.Zone).Format("2006-01-02"))
+}
+
+// parseExportOptions reads the format, locale and time zone of an export. The
+// locale comes from ?locale or, failing that, the Accept-Language header.
+func parseExportOptions(c *fiber.Ctx) (export.Format, export.Document, error) {
+	doc := export.Document{
+		ExportedAt: time.Now(),
+		Zone:       time.UTC,
+	}
+
+	format, err := export.ParseFormat(c.Query("format", "markdown"))
+	if err != nil {
+		return "", doc, err
+	}
+
+	if tag := c.Query("locale"); tag != "" {
+		locale, ok := export.LookupLocale(tag)
+		if !ok {
+			return "", doc, fmt.Errorf("locale %q is not supported", tag)
+		}
+		doc.Locale = locale
+	} else {
+		doc.Locale = export.NegotiateLocale(c.Get(fiber.HeaderAcceptLanguage))
+	}
+
+	if tz := c.Query("tz"); tz != "" {
+		zone, err := time.LoadLocation(tz)
+		if err != nil {
+			return "", doc, fmt.Errorf("tz must be an IANA time zone such as Europe/Berlin")
+		}
+		doc.Zone = zone
+	}
+
+	return format, doc, nil
+}
+
+// sendExport renders doc as an attachment named filename plus the format's extension
+func sendExport(c *fiber.Ctx, format export.Format, doc export.Document, filename string) error {
+	var body bytes.Buffer
+	if err := export.Render(&body, format, doc); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "export_failed",
+			Message: "Failed to render export",
+			Code:    500,
+		})
+	}
+
+	c.Attachment(filename + "." + format.Extension())
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentLanguage, doc.Locale.Tag)
+	return c.Send(body.Bytes())
+}
+
+func invalidExport(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_export",
+		Message: err.Error(),
+		Code:    400,
+	})
+}
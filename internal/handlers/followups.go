@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/collision"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// DeepenCollision adds spark questions and next steps to a session's domain pair as a follow-up session
+func (h *CollisionHandler) DeepenCollision(c *fiber.Ctx) error {
+	return h.followUp(c, models.FollowUpDeepen)
+}
+
+// PivotCollision keeps a session's project and swaps its domain for a nearby one as a follow-up session
+func (h *CollisionHandler) PivotCollision(c *fiber.Ctx) error {
+	return h.followUp(c, models.FollowUpPivot)
+}
+
+// IntensifyCollision reruns a session at the next intensity level as a follow-up session
+func (h *CollisionHandler) IntensifyCollision(c *fiber.Ctx) error {
+	return h.followUp(c, models.FollowUpIntensify)
+}
+
+// followUp creates a child session of the session in the :id parameter. Follow-ups
+// count toward usage limits like new collisions.
+func (h *CollisionHandler) followUp(c *fiber.Ctx, operation string) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	tier := middleware.GetSubscriptionTierFromContext(c)
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	parent, err := h.db.GetCollisionSession(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_fetch_failed",
+			Message: "Failed to fetch collision session",
+			Code:    500,
+		})
+	}
+
+	engine := h.currentEngine()
+	premium := tier == models.TierPro || tier == models.TierTeam
+	withinBudget := premium && h.withinAIBudget(userID, tier)
+	input := parent.InputData
+
+	var result *models.CollisionResult
+	switch operation {
+	case models.FollowUpDeepen:
+		domain := h.sessionDomain(parent.CollisionResult)
+		if domain == nil {
+			return domainUnavailable(c)
+		}
+
+		result = engine.Deepen(input, *domain, parent.CollisionResult)
+		if premium {
+			if !withinBudget {
+				result.FallbackReason = models.FallbackAIBudgetExceeded
+			} else if err := h.aiService.DeepenCollision(userID, result, input, *domain, parent.CollisionResult); err != nil {
+				fmt.Printf("AI deepen failed: %v\n", err)
+			}
+		}
+
+	case models.FollowUpPivot:
+		from := h.sessionDomain(parent.CollisionResult)
+		if from == nil {
+			return domainUnavailable(c)
+		}
+
+		explored, err := h.exploredDomains(parent.ID, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "session_fetch_failed",
+				Message: "Failed to fetch exploration tree",
+				Code:    500,
+			})
+		}
+
+		var domain models.CollisionDomain
+		result, domain, err = engine.Pivot(input, *from, explored)
+		if err == collision.ErrNoNearbyDomain {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+				Error:   "no_nearby_domain",
+				Message: "Every domain near this one has already been explored",
+				Code:    422,
+			})
+		}
+		if err != nil {
+			return followUpFailed(c)
+		}
+
+		if premium {
+			h.enhanceResult(userID, withinBudget, result, input, &domain)
+		}
+
+	case models.FollowUpIntensify:
+		next, err := collision.NextIntensity(input.CollisionIntensity)
+		if err != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+				Error:   "max_intensity",
+				Message: "This collision is already at the highest intensity",
+				Code:    422,
+			})
+		}
+		input.CollisionIntensity = next
+
+		result, err = engine.GenerateCollision(input)
+		if err != nil {
+			return followUpFailed(c)
+		}
+
+		if premium {
+			h.enhanceResult(userID, withinBudget, result, input, h.findDomainByName(result.CollisionDomain))
+		}
+	}
+
+	// Unlike new collisions, a follow-up that can't be saved fails: without the
+	// link to its parent it would be lost from the exploration tree
+	session := &models.CollisionSession{
+		ID:              uuid.New(),
+		UserID:          userID,
+		InputData:       input,
+		CollisionResult: *result,
+		CreatedAt:       time.Now(),
+		Tags:            []string{},
+		ParentID:        &parent.ID,
+		FollowUp:        operation,
+	}
+
+	if err := h.db.CreateCollisionSession(session); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_save_failed",
+			Message: "Failed to save follow-up collision",
+			Code:    500,
+		})
+	}
+
+	h.countUsage(userID, tier)
+
+	return c.Status(fiber.StatusCreated).JSON(session)
+}
+
+// GetCollisionTree returns the exploration tree containing a session, nested from its root
+func (h *CollisionHandler) GetCollisionTree(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	sessions, err := h.db.GetCollisionTree(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "session_fetch_failed",
+			Message: "Failed to fetch exploration tree",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"roots": buildCollisionTree(sessions),
+	})
+}
+
+// buildCollisionTree nests sessions under their parents. Sessions whose parent
+// isn't in the list, such as follow-ups of a trashed session, become roots.
+func buildCollisionTree(sessions []models.CollisionSession) []*models.CollisionTreeNode {
+	nodes := make(map[uuid.UUID]*models.CollisionTreeNode, len(sessions))
+	for _, session := range sessions {
+		nodes[session.ID] = &models.CollisionTreeNode{
+			CollisionSession: session,
+			Children:         []*models.CollisionTreeNode{},
+		}
+	}
+
+	roots := []*models.CollisionTreeNode{}
+	for _, session := range sessions {
+		node := nodes[session.ID]
+		if session.ParentID != nil {
+			if parent, ok := nodes[*session.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	return roots
+}
+
+// exploredDomains returns the collision domains already used in the exploration
+// tree containing a session
+func (h *CollisionHandler) exploredDomains(sessionID, userID uuid.UUID) ([]string, error) {
+	sessions, err := h.db.GetCollisionTree(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		domains = append(domains, session.CollisionResult.CollisionDomain)
+	}
+	return domains, nil
+}
+
+// sessionDomain finds the domain a result collided with: the AI-suggested
+// domain if there was one, otherwise the catalog domain of the same name
+func (h *CollisionHandler) sessionDomain(result models.CollisionResult) *models.CollisionDomain {
+	if result.SuggestedDomain != nil {
+		return result.SuggestedDomain
+	}
+	return h.findDomainByName(result.CollisionDomain)
+}
+
+func domainUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ErrorResponse{
+		Error:   "domain_unavailable",
+		Message: "The collision domain of this session is no longer available",
+		Code:    422,
+	})
+}
+
+func followUpFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "collision_generation_failed",
+		Message: "Failed to generate follow-up collision",
+		Code:    500,
+	})
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/collision"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	// joinCodeAlphabet leaves out characters that are easy to mix up when a
+	// code is read off a shared screen, like 0 and O or 1 and I
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	joinCodeLength   = 6
+
+	// joinCodeAttempts is how many codes are tried before giving up on
+	// creating a group session
+	joinCodeAttempts = 5
+
+	// groupSessionListLimit is how many of a user's group sessions are listed
+	groupSessionListLimit = 50
+)
+
+// CreateGroupSession starts a group session hosted by the user and returns it
+// with the code participants join with. Hosting takes a premium plan;
+// joining doesn't.
+func (h *CollisionHandler) CreateGroupSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.CreateGroupSessionRequest
+	if ok, err := h.parseGroupRequest(c, &req); !ok {
+		return err
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return invalidGroupRequest(c, "Title can't be blank")
+	}
+	duration := models.GroupDefaultDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+
+	session := &models.GroupSession{
+		HostID:             userID,
+		Title:              title,
+		CurrentProject:     strings.TrimSpace(req.CurrentProject),
+		ProjectType:        req.ProjectType,
+		CollisionIntensity: req.CollisionIntensity,
+		EndsAt:             time.Now().Add(duration),
+	}
+
+	// Codes are short, so the odd one is taken already
+	for attempt := 0; ; attempt++ {
+		session.JoinCode, err = newJoinCode()
+		if err != nil {
+			return tokenGenerationFailed(c)
+		}
+
+		err = h.db.CreateGroupSession(session, cleanInterests(req.Interests))
+		if err != database.ErrJoinCodeTaken || attempt == joinCodeAttempts-1 {
+			break
+		}
+	}
+	if err != nil {
+		return groupDatabaseError(c)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(session)
+}
+
+// JoinGroupSession joins the group session with the code in the request and
+// submits the user's interests, or replaces the interests they submitted
+// before. Interests can change until the host generates the collisions.
+func (h *CollisionHandler) JoinGroupSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.JoinGroupSessionRequest
+	if ok, err := h.parseGroupRequest(c, &req); !ok {
+		return err
+	}
+
+	interests := cleanInterests(req.Interests)
+	if len(interests) == 0 {
+		return invalidGroupRequest(c, "Submit at least one interest")
+	}
+
+	session, err := h.db.GetGroupSessionByCode(strings.ToUpper(strings.TrimSpace(req.Code)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return groupNotFound(c)
+		}
+		return groupDatabaseError(c)
+	}
+
+	if err := h.db.JoinGroupSession(session.ID, userID, interests); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return groupNotFound(c)
+		case database.ErrGroupSessionClosed:
+			return groupClosed(c, "This group session isn't taking new interests anymore")
+		case database.ErrGroupSessionFull:
+			return groupClosed(c, "This group session is full")
+		}
+		return groupDatabaseError(c)
+	}
+
+	return h.respondWithGroupSession(c, session.ID, userID)
+}
+
+// ListGroupSessions lists the group sessions the user hosts or joined, newest first
+func (h *CollisionHandler) ListGroupSessions(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.db.GetUserGroupSessions(userID, groupSessionListLimit)
+	if err != nil {
+		return groupDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// GetGroupSession returns a group session the user takes part in, with its
+// participants and, once generated, its collisions ranked by votes
+func (h *CollisionHandler) GetGroupSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return groupNotFound(c)
+	}
+
+	return h.respondWithGroupSession(c, sessionID, userID)
+}
+
+// GenerateGroupCollisions merges the participants' interests and generates
+// the group's collisions, avoiding domains any participant already lists.
+// Only the host can generate, once, and it closes the session to new
+// participants.
+func (h *CollisionHandler) GenerateGroupCollisions(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	session, err := h.loadHostedGroupSession(c, userID)
+	if session == nil {
+		return err
+	}
+	if session.Status == models.GroupStatusVoting {
+		return alreadyGenerated(c)
+	}
+
+	var req models.GenerateGroupCollisionsRequest
+	if len(c.Body()) > 0 {
+		if ok, err := h.parseGroupRequest(c, &req); !ok {
+			return err
+		}
+	}
+	count := req.Count
+	if count == 0 {
+		count = models.GroupDefaultCollisions
+	}
+
+	participants, err := h.db.GetGroupParticipants(session.ID)
+	if err != nil {
+		return groupDatabaseError(c)
+	}
+	lists := make([][]string, 0, len(participants))
+	for _, participant := range participants {
+		lists = append(lists, participant.Interests)
+	}
+
+	merged := collision.MergeGroupInterests(lists, collision.GroupInterestLimit)
+	if len(merged) == 0 {
+		return invalidGroupRequest(c, "No participant has submitted interests yet")
+	}
+
+	input := models.CollisionInput{
+		UserInterests:      merged,
+		CurrentProject:     session.CurrentProject,
+		ProjectType:        session.ProjectType,
+		CollisionIntensity: session.CollisionIntensity,
+	}
+	results, err := h.currentEngine().GenerateGroupCollisions(input, lists, count)
+	if err != nil || len(results) == 0 {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "collision_generation_failed",
+			Message: "Failed to generate collisions",
+			Code:    500,
+		})
+	}
+
+	if _, err := h.db.SaveGroupCollisions(session.ID, merged, results); err != nil {
+		if err == sql.ErrNoRows {
+			// Generated, or ended, since it was loaded
+			return alreadyGenerated(c)
+		}
+		return groupDatabaseError(c)
+	}
+
+	return h.respondWithGroupSession(c, session.ID, userID)
+}
+
+// CloseGroupSession ends a group session early, which stops joining and voting
+func (h *CollisionHandler) CloseGroupSession(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	session, err := h.loadHostedGroupSession(c, userID)
+	if session == nil {
+		return err
+	}
+
+	if err := h.db.CloseGroupSession(session.ID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return groupEnded(c)
+		}
+		return groupDatabaseError(c)
+	}
+
+	return h.respondWithGroupSession(c, session.ID, userID)
+}
+
+// VoteGroupCollision casts the user's vote for one of the group's
+// collisions. Each participant has one vote per collision.
+func (h *CollisionHandler) VoteGroupCollision(c *fiber.Ctx) error {
+	return h.setGroupVote(c, true)
+}
+
+// UnvoteGroupCollision withdraws the user's vote for one of the group's collisions
+func (h *CollisionHandler) UnvoteGroupCollision(c *fiber.Ctx) error {
+	return h.setGroupVote(c, false)
+}
+
+func (h *CollisionHandler) setGroupVote(c *fiber.Ctx, vote bool) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	session, err := h.loadGroupSession(c, userID)
+	if session == nil {
+		return err
+	}
+	if session.Status == models.GroupStatusEnded {
+		return groupEnded(c)
+	}
+
+	collisionID, err := uuid.Parse(c.Params("collisionId"))
+	if err != nil {
+		return groupCollisionNotFound(c)
+	}
+
+	count, err := h.db.SetGroupVote(collisionID, session.ID, userID, vote)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return groupCollisionNotFound(c)
+		}
+		return groupDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"collision_id": collisionID,
+		"voted":        vote,
+		"vote_count":   count,
+	})
+}
+
+// loadGroupSession looks up the group session in :id, which the user must
+// take part in. If it writes an error response it returns a nil session,
+// along with the error from writing it.
+func (h *CollisionHandler) loadGroupSession(c *fiber.Ctx, userID uuid.UUID) (*models.GroupSession, error) {
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, groupNotFound(c)
+	}
+
+	session, err := h.db.GetGroupSession(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, groupNotFound(c)
+		}
+		return nil, groupDatabaseError(c)
+	}
+
+	return session, nil
+}
+
+// loadHostedGroupSession is loadGroupSession for sessions the user hosts that
+// haven't ended
+func (h *CollisionHandler) loadHostedGroupSession(c *fiber.Ctx, userID uuid.UUID) (*models.GroupSession, error) {
+	session, err := h.loadGroupSession(c, userID)
+	if session == nil {
+		return nil, err
+	}
+
+	if session.HostID != userID {
+		return nil, c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Error:   "not_group_host",
+			Message: "Only the host can do this",
+			Code:    403,
+		})
+	}
+	if session.Status == models.GroupStatusEnded {
+		return nil, groupEnded(c)
+	}
+
+	return session, nil
+}
+
+// respondWithGroupSession writes a group session with its participants and
+// collisions, as the user sees it
+func (h *CollisionHandler) respondWithGroupSession(c *fiber.Ctx, sessionID, userID uuid.UUID) error {
+	session, err := h.db.GetGroupSession(sessionID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return groupNotFound(c)
+		}
+		return groupDatabaseError(c)
+	}
+
+	session.Participants, err = h.db.GetGroupParticipants(session.ID)
+	if err != nil {
+		return groupDatabaseError(c)
+	}
+	if session.GeneratedAt != nil {
+		session.Collisions, err = h.db.GetGroupCollisions(session.ID, userID)
+		if err != nil {
+			return groupDatabaseError(c)
+		}
+	}
+
+	return c.JSON(session)
+}
+
+func (h *CollisionHandler) parseGroupRequest(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, invalidGroupRequest(c, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	return true, nil
+}
+
+// newJoinCode returns a random code to join a group session with
+func newJoinCode() (string, error) {
+	buf := make([]byte, joinCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	// The alphabet has 32 characters, so every byte maps to one evenly
+	for i := range buf {
+		buf[i] = joinCodeAlphabet[int(buf[i])%len(joinCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// cleanInterests trims the interests a participant submitted and drops blank ones
+func cleanInterests(interests []string) []string {
+	cleaned := make([]string, 0, len(interests))
+	for _, interest := range interests {
+		if interest = strings.TrimSpace(interest); interest != "" {
+			cleaned = append(cleaned, interest)
+		}
+	}
+	return cleaned
+}
+
+func invalidGroupRequest(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_request",
+		Message: message,
+		Code:    400,
+	})
+}
+
+func groupNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "group_session_not_found",
+		Message: "Group session not found",
+		Code:    404,
+	})
+}
+
+func groupCollisionNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "group_collision_not_found",
+		Message: "No such collision in this group session",
+		Code:    404,
+	})
+}
+
+func groupClosed(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "group_session_closed",
+		Message: message,
+		Code:    409,
+	})
+}
+
+func groupEnded(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "group_session_ended",
+		Message: "This group session has ended",
+		Code:    409,
+	})
+}
+
+func alreadyGenerated(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "already_generated",
+		Message: "This group session's collisions have been generated already",
+		Code:    409,
+	})
+}
+
+func groupDatabaseError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to manage group session",
+		Code:    500,
+	})
+}
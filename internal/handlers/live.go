@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/models"
+	"idea-collision-engine-api/internal/realtime"
+)
+
+const (
+	// liveWriteWait is how long a write to a live connection may take
+	liveWriteWait = 10 * time.Second
+
+	// livePongWait is how long a live connection may go without answering a ping
+	livePongWait = 60 * time.Second
+
+	// livePingInterval is how often live connections are pinged and their
+	// presence refreshed
+	livePingInterval = livePongWait * 9 / 10
+
+	// livePresenceTTL is how long presence outlives a connection whose instance
+	// went away without removing it
+	livePresenceTTL = 2 * livePingInterval
+
+	// liveReadLimit is the largest message a client may send
+	liveReadLimit = 4096
+)
+
+// LiveHandler streams changes on a team's boards to its members over a
+// WebSocket, along with who else is connected. Events reach every API
+// instance through Redis, and the latest are kept so clients that reconnect
+// can pick up where they left off.
+type LiveHandler struct {
+	db      *database.PostgresDB
+	redis   *database.RedisClient
+	hub     *realtime.Hub
+	upgrade fiber.Handler
+}
+
+// liveClientMessage is what clients send over the connection
+type liveClientMessage struct {
+	Type    string     `json:"type"`               // "view"
+	BoardID *uuid.UUID `json:"board_id,omitempty"` // the board the member opened, or null when they close it
+}
+
+func NewLiveHandler(db *database.PostgresDB, redis *database.RedisClient, hub *realtime.Hub, origins []string) *LiveHandler {
+	h := &LiveHandler{
+		db:    db,
+		redis: redis,
+		hub:   hub,
+	}
+	h.upgrade = websocket.New(h.serve, websocket.Config{Origins: origins})
+	return h
+}
+
+// Connect upgrades the request to a WebSocket streaming the team's board
+// events. ?board_id= says which board the member has open, for presence, and
+// ?last_event_id= replays the events since the last one the client saw.
+func (h *LiveHandler) Connect(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(models.ErrorResponse{
+			Error:   "upgrade_required",
+			Message: "Connect to this endpoint with a WebSocket",
+			Code:    426,
+		})
+	}
+
+	return h.upgrade(c)
+}
+
+func (h *LiveHandler) serve(conn *websocket.Conn) {
+	member, ok := conn.Locals("team_member").(*models.TeamMember)
+	if !ok {
+		conn.Close()
+		return
+	}
+
+	// Subscribe before replaying, so nothing falls between the two
+	sub := h.hub.Subscribe(member.TeamID)
+	defer sub.Close()
+
+	connectionID := uuid.NewString()
+	presence := models.BoardPresence{
+		UserID:  member.UserID,
+		Name:    member.DisplayName,
+		BoardID: h.viewedBoard(member.TeamID, conn.Query("board_id")),
+	}
+	if presence.Name == "" {
+		presence.Name = member.Email
+	}
+	h.setPresence(member.TeamID, connectionID, presence)
+	defer h.removePresence(member.TeamID, connectionID)
+
+	members, err := h.redis.GetBoardPresence(member.TeamID)
+	if err != nil {
+		log.Printf("Failed to load board presence: %v", err)
+	}
+	if err := writeLive(conn, h.controlEvent(member.TeamID, models.EventHello, members)); err != nil {
+		return
+	}
+
+	lastID := conn.Query("last_event_id")
+	if lastID != "" {
+		missed, complete, err := h.redis.BoardEventsSince(member.TeamID, lastID)
+		if err != nil || !complete {
+			if err := writeLive(conn, h.controlEvent(member.TeamID, models.EventResync, nil)); err != nil {
+				return
+			}
+		}
+		for i := range missed {
+			if err := writeLive(conn, &missed[i]); err != nil {
+				return
+			}
+			lastID = missed[i].ID
+		}
+	}
+
+	views := make(chan *uuid.UUID)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go readLive(conn, views, stop, done)
+
+	// The connection is recycled once serve returns, so wait for the reader
+	defer func() {
+		close(stop)
+		conn.Close()
+		<-done
+	}()
+
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "reconnect with last_event_id"),
+					time.Now().Add(liveWriteWait))
+				return
+			}
+			if event.ID != "" {
+				if lastID != "" && !realtime.After(event.ID, lastID) {
+					continue // already replayed
+				}
+				lastID = event.ID
+			}
+			if err := writeLive(conn, event); err != nil {
+				return
+			}
+
+		case boardID := <-views:
+			presence.BoardID = h.viewedBoard(member.TeamID, uuidString(boardID))
+			h.setPresence(member.TeamID, connectionID, presence)
+
+		case <-ticker.C:
+			if err := h.redis.SetBoardPresence(member.TeamID, connectionID, presence, livePresenceTTL); err != nil {
+				log.Printf("Failed to refresh board presence: %v", err)
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)); err != nil {
+				return
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// readLive reads the client's messages until the connection fails or closes,
+// or stop closes, then closes done. Only the serving loop writes to the
+// connection.
+func readLive(conn *websocket.Conn, views chan<- *uuid.UUID, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(liveReadLimit)
+	conn.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message liveClientMessage
+		if err := json.Unmarshal(data, &message); err != nil || message.Type != "view" {
+			continue
+		}
+		select {
+		case views <- message.BoardID:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// viewedBoard parses the board a member says they have open, and returns nil
+// unless it's one of the team's boards
+func (h *LiveHandler) viewedBoard(teamID uuid.UUID, raw string) *uuid.UUID {
+	boardID, err := uuid.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	if _, err := h.db.GetBoard(boardID, teamID); err != nil {
+		return nil
+	}
+	return &boardID
+}
+
+// setPresence records a connection's presence and tells the team who is
+// connected now
+func (h *LiveHandler) setPresence(teamID uuid.UUID, connectionID string, presence models.BoardPresence) {
+	if err := h.redis.SetBoardPresence(teamID, connectionID, presence, livePresenceTTL); err != nil {
+		log.Printf("Failed to record board presence: %v", err)
+		return
+	}
+	h.broadcastPresence(teamID)
+}
+
+func (h *LiveHandler) removePresence(teamID uuid.UUID, connectionID string) {
+	if err := h.redis.RemoveBoardPresence(teamID, connectionID); err != nil {
+		log.Printf("Failed to remove board presence: %v", err)
+		return
+	}
+	h.broadcastPresence(teamID)
+}
+
+func (h *LiveHandler) broadcastPresence(teamID uuid.UUID) {
+	members, err := h.redis.GetBoardPresence(teamID)
+	if err != nil {
+		log.Printf("Failed to load board presence: %v", err)
+		return
+	}
+	if err := h.redis.BroadcastBoardEvent(h.controlEvent(teamID, models.EventPresence, members)); err != nil {
+		log.Printf("Failed to publish board presence: %v", err)
+	}
+}
+
+func (h *LiveHandler) controlEvent(teamID uuid.UUID, eventType string, members []models.BoardPresence) *models.BoardEvent {
+	event := &models.BoardEvent{
+		Type:      eventType,
+		TeamID:    teamID,
+		CreatedAt: time.Now(),
+	}
+	if members != nil {
+		event.Data = map[string]interface{}{"members": members}
+	}
+	return event
+}
+
+func writeLive(conn *websocket.Conn, event *models.BoardEvent) error {
+	conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+	return conn.WriteJSON(event)
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
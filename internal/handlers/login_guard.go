@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/models"
+)
+
+// loginFailureWindow is how long failed logins are remembered after the first one
+const loginFailureWindow = 15 * time.Minute
+
+// maxLoginDelay caps the wait between attempts before a lockout kicks in
+const maxLoginDelay = time.Minute
+
+// loginLimit throttles failed logins for one kind of subject. After delayAfter
+// failures each further attempt has to wait twice as long as the one before;
+// after lockAfter failures logins are refused for lockout.
+type loginLimit struct {
+	scope      string
+	delayAfter int64
+	lockAfter  int64
+	lockout    time.Duration
+}
+
+var (
+	// Per email address, against guessing one account's password
+	emailLoginLimit = loginLimit{scope: "email", delayAfter: 3, lockAfter: 10, lockout: 15 * time.Minute}
+	// Per IP address, against trying a few passwords on many accounts
+	ipLoginLimit = loginLimit{scope: "ip", delayAfter: 20, lockAfter: 100, lockout: time.Hour}
+)
+
+// block returns how long to refuse logins after the given number of failures,
+// and whether that's a lockout rather than a delay
+func (l loginLimit) block(failures int64) (time.Duration, bool) {
+	if failures >= l.lockAfter {
+		return l.lockout, true
+	}
+	if failures < l.delayAfter {
+		return 0, false
+	}
+
+	delay := time.Duration(math.Pow(2, float64(failures-l.delayAfter))) * time.Second
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay, false
+}
+
+// loginGuard throttles password guessing. Logins and the account endpoints
+// that ask for the current password count toward the same limits, so a stolen
+// access token doesn't buy unlimited guesses.
+type loginGuard struct {
+	db    *database.PostgresDB
+	redis *database.RedisClient
+}
+
+// loginSubjects pairs each limit with the subject it applies to for a login attempt
+func loginSubjects(c *fiber.Ctx, email string) map[string]loginLimit {
+	return map[string]loginLimit{
+		"email:" + email: emailLoginLimit,
+		"ip:" + c.IP():   ipLoginLimit,
+	}
+}
+
+// blockedFor returns how much longer password attempts for email from this
+// client are refused. Attempts are allowed if Redis can't be reached.
+func (g loginGuard) blockedFor(c *fiber.Ctx, email string) time.Duration {
+	if g.redis == nil {
+		return 0
+	}
+
+	var longest time.Duration
+	for subject := range loginSubjects(c, email) {
+		blocked, err := g.redis.LoginBlockedFor(subject)
+		if err != nil {
+			log.Printf("Failed to check login block for %s: %v", subject, err)
+			continue
+		}
+		if blocked > longest {
+			longest = blocked
+		}
+	}
+	return longest
+}
+
+// recordFailure counts a wrong password against the email address and the
+// client's IP address, delaying or locking out further attempts as needed.
+// Lockouts are written to the audit log; user is nil for unknown emails.
+func (g loginGuard) recordFailure(c *fiber.Ctx, email string, user *models.User) {
+	if g.redis == nil {
+		return
+	}
+
+	for subject, limit := range loginSubjects(c, email) {
+		failures, err := g.redis.RecordLoginFailure(subject, loginFailureWindow)
+		if err != nil {
+			log.Printf("Failed to record login failure for %s: %v", subject, err)
+			continue
+		}
+
+		duration, locked := limit.block(failures)
+		if duration == 0 {
+			continue
+		}
+		if err := g.redis.BlockLogin(subject, duration); err != nil {
+			log.Printf("Failed to block logins for %s: %v", subject, err)
+			continue
+		}
+
+		if locked {
+			g.auditLockout(c, limit, email, user, failures)
+		}
+	}
+}
+
+// clearFailures forgets an email address's wrong passwords after a right
+// one. The IP address's count is kept, since one good password doesn't make
+// the other attempts from it any less suspicious.
+func (g loginGuard) clearFailures(email string) {
+	if g.redis == nil {
+		return
+	}
+	if err := g.redis.ClearLoginFailures("email:" + email); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+}
+
+func (g loginGuard) auditLockout(c *fiber.Ctx, limit loginLimit, email string, user *models.User, failures int64) {
+	entry := &models.AuditEntry{
+		Action:    models.AuditLoginLockout,
+		IPAddress: c.IP(),
+		Details: map[string]interface{}{
+			"scope":          limit.scope,
+			"email":          email,
+			"failures":       failures,
+			"locked_seconds": int(limit.lockout.Seconds()),
+		},
+	}
+	if user != nil && limit.scope == emailLoginLimit.scope {
+		entry.UserID = &user.ID
+	}
+
+	log.Printf("Locked out logins by %s after %d failures (email %s, IP %s)", limit.scope, failures, email, c.IP())
+	if err := g.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write login lockout to the audit log: %v", err)
+	}
+}
+
+func tooManyLoginAttempts(c *fiber.Ctx, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+		Error:   "too_many_login_attempts",
+		Message: "Too many failed login attempts. Try again in " + strconv.Itoa(seconds) + " seconds.",
+		Code:    429,
+	})
+}
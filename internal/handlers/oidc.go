@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	// oidcLoginTTL is how long a sign-in at the identity provider may take
+	oidcLoginTTL = 10 * time.Minute
+	// oidcStateCookie ties the provider's callback to the browser that started
+	// the sign-in, so nobody can sign a victim into the attacker's account
+	oidcStateCookie = "oidc_state"
+)
+
+// UseOIDC enables sign-in with an OpenID Connect provider
+func (h *AuthHandler) UseOIDC(provider *auth.OIDCProvider) {
+	h.oidc = provider
+}
+
+// OIDCLogin sends the browser to the identity provider to sign in, using the
+// authorization code flow with PKCE
+func (h *AuthHandler) OIDCLogin(c *fiber.Ctx) error {
+	state, err := auth.NewOpaqueToken()
+	if err != nil {
+		return oidcUnavailable(c)
+	}
+	nonce, err := auth.NewOpaqueToken()
+	if err != nil {
+		return oidcUnavailable(c)
+	}
+	verifier, err := auth.NewPKCEVerifier()
+	if err != nil {
+		return oidcUnavailable(c)
+	}
+
+	authURL, err := h.oidc.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Printf("Failed to start sign-in with %s: %v", h.oidc.Name(), err)
+		return oidcUnavailable(c)
+	}
+
+	login := &models.OIDCLoginState{Nonce: nonce, Verifier: verifier}
+	if err := h.redis.SaveOIDCState(state, login, oidcLoginTTL); err != nil {
+		log.Printf("Failed to save sign-in state: %v", err)
+		return oidcUnavailable(c)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback finishes a sign-in at the identity provider. It finds the
+// account linked to the provider's identity, links one with the same verified
+// email address, or creates one, then hands our usual tokens to the frontend
+// in the fragment of its callback URL. An account whose address was never
+// verified is reclaimed from whoever registered it before it's linked.
+func (h *AuthHandler) OIDCCallback(c *fiber.Ctx) error {
+	state := c.Query("state")
+	cookieState := c.Cookies(oidcStateCookie)
+	c.ClearCookie(oidcStateCookie)
+
+	if providerError := c.Query("error"); providerError != "" {
+		return h.oidcRedirect(c, url.Values{"error": {providerError}})
+	}
+
+	if state == "" || state != cookieState {
+		return h.oidcRedirect(c, url.Values{"error": {"invalid_state"}})
+	}
+
+	login, err := h.redis.TakeOIDCState(state)
+	if err != nil {
+		log.Printf("Failed to load sign-in state: %v", err)
+		return h.oidcRedirect(c, url.Values{"error": {"server_error"}})
+	}
+	if login == nil {
+		return h.oidcRedirect(c, url.Values{"error": {"invalid_state"}})
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return h.oidcRedirect(c, url.Values{"error": {"invalid_request"}})
+	}
+
+	identity, err := h.oidc.Exchange(code, login.Verifier, login.Nonce)
+	if err != nil {
+		log.Printf("Failed to finish sign-in with %s: %v", h.oidc.Name(), err)
+		return h.oidcRedirect(c, url.Values{"error": {"sign_in_failed"}})
+	}
+
+	user, errorCode := h.oidcUser(c, identity)
+	if user == nil {
+		return h.oidcRedirect(c, url.Values{"error": {errorCode}})
+	}
+
+	tokens, err := h.issueTokens(user)
+	if err != nil {
+		return h.oidcRedirect(c, url.Values{"error": {"server_error"}})
+	}
+
+	return h.oidcRedirect(c, url.Values{
+		"token":         {tokens.Token},
+		"refresh_token": {tokens.RefreshToken},
+		"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+	})
+}
+
+// oidcUser returns the account to sign in with a provider's identity, linking
+// or creating one if needed. If it can't, it returns the error code to send
+// the frontend instead.
+func (h *AuthHandler) oidcUser(c *fiber.Ctx, identity *auth.OIDCIdentity) (*models.User, string) {
+	provider := h.oidc.Name()
+	email := normalizeEmail(identity.Email)
+
+	user, err := h.db.GetUserByIdentity(provider, identity.Subject)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, "server_error"
+	}
+
+	if user == nil {
+		// Linking by email is only safe if the provider checked the address
+		if email == "" || !identity.EmailVerified {
+			return nil, "email_not_verified"
+		}
+
+		user, err = h.db.GetUserByEmail(email)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, "server_error"
+		}
+		if user == nil {
+			if user, err = h.createOIDCUser(email, identity.Name); err != nil {
+				log.Printf("Failed to create user for %s identity %s: %v", provider, identity.Subject, err)
+				return nil, "server_error"
+			}
+		} else if user.EmailVerifiedAt == nil {
+			// Anyone can register an address they don't own. The provider shows
+			// this person does own it, so whoever registered it loses access.
+			if err := h.reclaimAccount(user); err != nil {
+				log.Printf("Failed to reclaim user %s for %s identity %s: %v", user.ID, provider, identity.Subject, err)
+				return nil, "server_error"
+			}
+		}
+
+		h.auditIdentityLinked(c, user, identity)
+	}
+
+	link := &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    email,
+	}
+	if err := h.db.LinkUserIdentity(link); err != nil {
+		log.Printf("Failed to link %s identity %s to user %s: %v", provider, identity.Subject, user.ID, err)
+		return nil, "server_error"
+	}
+
+	// The provider vouches for the address, so there's no need to mail a link
+	if user.EmailVerifiedAt == nil && identity.EmailVerified && strings.EqualFold(user.Email, email) {
+		if err := h.db.MarkEmailVerified(user.ID, user.Email); err != nil {
+			log.Printf("Failed to mark email of user %s verified: %v", user.ID, err)
+		} else {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := h.redis.InvalidateUser(user.ID.String()); err != nil {
+				log.Printf("Failed to clear cached row of user %s: %v", user.ID, err)
+			}
+		}
+	}
+
+	return user, ""
+}
+
+// createOIDCUser creates an account for someone signing in at an identity
+// provider for the first time. It gets a random password nobody knows; the
+// user can set one with a password reset.
+func (h *AuthHandler) createOIDCUser(email, name string) (*models.User, error) {
+	hashedPassword, err := unknownPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:               uuid.New(),
+		Email:            email,
+		PasswordHash:     hashedPassword,
+		SubscriptionTier: models.TierFree,
+		Interests:        []string{},
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+	if err := h.db.CreateUser(user); err != nil {
+		return nil, err
+	}
+
+	if name = strings.TrimSpace(name); name != "" {
+		user.DisplayName = name
+		if err := h.db.UpdateUser(user); err != nil {
+			log.Printf("Failed to save display name of user %s: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// reclaimAccount takes an account with an unverified email address away from
+// whoever registered it, before it's linked to the address's real owner. The
+// password is replaced with a random one and every session and API key ends.
+func (h *AuthHandler) reclaimAccount(user *models.User) error {
+	hashedPassword, err := unknownPasswordHash()
+	if err != nil {
+		return err
+	}
+
+	if err := h.db.ReclaimUserAccount(user.ID, hashedPassword); err != nil {
+		return err
+	}
+	user.PasswordHash = hashedPassword
+
+	return h.revokeAccessTokens(user.ID)
+}
+
+// unknownPasswordHash hashes a random password nobody knows
+func unknownPasswordHash() (string, error) {
+	password, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return auth.HashPassword(password)
+}
+
+func (h *AuthHandler) auditIdentityLinked(c *fiber.Ctx, user *models.User, identity *auth.OIDCIdentity) {
+	entry := &models.AuditEntry{
+		UserID:    &user.ID,
+		Action:    models.AuditIdentityLinked,
+		IPAddress: c.IP(),
+		Details: map[string]interface{}{
+			"provider": h.oidc.Name(),
+			"subject":  identity.Subject,
+			"email":    identity.Email,
+		},
+	}
+	if err := h.db.CreateAuditEntry(entry); err != nil {
+		log.Printf("Failed to write identity link to the audit log: %v", err)
+	}
+}
+
+// oidcRedirect sends the browser back to the frontend. Tokens and errors go in
+// the fragment, which browsers don't send to servers or in Referer headers.
+func (h *AuthHandler) oidcRedirect(c *fiber.Ctx, values url.Values) error {
+	return c.Redirect(h.appURL+"/auth/callback#"+values.Encode(), fiber.StatusFound)
+}
+
+func oidcUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+		Error:   "oidc_unavailable",
+		Message: "Sign-in with the identity provider is unavailable",
+		Code:    502,
+	})
+}
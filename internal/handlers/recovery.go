@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/mail"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link works
+	passwordResetTTL = time.Hour
+	// emailVerificationTTL is how long an email verification link works
+	emailVerificationTTL = 48 * time.Hour
+
+	// Requests for mailed links are limited per hour, so the endpoints can't be
+	// used to flood someone's inbox
+	mailRequestWindow   = 3600
+	mailRequestsPerIP   = 20
+	mailRequestsPerUser = 5
+)
+
+// ForgotPassword mails a password reset link. It answers the same way whether
+// or not an account exists, so it can't be used to find out who has one.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req models.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	email := normalizeEmail(req.Email)
+	if !h.allowMailRequest("password-reset:ip:"+c.IP(), mailRequestsPerIP) ||
+		!h.allowMailRequest("password-reset:email:"+email, mailRequestsPerUser) {
+		return tooManyMailRequests(c)
+	}
+
+	accepted := fiber.Map{
+		"message": "If an account uses that email address, a password reset link is on its way",
+	}
+
+	user, err := h.db.GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusAccepted).JSON(accepted)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve user",
+			Code:    500,
+		})
+	}
+
+	// The token is bound to the current password, so it stops working once the
+	// password changes, whichever way that happens
+	token, err := h.jwtService.GenerateActionToken(user.ID, auth.PurposePasswordReset, auth.TokenBinding(user.PasswordHash), passwordResetTTL)
+	if err != nil {
+		return tokenGenerationFailed(c)
+	}
+
+	err = h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Someone asked to reset the password of your Idea Collision Engine account. Choose a new password here:\n\n%s/reset-password?token=%s\n\nThe link works for one hour and only once. If you didn't ask for this, ignore this email; your password hasn't changed.",
+			h.appURL, token),
+	})
+	if err != nil {
+		// Answer as usual; a failure here shouldn't reveal that the account exists
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(accepted)
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and logs
+// the account out everywhere
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	claims, user, err := h.actionTokenUser(req.Token, auth.PurposePasswordReset)
+	if err != nil {
+		return actionTokenError(c, err)
+	}
+	if claims.Binding != auth.TokenBinding(user.PasswordHash) {
+		return invalidActionToken(c)
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "hash_failed",
+			Message: "Failed to hash password",
+			Code:    500,
+		})
+	}
+
+	if err := h.db.ResetUserPassword(usedActionToken(claims, user.ID), hashedPassword); err != nil {
+		return actionTokenError(c, err)
+	}
+
+	// Refresh tokens were revoked with the password; access tokens go too
+	if err := h.revokeAccessTokens(user.ID); err != nil {
+		log.Printf("Failed to revoke access tokens of user %s after a password reset: %v", user.ID, err)
+	}
+
+	if err := h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Your password was reset",
+		Text:    "The password of your Idea Collision Engine account was just reset and every device was signed out. If this wasn't you, reset your password again and contact support.",
+	}); err != nil {
+		log.Printf("Failed to send password reset notice to user %s: %v", user.ID, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password updated. Please sign in again.",
+	})
+}
+
+// VerifyEmail marks the account's email address as verified. It only needs
+// the mailed token, so the link works from any device.
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid request body",
+			Code:    400,
+		})
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+
+	claims, user, err := h.actionTokenUser(req.Token, auth.PurposeEmailVerification)
+	if err != nil {
+		return actionTokenError(c, err)
+	}
+	// A token for an earlier address doesn't verify the current one
+	if claims.Binding != emailBinding(user.Email) {
+		return invalidActionToken(c)
+	}
+
+	// Opening the link twice is harmless
+	if user.EmailVerifiedAt == nil {
+		if err := h.db.VerifyUserEmail(usedActionToken(claims, user.ID), user.Email); err != nil {
+			return actionTokenError(c, err)
+		}
+		invalidateCachedUser(h.redis, user.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email address verified",
+	})
+}
+
+// ResendVerification mails a new verification link to the signed-in user
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.db.GetUserByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+			Code:    404,
+		})
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Error:   "already_verified",
+			Message: "Your email address is already verified",
+			Code:    409,
+		})
+	}
+
+	if !h.allowMailRequest("verify-email:user:"+userID.String(), mailRequestsPerUser) {
+		return tooManyMailRequests(c)
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "email_send_failed",
+			Message: "Failed to send the verification email",
+			Code:    502,
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Check your inbox for a verification link",
+	})
+}
+
+// sendVerificationEmail mails a link that verifies the user's current email address
+func (h *AuthHandler) sendVerificationEmail(user *models.User) error {
+	token, err := h.jwtService.GenerateActionToken(user.ID, auth.PurposeEmailVerification, emailBinding(user.Email), emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Welcome to Idea Collision Engine! Verify your email address to start generating collisions:\n\n%s/verify-email?token=%s\n\nThe link works for 48 hours.",
+			h.appURL, token),
+	})
+}
+
+// actionTokenUser validates an action token and loads the user it was issued to
+func (h *AuthHandler) actionTokenUser(token, purpose string) (*auth.ActionClaims, *models.User, error) {
+	claims, err := h.jwtService.ValidateActionToken(token, purpose)
+	if err != nil {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	user, err := h.db.GetUserByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, user, nil
+}
+
+// allowMailRequest counts a request for a mailed link against key's hourly limit.
+// Requests are allowed if Redis can't be reached.
+func (h *AuthHandler) allowMailRequest(key string, limit int) bool {
+	if h.redis == nil {
+		return true
+	}
+	allowed, err := h.redis.CheckRateLimit(key, mailRequestWindow, limit)
+	if err != nil {
+		return true
+	}
+	return allowed
+}
+
+// usedActionToken is the record that makes an action token single use
+func usedActionToken(claims *auth.ActionClaims, userID uuid.UUID) *models.UsedActionToken {
+	tokenID, _ := uuid.Parse(claims.ID)
+	return &models.UsedActionToken{
+		TokenID:   tokenID,
+		UserID:    userID,
+		Purpose:   claims.Purpose,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+}
+
+// emailBinding binds verification tokens to an email address
+func emailBinding(email string) string {
+	return auth.TokenBinding(strings.ToLower(email))
+}
+
+// actionTokenError answers a failed action token: unknown users and used or
+// invalid tokens all look the same to the client
+func actionTokenError(c *fiber.Ctx, err error) error {
+	if err == sql.ErrNoRows || err == database.ErrActionTokenUsed {
+		return invalidActionToken(c)
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to use token",
+		Code:    500,
+	})
+}
+
+func invalidActionToken(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_token",
+		Message: "This link is invalid, has expired or was already used",
+		Code:    400,
+	})
+}
+
+func tooManyMailRequests(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+		Error:   "rate_limit_exceeded",
+		Message: "Too many requests. Please try again later.",
+		Code:    429,
+	})
+}
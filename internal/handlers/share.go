@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/export"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	shareViewWindow  = 30 * time.Minute // repeat views by the same viewer within this window count once
+	shareRateLimit   = 120              // public share requests per IP per minute
+	shareSiteName    = "Idea Collision Engine"
+	shareDescription = 200 // max characters of the connection used as the preview description
+)
+
+//go:embed templates/share.html.tmpl
+var sharePageSource string
+
+var sharePageTemplate = template.Must(template.New("share").Parse(sharePageSource))
+
+// shareTokenLength is the length of an encoded share token
+var shareTokenLength = base64.RawURLEncoding.EncodedLen(auth.OpaqueTokenBytes)
+
+// ShareHandler manages public share links for collision sessions
+type ShareHandler struct {
+	db        *database.PostgresDB
+	redis     *database.RedisClient
+	validator *validator.Validate
+	publicURL string
+}
+
+func NewShareHandler(db *database.PostgresDB, redis *database.RedisClient, publicURL string) *ShareHandler {
+	return &ShareHandler{
+		db:        db,
+		redis:     redis,
+		validator: validator.New(),
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// CreateShareLink creates a public link to one of the user's collision sessions
+func (h *ShareHandler) CreateShareLink(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	var req models.CreateShareLinkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid request body",
+				Code:    400,
+			})
+		}
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: "expires_in_days must be between 1 and 365",
+			Code:    400,
+		})
+	}
+
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "share_failed",
+			Message: "Failed to create share link",
+			Code:    500,
+		})
+	}
+
+	link := &models.ShareLink{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		UserID:    userID,
+		Token:     token,
+		TokenHash: auth.HashToken(token),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.CreateShareLink(link); err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "share_failed",
+			Message: "Failed to create share link",
+			Code:    500,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(h.present(*link))
+}
+
+// ListShareLinks returns the share links of one of the user's collision sessions
+func (h *ShareHandler) ListShareLinks(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	links, err := h.db.GetShareLinks(sessionID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to retrieve share links",
+			Code:    500,
+		})
+	}
+
+	presented := make([]models.ShareLink, 0, len(links))
+	for _, link := range links {
+		presented = append(presented, h.present(link))
+	}
+
+	return c.JSON(fiber.Map{
+		"share_links": presented,
+	})
+}
+
+// RevokeShareLink permanently disables a share link
+func (h *ShareHandler) RevokeShareLink(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	linkID, err := uuid.Parse(c.Params("linkId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_share_link_id",
+			Message: "Invalid share link ID",
+			Code:    400,
+		})
+	}
+
+	if err := h.db.RevokeShareLink(linkID, sessionID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "share_link_not_found",
+				Message: "Share link not found or already revoked",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to revoke share link",
+			Code:    500,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Share link revoked",
+	})
+}
+
+// GetSharedCollision returns a shared collision without authentication
+func (h *ShareHandler) GetSharedCollision(c *fiber.Ctx) error {
+	if !h.allowPublicRequest(c) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(models.ErrorResponse{
+			Error:   "rate_limit_exceeded",
+			Message: "Too many requests, try again in a minute",
+			Code:    429,
+		})
+	}
+
+	shared, err := h.openShare(c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "share_not_found",
+				Message: "This share link doesn't exist, has expired or was revoked",
+				Code:    404,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to load shared collision",
+			Code:    500,
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set("X-Robots-Tag", "noindex")
+	return c.JSON(shared)
+}
+
+// SharePage renders a shared collision as a standalone page with OpenGraph tags,
+// so the link unfurls with a title and description in chat apps and social media
+func (h *ShareHandler) SharePage(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set("X-Robots-Tag", "noindex")
+	c.Type("html", "utf-8")
+
+	if !h.allowPublicRequest(c) {
+		return c.Status(fiber.StatusTooManyRequests).SendString("<!DOCTYPE html><title>Too many requests</title><p>Too many requests, try again in a minute.</p>")
+	}
+
+	shared, err := h.openShare(c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).SendString("<!DOCTYPE html><title>Link unavailable</title><p>This share link doesn't exist, has expired or was revoked.</p>")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("<!DOCTYPE html><title>Error</title><p>Something went wrong loading this collision.</p>")
+	}
+
+	var page strings.Builder
+	if err := sharePageTemplate.Execute(&page, shared); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("<!DOCTYPE html><title>Error</title><p>Something went wrong loading this collision.</p>")
+	}
+	return c.SendString(page.String())
+}
+
+// openShare loads the collision behind the :token parameter and counts the view.
+// Returns sql.ErrNoRows for unknown, revoked or expired links and for sessions
+// that have since been deleted.
+func (h *ShareHandler) openShare(c *fiber.Ctx) (*models.SharedCollision, error) {
+	token := c.Params("token")
+	if len(token) != shareTokenLength {
+		return nil, sql.ErrNoRows
+	}
+
+	link, err := h.db.GetActiveShareLink(auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	link.Token = token
+
+	session, err := h.db.GetCollisionSession(link.SessionID, link.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.countView(c, link.ID) {
+		if views, err := h.db.RecordShareView(link.ID); err != nil {
+			log.Printf("Failed to record share view: %v", err)
+		} else {
+			link.ViewCount = views
+		}
+	}
+
+	return h.sharedCollision(session, link), nil
+}
+
+// countView reports whether a request should count as a new view: link preview
+// bots and repeat views by the same viewer within shareViewWindow don't count
+func (h *ShareHandler) countView(c *fiber.Ctx, linkID uuid.UUID) bool {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if isLinkPreviewBot(userAgent) {
+		return false
+	}
+	if h.redis == nil {
+		return true
+	}
+
+	fingerprint := sha256.Sum256([]byte(c.IP() + "|" + userAgent))
+	first, err := h.redis.MarkShareViewed(linkID.String(), hex.EncodeToString(fingerprint[:8]), shareViewWindow)
+	if err != nil {
+		// Count the view rather than lose it if Redis is down
+		return true
+	}
+	return first
+}
+
+// allowPublicRequest limits unauthenticated share requests per IP address
+func (h *ShareHandler) allowPublicRequest(c *fiber.Ctx) bool {
+	if h.redis == nil {
+		return true
+	}
+	allowed, err := h.redis.CheckRateLimit("share:"+c.IP(), 60, shareRateLimit)
+	if err != nil {
+		return true
+	}
+	return allowed
+}
+
+// sharedCollision builds the public view of a session, leaving out everything
+// that identifies its owner or was meant for them alone
+func (h *ShareHandler) sharedCollision(session *models.CollisionSession, link *models.ShareLink) *models.SharedCollision {
+	result := session.CollisionResult
+
+	description := result.Connection
+	if description == "" {
+		description = fmt.Sprintf("An idea collision between %s and %s", result.PrimaryDomain, result.CollisionDomain)
+	}
+
+	return &models.SharedCollision{
+		CurrentProject:  session.InputData.CurrentProject,
+		ProjectType:     session.InputData.ProjectType,
+		Intensity:       session.InputData.CollisionIntensity,
+		PrimaryDomain:   result.PrimaryDomain,
+		CollisionDomain: result.CollisionDomain,
+		Connection:      result.Connection,
+		SparkQuestions:  nonNil(result.SparkQuestions),
+		Examples:        nonNil(result.Examples),
+		NextSteps:       nonNil(result.NextSteps),
+		QualityScore:    result.QualityScore,
+		CreatedAt:       session.CreatedAt,
+		ViewCount:       link.ViewCount,
+		Meta: models.ShareMeta{
+			Title:       export.Title(*session),
+			Description: truncateText(description, shareDescription),
+			URL:         h.shareURL(link.Token),
+			Type:        "article",
+			SiteName:    shareSiteName,
+		},
+	}
+}
+
+// present fills in the fields of a share link that aren't stored. Only a new
+// link has its token, and with it a URL; stored links only have its hash.
+func (h *ShareHandler) present(link models.ShareLink) models.ShareLink {
+	if link.Token != "" {
+		link.URL = h.shareURL(link.Token)
+	}
+	link.Active = link.RevokedAt == nil && (link.ExpiresAt == nil || link.ExpiresAt.After(time.Now()))
+	return link
+}
+
+func (h *ShareHandler) shareURL(token string) string {
+	return h.publicURL + "/share/" + token
+}
+
+// linkPreviewAgents are user agent fragments of crawlers that fetch pages to
+// build link previews
+var linkPreviewAgents = []string{
+	"bot", "crawler", "spider", "facebookexternalhit", "embedly", "slack", "whatsapp", "telegram", "discord", "skype",
+}
+
+func isLinkPreviewBot(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, agent := range linkPreviewAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateText shortens s to at most max characters, ending with an ellipsis if cut
+func truncateText(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
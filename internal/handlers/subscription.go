@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,23 +11,27 @@ import (
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/checkout/session"
 	"github.com/stripe/stripe-go/v76/customer"
+	"github.com/stripe/stripe-go/v76/webhook"
 
+	"idea-collision-engine-api/internal/billing"
 	"idea-collision-engine-api/internal/database"
 	"idea-collision-engine-api/internal/middleware"
 	"idea-collision-engine-api/internal/models"
 )
 
 type SubscriptionHandler struct {
-	db    *database.PostgresDB
-	redis *database.RedisClient
+	db            *database.PostgresDB
+	redis         *database.RedisClient
+	webhookSecret string
 }
 
-func NewSubscriptionHandler(db *database.PostgresDB, redis *database.RedisClient, stripeKey string) *SubscriptionHandler {
+func NewSubscriptionHandler(db *database.PostgresDB, redis *database.RedisClient, stripeKey, webhookSecret string) *SubscriptionHandler {
 	stripe.Key = stripeKey
 	
 	return &SubscriptionHandler{
-		db:    db,
-		redis: redis,
+		db:            db,
+		redis:         redis,
+		webhookSecret: webhookSecret,
 	}
 }
 
@@ -35,6 +41,12 @@ const (
 	TeamMonthlyPriceID = "price_team_monthly" // Replace with actual Stripe price ID
 )
 
+// planPrices maps the Stripe price of each plan to its tier
+var planPrices = map[string]string{
+	ProMonthlyPriceID:  models.TierPro,
+	TeamMonthlyPriceID: models.TierTeam,
+}
+
 // CreateCheckoutSession creates a Stripe checkout session for subscription
 func (h *SubscriptionHandler) CreateCheckoutSession(c *fiber.Ctx) error {
 	userID, err := middleware.GetUserIDFromContext(c)
@@ -108,6 +120,12 @@ func (h *SubscriptionHandler) CreateCheckoutSession(c *fiber.Ctx) error {
 		Metadata: map[string]string{
 			"user_id": userID.String(),
 		},
+		// Subscription events name the user through this metadata
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: map[string]string{
+				"user_id": userID.String(),
+			},
+		},
 	}
 	
 	session, err := session.New(params)
@@ -180,7 +198,10 @@ func (h *SubscriptionHandler) CancelSubscription(c *fiber.Ctx) error {
 	})
 }
 
-// WebhookHandler handles Stripe webhooks
+// WebhookHandler handles Stripe webhooks. Subscription events set the
+// subscriber's tier and the seats of the team they own; other events are
+// acknowledged and ignored. Failures to apply a change return an error so
+// Stripe retries the event.
 func (h *SubscriptionHandler) WebhookHandler(c *fiber.Ctx) error {
 	// Get the webhook signature
 	sig := c.Get("Stripe-Signature")
@@ -192,17 +213,55 @@ func (h *SubscriptionHandler) WebhookHandler(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Get the request body
-	body := c.Body()
+	event, err := webhook.ConstructEventWithOptions(c.Body(), sig, h.webhookSecret, webhook.ConstructEventOptions{
+		IgnoreAPIVersionMismatch: true,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_signature",
+			Message: "Invalid Stripe signature",
+			Code:    400,
+		})
+	}
 	
-	// In a real implementation, you'd:
-	// 1. Verify the webhook signature
-	// 2. Parse the webhook event
-	// 3. Handle different event types (customer.subscription.created, etc.)
-	// 4. Update user subscription status in database
+	switch event.Type {
+	case stripe.EventTypeCustomerSubscriptionCreated,
+		stripe.EventTypeCustomerSubscriptionUpdated,
+		stripe.EventTypeCustomerSubscriptionDeleted:
+	default:
+		return c.JSON(fiber.Map{
+			"received": true,
+		})
+	}
+	
+	var sub stripe.Subscription
+	if err := json.Unmarshal(event.Data.Raw, &sub); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_event",
+			Message: "Invalid subscription event",
+			Code:    400,
+		})
+	}
 	
-	// For now, return success
-	fmt.Printf("Received Stripe webhook: %s\n", string(body))
+	change, err := billing.PlanChangeFor(&sub, planPrices)
+	if err != nil {
+		// Retrying won't fix a subscription we can't place, so acknowledge it
+		log.Printf("Ignoring Stripe event %s: %v", event.ID, err)
+		return c.JSON(fiber.Map{
+			"received": true,
+		})
+	}
+	
+	if err := h.UpdateSubscriptionTier(change.UserID, change.Tier); err != nil {
+		log.Printf("Failed to apply Stripe event %s: %v", event.ID, err)
+		return subscriptionUpdateFailed(c)
+	}
+	if change.Tier == models.TierTeam {
+		if err := h.UpdateTeamSeats(change.UserID, change.Seats); err != nil {
+			log.Printf("Failed to apply Stripe event %s: %v", event.ID, err)
+			return subscriptionUpdateFailed(c)
+		}
+	}
 	
 	return c.JSON(fiber.Map{
 		"received": true,
@@ -269,6 +328,7 @@ func (h *SubscriptionHandler) GetPricingPlans(c *fiber.Ctx) error {
 				"premium_domains":     true,
 				"ai_enhancement":      true,
 				"team_features":       true,
+				"team_seats":          models.TeamIncludedSeats,
 			},
 		},
 	}
@@ -310,12 +370,34 @@ func (h *SubscriptionHandler) getTierFeatures(tier string) []string {
 
 // UpdateSubscriptionTier updates user's subscription tier (called from webhook)
 func (h *SubscriptionHandler) UpdateSubscriptionTier(userID uuid.UUID, tier string) error {
-	// In a real implementation, you'd update the user's subscription tier in the database
-	// For now, this is a placeholder
-	fmt.Printf("Updating user %s to tier %s\n", userID, tier)
+	if err := h.db.UpdateUserSubscriptionTier(userID, tier); err != nil {
+		return fmt.Errorf("failed to update subscription tier of user %s: %w", userID, err)
+	}
 	
-	// Invalidate user cache
-	h.redis.InvalidateUserUsage(userID.String())
+	invalidateCachedUser(h.redis, userID)
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// UpdateTeamSeats records the seat quantity of a user's Team subscription and
+// applies it to the team they own, or to the one they create later (called
+// from webhook)
+func (h *SubscriptionHandler) UpdateTeamSeats(ownerID uuid.UUID, seats int) error {
+	if seats < 1 {
+		return fmt.Errorf("invalid seat count %d", seats)
+	}
+	
+	if err := h.db.UpdateTeamSeats(ownerID, seats); err != nil {
+		return fmt.Errorf("failed to update team seats of user %s: %w", ownerID, err)
+	}
+	
+	return nil
+}
+
+func subscriptionUpdateFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "subscription_update_failed",
+		Message: "Failed to apply subscription change",
+		Code:    500,
+	})
+}
@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/mail"
+	"idea-collision-engine-api/internal/middleware"
+	"idea-collision-engine-api/internal/models"
+)
+
+// teamInvitationTTL is how long the link in a team invitation works
+const teamInvitationTTL = 7 * 24 * time.Hour
+
+// TeamHandler manages teams, their members and invitations, and the sessions
+// members share with them. Routes with a :teamId go through
+// middleware.RequireTeamRole, which checks membership and role.
+type TeamHandler struct {
+	db        *database.PostgresDB
+	mailer    mail.Mailer
+	validator *validator.Validate
+	appURL    string
+}
+
+func NewTeamHandler(db *database.PostgresDB, mailer mail.Mailer, appURL string) *TeamHandler {
+	return &TeamHandler{
+		db:        db,
+		mailer:    mailer,
+		validator: validator.New(),
+		appURL:    strings.TrimRight(appURL, "/"),
+	}
+}
+
+// ListTeams lists the teams the user belongs to, with their role in each
+func (h *TeamHandler) ListTeams(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	teams, err := h.db.GetUserTeams(userID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"teams": teams,
+		"count": len(teams),
+	})
+}
+
+// CreateTeam creates a team owned by the user. It needs the Team plan, which
+// pays for the team's seats, and each user can own one team.
+func (h *TeamHandler) CreateTeam(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if middleware.GetSubscriptionTierFromContext(c) != models.TierTeam {
+		return c.Status(fiber.StatusPaymentRequired).JSON(models.ErrorResponse{
+			Error:   "team_plan_required",
+			Message: "Creating a team requires the Team plan",
+			Code:    402,
+		})
+	}
+
+	var req models.CreateTeamRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	team := &models.Team{
+		Name:       strings.TrimSpace(req.Name),
+		OwnerID:    userID,
+		PlanActive: true,
+	}
+	if err := h.db.CreateTeam(team); err != nil {
+		if err == database.ErrTeamExists {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error:   "team_exists",
+				Message: "You already own a team",
+				Code:    409,
+			})
+		}
+		return teamDatabaseError(c)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(team)
+}
+
+// GetTeam returns a team with its members
+func (h *TeamHandler) GetTeam(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	team, err := h.db.GetTeam(member.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+	team.Role = member.Role
+
+	members, err := h.db.GetTeamMembers(member.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"team":    team,
+		"members": members,
+	})
+}
+
+// UpdateTeam renames a team
+func (h *TeamHandler) UpdateTeam(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.UpdateTeamRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	if err := h.db.UpdateTeamName(member.TeamID, strings.TrimSpace(req.Name)); err != nil {
+		return teamDatabaseError(c)
+	}
+
+	team, err := h.db.GetTeam(member.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+	team.Role = member.Role
+
+	return c.JSON(team)
+}
+
+// DeleteTeam deletes a team. Members keep the sessions they shared with it.
+func (h *TeamHandler) DeleteTeam(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.db.DeleteTeam(member.TeamID); err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Team deleted",
+		"id":      member.TeamID,
+	})
+}
+
+// InviteMember mails an invitation to join the team. The invitation holds a
+// seat until it's accepted, revoked or expires. Only the owner can invite admins.
+func (h *TeamHandler) InviteMember(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.InviteTeamMemberRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.TeamRoleMember
+	}
+	if role == models.TeamRoleAdmin && member.Role != models.TeamRoleOwner {
+		return insufficientTeamRole(c, "Only the team owner can invite admins")
+	}
+
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return tokenGenerationFailed(c)
+	}
+
+	invitation := &models.TeamInvitation{
+		TeamID:    member.TeamID,
+		Email:     normalizeEmail(req.Email),
+		Role:      role,
+		TokenHash: auth.HashToken(token),
+		InvitedBy: &member.UserID,
+		ExpiresAt: time.Now().Add(teamInvitationTTL),
+	}
+	if err := h.db.CreateTeamInvitation(invitation); err != nil {
+		switch err {
+		case database.ErrAlreadyTeamMember:
+			return alreadyTeamMember(c)
+		case database.ErrTeamFull:
+			return teamFull(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	team, err := h.db.GetTeam(member.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	inviter := member.DisplayName
+	if inviter == "" {
+		inviter = member.Email
+	}
+	err = h.mailer.Send(mail.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("Join %s on Idea Collision Engine", team.Name),
+		Text: fmt.Sprintf("%s invited you to join the team %s on Idea Collision Engine:\n\n%s/teams/join?token=%s\n\nThe link works for 7 days. Sign in or create an account with this email address to accept.",
+			inviter, team.Name, h.appURL, token),
+	})
+	if err != nil {
+		log.Printf("Failed to send team invitation to %s: %v", invitation.Email, err)
+		// Free the seat; nobody can use an invitation they never got
+		if err := h.db.RevokeTeamInvitation(invitation.ID, invitation.TeamID); err != nil {
+			log.Printf("Failed to revoke unsent team invitation %s: %v", invitation.ID, err)
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(models.ErrorResponse{
+			Error:   "email_send_failed",
+			Message: "Failed to send the invitation email",
+			Code:    502,
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+// ListInvitations lists a team's pending invitations
+func (h *TeamHandler) ListInvitations(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := h.db.GetTeamInvitations(member.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"invitations": invitations,
+		"count":       len(invitations),
+	})
+}
+
+// RevokeInvitation cancels a pending invitation, freeing its seat
+func (h *TeamHandler) RevokeInvitation(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	invitationID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "invalid_invitation_id",
+			Message: "Invalid invitation ID",
+			Code:    400,
+		})
+	}
+
+	if err := h.db.RevokeTeamInvitation(invitationID, member.TeamID); err != nil {
+		if err == sql.ErrNoRows {
+			return invitationNotFound(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Invitation revoked",
+		"id":      invitationID,
+	})
+}
+
+// AcceptInvitation joins a team with the token from an invitation email. The
+// account's email address must be the one the invitation was sent to.
+func (h *TeamHandler) AcceptInvitation(c *fiber.Ctx) error {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.AcceptTeamInvitationRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	user, err := h.db.GetUserByID(userID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	invitation, err := h.db.AcceptTeamInvitation(auth.HashToken(req.Token), user)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return invitationNotFound(c)
+		case database.ErrInvitationEmailMismatch:
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "invitation_email_mismatch",
+				Message: "This invitation was sent to another email address",
+				Code:    403,
+			})
+		case database.ErrAlreadyTeamMember:
+			return alreadyTeamMember(c)
+		case database.ErrTeamFull:
+			return teamFull(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	team, err := h.db.GetTeam(invitation.TeamID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+	team.Role = invitation.Role
+
+	return c.JSON(team)
+}
+
+// UpdateMemberRole makes a member an admin or an admin a member. The owner's
+// role can't be changed.
+func (h *TeamHandler) UpdateMemberRole(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	targetID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return invalidTeamRequest(c, "Invalid user ID")
+	}
+
+	var req models.UpdateTeamMemberRequest
+	if ok, err := h.parse(c, &req); !ok {
+		return err
+	}
+
+	if err := h.db.UpdateTeamMemberRole(member.TeamID, targetID, req.Role); err != nil {
+		if err == sql.ErrNoRows {
+			return teamMemberNotFound(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	updated, err := h.db.GetTeamMember(member.TeamID, targetID)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(updated)
+}
+
+// RemoveMember takes someone off the team, along with the sessions they shared
+// with it. Anyone can leave; otherwise admins remove members and the owner
+// removes anyone. The owner can't leave, only delete the team.
+func (h *TeamHandler) RemoveMember(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	targetID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return invalidTeamRequest(c, "Invalid user ID")
+	}
+
+	if targetID == member.UserID {
+		if member.Role == models.TeamRoleOwner {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error:   "owner_cannot_leave",
+				Message: "The owner can't leave the team; delete it instead",
+				Code:    400,
+			})
+		}
+	} else {
+		target, err := h.db.GetTeamMember(member.TeamID, targetID)
+		if err == sql.ErrNoRows {
+			return teamMemberNotFound(c)
+		}
+		if err != nil {
+			return teamDatabaseError(c)
+		}
+		if models.TeamRoleRanks[member.Role] <= models.TeamRoleRanks[target.Role] {
+			return insufficientTeamRole(c, "You can only remove members with a lower role than yours")
+		}
+	}
+
+	if err := h.db.RemoveTeamMember(member.TeamID, targetID); err != nil {
+		if err == sql.ErrNoRows {
+			return teamMemberNotFound(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Member removed",
+		"user_id": targetID,
+	})
+}
+
+// GetTeamHistory returns the sessions members shared with the team, most
+// recently shared first (?limit=, up to 100)
+func (h *TeamHandler) GetTeamHistory(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	history, err := h.db.GetTeamHistory(member.TeamID, limit)
+	if err != nil {
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"team_id":    member.TeamID,
+		"collisions": history,
+		"count":      len(history),
+	})
+}
+
+// ShareSession adds one of the user's sessions to the team's history
+func (h *TeamHandler) ShareSession(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("sessionId"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	sharedAt, err := h.db.ShareSessionWithTeam(member.TeamID, sessionID, member.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return sessionNotFound(c)
+		}
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"team_id":    member.TeamID,
+		"session_id": sessionID,
+		"shared_at":  sharedAt,
+	})
+}
+
+// UnshareSession takes a session out of the team's history. Members can take
+// out what they shared; admins can take out anything.
+func (h *TeamHandler) UnshareSession(c *fiber.Ctx) error {
+	member, err := middleware.GetTeamMemberFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := uuid.Parse(c.Params("sessionId"))
+	if err != nil {
+		return invalidSessionID(c)
+	}
+
+	sharedBy := &member.UserID
+	if models.TeamRoleRanks[member.Role] >= models.TeamRoleRanks[models.TeamRoleAdmin] {
+		sharedBy = nil
+	}
+
+	if err := h.db.UnshareSessionFromTeam(member.TeamID, sessionID, sharedBy); err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error:   "session_not_shared",
+				Message: "You haven't shared this session with the team",
+				Code:    404,
+			})
+		}
+		return teamDatabaseError(c)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "Session removed from the team",
+		"session_id": sessionID,
+	})
+}
+
+// parse reads and validates a request body into req. If it writes an error
+// response it returns false, along with the error from writing it.
+func (h *TeamHandler) parse(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, invalidTeamRequest(c, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error:   "validation_failed",
+			Message: err.Error(),
+			Code:    400,
+		})
+	}
+	return true, nil
+}
+
+func invalidTeamRequest(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+		Error:   "invalid_request",
+		Message: message,
+		Code:    400,
+	})
+}
+
+func insufficientTeamRole(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+		Error:   "insufficient_team_role",
+		Message: message,
+		Code:    403,
+	})
+}
+
+func alreadyTeamMember(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "already_team_member",
+		Message: "Already a member of this team",
+		Code:    409,
+	})
+}
+
+func teamFull(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+		Error:   "team_full",
+		Message: "Every seat on the team is taken. Revoke an invitation, remove a member or add seats to the Team plan.",
+		Code:    409,
+	})
+}
+
+func invitationNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "invitation_not_found",
+		Message: "This invitation is invalid, was revoked or has expired",
+		Code:    404,
+	})
+}
+
+func teamMemberNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "member_not_found",
+		Message: "No such member on this team",
+		Code:    404,
+	})
+}
+
+func teamDatabaseError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error:   "database_error",
+		Message: "Failed to manage team",
+		Code:    500,
+	})
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes each message to its own .eml file in a directory, where
+// it can be opened with a mail client. It is meant for local development and
+// tests that need to read the links that were sent.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("mail directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+	data, err := compose(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	// Names sort in the order the messages were sent
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), uuid.NewString()[:8])
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
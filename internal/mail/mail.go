@@ -0,0 +1,109 @@
+// Package mail sends transactional email such as verification links
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Drivers select the Mailer built by New
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+	DriverSMTP = "smtp"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config configures the Mailer built by New
+type Config struct {
+	Driver       string // log, file or smtp
+	From         string // sender address, optionally with a display name
+	Dir          string // directory the file driver writes .eml files to
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New builds the Mailer selected by cfg.Driver
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverLog, "":
+		return NewLogMailer(), nil
+	case DriverFile:
+		return NewFileMailer(cfg.Dir, cfg.From)
+	case DriverSMTP:
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// LogMailer writes messages to the server log instead of sending them. It is
+// meant for local development, where links can be copied from the log.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, strings.TrimSpace(msg.Text))
+	return nil
+}
+
+// compose renders msg as an RFC 5322 message from the given sender
+func compose(from string, msg Message, now time.Time) ([]byte, error) {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("email headers must not contain line breaks")
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+
+	domain := sender.Address[strings.LastIndex(sender.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", sender.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailTestSuite struct {
+	suite.Suite
+}
+
+func (suite *MailTestSuite) TestCompose() {
+	sent := time.Date(2026, 3, 9, 14, 30, 0, 0, time.UTC)
+	data, err := compose("Idea Collision Engine <no-reply@example.com>", Message{
+		To:      "ada@example.com",
+		Subject: "Réinitialiser le mot de passe",
+		Text:    "Open this link:\nhttps://app.example.com/reset-password?token=abc=def",
+	}, sent)
+	assert.NoError(suite.T(), err)
+
+	msg, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), `"Idea Collision Engine" <no-reply@example.com>`, msg.Header.Get("From"))
+	assert.Equal(suite.T(), "<ada@example.com>", msg.Header.Get("To"))
+	assert.True(suite.T(), strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Réinitialiser le mot de passe", subject)
+
+	date, err := msg.Header.Date()
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), sent.Equal(date))
+
+	// The body is quoted-printable, so "=" in links is escaped
+	body, err := io.ReadAll(msg.Body)
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(body), "token=3Dabc=3Ddef")
+}
+
+func (suite *MailTestSuite) TestComposeRejectsHeaderInjection() {
+	_, err := compose("no-reply@example.com", Message{
+		To:      "ada@example.com",
+		Subject: "Hello\r\nBcc: everyone@example.com",
+	}, time.Now())
+	assert.Error(suite.T(), err)
+
+	_, err = compose("no-reply@example.com", Message{To: "not an address"}, time.Now())
+	assert.Error(suite.T(), err)
+}
+
+func (suite *MailTestSuite) TestFileMailer() {
+	dir := filepath.Join(suite.T().TempDir(), "mail")
+	mailer, err := New(Config{Driver: DriverFile, Dir: dir, From: "no-reply@example.com"})
+	assert.NoError(suite.T(), err)
+
+	assert.NoError(suite.T(), mailer.Send(Message{To: "ada@example.com", Subject: "First", Text: "one"}))
+	assert.NoError(suite.T(), mailer.Send(Message{To: "ada@example.com", Subject: "Second", Text: "two"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), files, 2)
+
+	data, err := os.ReadFile(files[1])
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(data), "Subject: Second\r\n")
+}
+
+func (suite *MailTestSuite) TestNew() {
+	mailer, err := New(Config{})
+	assert.NoError(suite.T(), err)
+	assert.IsType(suite.T(), &LogMailer{}, mailer)
+
+	_, err = New(Config{Driver: DriverSMTP, From: "no-reply@example.com"})
+	assert.Error(suite.T(), err)
+
+	mailer, err = New(Config{Driver: DriverSMTP, SMTPHost: "smtp.example.com", SMTPPort: 587, From: "no-reply@example.com"})
+	assert.NoError(suite.T(), err)
+	assert.IsType(suite.T(), &SMTPMailer{}, mailer)
+
+	_, err = New(Config{Driver: "pigeon"})
+	assert.Error(suite.T(), err)
+}
+
+func TestMailTestSuite(t *testing.T) {
+	suite.Run(t, new(MailTestSuite))
+}
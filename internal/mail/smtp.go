@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer sends email through an SMTP server. The connection is upgraded
+// with STARTTLS when the server offers it, which net/smtp requires before it
+// sends credentials to anything but localhost.
+type SMTPMailer struct {
+	addr   string
+	auth   smtp.Auth
+	from   string
+	sender string // envelope sender, the bare address of from
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	sender, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+
+	mailer := &SMTPMailer{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		from:   from,
+		sender: sender.Address,
+	}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := compose(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.sender, []string{to.Address}, data); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", m.addr, err)
+	}
+	return nil
+}
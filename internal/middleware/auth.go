@@ -1,17 +1,29 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
 	"idea-collision-engine-api/internal/auth"
+	"idea-collision-engine-api/internal/database"
 	"idea-collision-engine-api/internal/models"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(jwtService *auth.JWTService) fiber.Handler {
+// UserCacheTTL is how long a user's row stays cached in Redis for resolving
+// their subscription tier. Tier changes invalidate it straight away.
+const UserCacheTTL = time.Minute
+
+// AuthMiddleware validates JWT tokens, rejects tokens on the Redis deny-list
+// and resolves the user's current subscription tier. A personal API key works
+// in place of a JWT; RequireScope limits what it can do.
+func AuthMiddleware(jwtService *auth.JWTService, db *database.PostgresDB, redis *database.RedisClient) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -33,6 +45,10 @@ func AuthMiddleware(jwtService *auth.JWTService) fiber.Handler {
 		}
 
 		tokenString := parts[1]
+		if auth.IsAPIKey(tokenString) {
+			return authenticateAPIKey(c, db, redis, tokenString)
+		}
+
 		claims, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
@@ -42,17 +58,32 @@ func AuthMiddleware(jwtService *auth.JWTService) fiber.Handler {
 			})
 		}
 
+		if isTokenDenied(redis, claims) {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error:   "token_revoked",
+				Message: "This session has been logged out",
+				Code:    401,
+			})
+		}
+
+		account, ok := resolveAccount(db, redis, claims)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "Account no longer exists",
+				Code:    401,
+			})
+		}
+
 		// Store user information in context
-		c.Locals("user_id", claims.UserID)
-		c.Locals("user_email", claims.Email)
-		c.Locals("subscription_tier", claims.SubscriptionTier)
+		storeClaims(c, claims, account)
 
 		return c.Next()
 	}
 }
 
 // OptionalAuthMiddleware validates JWT tokens but doesn't require them
-func OptionalAuthMiddleware(jwtService *auth.JWTService) fiber.Handler {
+func OptionalAuthMiddleware(jwtService *auth.JWTService, db *database.PostgresDB, redis *database.RedisClient) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -67,19 +98,190 @@ func OptionalAuthMiddleware(jwtService *auth.JWTService) fiber.Handler {
 
 		tokenString := parts[1]
 		claims, err := jwtService.ValidateToken(tokenString)
-		if err != nil {
+		if err != nil || isTokenDenied(redis, claims) {
+			return c.Next()
+		}
+
+		account, ok := resolveAccount(db, redis, claims)
+		if !ok {
 			return c.Next()
 		}
 
 		// Store user information in context
-		c.Locals("user_id", claims.UserID)
-		c.Locals("user_email", claims.Email)
-		c.Locals("subscription_tier", claims.SubscriptionTier)
+		storeClaims(c, claims, account)
+
+		return c.Next()
+	}
+}
 
+// WebSocketToken lets WebSocket handshakes carry the access token as
+// ?access_token=, since browsers can't set headers on them. It goes before
+// AuthMiddleware and leaves other requests alone.
+func WebSocketToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Query("access_token")
+		if token != "" && c.Get("Authorization") == "" && strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") {
+			c.Request().Header.Set("Authorization", "Bearer "+token)
+		}
 		return c.Next()
 	}
 }
 
+// isTokenDenied checks the deny-list for a validated token. If Redis can't be
+// reached the token is accepted; it expires soon enough on its own.
+func isTokenDenied(redis *database.RedisClient, claims *auth.Claims) bool {
+	if redis == nil {
+		return false
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	denied, err := redis.IsTokenDenied(claims.ID, claims.UserID.String(), issuedAt)
+	if err != nil {
+		log.Printf("Token deny-list check failed: %v", err)
+		return false
+	}
+	return denied
+}
+
+// accountState is what the middleware needs to know about a user's current account
+type accountState struct {
+	tier          string
+	emailVerified bool
+	role          string
+}
+
+// resolveAccount looks up the user's current tier, verification state and
+// role, from the Redis cache of their row or else the database. The token's
+// claims are only a hint, used when neither can be reached; they carry no
+// role, so staff routes are closed until one can. Reports false if the user no
+// longer exists.
+func resolveAccount(db *database.PostgresDB, redis *database.RedisClient, claims *auth.Claims) (accountState, bool) {
+	hint := accountState{tier: claims.SubscriptionTier, emailVerified: claims.EmailVerified, role: models.RoleUser}
+
+	if db == nil {
+		return hint, true
+	}
+
+	user, err := lookupUser(db, redis, claims.UserID)
+	if err == sql.ErrNoRows {
+		return accountState{}, false
+	}
+	if err != nil {
+		log.Printf("Account lookup failed, using token claims: %v", err)
+		return hint, true
+	}
+
+	return accountState{tier: user.SubscriptionTier, emailVerified: user.EmailVerifiedAt != nil, role: user.Role}, true
+}
+
+// lookupUser returns a user's row from the Redis cache, or else the database,
+// caching it for next time
+func lookupUser(db *database.PostgresDB, redis *database.RedisClient, userID uuid.UUID) (*models.User, error) {
+	if redis != nil {
+		user, err := redis.GetCachedUser(userID.String())
+		if err != nil {
+			log.Printf("User cache lookup failed: %v", err)
+		} else if user != nil {
+			return user, nil
+		}
+	}
+
+	user, err := db.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if redis != nil {
+		if err := redis.CacheUser(user, UserCacheTTL); err != nil {
+			log.Printf("Failed to cache user: %v", err)
+		}
+	}
+
+	return user, nil
+}
+
+// storeClaims stores the user and token information of a validated token in context
+func storeClaims(c *fiber.Ctx, claims *auth.Claims, account accountState) {
+	c.Locals("user_id", claims.UserID)
+	c.Locals("user_email", claims.Email)
+	c.Locals("subscription_tier", account.tier)
+	c.Locals("email_verified", account.emailVerified)
+	c.Locals("user_role", account.role)
+	c.Locals("token_id", claims.ID)
+	if claims.ExpiresAt != nil {
+		c.Locals("token_expires_at", claims.ExpiresAt.Time)
+	}
+}
+
+// authenticateAPIKey signs the request in with a personal API key. Keys are
+// looked up on every request, so revoking one takes effect immediately.
+func authenticateAPIKey(c *fiber.Ctx, db *database.PostgresDB, redis *database.RedisClient, apiKey string) error {
+	invalid := func() error {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Error:   "invalid_api_key",
+			Message: "API key is invalid, revoked or expired",
+			Code:    401,
+		})
+	}
+
+	prefix, ok := auth.APIKeyPrefix(apiKey)
+	if !ok || db == nil {
+		return invalid()
+	}
+
+	key, err := db.GetAPIKeyByPrefix(prefix)
+	if err == sql.ErrNoRows {
+		return invalid()
+	}
+	if err != nil {
+		log.Printf("API key lookup failed: %v", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+			Error:   "service_unavailable",
+			Message: "Failed to check API key",
+			Code:    503,
+		})
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(apiKey)), []byte(key.SecretHash)) != 1 {
+		return invalid()
+	}
+
+	user, err := lookupUser(db, redis, key.UserID)
+	if err != nil {
+		return invalid()
+	}
+
+	if err := db.TouchAPIKey(key.ID); err != nil {
+		log.Printf("Failed to record API key use: %v", err)
+	}
+
+	c.Locals("user_id", user.ID)
+	c.Locals("user_email", user.Email)
+	c.Locals("subscription_tier", user.SubscriptionTier)
+	c.Locals("email_verified", user.EmailVerifiedAt != nil)
+	c.Locals("user_role", user.Role)
+	c.Locals("api_key_id", key.ID)
+	c.Locals("api_key_scopes", key.Scopes)
+
+	return c.Next()
+}
+
+// IsAPIKeyRequest reports whether the request was signed in with an API key
+func IsAPIKeyRequest(c *fiber.Ctx) bool {
+	_, ok := c.Locals("api_key_scopes").([]string)
+	return ok
+}
+
+// GetTokenFromContext returns the ID and expiry of the access token used for the request
+func GetTokenFromContext(c *fiber.Ctx) (string, time.Time) {
+	tokenID, _ := c.Locals("token_id").(string)
+	expiresAt, _ := c.Locals("token_expires_at").(time.Time)
+	return tokenID, expiresAt
+}
+
 // GetUserIDFromContext extracts user ID from Fiber context
 func GetUserIDFromContext(c *fiber.Ctx) (uuid.UUID, error) {
 	userID := c.Locals("user_id")
@@ -110,6 +312,15 @@ func GetSubscriptionTierFromContext(c *fiber.Ctx) string {
 	return tierStr
 }
 
+// GetRoleFromContext returns the user's role, or models.RoleUser if it isn't known
+func GetRoleFromContext(c *fiber.Ctx) string {
+	role, _ := c.Locals("user_role").(string)
+	if role == "" {
+		return models.RoleUser
+	}
+	return role
+}
+
 // RequirePremium middleware requires pro or team subscription
 func RequirePremium() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -125,4 +336,68 @@ func RequirePremium() fiber.Handler {
 
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireVerifiedEmail middleware requires the user to have verified their email address
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if verified, _ := c.Locals("email_verified").(bool); !verified {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "email_not_verified",
+				Message: "Please verify your email address first. Check your inbox or request a new link.",
+				Code:    403,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireScope middleware limits requests signed in with an API key to keys
+// granted the scope. Requests signed in with a JWT pass.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("api_key_scopes").([]string)
+		if ok && !slices.Contains(scopes, scope) {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "insufficient_scope",
+				Message: "This API key needs the " + scope + " scope",
+				Code:    403,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireSession middleware refuses API keys, for routes that manage the
+// account or its credentials and so need the user to have signed in
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsAPIKeyRequest(c) {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "session_required",
+				Message: "API keys can't be used for this endpoint",
+				Code:    403,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireRole middleware restricts a route to users with one of the given
+// roles. Staff routes can't be reached with an API key.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !slices.Contains(roles, GetRoleFromContext(c)) || IsAPIKeyRequest(c) {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "Staff access required",
+				Code:    403,
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/database"
+	"idea-collision-engine-api/internal/models"
+)
+
+// RequireTeamRole middleware restricts a route to members of the team in the
+// :teamId parameter whose role ranks at least role. People outside the team
+// get a 404, so they can't tell which teams exist. The membership is stored in
+// context for GetTeamMemberFromContext.
+func RequireTeamRole(db *database.PostgresDB, role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := GetUserIDFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		teamID, err := uuid.Parse(c.Params("teamId"))
+		if err != nil {
+			return teamNotFound(c)
+		}
+
+		member, err := db.GetTeamMember(teamID, userID)
+		if err == sql.ErrNoRows {
+			return teamNotFound(c)
+		}
+		if err != nil {
+			fmt.Printf("Team membership lookup failed: %v\n", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to check team membership",
+				Code:    500,
+			})
+		}
+
+		if models.TeamRoleRanks[member.Role] < models.TeamRoleRanks[role] {
+			return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+				Error:   "insufficient_team_role",
+				Message: "This requires the " + role + " role on the team",
+				Code:    403,
+			})
+		}
+
+		c.Locals("team_member", member)
+		return c.Next()
+	}
+}
+
+func teamNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+		Error:   "team_not_found",
+		Message: "Team not found",
+		Code:    404,
+	})
+}
+
+// GetTeamMemberFromContext returns the membership RequireTeamRole checked
+func GetTeamMemberFromContext(c *fiber.Ctx) (*models.TeamMember, error) {
+	member, ok := c.Locals("team_member").(*models.TeamMember)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Team membership not checked")
+	}
+
+	return member, nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Encode returns an opaque, URL-safe representation of the cursor
+func (c HistoryCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeHistoryCursor parses a cursor produced by HistoryCursor.Encode
+func DecodeHistoryCursor(encoded string) (*HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor format")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id: %w", err)
+	}
+
+	return &HistoryCursor{CreatedAt: createdAt, ID: id}, nil
+}
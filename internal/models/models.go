@@ -27,6 +27,10 @@ type CollisionResult struct {
 	Timestamp       time.Time `json:"timestamp" db:"timestamp"`
 	Rating          *int      `json:"rating,omitempty" db:"rating"`
 	Notes           *string   `json:"notes,omitempty" db:"notes"`
+	PromptVersions  map[string]string `json:"prompt_versions,omitempty" db:"prompt_versions"` // prompt name -> version used for AI output
+	FallbackReason  string    `json:"fallback_reason,omitempty" db:"fallback_reason"`       // why template output was kept instead of AI output
+	FallbackReasons map[string]string `json:"fallback_reasons,omitempty" db:"fallback_reasons"` // prompt name -> reason code for parts that kept template output
+	SuggestedDomain *CollisionDomain `json:"suggested_domain,omitempty" db:"suggested_domain"` // AI-proposed domain used when no catalog domain matched
 }
 
 // CollisionDomain represents a curated domain for collision generation
@@ -43,15 +47,55 @@ type CollisionDomain struct {
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// DomainSuggestion is an AI-proposed collision domain awaiting editorial review
+type DomainSuggestion struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	UserID      *uuid.UUID      `json:"user_id,omitempty" db:"user_id"`
+	Interests   []string        `json:"interests" db:"interests"`
+	Domain      CollisionDomain `json:"domain" db:"domain"`
+	Status      string          `json:"status" db:"status"` // pending, approved, rejected
+	ReviewNotes *string         `json:"review_notes,omitempty" db:"review_notes"`
+	ReviewedBy  *uuid.UUID      `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	DomainID    *uuid.UUID      `json:"domain_id,omitempty" db:"domain_id"` // catalog domain created on approval
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty" db:"reviewed_at"`
+}
+
+// Embedding is a cached vector for a domain or interest, computed with one model
+type Embedding struct {
+	Kind        string    `json:"kind" db:"kind"` // domain, interest
+	Key         string    `json:"key" db:"key"`   // domain ID or normalized interest text
+	Model       string    `json:"model" db:"model"`
+	ContentHash string    `json:"content_hash" db:"content_hash"` // hash of the embedded text
+	Vector      []float32 `json:"vector" db:"vector"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PromptTemplate represents a versioned AI prompt (system + user message templates)
+type PromptTemplate struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Version   string    `json:"version" db:"version"`
+	System    string    `json:"system" db:"system_template"`
+	User      string    `json:"user" db:"user_template"`
+	Active    bool      `json:"active" db:"active"`
+	Source    string    `json:"source" db:"-"` // embedded, file, database
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
 // User represents a user in the system
 type User struct {
-	ID               uuid.UUID `json:"id" db:"id"`
-	Email            string    `json:"email" db:"email"`
-	PasswordHash     string    `json:"-" db:"password_hash"`
-	SubscriptionTier string    `json:"subscription_tier" db:"subscription_tier"` // free, pro, team
-	Interests        []string  `json:"interests" db:"interests"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	ID               uuid.UUID  `json:"id" db:"id"`
+	Email            string     `json:"email" db:"email"`
+	PasswordHash     string     `json:"-" db:"password_hash"`
+	SubscriptionTier string     `json:"subscription_tier" db:"subscription_tier"` // free, pro, team
+	DisplayName      string     `json:"display_name" db:"display_name"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at" db:"email_verified_at"` // nil until the email address is verified
+	Role             string     `json:"role" db:"role"`                           // user, support or admin
+	Interests        []string   `json:"interests" db:"interests"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // CollisionSession represents a collision generation session
@@ -63,6 +107,142 @@ type CollisionSession struct {
 	UserRating       *int            `json:"user_rating,omitempty" db:"user_rating"`
 	ExplorationNotes *string         `json:"exploration_notes,omitempty" db:"exploration_notes"`
 	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	DeletedAt        *time.Time      `json:"deleted_at,omitempty" db:"deleted_at"` // set while the session is in the trash
+	IsFavorite       bool            `json:"is_favorite" db:"is_favorite"`
+	Tags             []string        `json:"tags" db:"tags"`
+	ParentID         *uuid.UUID      `json:"parent_id,omitempty" db:"parent_id"` // session this one followed up on
+	FollowUp         string          `json:"follow_up,omitempty" db:"follow_up"` // deepen, pivot or intensify
+	ChildCount       int             `json:"child_count"`                         // follow-ups made from this session
+}
+
+// Follow-up operations on an existing collision session
+const (
+	FollowUpDeepen    = "deepen"    // more spark questions and next steps for the same domain pair
+	FollowUpPivot     = "pivot"     // same project, a nearby collision domain
+	FollowUpIntensify = "intensify" // rerun at the next intensity level
+)
+
+// CollisionTreeNode is a session with the follow-ups made from it
+type CollisionTreeNode struct {
+	CollisionSession
+	Children []*CollisionTreeNode `json:"children"`
+}
+
+// SessionUpdate holds the editable fields of a collision session; nil fields are left unchanged
+type SessionUpdate struct {
+	Rating *int    `json:"rating,omitempty" validate:"omitempty,min=1,max=5"`
+	Notes  *string `json:"notes,omitempty" validate:"omitempty,max=5000"`
+}
+
+// Collection is a named, ordered list of a user's collision sessions
+type Collection struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	UserID       uuid.UUID          `json:"user_id" db:"user_id"`
+	Name         string             `json:"name" db:"name"`
+	Description  *string            `json:"description,omitempty" db:"description"`
+	SessionCount int                `json:"session_count"`
+	Sessions     []CollisionSession `json:"sessions,omitempty"` // in collection order; only set when fetching a single collection
+	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// TagCount is one of a user's tags and how many sessions carry it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// LibraryCounts summarizes how a user has organized their sessions
+type LibraryCounts struct {
+	Favorites   int `json:"favorites"`
+	Tags        int `json:"tags"`
+	Collections int `json:"collections"`
+}
+
+// Limits on organizing sessions
+const (
+	MaxTagsPerSession = 20
+	MaxTagLength      = 50
+)
+
+// ShareLink gives read-only public access to one collision session
+type ShareLink struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	SessionID    uuid.UUID  `json:"session_id" db:"session_id"`
+	UserID       uuid.UUID  `json:"-" db:"user_id"`
+	Token        string     `json:"token,omitempty"`   // only known when the link is created
+	TokenHash    string     `json:"-" db:"token_hash"` // SHA-256 of the token
+	URL          string     `json:"url,omitempty"`     // public page for the link, built from the token
+	Active       bool       `json:"active"`            // neither revoked nor expired
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ViewCount    int        `json:"view_count" db:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty" db:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateShareLinkRequest configures a new share link; without an expiry the link lasts until revoked
+type CreateShareLinkRequest struct {
+	ExpiresInDays *int `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// SharedCollision is the public view of a shared session. It carries the
+// collision itself but nothing about who created it: no user ID, interests,
+// rating, notes or tags.
+type SharedCollision struct {
+	CurrentProject  string    `json:"current_project"`
+	ProjectType     string    `json:"project_type"`
+	Intensity       string    `json:"intensity"`
+	PrimaryDomain   string    `json:"primary_domain"`
+	CollisionDomain string    `json:"collision_domain"`
+	Connection      string    `json:"connection"`
+	SparkQuestions  []string  `json:"spark_questions"`
+	Examples        []string  `json:"examples"`
+	NextSteps       []string  `json:"next_steps"`
+	QualityScore    float64   `json:"quality_score"`
+	CreatedAt       time.Time `json:"created_at"`
+	ViewCount       int       `json:"view_count"`
+	Meta            ShareMeta `json:"meta"`
+}
+
+// ShareMeta holds OpenGraph values for link previews of a shared collision
+type ShareMeta struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Type        string `json:"type"`
+	SiteName    string `json:"site_name"`
+}
+
+// HistoryFilter narrows a user's collision history. Zero values mean no filter.
+type HistoryFilter struct {
+	Domain      string         // collision domain name, case-insensitive
+	ProjectType string
+	Intensity   string
+	MinRating   *int
+	MaxRating   *int
+	From        *time.Time
+	To          *time.Time
+	Query       string         // full-text search over project, connection and notes
+	Tag         string
+	CollectionID *uuid.UUID
+	FavoritesOnly bool
+	Cursor      *HistoryCursor // continue after this session
+	Limit       int
+}
+
+// HistoryCursor identifies the last session of a history page. Sessions are
+// ordered by created_at then id, newest first.
+type HistoryCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// HistoryPage is one page of a user's collision history
+type HistoryPage struct {
+	Collisions []CollisionSession `json:"collisions"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	HasMore    bool               `json:"has_more"`
 }
 
 // UserUsage represents user usage tracking for freemium limits
@@ -75,6 +255,39 @@ type UserUsage struct {
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// AIUsage records token usage and estimated cost for a single model call
+type AIUsage struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	UserID           uuid.UUID `json:"user_id" db:"user_id"`
+	Prompt           string    `json:"prompt" db:"prompt"`
+	PromptVersion    string    `json:"prompt_version" db:"prompt_version"`
+	Model            string    `json:"model" db:"model"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens" db:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd" db:"cost_usd"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// AIUsageSummary aggregates AI usage for one user on one day
+type AIUsageSummary struct {
+	UserID           uuid.UUID `json:"user_id" db:"user_id"`
+	Day              time.Time `json:"day" db:"day"`
+	Calls            int       `json:"calls" db:"calls"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd" db:"cost_usd"`
+}
+
+// AIUsageTotal adds up one team member's AI usage over a period
+type AIUsageTotal struct {
+	UserID           uuid.UUID `json:"user_id"`
+	Calls            int       `json:"calls"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
 // LoginRequest represents login request payload
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
@@ -88,10 +301,538 @@ type RegisterRequest struct {
 	Interests []string `json:"interests,omitempty"`
 }
 
+// UpdateProfileRequest holds the editable profile fields; fields left out are not changed
+type UpdateProfileRequest struct {
+	DisplayName *string  `json:"display_name,omitempty" validate:"omitempty,max=100"`
+	Interests   []string `json:"interests,omitempty" validate:"omitempty,max=20,dive,required,max=100"`
+}
+
+// ChangeEmailRequest starts a change of the account's email address
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required"`
+}
+
+// VerifyEmailChangeRequest confirms an email change with the token mailed to the new address
+type VerifyEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ChangePasswordRequest replaces the account password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,max=72"`
+}
+
+// DeleteAccountRequest confirms account deletion with the account password
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// EmailChange is a pending change of a user's email address
+type EmailChange struct {
+	UserID    uuid.UUID `db:"user_id"`
+	NewEmail  string    `db:"new_email"`
+	TokenHash string    `db:"token_hash"` // SHA-256 of the token mailed to NewEmail
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Audit log actions
+const (
+	AuditLoginLockout   = "login.lockout"
+	AuditIdentityLinked = "identity.linked"
+	AuditTierChanged    = "admin.tier_changed"
+	AuditRoleChanged    = "admin.role_changed"
+	AuditUsageReset     = "admin.usage_reset"
+	AuditDomainCreated  = "admin.domain_created"
+	AuditDomainUpdated  = "admin.domain_updated"
+	AuditDomainDeleted  = "admin.domain_deleted"
+)
+
+// AuditEntry records a security or administrative event
+type AuditEntry struct {
+	ID        uuid.UUID              `json:"id" db:"id"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty" db:"user_id"`   // the account the event concerns
+	ActorID   *uuid.UUID             `json:"actor_id,omitempty" db:"actor_id"` // the staff member who made an administrative change
+	Action    string                 `json:"action" db:"action"`
+	IPAddress string                 `json:"ip_address,omitempty" db:"ip_address"`
+	Details   map[string]interface{} `json:"details" db:"details"`
+	CreatedAt time.Time              `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter narrows the audit log. Zero values match everything.
+type AuditFilter struct {
+	UserID  *uuid.UUID
+	ActorID *uuid.UUID
+	Action  string
+	Limit   int
+}
+
+// User roles. Staff roles open the /api/admin endpoints.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support" // looks up users and resets their usage
+	RoleAdmin   = "admin"   // also changes tiers and roles and manages domains, prompts and AI usage
+)
+
+// AdminTierRequest overrides a user's subscription tier
+type AdminTierRequest struct {
+	Tier   string `json:"tier" validate:"required,oneof=free pro team"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AdminRoleRequest changes a user's role
+type AdminRoleRequest struct {
+	Role   string `json:"role" validate:"required,oneof=user support admin"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AdminReasonRequest gives the reason for an administrative change that
+// takes nothing else
+type AdminReasonRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AdminDomainRequest creates or replaces a catalog domain
+type AdminDomainRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Category    string   `json:"category" validate:"required,max=50"`
+	Description string   `json:"description" validate:"required"`
+	Examples    []string `json:"examples,omitempty"`
+	Keywords    []string `json:"keywords" validate:"required,min=1"`
+	Intensity   []string `json:"intensity" validate:"required,min=1,dive,oneof=gentle moderate radical"`
+	Tier        string   `json:"tier" validate:"required,oneof=basic premium"`
+	Reason      string   `json:"reason,omitempty" validate:"max=500"`
+}
+
+// ForgotPasswordRequest asks for a password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with a mailed reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6,max=72"`
+}
+
+// VerifyEmailRequest verifies an email address with a mailed token
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UsedActionToken records that a single-use action token was used
+type UsedActionToken struct {
+	TokenID   uuid.UUID `db:"token_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Purpose   string    `db:"purpose"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// UserIdentity links an account to a person at an OpenID Connect provider
+type UserIdentity struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Provider    string    `json:"provider" db:"provider"`
+	Subject     string    `json:"subject" db:"subject"`
+	Email       string    `json:"email" db:"email"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at"`
+}
+
+// OIDCLoginState is what we remember about a sign-in in progress at an
+// OpenID Connect provider, keyed by the state parameter
+type OIDCLoginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+}
+
+// SigningKey is a key pair that signs access and action tokens. It signs from
+// ActivatesAt until RetiresAt, then only validates until ExpiresAt.
+type SigningKey struct {
+	ID          string    `db:"kid"`
+	Algorithm   string    `db:"algorithm"`
+	PrivateKey  []byte    `db:"private_key"` // PKCS #8, encrypted with a key derived from the JWT secret
+	ActivatesAt time.Time `db:"activates_at"`
+	RetiresAt   time.Time `db:"retires_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// RefreshToken is one link in a chain of rotating refresh tokens. Tokens from
+// the same login share a FamilyID.
+type RefreshToken struct {
+	ID         uuid.UUID  `db:"id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	FamilyID   uuid.UUID  `db:"family_id"`
+	TokenHash  string     `db:"token_hash"` // SHA-256 of the token given to the client
+	ExpiresAt  time.Time  `db:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	ReplacedBy *uuid.UUID `db:"replaced_by"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// RefreshRequest exchanges a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest ends a session. The refresh token is optional; without it only
+// the access token is revoked.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // AuthResponse represents authentication response
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"` // seconds until Token expires
+	User         User   `json:"user"`
+}
+
+// TokenResponse is a refreshed pair of tokens
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// APIKey lets scripts call the API as a user, limited to its scopes
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"` // public start of the key, shown to tell keys apart
+	SecretHash string     `json:"-" db:"secret_hash"` // SHA-256 of the whole key
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreateAPIKeyRequest creates an API key with the given scopes
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=collisions:write history:read history:write collections:read collections:write"`
+	ExpiresInDays *int     `json:"expires_in_days" validate:"omitempty,min=1,max=365"` // never expires if omitted
+}
+
+// CreateAPIKeyResponse is a new API key. Key is only ever returned here.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// API key scopes
+const (
+	ScopeCollisionsWrite  = "collisions:write" // generate collisions and follow-ups
+	ScopeHistoryRead      = "history:read"     // read and export past sessions, usage and tags
+	ScopeHistoryWrite     = "history:write"    // rate, edit, tag, star, share and delete sessions
+	ScopeCollectionsRead  = "collections:read"
+	ScopeCollectionsWrite = "collections:write"
+)
+
+// Team roles. Owners manage admins and the team itself; admins manage members
+// and invitations.
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+// TeamRoleRanks orders team roles; a role can do everything lower ranks can
+var TeamRoleRanks = map[string]int{
+	TeamRoleMember: 1,
+	TeamRoleAdmin:  2,
+	TeamRoleOwner:  3,
+}
+
+// Team is a group of users sharing collision sessions. Its owner's Team plan
+// pays for its seats.
+type Team struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	OwnerID    uuid.UUID `json:"owner_id" db:"owner_id"`
+	Seats      int       `json:"seats" db:"seats"` // seats bought with the owner's subscription
+	SeatsUsed  int       `json:"seats_used"`       // members plus pending invitations
+	PlanActive bool      `json:"plan_active"`      // the owner is on the Team plan; nobody can join otherwise
+	Role       string    `json:"role,omitempty"`   // the requesting user's role
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TeamMember is a user's membership of a team
+type TeamMember struct {
+	TeamID      uuid.UUID `json:"team_id" db:"team_id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	Role        string    `json:"role" db:"role"`
+	JoinedAt    time.Time `json:"joined_at" db:"joined_at"`
+}
+
+// TeamInvitation invites an email address to join a team. The link mailed to
+// it carries a token whose hash is stored.
+type TeamInvitation struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	TeamID     uuid.UUID  `json:"team_id" db:"team_id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	TokenHash  string     `json:"-" db:"token_hash"` // SHA-256 of the mailed token
+	InvitedBy  *uuid.UUID `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TeamSession is a collision session a member shared with their team
+type TeamSession struct {
+	CollisionSession
+	SharedBy     uuid.UUID `json:"shared_by" db:"shared_by"`
+	SharedByName string    `json:"shared_by_name"` // display name, or email if there is none
+	SharedAt     time.Time `json:"shared_at" db:"shared_at"`
+}
+
+// CreateTeamRequest creates a team owned by the requesting user
+type CreateTeamRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// UpdateTeamRequest renames a team
+type UpdateTeamRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// InviteTeamMemberRequest invites someone to a team, as a member unless another role is given
+type InviteTeamMemberRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role,omitempty" validate:"omitempty,oneof=admin member"`
+}
+
+// AcceptTeamInvitationRequest joins a team with the token from an invitation email
+type AcceptTeamInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// UpdateTeamMemberRequest changes a member's role
+type UpdateTeamMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin member"`
+}
+
+// Board is a team workspace collecting collisions from its members
+type Board struct {
+	ID          uuid.UUID   `json:"id" db:"id"`
+	TeamID      uuid.UUID   `json:"team_id" db:"team_id"`
+	Name        string      `json:"name" db:"name"`
+	Description *string     `json:"description,omitempty" db:"description"`
+	CreatedBy   *uuid.UUID  `json:"created_by,omitempty" db:"created_by"`
+	ItemCount   int         `json:"item_count"`
+	Items       []BoardItem `json:"items,omitempty"` // only set when fetching a single board
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// BoardItem is a collision on a board, with the team's votes, reactions and
+// comment count. Voted and MyReactions are the requesting user's.
+type BoardItem struct {
+	ID           uuid.UUID         `json:"id" db:"id"`
+	BoardID      uuid.UUID         `json:"board_id" db:"board_id"`
+	SessionID    uuid.UUID         `json:"session_id" db:"session_id"`
+	Session      *CollisionSession `json:"session,omitempty"`
+	AddedBy      uuid.UUID         `json:"added_by" db:"added_by"`
+	AddedByName  string            `json:"added_by_name,omitempty"` // display name, or email if there is none
+	Note         *string           `json:"note,omitempty" db:"note"`
+	VoteCount    int               `json:"vote_count"`
+	Voted        bool              `json:"voted"`
+	Reactions    map[string]int    `json:"reactions"` // reaction -> how many members gave it
+	MyReactions  []string          `json:"my_reactions"`
+	CommentCount int               `json:"comment_count"`
+	CreatedAt    time.Time         `json:"created_at" db:"created_at"`
+}
+
+// BoardComment is a member's comment on a board item
+type BoardComment struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	ItemID     uuid.UUID  `json:"item_id" db:"item_id"`
+	UserID     *uuid.UUID `json:"user_id" db:"user_id"` // nil once the author deleted their account
+	AuthorName string     `json:"author_name"`
+	Body       string     `json:"body" db:"body"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// BoardActivity is an entry in a team's activity feed
+type BoardActivity struct {
+	ID        uuid.UUID              `json:"id" db:"id"`
+	TeamID    uuid.UUID              `json:"team_id" db:"team_id"`
+	BoardID   *uuid.UUID             `json:"board_id,omitempty" db:"board_id"`
+	ItemID    *uuid.UUID             `json:"item_id,omitempty" db:"item_id"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty" db:"user_id"`
+	ActorName string                 `json:"actor_name,omitempty"`
+	Action    string                 `json:"action" db:"action"`
+	Details   map[string]interface{} `json:"details" db:"details"`
+	CreatedAt time.Time              `json:"created_at" db:"created_at"`
+}
+
+// Board activity actions
+const (
+	ActivityBoardCreated  = "board.created"
+	ActivityBoardUpdated  = "board.updated"
+	ActivityBoardDeleted  = "board.deleted"
+	ActivityItemAdded     = "item.added"
+	ActivityItemRemoved   = "item.removed"
+	ActivityCommentAdded  = "comment.added"
+	ActivityVoteCast      = "vote.cast"
+	ActivityReactionAdded = "reaction.added"
+)
+
+// BoardEvent is a change on a team's boards, pushed live to members who have
+// them open. Events with an ID can be replayed after a reconnect.
+type BoardEvent struct {
+	ID        string                 `json:"id,omitempty"` // Redis stream entry ID; empty for presence updates
+	Type      string                 `json:"type"`
+	TeamID    uuid.UUID              `json:"team_id"`
+	BoardID   *uuid.UUID             `json:"board_id,omitempty"`
+	ItemID    *uuid.UUID             `json:"item_id,omitempty"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// BoardPresence is a member connected to their team's live board feed
+type BoardPresence struct {
+	UserID  uuid.UUID  `json:"user_id"`
+	Name    string     `json:"name"`               // display name, or email if there is none
+	BoardID *uuid.UUID `json:"board_id,omitempty"` // the board they have open, if any
+}
+
+// Board event types besides the activity actions, which are events too. The
+// rest only matter while someone has the board open, so they aren't in the feed.
+const (
+	EventVoteWithdrawn   = "vote.withdrawn"
+	EventReactionRemoved = "reaction.removed"
+	EventCommentUpdated  = "comment.updated"
+	EventCommentDeleted  = "comment.deleted"
+	EventPresence        = "presence" // data.members lists who is connected
+	EventHello           = "hello"    // first message on a connection, with data.members
+	EventResync          = "resync"   // events were missed and can't be replayed; reload the boards
+)
+
+// BoardReactions are the reactions members can give board items
+var BoardReactions = []string{"thumbs_up", "heart", "tada", "thinking", "bulb", "fire"}
+
+// CreateBoardRequest creates a board on a team
+type CreateBoardRequest struct {
+	Name        string  `json:"name" validate:"required,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=1000"`
+}
+
+// UpdateBoardRequest renames a board and/or changes its description; fields left out are not changed
+type UpdateBoardRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=1000"`
+}
+
+// AddBoardItemRequest puts one of the user's collision sessions on a board
+type AddBoardItemRequest struct {
+	SessionID uuid.UUID `json:"session_id" validate:"required"`
+	Note      *string   `json:"note,omitempty" validate:"omitempty,max=1000"`
+}
+
+// BoardCommentRequest writes or edits a comment
+type BoardCommentRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// GroupSession is a time-boxed brainstorm for a group. Participants join with
+// the code and submit their interests; the host then generates collisions
+// from the merged interests, and everyone votes on them until it ends.
+type GroupSession struct {
+	ID                 uuid.UUID          `json:"id" db:"id"`
+	HostID             uuid.UUID          `json:"host_id" db:"host_id"`
+	Title              string             `json:"title" db:"title"`
+	CurrentProject     string             `json:"current_project" db:"current_project"`
+	ProjectType        string             `json:"project_type" db:"project_type"`
+	CollisionIntensity string             `json:"collision_intensity" db:"collision_intensity"`
+	JoinCode           string             `json:"join_code" db:"join_code"`
+	MergedInterests    []string           `json:"merged_interests" db:"merged_interests"` // set once collisions are generated
+	Status             string             `json:"status"`
+	ParticipantCount   int                `json:"participant_count"`
+	Participants       []GroupParticipant `json:"participants,omitempty"` // only set when fetching a single session
+	Collisions         []GroupCollision   `json:"collisions,omitempty"`   // only set when fetching a single session
+	EndsAt             time.Time          `json:"ends_at" db:"ends_at"`
+	GeneratedAt        *time.Time         `json:"generated_at,omitempty" db:"generated_at"`
+	CreatedAt          time.Time          `json:"created_at" db:"created_at"`
+}
+
+// GroupParticipant is someone who joined a group session, with the interests
+// they submitted
+type GroupParticipant struct {
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Name      string    `json:"name"` // display name, or the part of the email before the @
+	Interests []string  `json:"interests" db:"interests"`
+	IsHost    bool      `json:"is_host"`
+	JoinedAt  time.Time `json:"joined_at" db:"joined_at"`
+}
+
+// GroupCollision is one of the collisions generated for a group session
+type GroupCollision struct {
+	ID              uuid.UUID       `json:"id" db:"id"`
+	SessionID       uuid.UUID       `json:"session_id" db:"session_id"`
+	Rank            int             `json:"rank" db:"rank"` // the engine's order, best first
+	CollisionResult CollisionResult `json:"collision_result" db:"collision_result"`
+	VoteCount       int             `json:"vote_count"`
+	Voted           bool            `json:"voted"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Group session statuses
+const (
+	GroupStatusCollecting = "collecting" // participants can join and submit interests
+	GroupStatusVoting     = "voting"     // collisions are generated; participants can vote
+	GroupStatusEnded      = "ended"
+)
+
+// Group session limits
+const (
+	GroupDefaultDuration   = 30 * time.Minute
+	GroupMaxParticipants   = 50
+	GroupDefaultCollisions = 5
+)
+
+// GroupSessionStatus returns what stage a group session is at
+func GroupSessionStatus(endsAt time.Time, generatedAt *time.Time, now time.Time) string {
+	switch {
+	case !now.Before(endsAt):
+		return GroupStatusEnded
+	case generatedAt != nil:
+		return GroupStatusVoting
+	default:
+		return GroupStatusCollecting
+	}
+}
+
+// CreateGroupSessionRequest starts a group session hosted by the user
+type CreateGroupSessionRequest struct {
+	Title              string   `json:"title" validate:"required,max=100"`
+	CurrentProject     string   `json:"current_project" validate:"required,max=1000"`
+	ProjectType        string   `json:"project_type" validate:"required,oneof=product content business research"`
+	CollisionIntensity string   `json:"collision_intensity" validate:"required,oneof=gentle moderate radical"`
+	DurationMinutes    int      `json:"duration_minutes,omitempty" validate:"omitempty,min=5,max=240"`        // defaults to 30
+	Interests          []string `json:"interests,omitempty" validate:"omitempty,max=10,dive,required,max=50"` // the host's own
+}
+
+// JoinGroupSessionRequest joins a group session by its code, or replaces the
+// interests submitted when joining earlier
+type JoinGroupSessionRequest struct {
+	Code      string   `json:"code" validate:"required,max=8"`
+	Interests []string `json:"interests" validate:"required,min=1,max=10,dive,required,max=50"`
+}
+
+// GenerateGroupCollisionsRequest generates a group session's collisions
+type GenerateGroupCollisionsRequest struct {
+	Count int `json:"count,omitempty" validate:"omitempty,min=1,max=10"` // defaults to 5
 }
 
 // SubscriptionTier constants
@@ -108,6 +849,33 @@ var UsageLimits = map[string]int{
 	TierTeam: -1, // unlimited
 }
 
+// TeamIncludedSeats is how many seats a team gets with its owner's Team plan
+const TeamIncludedSeats = 5
+
+// TeamSeatLimit returns how many members and pending invitations a team can
+// have. Without the owner's Team plan it has none: members stay, but nobody
+// new can join.
+func TeamSeatLimit(seats int, ownerTier string) int {
+	if ownerTier != TierTeam {
+		return 0
+	}
+	return seats
+}
+
+// Fallback reasons recorded when AI output is not used
+const (
+	FallbackAIBudgetExceeded = "ai_budget_exceeded"
+	FallbackAIUnavailable    = "ai_unavailable"
+	FallbackAIQualityRejected = "ai_quality_rejected"
+)
+
+// Domain suggestion review statuses
+const (
+	SuggestionPending  = "pending"
+	SuggestionApproved = "approved"
+	SuggestionRejected = "rejected"
+)
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
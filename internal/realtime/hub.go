@@ -0,0 +1,138 @@
+package realtime
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+const (
+	// SubscriptionBuffer is how many events a subscriber can fall behind by
+	// before the hub drops it. A dropped client reconnects and replays what it
+	// missed.
+	SubscriptionBuffer = 64
+
+	// ReplayLength is about how many of a team's latest events are kept in
+	// Redis for clients that reconnect
+	ReplayLength = 500
+
+	// ReplayRetention is how long a team's events are kept after the last one
+	ReplayRetention = 24 * time.Hour
+)
+
+// Hub hands the board events published through Redis to the connections on
+// this instance, by team. Every instance runs one, so an event published on
+// any of them reaches every connected member.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[uuid.UUID]map[*Subscription]struct{}),
+	}
+}
+
+// Run delivers events to subscribers until the events channel closes
+func (h *Hub) Run(events <-chan *models.BoardEvent) {
+	for event := range events {
+		h.Deliver(event)
+	}
+}
+
+// Deliver hands an event to the subscribers of its team. Subscribers whose
+// buffer is full are dropped rather than holding up everyone else.
+func (h *Hub) Deliver(event *models.BoardEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[event.TeamID] {
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe starts delivering a team's events to a new subscription, which
+// must be closed once it's no longer read
+func (h *Hub) Subscribe(teamID uuid.UUID) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		teamID: teamID,
+		events: make(chan *models.BoardEvent, SubscriptionBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[teamID] == nil {
+		h.subscribers[teamID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[teamID][sub] = struct{}{}
+	return sub
+}
+
+// remove stops delivering to a subscription and closes its channel. The
+// caller holds h.mu.
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscribers[sub.teamID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.teamID)
+	}
+	close(sub.events)
+}
+
+// Subscription receives a team's events from a Hub
+type Subscription struct {
+	hub    *Hub
+	teamID uuid.UUID
+	events chan *models.BoardEvent
+}
+
+// Events delivers the team's events. It closes when the subscription is
+// closed, or dropped for falling behind.
+func (s *Subscription) Events() <-chan *models.BoardEvent {
+	return s.events
+}
+
+// Close stops the subscription. Closing it twice is harmless.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// After reports whether stream entry ID a comes after b. IDs are
+// "<milliseconds>-<sequence>", as Redis assigns them.
+func After(a, b string) bool {
+	aMillis, aSeq := splitStreamID(a)
+	bMillis, bSeq := splitStreamID(b)
+	if aMillis != bMillis {
+		return aMillis > bMillis
+	}
+	return aSeq > bSeq
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	millis, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(millis, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
@@ -0,0 +1,93 @@
+package realtime
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"idea-collision-engine-api/internal/models"
+)
+
+type HubTestSuite struct {
+	suite.Suite
+	hub    *Hub
+	teamID uuid.UUID
+}
+
+func (suite *HubTestSuite) SetupTest() {
+	suite.hub = NewHub()
+	suite.teamID = uuid.New()
+}
+
+func (suite *HubTestSuite) TestDeliversToTeam() {
+	sub := suite.hub.Subscribe(suite.teamID)
+	other := suite.hub.Subscribe(uuid.New())
+	defer sub.Close()
+	defer other.Close()
+
+	suite.hub.Deliver(&models.BoardEvent{ID: "1-0", Type: models.ActivityItemAdded, TeamID: suite.teamID})
+
+	event := <-sub.Events()
+	assert.Equal(suite.T(), "1-0", event.ID)
+	assert.Empty(suite.T(), other.Events())
+}
+
+func (suite *HubTestSuite) TestRun() {
+	sub := suite.hub.Subscribe(suite.teamID)
+	defer sub.Close()
+
+	events := make(chan *models.BoardEvent, 2)
+	events <- &models.BoardEvent{ID: "1-0", TeamID: suite.teamID}
+	events <- &models.BoardEvent{ID: "2-0", TeamID: suite.teamID}
+	close(events)
+	suite.hub.Run(events)
+
+	assert.Equal(suite.T(), "1-0", (<-sub.Events()).ID)
+	assert.Equal(suite.T(), "2-0", (<-sub.Events()).ID)
+}
+
+func (suite *HubTestSuite) TestDropsSlowSubscribers() {
+	slow := suite.hub.Subscribe(suite.teamID)
+	fast := suite.hub.Subscribe(suite.teamID)
+	defer fast.Close()
+
+	for i := 0; i <= SubscriptionBuffer; i++ {
+		suite.hub.Deliver(&models.BoardEvent{TeamID: suite.teamID})
+		<-fast.Events()
+	}
+
+	// The slow subscriber gets what fit in its buffer, then its channel closes
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(suite.T(), SubscriptionBuffer, received)
+
+	// Closing after being dropped is harmless
+	slow.Close()
+	suite.hub.Deliver(&models.BoardEvent{TeamID: suite.teamID})
+	assert.Len(suite.T(), fast.Events(), 1)
+}
+
+func (suite *HubTestSuite) TestClose() {
+	sub := suite.hub.Subscribe(suite.teamID)
+	sub.Close()
+	sub.Close()
+
+	_, open := <-sub.Events()
+	assert.False(suite.T(), open)
+	assert.Empty(suite.T(), suite.hub.subscribers)
+}
+
+func (suite *HubTestSuite) TestAfter() {
+	assert.True(suite.T(), After("1700000000001-0", "1700000000000-5"))
+	assert.True(suite.T(), After("1700000000000-10", "1700000000000-9"))
+	assert.False(suite.T(), After("1700000000000-0", "1700000000000-0"))
+	assert.False(suite.T(), After("999-0", "1000-0"))
+}
+
+func TestHubTestSuite(t *testing.T) {
+	suite.Run(t, new(HubTestSuite))
+}
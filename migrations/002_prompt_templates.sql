@@ -0,0 +1,21 @@
+-- Versioned AI prompt templates that override the embedded defaults
+
+CREATE TABLE prompt_templates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(50) NOT NULL,
+    version VARCHAR(20) NOT NULL,
+    system_template TEXT NOT NULL,
+    user_template TEXT NOT NULL,
+    active BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(name, version)
+);
+
+CREATE INDEX idx_prompt_templates_name ON prompt_templates(name);
+
+-- Only one active version per prompt
+CREATE UNIQUE INDEX idx_prompt_templates_active ON prompt_templates(name) WHERE active;
+
+CREATE TRIGGER update_prompt_templates_updated_at BEFORE UPDATE ON prompt_templates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
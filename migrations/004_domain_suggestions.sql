@@ -0,0 +1,19 @@
+-- AI-proposed collision domains awaiting editorial review
+
+CREATE TABLE domain_suggestions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    interests JSONB DEFAULT '[]'::jsonb,
+    domain JSONB NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    review_notes TEXT,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    domain_id UUID REFERENCES collision_domains(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_domain_suggestions_status ON domain_suggestions(status, created_at DESC);
+
+-- Queue each proposed name once while it is pending review
+CREATE UNIQUE INDEX idx_domain_suggestions_pending_name ON domain_suggestions(LOWER(domain->>'name')) WHERE status = 'pending';
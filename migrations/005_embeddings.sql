@@ -0,0 +1,11 @@
+-- Cached embeddings for collision domains and user interests, one row per model
+
+CREATE TABLE embeddings (
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('domain', 'interest')),
+    key TEXT NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    content_hash VARCHAR(64) NOT NULL,
+    vector REAL[] NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (kind, model, key)
+);
@@ -0,0 +1,19 @@
+-- Collision history filtering, full-text search and cursor pagination
+
+-- Searchable text from the project description, connection and notes
+ALTER TABLE collision_sessions ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(input_data->>'current_project', '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(collision_result->>'connection', '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(exploration_notes, '')), 'C')
+    ) STORED;
+
+CREATE INDEX idx_collision_sessions_search ON collision_sessions USING GIN (search_vector);
+
+-- Keyset pagination: newest first, id breaks ties
+CREATE INDEX idx_collision_sessions_user_cursor ON collision_sessions(user_id, created_at DESC, id DESC);
+
+-- Filters on JSONB fields
+CREATE INDEX idx_collision_sessions_domain ON collision_sessions(user_id, LOWER(collision_result->>'collision_domain'));
+CREATE INDEX idx_collision_sessions_project_type ON collision_sessions(user_id, (input_data->>'project_type'));
+CREATE INDEX idx_collision_sessions_intensity ON collision_sessions(user_id, (input_data->>'collision_intensity'));
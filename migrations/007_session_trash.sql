@@ -0,0 +1,5 @@
+-- Soft delete for collision sessions; trashed sessions can be restored until purged
+
+ALTER TABLE collision_sessions ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_collision_sessions_deleted_at ON collision_sessions(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,42 @@
+-- Favorites, tags and collections for organizing collision sessions
+
+ALTER TABLE collision_sessions ADD COLUMN is_favorite BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_collision_sessions_favorite ON collision_sessions(user_id) WHERE is_favorite;
+
+-- Tags are lowercase labels; user_id is denormalized so a user's tag list doesn't need a join
+CREATE TABLE session_tags (
+    session_id UUID NOT NULL REFERENCES collision_sessions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (session_id, tag)
+);
+
+CREATE INDEX idx_session_tags_user_tag ON session_tags(user_id, tag);
+
+-- Named, ordered lists of sessions
+CREATE TABLE collections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_collections_user_name ON collections(user_id, LOWER(name));
+
+CREATE TRIGGER update_collections_updated_at BEFORE UPDATE ON collections
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE collection_items (
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES collision_sessions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (collection_id, session_id)
+);
+
+CREATE INDEX idx_collection_items_position ON collection_items(collection_id, position);
+CREATE INDEX idx_collection_items_session ON collection_items(session_id);
@@ -0,0 +1,7 @@
+-- Follow-up sessions (deepen, pivot, intensify) link back to the session they came from
+
+ALTER TABLE collision_sessions
+    ADD COLUMN parent_id UUID REFERENCES collision_sessions(id) ON DELETE SET NULL,
+    ADD COLUMN follow_up VARCHAR(20);
+
+CREATE INDEX idx_collision_sessions_parent ON collision_sessions(parent_id) WHERE parent_id IS NOT NULL;
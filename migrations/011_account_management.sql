@@ -0,0 +1,13 @@
+-- Display names and verified email changes
+
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100);
+
+-- A pending change of a user's email address. The new address only takes effect
+-- once the token mailed to it is confirmed; a new request replaces the old one.
+CREATE TABLE email_changes (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
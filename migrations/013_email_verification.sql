@@ -0,0 +1,19 @@
+-- Email verification and single-use action tokens
+
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Accounts created before verification existed keep working
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- Action tokens (password reset, email verification) are signed and expire on
+-- their own; recording their IDs once used makes them single use. Rows can be
+-- purged after the token would have expired.
+CREATE TABLE used_action_tokens (
+    token_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_used_action_tokens_expires_at ON used_action_tokens(expires_at);
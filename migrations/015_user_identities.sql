@@ -0,0 +1,15 @@
+-- Identities at OpenID Connect providers linked to accounts. A provider's
+-- subject identifies the person for good; the email is kept for reference.
+
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(100) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_login_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
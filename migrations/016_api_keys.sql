@@ -0,0 +1,17 @@
+-- Personal API keys for scripts and CI. Keys are stored as a public prefix and
+-- a SHA-256 hash of the whole key; the key itself is only shown on creation.
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    secret_hash VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id) WHERE revoked_at IS NULL;
@@ -0,0 +1,15 @@
+-- Key pairs that sign access and action tokens. A key signs from activates_at
+-- until retires_at and keeps validating tokens until expires_at. Private keys
+-- are PKCS #8, encrypted with a key derived from JWT_SECRET.
+
+CREATE TABLE signing_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key BYTEA NOT NULL,
+    activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    retires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys(expires_at);
@@ -0,0 +1,48 @@
+-- Teams for the Team plan. The owner's subscription pays for the team's seats;
+-- members and pending invitations each take one. Members share collision
+-- sessions with the team to make them part of its history.
+
+CREATE TABLE teams (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    owner_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    seats INTEGER NOT NULL DEFAULT 5 CHECK (seats > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE team_members (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX idx_team_members_user ON team_members(user_id);
+
+-- Invitations are mailed as a link with an opaque token; only its SHA-256 hash
+-- is stored. Accepted invitations are kept for reference.
+CREATE TABLE team_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_team_invitations_pending ON team_invitations(team_id, email) WHERE accepted_at IS NULL;
+
+CREATE TABLE team_sessions (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES collision_sessions(id) ON DELETE CASCADE,
+    shared_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (team_id, session_id)
+);
+
+CREATE INDEX idx_team_sessions_history ON team_sessions(team_id, shared_at DESC);
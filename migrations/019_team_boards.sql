@@ -0,0 +1,65 @@
+-- Team boards: members collect collisions on a board, then comment, react and
+-- vote on them. The activity feed outlives the boards and items it mentions,
+-- so it only references the team.
+
+CREATE TABLE boards (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_boards_team ON boards(team_id, created_at DESC);
+
+CREATE TABLE board_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    board_id UUID NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES collision_sessions(id) ON DELETE CASCADE,
+    added_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (board_id, session_id)
+);
+
+CREATE TABLE board_comments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    item_id UUID NOT NULL REFERENCES board_items(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_board_comments_item ON board_comments(item_id, created_at);
+
+CREATE TABLE board_reactions (
+    item_id UUID NOT NULL REFERENCES board_items(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reaction VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (item_id, user_id, reaction)
+);
+
+CREATE TABLE board_votes (
+    item_id UUID NOT NULL REFERENCES board_items(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (item_id, user_id)
+);
+
+CREATE TABLE board_activity (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    board_id UUID,
+    item_id UUID,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_board_activity_team ON board_activity(team_id, created_at DESC);
+CREATE INDEX idx_board_activity_board ON board_activity(board_id, created_at DESC) WHERE board_id IS NOT NULL;
@@ -0,0 +1,46 @@
+-- Group brainstorming sessions. Participants join with a code and submit their
+-- interests until the host generates collisions from the merged interests;
+-- everyone then votes on them until the session ends.
+
+CREATE TABLE group_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    host_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(100) NOT NULL,
+    current_project TEXT NOT NULL,
+    project_type VARCHAR(20) NOT NULL CHECK (project_type IN ('product', 'content', 'business', 'research')),
+    collision_intensity VARCHAR(20) NOT NULL CHECK (collision_intensity IN ('gentle', 'moderate', 'radical')),
+    join_code VARCHAR(8) NOT NULL UNIQUE,
+    merged_interests TEXT[],
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    generated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_group_sessions_host ON group_sessions(host_id, created_at DESC);
+
+CREATE TABLE group_participants (
+    session_id UUID NOT NULL REFERENCES group_sessions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    interests TEXT[] NOT NULL DEFAULT '{}',
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (session_id, user_id)
+);
+
+CREATE INDEX idx_group_participants_user ON group_participants(user_id);
+
+-- rank is the engine's order, best first; votes decide the final ranking
+CREATE TABLE group_collisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL REFERENCES group_sessions(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    collision_result JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (session_id, rank)
+);
+
+CREATE TABLE group_votes (
+    collision_id UUID NOT NULL REFERENCES group_collisions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (collision_id, user_id)
+);
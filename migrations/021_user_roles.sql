@@ -0,0 +1,13 @@
+-- Staff roles. Support staff can look up users and reset their usage; admins
+-- can also change tiers and roles and manage the domain catalog.
+
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'support', 'admin'));
+
+CREATE INDEX idx_users_staff ON users(role) WHERE role <> 'user';
+
+-- The staff member behind an administrative change; user_id is the account it
+-- concerns
+ALTER TABLE audit_log ADD COLUMN actor_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, created_at DESC) WHERE actor_id IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Store share link tokens as SHA-256 hashes, like refresh tokens, email
+-- changes and team invitations. Existing links keep working: the hash of the
+-- token in their URL matches. Owners can no longer list the URLs of existing
+-- links, only revoke them and create new ones.
+
+ALTER TABLE share_links ADD COLUMN token_hash VARCHAR(64);
+
+UPDATE share_links SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+ALTER TABLE share_links ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE share_links ADD CONSTRAINT share_links_token_hash_key UNIQUE (token_hash);
+ALTER TABLE share_links DROP COLUMN token;
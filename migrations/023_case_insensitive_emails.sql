@@ -0,0 +1,21 @@
+-- Email addresses are case-insensitive. Sign-up, login, password resets,
+-- email changes and SSO linking all lowercase the address before using it, so
+-- store existing addresses in lowercase and keep two accounts from differing
+-- only by case.
+--
+-- Accounts that already differ only by case have to be merged or renamed by
+-- hand first; the migration stops rather than pick one of them.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY LOWER(email) HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'some accounts differ only by email case; resolve them before migrating';
+    END IF;
+END;
+$$ language 'plpgsql';
+
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
+UPDATE email_changes SET new_email = LOWER(new_email) WHERE new_email <> LOWER(new_email);
+
+DROP INDEX idx_users_email;
+CREATE UNIQUE INDEX idx_users_email_lower ON users(LOWER(email));
@@ -0,0 +1,5 @@
+-- The seat quantity of a user's Team subscription. Stripe can report it
+-- before the user has created their team, so it's kept on the user and a team
+-- starts with it when created. NULL until a Team subscription has been seen.
+
+ALTER TABLE users ADD COLUMN team_seats INTEGER CHECK (team_seats > 0);
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -19,8 +20,44 @@ type Config struct {
 	CORSOrigins      []string
 	RateLimitRPS     int
 	CacheExpiration  int // seconds
+	PromptTemplatesDir string   // optional directory of <name>.<version>.tmpl prompt overrides
+	AdminEmails        []string // verified accounts made admins at startup
+	AIMonthlyBudgets   map[string]float64 // USD per user per month by tier, 0 = unlimited
+	AIMaxRetries       int                // retries for rate-limited or failed AI calls
+	AIBreakerThreshold int                // consecutive AI failures before the circuit breaker opens
+	AIBreakerCooldown  int                // seconds the breaker stays open before probing
+	AIBlocklist        []string           // terms that cause AI output to be rejected
+	AIBlocklistFile    string             // optional file with one blocked term per line
+	AIDomainSuggestions bool              // let the AI propose a new domain when no catalog domain matches
+	EmbeddingProvider      string         // none, openai or local
+	EmbeddingModel         string         // model name for the openai provider
+	EmbeddingMinSimilarity float64        // cosine similarity needed for an interest to match a domain
+	TrashRetentionDays     int            // days a deleted collision session can be restored before it's purged
+	PublicURL              string         // base URL of this server as seen by people opening share links
+	AppURL                 string         // base URL of the web app, used for links in emails
+	AccessTokenTTLMinutes  int            // minutes an access token is valid
+	RefreshTokenTTLDays    int            // days a refresh token is valid if it isn't used
+	MailDriver             string         // log, file or smtp
+	MailFrom               string         // sender of transactional email
+	MailDir                string         // directory the file mail driver writes to
+	SMTPHost               string
+	SMTPPort               int
+	SMTPUsername           string
+	SMTPPassword           string
+	OIDCProviderName       string         // name linked identities are stored under
+	OIDCDiscoveryURL       string         // issuer URL of an OpenID Connect provider; enables SSO sign-in
+	OIDCClientID           string
+	OIDCClientSecret       string
+	OIDCRedirectURL        string         // defaults to PUBLIC_URL/api/auth/oidc/callback
+	OIDCScopes             []string
+	JWTAlgorithm           string         // RS256 or EdDSA for rotating key pairs, or HS256 for the shared secret
+	JWTKeyRotationDays     int            // days a signing key signs tokens before its successor takes over
+	StripeWebhookSecret    string         // signs the subscription events Stripe sends to the webhook
 }
 
+// DefaultJWTSecret is the placeholder JWT secret, only accepted in development
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 func LoadConfig() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		fmt.Println("No .env file found, using environment variables")
@@ -28,18 +65,69 @@ func LoadConfig() (*Config, error) {
 
 	rateLimitRPS, _ := strconv.Atoi(getEnvWithDefault("RATE_LIMIT_RPS", "10"))
 	cacheExpiration, _ := strconv.Atoi(getEnvWithDefault("CACHE_EXPIRATION", "300"))
+	aiBudgetPro, _ := strconv.ParseFloat(getEnvWithDefault("AI_MONTHLY_BUDGET_PRO", "0"), 64)
+	aiBudgetTeam, _ := strconv.ParseFloat(getEnvWithDefault("AI_MONTHLY_BUDGET_TEAM", "0"), 64)
+	aiMaxRetries, _ := strconv.Atoi(getEnvWithDefault("AI_MAX_RETRIES", "2"))
+	aiBreakerThreshold, _ := strconv.Atoi(getEnvWithDefault("AI_BREAKER_THRESHOLD", "5"))
+	aiBreakerCooldown, _ := strconv.Atoi(getEnvWithDefault("AI_BREAKER_COOLDOWN", "30"))
+	aiDomainSuggestions, _ := strconv.ParseBool(getEnvWithDefault("AI_DOMAIN_SUGGESTIONS", "true"))
+	embeddingMinSimilarity, _ := strconv.ParseFloat(getEnvWithDefault("EMBEDDING_MIN_SIMILARITY", "0.3"), 64)
+	trashRetentionDays, _ := strconv.Atoi(getEnvWithDefault("TRASH_RETENTION_DAYS", "30"))
+	accessTokenTTLMinutes, _ := strconv.Atoi(getEnvWithDefault("ACCESS_TOKEN_TTL_MINUTES", "15"))
+	refreshTokenTTLDays, _ := strconv.Atoi(getEnvWithDefault("REFRESH_TOKEN_TTL_DAYS", "30"))
+	smtpPort, _ := strconv.Atoi(getEnvWithDefault("SMTP_PORT", "587"))
+	jwtKeyRotationDays, _ := strconv.Atoi(getEnvWithDefault("JWT_KEY_ROTATION_DAYS", "30"))
 
 	config := &Config{
 		Port:             getEnvWithDefault("PORT", "8080"),
 		DatabaseURL:      getEnvWithDefault("DATABASE_URL", ""),
 		RedisURL:         getEnvWithDefault("REDIS_URL", "redis://localhost:6379"),
-		JWTSecret:        getEnvWithDefault("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTSecret:        getEnvWithDefault("JWT_SECRET", DefaultJWTSecret),
 		OpenAIAPIKey:     getEnvWithDefault("OPENAI_API_KEY", ""),
 		StripeSecretKey:  getEnvWithDefault("STRIPE_SECRET_KEY", ""),
 		Environment:      getEnvWithDefault("ENVIRONMENT", "development"),
 		CORSOrigins:      []string{getEnvWithDefault("CORS_ORIGINS", "http://localhost:5173")},
 		RateLimitRPS:     rateLimitRPS,
 		CacheExpiration:  cacheExpiration,
+		PromptTemplatesDir: getEnvWithDefault("PROMPT_TEMPLATES_DIR", ""),
+		AdminEmails:        splitList(getEnvWithDefault("ADMIN_EMAILS", "")),
+		AIMonthlyBudgets: map[string]float64{
+			"pro":  aiBudgetPro,
+			"team": aiBudgetTeam,
+		},
+		AIMaxRetries:       aiMaxRetries,
+		AIBreakerThreshold: aiBreakerThreshold,
+		AIBreakerCooldown:  aiBreakerCooldown,
+		AIBlocklist:        splitList(getEnvWithDefault("AI_BLOCKLIST", "")),
+		AIBlocklistFile:    getEnvWithDefault("AI_BLOCKLIST_FILE", ""),
+		AIDomainSuggestions: aiDomainSuggestions,
+		EmbeddingProvider:      getEnvWithDefault("EMBEDDING_PROVIDER", "none"),
+		EmbeddingModel:         getEnvWithDefault("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingMinSimilarity: embeddingMinSimilarity,
+		TrashRetentionDays:     trashRetentionDays,
+		PublicURL:              strings.TrimRight(getEnvWithDefault("PUBLIC_URL", "http://localhost:8080"), "/"),
+		AppURL:                 strings.TrimRight(getEnvWithDefault("APP_URL", "http://localhost:5173"), "/"),
+		AccessTokenTTLMinutes:  accessTokenTTLMinutes,
+		RefreshTokenTTLDays:    refreshTokenTTLDays,
+		MailDriver:             getEnvWithDefault("MAIL_DRIVER", "log"),
+		MailFrom:               getEnvWithDefault("MAIL_FROM", "Idea Collision Engine <no-reply@localhost>"),
+		MailDir:                getEnvWithDefault("MAIL_DIR", "./tmp/mail"),
+		SMTPHost:               getEnvWithDefault("SMTP_HOST", ""),
+		SMTPPort:               smtpPort,
+		SMTPUsername:           getEnvWithDefault("SMTP_USERNAME", ""),
+		SMTPPassword:           getEnvWithDefault("SMTP_PASSWORD", ""),
+		OIDCProviderName:       getEnvWithDefault("OIDC_PROVIDER_NAME", "sso"),
+		OIDCDiscoveryURL:       getEnvWithDefault("OIDC_DISCOVERY_URL", ""),
+		OIDCClientID:           getEnvWithDefault("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:       getEnvWithDefault("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:        getEnvWithDefault("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:             strings.Fields(getEnvWithDefault("OIDC_SCOPES", "openid email profile")),
+		JWTAlgorithm:           getEnvWithDefault("JWT_ALGORITHM", "RS256"),
+		JWTKeyRotationDays:     jwtKeyRotationDays,
+		StripeWebhookSecret:    getEnvWithDefault("STRIPE_WEBHOOK_SECRET", ""),
+	}
+	if config.OIDCRedirectURL == "" {
+		config.OIDCRedirectURL = config.PublicURL + "/api/auth/oidc/callback"
 	}
 
 	if err := config.Validate(); err != nil {
@@ -56,9 +144,58 @@ func (c *Config) Validate() error {
 	if c.OpenAIAPIKey == "" {
 		return fmt.Errorf("OPENAI_API_KEY is required")
 	}
+	if c.JWTSecret == DefaultJWTSecret && c.Environment != "development" {
+		return fmt.Errorf("JWT_SECRET must be changed from its default outside development")
+	}
+	switch c.JWTAlgorithm {
+	case "RS256", "EdDSA", "HS256":
+	default:
+		return fmt.Errorf("JWT_ALGORITHM must be RS256, EdDSA or HS256")
+	}
+	if c.JWTKeyRotationDays < 1 {
+		return fmt.Errorf("JWT_KEY_ROTATION_DAYS must be at least 1")
+	}
+	if c.AIMaxRetries < 0 {
+		return fmt.Errorf("AI_MAX_RETRIES must not be negative")
+	}
+	if c.TrashRetentionDays < 1 {
+		return fmt.Errorf("TRASH_RETENTION_DAYS must be at least 1")
+	}
+	if c.AccessTokenTTLMinutes < 1 {
+		return fmt.Errorf("ACCESS_TOKEN_TTL_MINUTES must be at least 1")
+	}
+	if c.RefreshTokenTTLDays < 1 {
+		return fmt.Errorf("REFRESH_TOKEN_TTL_DAYS must be at least 1")
+	}
+	if !strings.HasPrefix(c.PublicURL, "http://") && !strings.HasPrefix(c.PublicURL, "https://") {
+		return fmt.Errorf("PUBLIC_URL must be an http or https URL")
+	}
+	if !strings.HasPrefix(c.AppURL, "http://") && !strings.HasPrefix(c.AppURL, "https://") {
+		return fmt.Errorf("APP_URL must be an http or https URL")
+	}
+	switch c.MailDriver {
+	case "log", "file":
+	case "smtp":
+		if c.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+	default:
+		return fmt.Errorf("MAIL_DRIVER must be log, file or smtp")
+	}
+	if c.OIDCDiscoveryURL != "" {
+		if c.OIDCClientID == "" {
+			return fmt.Errorf("OIDC_CLIENT_ID is required when OIDC_DISCOVERY_URL is set")
+		}
+		if !strings.HasPrefix(c.OIDCDiscoveryURL, "https://") && !strings.HasPrefix(c.OIDCDiscoveryURL, "http://") {
+			return fmt.Errorf("OIDC_DISCOVERY_URL must be an http or https URL")
+		}
+	}
 	if c.StripeSecretKey == "" && c.Environment == "production" {
 		return fmt.Errorf("STRIPE_SECRET_KEY is required in production")
 	}
+	if c.StripeWebhookSecret == "" && c.Environment == "production" {
+		return fmt.Errorf("STRIPE_WEBHOOK_SECRET is required in production")
+	}
 	return nil
 }
 
@@ -67,4 +204,15 @@ func getEnvWithDefault(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// splitList parses a comma-separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}